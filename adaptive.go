@@ -0,0 +1,64 @@
+package buckyclient
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// WithAdaptiveInterval lengthens the interval while flushes fail, so a
+// struggling server isn't sent a payload every interval. Every failed flush
+// in a row doubles the time until the next one, up to max, and the first
+// flush that succeeds goes back to the configured interval. Each window
+// from WithUnitInterval backs off on its own. Metrics keep being
+// aggregated in the meantime, so a longer interval means fewer, larger
+// payloads rather than lost data.
+func WithAdaptiveInterval(max time.Duration) Option {
+	return func(c *Client) error {
+		if max <= 0 {
+			return invalidOption("WithAdaptiveInterval", "max must be positive")
+		}
+
+		c.maxInterval = max
+		return nil
+	}
+}
+
+// nextInterval returns how long a window waits after a flush that
+// returned err
+func (c *Client) nextInterval(w *window, err error) time.Duration {
+	if c.maxInterval == 0 {
+		return w.interval
+	}
+
+	if !flushFailed(err) {
+		if w.failures > 0 {
+			c.logger.Printf("flushes to %s recovered, interval back to %s", c.hostURL, w.interval)
+		}
+
+		w.failures = 0
+		return w.interval
+	}
+
+	w.failures++
+
+	d := w.interval
+	for i := 0; i < w.failures && d < c.maxInterval; i++ {
+		d *= 2
+	}
+
+	if d > c.maxInterval {
+		d = c.maxInterval
+	}
+
+	c.logAt(severityWarning, 1, fmt.Sprintf("%d failed flushes in a row, next in %s", w.failures, d))
+
+	return d
+}
+
+// flushFailed reports whether a flush error means the server couldn't be
+// reached or didn't accept the payload, rather than there being nothing
+// to send
+func flushFailed(err error) bool {
+	return err != nil && !errors.Is(err, ErrNoMetrics) && !errors.Is(err, ErrBusy) && !errors.Is(err, ErrStopped)
+}
@@ -0,0 +1,43 @@
+package buckyclient
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdaptive_Client_nextInterval(t *testing.T) {
+	c := &Client{logger: log.New(ioutil.Discard, "", 0)}
+	assert.NoError(t, WithAdaptiveInterval(35*time.Second)(c))
+
+	w := &window{interval: 10 * time.Second}
+	failed := &StatusError{StatusCode: 503}
+
+	assert.Equal(t, 20*time.Second, c.nextInterval(w, failed))
+	assert.Equal(t, 35*time.Second, c.nextInterval(w, fmt.Errorf("flush: %w", failed)))
+	assert.Equal(t, 35*time.Second, c.nextInterval(w, errors.New("connection refused")))
+	assert.Equal(t, 3, w.failures)
+
+	// Nothing to send says nothing about the server
+	assert.Equal(t, 10*time.Second, c.nextInterval(w, ErrNoMetrics))
+	assert.Equal(t, 20*time.Second, c.nextInterval(w, failed))
+	assert.Equal(t, 10*time.Second, c.nextInterval(w, nil))
+	assert.Equal(t, 0, w.failures)
+}
+
+func TestAdaptive_Client_nextInterval_Disabled(t *testing.T) {
+	c := &Client{}
+	w := &window{interval: time.Second}
+
+	assert.Equal(t, time.Second, c.nextInterval(w, errors.New("connection refused")))
+	assert.Equal(t, 0, w.failures)
+}
+
+func TestAdaptive_WithAdaptiveInterval_Invalid(t *testing.T) {
+	assert.ErrorIs(t, WithAdaptiveInterval(0)(&Client{}), ErrInvalidOption)
+}
@@ -0,0 +1,79 @@
+package buckyclient
+
+import "strings"
+
+// aggregations maps metric names to the action their samples are
+// aggregated with, whatever the recording call asked for
+type aggregations struct {
+	exact    map[string]Action
+	prefixes []prefixAction
+}
+
+type prefixAction struct {
+	prefix string
+	action Action
+}
+
+// WithAggregation aggregates the named metrics with action instead of the
+// one their recording calls imply, e.g. WithAggregation(ActionHistogram,
+// "db.latency") to get percentiles for a metric recorded with Timer all
+// over a code base. Names match like WithPriority: a name ending in * is a
+// prefix, and an exact name wins over a prefix and a longer prefix over a
+// shorter one. Only ActionSum, ActionAvg, ActionLast, ActionHistogram and
+// ActionDigest can be set, and only samples recorded with one of them are
+// changed, as ratios and sets need more than a single value.
+func WithAggregation(action Action, names ...string) Option {
+	return func(c *Client) error {
+		switch action {
+		case ActionSum, ActionAvg, ActionLast, ActionHistogram, ActionDigest:
+		default:
+			return invalidOption("WithAggregation", "action must be sum, avg, last, histogram or digest")
+		}
+
+		if len(names) == 0 {
+			return invalidOption("WithAggregation", "no metric names given")
+		}
+
+		for _, name := range names {
+			if name == "" || name == "*" {
+				return invalidOption("WithAggregation", "name must not be empty")
+			}
+
+			if strings.HasSuffix(name, "*") {
+				c.aggregations.prefixes = append(c.aggregations.prefixes, prefixAction{strings.TrimSuffix(name, "*"), action})
+				continue
+			}
+
+			if c.aggregations.exact == nil {
+				c.aggregations.exact = make(map[string]Action)
+			}
+
+			c.aggregations.exact[name] = action
+		}
+
+		return nil
+	}
+}
+
+// aggregation returns the action a sample of name recorded with action
+// is aggregated with
+func (c *Client) aggregation(name string, action Action) Action {
+	switch action {
+	case ActionSum, ActionAvg, ActionLast, ActionHistogram, ActionDigest:
+	default:
+		return action
+	}
+
+	if a, ok := c.aggregations.exact[name]; ok {
+		return a
+	}
+
+	longest := -1
+	for _, pa := range c.aggregations.prefixes {
+		if len(pa.prefix) > longest && strings.HasPrefix(name, pa.prefix) {
+			action, longest = pa.action, len(pa.prefix)
+		}
+	}
+
+	return action
+}
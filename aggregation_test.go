@@ -0,0 +1,45 @@
+package buckyclient
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAggregation_Client_aggregation(t *testing.T) {
+	c := &Client{}
+	assert.NoError(t, WithAggregation(ActionHistogram, "db.*", "http.latency")(c))
+	assert.NoError(t, WithAggregation(ActionAvg, "db.pool.*")(c))
+
+	assert.Equal(t, ActionHistogram, c.aggregation("http.latency", ActionSum))
+	assert.Equal(t, ActionHistogram, c.aggregation("db.query", ActionSum))
+	assert.Equal(t, ActionAvg, c.aggregation("db.pool.wait", ActionSum), "the longer prefix wins")
+	assert.Equal(t, ActionSum, c.aggregation("http.requests", ActionSum))
+
+	// Ratios and sets keep their action
+	assert.Equal(t, ActionRatio, c.aggregation("db.hit_rate", ActionRatio))
+	assert.Equal(t, ActionUnique, c.aggregation("db.users", ActionUnique))
+}
+
+func TestAggregation_WithAggregation(t *testing.T) {
+	c := newBatchingClient(1)
+	assert.NoError(t, WithAggregation(ActionAvg, "latency")(c))
+
+	c.Timer("latency", 10)
+	c.Timer("latency", 20)
+
+	b := c.Batch()
+	b.Timer("latency", 30)
+	b.Submit()
+
+	buf := &bytes.Buffer{}
+	c.formatMetricsForFlush(buf)
+	assert.Equal(t, []string{"latency:20|ms"}, splitLines(buf.String()))
+}
+
+func TestAggregation_WithAggregation_Invalid(t *testing.T) {
+	assert.ErrorIs(t, WithAggregation(ActionRatio, "a")(&Client{}), ErrInvalidOption)
+	assert.ErrorIs(t, WithAggregation(ActionSum)(&Client{}), ErrInvalidOption)
+	assert.ErrorIs(t, WithAggregation(ActionSum, "*")(&Client{}), ErrInvalidOption)
+}
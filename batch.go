@@ -0,0 +1,180 @@
+package buckyclient
+
+import (
+	"math/rand/v2"
+	"runtime"
+	"sync"
+)
+
+// batcher spreads recorded samples over GOMAXPROCS shards, picked at
+// random for every sample, so that goroutines recording at the same time
+// rarely share a lock. A shard hands its batch to the aggregator once it
+// is full, taking the client lock once for many samples instead of
+// sending each one over the input channel. Samples in different shards
+// are aggregated in no particular order.
+//
+// Batch slices are recycled through a sync.Pool. The pool only ever holds
+// empty slices, since anything in it can be dropped by the garbage
+// collector.
+type batcher struct {
+	size   int
+	shards []batchShard
+	pool   sync.Pool
+}
+
+type batchShard struct {
+	m     sync.Mutex
+	batch []MetricWithAmount
+
+	// Keep shards on separate cache lines
+	_ [32]byte
+}
+
+func newBatcher(size int) *batcher {
+	b := &batcher{
+		size:   size,
+		shards: make([]batchShard, runtime.GOMAXPROCS(0)),
+	}
+
+	b.pool.New = func() interface{} {
+		batch := make([]MetricWithAmount, 0, size)
+		return &batch
+	}
+
+	for i := range b.shards {
+		b.shards[i].batch = b.get()
+	}
+
+	return b
+}
+
+// shardIndex picks a shard without any shared state to contend on
+func shardIndex(n int) int {
+	return rand.IntN(n)
+}
+
+func (b *batcher) get() []MetricWithAmount {
+	return (*b.pool.Get().(*[]MetricWithAmount))[:0]
+}
+
+func (b *batcher) put(batch []MetricWithAmount) {
+	batch = batch[:0]
+	b.pool.Put(&batch)
+}
+
+// add appends a sample to a shard, returning the shard's batch if that
+// filled it. The caller aggregates it and gives it back with put.
+func (b *batcher) add(metric MetricWithAmount) []MetricWithAmount {
+	s := &b.shards[shardIndex(len(b.shards))]
+
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.batch = append(s.batch, metric)
+	if len(s.batch) < b.size {
+		return nil
+	}
+
+	full := s.batch
+	s.batch = b.get()
+
+	return full
+}
+
+// drain takes every shard's batch, however full
+func (b *batcher) drain(fn func([]MetricWithAmount)) {
+	for i := range b.shards {
+		s := &b.shards[i]
+
+		s.m.Lock()
+		batch := s.batch
+		s.batch = b.get()
+		s.m.Unlock()
+
+		fn(batch)
+	}
+}
+
+// WithSampleBatching makes recorded samples collect in batches of the
+// given size, spread over GOMAXPROCS shards, which are aggregated together
+// when they fill up and before every flush. Under heavy load this replaces
+// a channel send for every sample with one lock for every batch. Gauges
+// depend on the order they are set and adjusted in, which the shards
+// would lose, so they are aggregated straight away instead.
+func WithSampleBatching(size int) Option {
+	return func(c *Client) error {
+		if size <= 0 {
+			return invalidOption("WithSampleBatching", "size must be positive")
+		}
+
+		c.batcher = newBatcher(size)
+		return nil
+	}
+}
+
+// recordBatched adds a sample to the batcher, aggregating the batch it
+// fills if any
+func (c *Client) recordBatched(metric MetricWithAmount) {
+	if metric.Action == ActionLast {
+		c.aggregateSamples([]MetricWithAmount{metric}, true)
+		return
+	}
+
+	if full := c.batcher.add(metric); full != nil {
+		c.aggregateBatch(full)
+	}
+}
+
+// drainBatches aggregates every sample still waiting in a batch
+func (c *Client) drainBatches() {
+	if c.batcher != nil {
+		c.batcher.drain(c.aggregateBatch)
+	}
+}
+
+// aggregateBatch folds a batch into the metrics map and recycles it
+func (c *Client) aggregateBatch(batch []MetricWithAmount) {
+	c.aggregateSamples(batch, true)
+	c.batcher.put(batch)
+}
+
+// aggregateSamples folds samples into the metrics map in order. Unless
+// wait is set it gives up, returning false, if the client lock is taken.
+func (c *Client) aggregateSamples(batch []MetricWithAmount, wait bool) bool {
+	var errs []error
+	var traced []TraceEvent
+
+	tracing := c.tracingEnabled()
+
+	if wait {
+		c.m.Lock()
+	} else if !c.m.TryLock() {
+		return false
+	}
+	for _, metric := range batch {
+		var err error
+		if c.admit(metric.Metric) {
+			err = c.aggregate(metric)
+		}
+
+		if err != nil {
+			errs = append(errs, err)
+		}
+
+		if tracing {
+			traced = append(traced, c.traceEvent(metric, err))
+		}
+	}
+	c.m.Unlock()
+
+	// Report outside the lock in case the handler records metrics itself
+	for _, err := range errs {
+		c.handleError(err)
+	}
+
+	if traced != nil {
+		c.emitTrace(traced)
+	}
+
+	return true
+}
@@ -0,0 +1,118 @@
+package buckyclient
+
+import (
+	"runtime"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newBatchingClient(size int) *Client {
+	c := &Client{
+		metrics:    make(map[Metric]Value),
+		bufferPool: newBufferPool(),
+	}
+
+	WithSampleBatching(size)(c)
+	return c
+}
+
+func TestBatch_Client_Count_FullBatch(t *testing.T) {
+	c := newBatchingClient(4)
+	c.batcher.shards = c.batcher.shards[:1]
+
+	for i := 0; i < 5; i++ {
+		c.Count("a", 1)
+	}
+
+	// the first four were aggregated when the batch filled
+	c.m.Lock()
+	assert.Equal(t, int64(4), c.metrics[Metric{name: "a", unit: UnitCount}].Sum.Value)
+	c.m.Unlock()
+
+	assert.Len(t, c.batcher.shards[0].batch, 1)
+}
+
+func TestBatch_Client_Count_Concurrent(t *testing.T) {
+	c := newBatchingClient(16)
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				c.Count("a", 1)
+				c.AverageTimer("b", 2)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// PendingLines drains the batches like a flush
+	assert.Equal(t, 2, c.PendingLines())
+	assert.Equal(t, int64(8000), c.metrics[Metric{name: "a", unit: UnitCount}].Sum.Value)
+	assert.Equal(t, int64(8000), c.metrics[Metric{name: "b", unit: UnitMillisecond}].Avg.Count)
+}
+
+func TestBatch_Client_Gauge_Shards(t *testing.T) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(8))
+
+	for run := 0; run < 20; run++ {
+		c := newBatchingClient(64)
+		assert.Len(t, c.batcher.shards, 8)
+
+		c.Gauge("g", 0)
+		for v := 1; v <= 10; v++ {
+			c.GaugeDelta("d", 1)
+			c.Gauge("g", v)
+		}
+		c.GaugeDelta("d", -3)
+		c.Gauge("d", 2)
+		c.GaugeDelta("d", 1)
+
+		c.drainBatches()
+
+		// The last value set is the one that is kept, whichever shards
+		// the samples would have gone to
+		assert.Equal(t, int64(10), c.metrics[Metric{name: "g", unit: UnitGauge}].Last.Value)
+		assert.Equal(t, int64(3), c.metrics[Metric{name: "d", unit: UnitGauge}].Last.Value)
+	}
+}
+
+func TestBatch_WithSampleBatching_Invalid(t *testing.T) {
+	assert.Error(t, WithSampleBatching(0)(&Client{}))
+}
+
+func BenchmarkRecord_Channel(b *testing.B) {
+	c := &Client{
+		metrics: make(map[Metric]Value),
+		input:   make(chan MetricWithAmount),
+	}
+
+	go c.inputProcessor()
+
+	benchmarkRecord(b, c)
+}
+
+func BenchmarkRecord_Batched(b *testing.B) {
+	benchmarkRecord(b, newBatchingClient(256))
+}
+
+func benchmarkRecord(b *testing.B, c *Client) {
+	names := make([]string, 16)
+	for i := range names {
+		names[i] = "test_" + strconv.Itoa(i)
+	}
+
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			c.recordAmount(names[i%len(names)], Amount{Value: 1}, UnitCount, ActionSum, nil)
+			i++
+		}
+	})
+}
@@ -0,0 +1,31 @@
+package buckyclient
+
+import (
+	"strings"
+	"time"
+)
+
+// LatencyBuckets records a latency as cumulative bucket counters. Every
+// bound that d fits under is incremented as name.le_<bound>, e.g.
+// name.le_100ms, and name.le_inf is always incremented so it counts every
+// observation. This gives Prometheus-style histogram buckets on a statsd
+// backend.
+func (c *Client) LatencyBuckets(name string, d time.Duration, bounds []time.Duration, tags ...Tag) {
+	tags = c.withTags(tags)
+	c, name = c.scoped(name)
+	c.logCaller(name)
+
+	for _, bound := range bounds {
+		if d <= bound {
+			c.record(bucketName(name, bound), 1, UnitCount, ActionSum, tags)
+		}
+	}
+
+	c.record(name+".le_inf", 1, UnitCount, ActionSum, tags)
+}
+
+// bucketName turns a bound into a metric name safe for graphite, which
+// treats dots as separators, so 1.5s becomes le_1_5s
+func bucketName(name string, bound time.Duration) string {
+	return name + ".le_" + strings.Replace(bound.String(), ".", "_", -1)
+}
@@ -0,0 +1,41 @@
+package buckyclient
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuckets_Client_LatencyBuckets(t *testing.T) {
+	cl := &Client{
+		metrics: make(map[Metric]Value),
+		input:   make(chan MetricWithAmount, 10),
+	}
+
+	bounds := []time.Duration{50 * time.Millisecond, 100 * time.Millisecond, 1500 * time.Millisecond}
+
+	cl.LatencyBuckets("myapp.latency", 80*time.Millisecond, bounds)
+	cl.LatencyBuckets("myapp.latency", 2*time.Second, bounds)
+
+	cl.flushInputChannel()
+
+	count := func(name string) int64 {
+		v, ok := cl.metrics[Metric{name: name, unit: UnitCount}]
+		if !ok {
+			return 0
+		}
+		return v.Sum.Value
+	}
+
+	assert.Equal(t, int64(0), count("myapp.latency.le_50ms"))
+	assert.Equal(t, int64(1), count("myapp.latency.le_100ms"))
+	assert.Equal(t, int64(1), count("myapp.latency.le_1_5s"))
+	assert.Equal(t, int64(2), count("myapp.latency.le_inf"))
+}
+
+func TestBuckets_bucketName(t *testing.T) {
+	assert.Equal(t, "a.le_250ms", bucketName("a", 250*time.Millisecond))
+	assert.Equal(t, "a.le_2s", bucketName("a", 2*time.Second))
+	assert.Equal(t, "a.le_1_5s", bucketName("a", 1500*time.Millisecond))
+}
@@ -0,0 +1,166 @@
+package buckytest
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrInjected is the error returned by an injected fault that doesn't set
+// its own error
+var ErrInjected = errors.New("buckytest: injected fault")
+
+// Fault is what a FaultTransport does to one request
+type Fault struct {
+	// Latency is waited before anything else happens
+	Latency time.Duration
+
+	// Err fails the request without sending it, unless AfterSend is set
+	Err error
+
+	// StatusCode answers the request without sending it, unless AfterSend
+	// is set
+	StatusCode int
+
+	// AfterSend sends the request and then reports Err or StatusCode
+	// anyway, the way a response lost on the way back looks to a client
+	AfterSend bool
+}
+
+// FaultRates are the chances of each fault used by RandomSchedule
+type FaultRates struct {
+	Error      float64
+	Status     float64
+	AfterSend  float64
+	MaxLatency time.Duration
+}
+
+// RandomSchedule returns n faults drawn from the rates. The same seed
+// always gives the same schedule, so a failing test can be replayed.
+// Faults with nothing set let the request through.
+func RandomSchedule(seed int64, n int, rates FaultRates) []Fault {
+	r := rand.New(rand.NewSource(seed))
+	schedule := make([]Fault, n)
+
+	for i := range schedule {
+		f := &schedule[i]
+
+		if rates.MaxLatency > 0 {
+			f.Latency = time.Duration(r.Int63n(int64(rates.MaxLatency)))
+		}
+
+		switch p := r.Float64(); {
+		case p < rates.Error:
+			f.Err = ErrInjected
+		case p < rates.Error+rates.Status:
+			f.StatusCode = http.StatusServiceUnavailable
+		}
+
+		if f.Err != nil || f.StatusCode != 0 {
+			f.AfterSend = r.Float64() < rates.AfterSend
+		}
+	}
+
+	return schedule
+}
+
+// FaultTransport is an http.RoundTripper that applies a schedule of faults
+// to the requests it is given, in order. Once the schedule runs out
+// requests go straight through. Give it to a client with
+// buckyclient.WithRoundTripper to test retries and the retry queue.
+type FaultTransport struct {
+	// Transport sends the requests, http.DefaultTransport if nil
+	Transport http.RoundTripper
+
+	m        sync.Mutex
+	schedule []Fault
+	requests int
+	sent     int
+}
+
+// NewFaultTransport returns a transport that applies the schedule
+func NewFaultTransport(next http.RoundTripper, schedule ...Fault) *FaultTransport {
+	return &FaultTransport{Transport: next, schedule: schedule}
+}
+
+// Requests returns how many requests the transport has been given
+func (t *FaultTransport) Requests() int {
+	t.m.Lock()
+	defer t.m.Unlock()
+
+	return t.requests
+}
+
+// Sent returns how many requests actually reached the next transport
+func (t *FaultTransport) Sent() int {
+	t.m.Lock()
+	defer t.m.Unlock()
+
+	return t.sent
+}
+
+// RoundTrip applies the next fault in the schedule to the request
+func (t *FaultTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.m.Lock()
+	var f Fault
+	if t.requests < len(t.schedule) {
+		f = t.schedule[t.requests]
+	}
+	t.requests++
+	t.m.Unlock()
+
+	if f.Latency > 0 {
+		timer := time.NewTimer(f.Latency)
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		}
+	}
+
+	failed := f.Err != nil || f.StatusCode != 0
+
+	if !failed || f.AfterSend {
+		resp, err := t.next().RoundTrip(req)
+
+		t.m.Lock()
+		t.sent++
+		t.m.Unlock()
+
+		if !failed || err != nil {
+			return resp, err
+		}
+
+		resp.Body.Close()
+	} else if req.Body != nil {
+		req.Body.Close()
+	}
+
+	if f.Err != nil {
+		return nil, f.Err
+	}
+
+	return &http.Response{
+		Status:     http.StatusText(f.StatusCode),
+		StatusCode: f.StatusCode,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{},
+		Body:       ioutil.NopCloser(&bytes.Buffer{}),
+		Request:    req,
+	}, nil
+}
+
+func (t *FaultTransport) next() http.RoundTripper {
+	if t.Transport != nil {
+		return t.Transport
+	}
+
+	return http.DefaultTransport
+}
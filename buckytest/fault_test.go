@@ -0,0 +1,126 @@
+package buckytest
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matzhouse/go-bucky-client"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFault_FaultTransport_RoundTrip(t *testing.T) {
+	received := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received++
+	}))
+	defer srv.Close()
+
+	ft := NewFaultTransport(nil,
+		Fault{Err: ErrInjected},
+		Fault{StatusCode: http.StatusBadGateway},
+		Fault{StatusCode: http.StatusServiceUnavailable, AfterSend: true},
+		Fault{Latency: 10 * time.Millisecond},
+	)
+	client := &http.Client{Transport: ft}
+
+	_, err := client.Post(srv.URL, "text/plain", strings.NewReader("a:1|c\n"))
+	assert.ErrorIs(t, err, ErrInjected)
+
+	resp, err := client.Post(srv.URL, "text/plain", strings.NewReader("a:1|c\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadGateway, resp.StatusCode)
+	assert.Equal(t, 0, received)
+
+	resp, err = client.Post(srv.URL, "text/plain", strings.NewReader("a:1|c\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, 1, received)
+
+	start := time.Now()
+	resp, err = client.Post(srv.URL, "text/plain", strings.NewReader("a:1|c\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.True(t, time.Since(start) >= 10*time.Millisecond)
+
+	assert.Equal(t, 4, ft.Requests())
+	assert.Equal(t, 2, ft.Sent())
+}
+
+func TestFault_FaultTransport_RoundTrip_Cancelled(t *testing.T) {
+	ft := NewFaultTransport(nil, Fault{Latency: time.Hour})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	req, _ := http.NewRequestWithContext(ctx, "POST", "http://127.0.0.1:1", nil)
+	_, err := ft.RoundTrip(req)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestFault_RandomSchedule(t *testing.T) {
+	rates := FaultRates{Error: 0.2, Status: 0.2, AfterSend: 0.5, MaxLatency: time.Second}
+
+	a := RandomSchedule(42, 100, rates)
+	assert.Equal(t, a, RandomSchedule(42, 100, rates))
+	assert.NotEqual(t, a, RandomSchedule(43, 100, rates))
+
+	failures := 0
+	for _, f := range a {
+		assert.True(t, f.Latency < time.Second)
+		if f.Err != nil || f.StatusCode != 0 {
+			failures++
+		} else {
+			assert.False(t, f.AfterSend)
+		}
+	}
+
+	assert.InDelta(t, 40, failures, 20)
+}
+
+func TestFault_RetryQueue(t *testing.T) {
+	bodies := make(chan string, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		bodies <- string(b)
+	}))
+	defer srv.Close()
+
+	// The first two flushes fail, so their payloads are retried on the third
+	ft := NewFaultTransport(nil, Fault{Err: ErrInjected}, Fault{StatusCode: http.StatusServiceUnavailable})
+
+	cl, err := buckyclient.NewClient(srv.URL, 60,
+		buckyclient.WithRoundTripper(ft),
+		buckyclient.WithRetryQueue(time.Minute, 1<<20),
+		buckyclient.WithUnitInterval(buckyclient.UnitCount, 20*time.Millisecond),
+	)
+	assert.NoError(t, err)
+	cl.SetLogger(log.New(ioutil.Discard, "", 0))
+	defer cl.Stop()
+
+	for i := 0; i < 3; i++ {
+		cl.Count("a", 1)
+		time.Sleep(30 * time.Millisecond)
+	}
+
+	// Nothing is lost, however the counts were split between flushes
+	total := 0.0
+	for total < 3 {
+		select {
+		case body := <-bodies:
+			for _, l := range MustParsePayload(body) {
+				total += l.Value
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("only received %v counts", total)
+		}
+	}
+
+	assert.Equal(t, 3.0, total)
+	assert.True(t, ft.Requests() >= 3)
+}
@@ -0,0 +1,150 @@
+// Package buckytest has helpers for tests that check bucky payloads, and
+// a Recorder for tests of code that records on a buckyclient.Statter.
+package buckytest
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/matzhouse/go-bucky-client"
+)
+
+// Line is a single parsed metric. Integer and fractional values are both
+// held as a float64, and DogStatsD tags are kept sorted as k:v,k2:v2 so
+// lines can still be compared.
+type Line struct {
+	Name  string
+	Value float64
+	Unit  buckyclient.Unit
+	Tags  string
+}
+
+func (l Line) String() string {
+	if l.Tags == "" {
+		return buckyclient.FormatLineFloat(l.Name, l.Value, l.Unit)
+	}
+
+	return buckyclient.FormatLineFloat(l.Name, l.Value, l.Unit) + "|#" + l.Tags
+}
+
+// joinTags returns tags sorted and joined as k:v,k2:v2
+func joinTags(tags []buckyclient.Tag) string {
+	parts := make([]string, len(tags))
+	for i, t := range tags {
+		parts[i] = t.Key + ":" + t.Value
+	}
+
+	sort.Strings(parts)
+
+	return strings.Join(parts, ",")
+}
+
+// Payload is a flush payload in a canonical order, so two payloads with
+// the same lines compare equal however they were ordered on the wire
+type Payload []Line
+
+// ParsePayload reads a flush payload. Blank lines are ignored.
+func ParsePayload(b []byte) (Payload, error) {
+	var p Payload
+
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for n := 1; scanner.Scan(); n++ {
+		text := scanner.Text()
+		if text == "" {
+			continue
+		}
+
+		name, value, unit, tags, err := buckyclient.ParseTaggedLine(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d %q: %w", n, text, err)
+		}
+
+		p = append(p, Line{Name: name, Value: value, Unit: unit, Tags: joinTags(tags)})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(p, func(i, j int) bool {
+		return p[i].String() < p[j].String()
+	})
+
+	return p, nil
+}
+
+// MustParsePayload is like ParsePayload but panics on an invalid payload
+func MustParsePayload(s string) Payload {
+	p, err := ParsePayload([]byte(s))
+	if err != nil {
+		panic(err)
+	}
+
+	return p
+}
+
+func (p Payload) String() string {
+	lines := make([]string, len(p))
+	for i, l := range p {
+		lines[i] = l.String()
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// Diff returns the lines missing from got with a "-" prefix and the
+// unexpected lines with a "+" prefix, or an empty string if they match
+func Diff(want, got Payload) string {
+	counts := make(map[Line]int)
+	for _, l := range want {
+		counts[l]++
+	}
+	for _, l := range got {
+		counts[l]--
+	}
+
+	var out []string
+	for _, l := range want {
+		if counts[l] > 0 {
+			out = append(out, "- "+l.String())
+			counts[l]--
+		}
+	}
+	for _, l := range got {
+		if counts[l] < 0 {
+			out = append(out, "+ "+l.String())
+			counts[l]++
+		}
+	}
+
+	return strings.Join(out, "\n")
+}
+
+// AssertPayload fails the test with a readable diff unless the raw payload
+// got has the same lines as want
+func AssertPayload(t testing.TB, want string, got []byte) bool {
+	t.Helper()
+
+	wantPayload, err := ParsePayload([]byte(want))
+	if err != nil {
+		t.Errorf("invalid expected payload: %v", err)
+		return false
+	}
+
+	gotPayload, err := ParsePayload(got)
+	if err != nil {
+		t.Errorf("invalid payload: %v", err)
+		return false
+	}
+
+	if diff := Diff(wantPayload, gotPayload); diff != "" {
+		t.Errorf("payloads differ (-want +got):\n%s", diff)
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,73 @@
+package buckytest
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/matzhouse/go-bucky-client"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPayload_ParsePayload(t *testing.T) {
+	p, err := ParsePayload([]byte("b.metric:2|ms\n\na.metric:1|c\n"))
+
+	assert.NoError(t, err)
+	assert.Equal(t, Payload{
+		{Name: "a.metric", Value: 1, Unit: buckyclient.UnitCount},
+		{Name: "b.metric", Value: 2, Unit: buckyclient.UnitMillisecond},
+	}, p)
+}
+
+func TestPayload_ParsePayload_Tags(t *testing.T) {
+	p, err := ParsePayload([]byte("a:1|c|#region:eu,env:prod\na:2|c\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, Payload{
+		{Name: "a", Value: 1, Unit: buckyclient.UnitCount, Tags: "env:prod,region:eu"},
+		{Name: "a", Value: 2, Unit: buckyclient.UnitCount},
+	}, p)
+
+	assert.Empty(t, Diff(MustParsePayload("a:1|c|#env:prod,region:eu"), MustParsePayload("a:1|c|#region:eu,env:prod")))
+	assert.NotEmpty(t, Diff(MustParsePayload("a:1|c|#env:prod"), MustParsePayload("a:1|c")))
+}
+
+func TestPayload_ParsePayload_Invalid(t *testing.T) {
+	_, err := ParsePayload([]byte("a.metric:1|c\nnonsense\n"))
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "line 2")
+}
+
+func TestPayload_Diff(t *testing.T) {
+	want := MustParsePayload("a:1|c\nb:2|c\nc:3|ms\n")
+	got := MustParsePayload("c:3|ms\na:1|c\nb:5|c\n")
+
+	assert.Equal(t, "- b:2|c\n+ b:5|c", Diff(want, got))
+}
+
+func TestPayload_Diff_Equal(t *testing.T) {
+	assert.Equal(t, "", Diff(MustParsePayload("a:1|c\nb:2|c"), MustParsePayload("b:2|c\na:1|c\n")))
+}
+
+func TestPayload_Diff_Duplicates(t *testing.T) {
+	assert.Equal(t, "- a:1|c", Diff(MustParsePayload("a:1|c\na:1|c"), MustParsePayload("a:1|c")))
+}
+
+func TestPayload_AssertPayload(t *testing.T) {
+	assert.True(t, AssertPayload(t, "a:1|c\nb:2|c\n", []byte("b:2|c\na:1|c\n")))
+
+	fake := &fakeTB{TB: t}
+	assert.False(t, AssertPayload(fake, "a:1|c\n", []byte("a:2|c\n")))
+	assert.Contains(t, fake.msg, "- a:1|c\n+ a:2|c")
+}
+
+// fakeTB captures failures instead of failing the real test
+type fakeTB struct {
+	testing.TB
+	msg string
+}
+
+func (f *fakeTB) Helper() {}
+
+func (f *fakeTB) Errorf(format string, args ...interface{}) {
+	f.msg = fmt.Sprintf(format, args...)
+}
@@ -0,0 +1,254 @@
+package buckytest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/matzhouse/go-bucky-client"
+)
+
+// Sample is one call recorded by a Recorder
+type Sample struct {
+	Name   string
+	Unit   buckyclient.Unit
+	Action buckyclient.Action
+	Value  float64
+	Tags   []buckyclient.Tag
+
+	// Denominator is the denominator of a Ratio
+	Denominator float64
+
+	// Member is the value given to Unique
+	Member string
+}
+
+// hasTags reports whether the sample was recorded with every one of tags
+func (s Sample) hasTags(tags []buckyclient.Tag) bool {
+	for _, want := range tags {
+		found := false
+
+		for _, tag := range s.Tags {
+			if tag == want {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Recorder is a buckyclient.Statter that keeps every sample it is given,
+// so a test can check what the code it gives the recorder records without
+// a server or waiting for a flush. Nothing is aggregated: each call is a
+// Sample, in the order the calls were made. It is safe for concurrent use
+// and its zero value is ready to use.
+type Recorder struct {
+	m       sync.Mutex
+	samples []Sample
+	flushes int
+	stopped bool
+}
+
+func (r *Recorder) add(s Sample) {
+	r.m.Lock()
+	r.samples = append(r.samples, s)
+	r.m.Unlock()
+}
+
+func (r *Recorder) record(name string, value float64, unit buckyclient.Unit, action buckyclient.Action, tags []buckyclient.Tag) {
+	r.add(Sample{Name: name, Unit: unit, Action: action, Value: value, Tags: tags})
+}
+
+// Count records a counter sample
+func (r *Recorder) Count(name string, value int, tags ...buckyclient.Tag) {
+	r.record(name, float64(value), buckyclient.UnitCount, buckyclient.ActionSum, tags)
+}
+
+// Incr records a counter sample of 1
+func (r *Recorder) Incr(name string, tags ...buckyclient.Tag) {
+	r.Count(name, 1, tags...)
+}
+
+// Decr records a counter sample of -1
+func (r *Recorder) Decr(name string, tags ...buckyclient.Tag) {
+	r.Count(name, -1, tags...)
+}
+
+// Timer records a timer sample
+func (r *Recorder) Timer(name string, value int, tags ...buckyclient.Tag) {
+	r.record(name, float64(value), buckyclient.UnitMillisecond, buckyclient.ActionSum, tags)
+}
+
+// Gauge records a gauge sample
+func (r *Recorder) Gauge(name string, value int, tags ...buckyclient.Tag) {
+	r.record(name, float64(value), buckyclient.UnitGauge, buckyclient.ActionLast, tags)
+}
+
+// Ratio records a ratio sample
+func (r *Recorder) Ratio(name string, numerator, denominator int, tags ...buckyclient.Tag) {
+	r.add(Sample{
+		Name:        name,
+		Unit:        buckyclient.UnitGauge,
+		Action:      buckyclient.ActionRatio,
+		Value:       float64(numerator),
+		Denominator: float64(denominator),
+		Tags:        tags,
+	})
+}
+
+// AverageTimer records an averaged timer sample
+func (r *Recorder) AverageTimer(name string, value int, tags ...buckyclient.Tag) {
+	r.record(name, float64(value), buckyclient.UnitMillisecond, buckyclient.ActionAvg, tags)
+}
+
+// CountF records a fractional counter sample
+func (r *Recorder) CountF(name string, value float64, tags ...buckyclient.Tag) {
+	r.record(name, value, buckyclient.UnitCount, buckyclient.ActionSum, tags)
+}
+
+// TimerF records a fractional timer sample
+func (r *Recorder) TimerF(name string, value float64, tags ...buckyclient.Tag) {
+	r.record(name, value, buckyclient.UnitMillisecond, buckyclient.ActionSum, tags)
+}
+
+// AverageTimerF records a fractional averaged timer sample
+func (r *Recorder) AverageTimerF(name string, value float64, tags ...buckyclient.Tag) {
+	r.record(name, value, buckyclient.UnitMillisecond, buckyclient.ActionAvg, tags)
+}
+
+// GaugeF records a fractional gauge sample
+func (r *Recorder) GaugeF(name string, value float64, tags ...buckyclient.Tag) {
+	r.record(name, value, buckyclient.UnitGauge, buckyclient.ActionLast, tags)
+}
+
+// TimerDuration records a timer sample in fractional milliseconds
+func (r *Recorder) TimerDuration(name string, d time.Duration, tags ...buckyclient.Tag) {
+	r.record(name, float64(d)/float64(time.Millisecond), buckyclient.UnitMillisecond, buckyclient.ActionSum, tags)
+}
+
+// Histogram records a histogram sample
+func (r *Recorder) Histogram(name string, value int, tags ...buckyclient.Tag) {
+	r.record(name, float64(value), buckyclient.UnitMillisecond, buckyclient.ActionHistogram, tags)
+}
+
+// Digest records a digest sample
+func (r *Recorder) Digest(name string, value int, tags ...buckyclient.Tag) {
+	r.record(name, float64(value), buckyclient.UnitMillisecond, buckyclient.ActionDigest, tags)
+}
+
+// Unique records a member of a set
+func (r *Recorder) Unique(name string, value string, tags ...buckyclient.Tag) {
+	r.add(Sample{Name: name, Unit: buckyclient.UnitSet, Action: buckyclient.ActionUnique, Member: value, Tags: tags})
+}
+
+// Flush counts the flush and never fails
+func (r *Recorder) Flush() error {
+	r.m.Lock()
+	r.flushes++
+	r.m.Unlock()
+
+	return nil
+}
+
+// Stop marks the recorder stopped. Samples are still recorded after it.
+func (r *Recorder) Stop() {
+	r.m.Lock()
+	r.stopped = true
+	r.m.Unlock()
+}
+
+// Samples returns a copy of every sample recorded so far
+func (r *Recorder) Samples() []Sample {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	return append([]Sample(nil), r.samples...)
+}
+
+// SamplesFor returns the samples recorded for name with every one of tags,
+// and any others
+func (r *Recorder) SamplesFor(name string, tags ...buckyclient.Tag) []Sample {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	var out []Sample
+	for _, s := range r.samples {
+		if s.Name == name && s.hasTags(tags) {
+			out = append(out, s)
+		}
+	}
+
+	return out
+}
+
+// CountOf returns the total of the counter samples for name with every one
+// of tags, so CountOf("requests") adds up every status while
+// CountOf("requests", status500) only counts the failures
+func (r *Recorder) CountOf(name string, tags ...buckyclient.Tag) float64 {
+	total := 0.0
+
+	for _, s := range r.SamplesFor(name, tags...) {
+		if s.Unit == buckyclient.UnitCount {
+			total += s.Value
+		}
+	}
+
+	return total
+}
+
+// TimersFor returns the values of the timer samples for name with every
+// one of tags, in the order they were recorded, whichever timer method
+// recorded them
+func (r *Recorder) TimersFor(name string, tags ...buckyclient.Tag) []float64 {
+	var out []float64
+
+	for _, s := range r.SamplesFor(name, tags...) {
+		if s.Unit == buckyclient.UnitMillisecond {
+			out = append(out, s.Value)
+		}
+	}
+
+	return out
+}
+
+// GaugeOf returns the last gauge value set for name with every one of
+// tags, and whether there was one
+func (r *Recorder) GaugeOf(name string, tags ...buckyclient.Tag) (float64, bool) {
+	value, ok := 0.0, false
+
+	for _, s := range r.SamplesFor(name, tags...) {
+		if s.Action == buckyclient.ActionLast {
+			value, ok = s.Value, true
+		}
+	}
+
+	return value, ok
+}
+
+// Flushes returns how often Flush was called
+func (r *Recorder) Flushes() int {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	return r.flushes
+}
+
+// Stopped reports whether Stop was called
+func (r *Recorder) Stopped() bool {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	return r.stopped
+}
+
+// Reset forgets every sample, flush and stop
+func (r *Recorder) Reset() {
+	r.m.Lock()
+	r.samples, r.flushes, r.stopped = nil, 0, false
+	r.m.Unlock()
+}
@@ -0,0 +1,79 @@
+package buckytest
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/matzhouse/go-bucky-client"
+	"github.com/stretchr/testify/assert"
+)
+
+var _ buckyclient.Statter = (*Recorder)(nil)
+
+func TestRecorder_Recorder(t *testing.T) {
+	failed := buckyclient.Tag{Key: "status", Value: "500"}
+
+	var s buckyclient.Statter = &Recorder{}
+	s.Count("requests", 2, buckyclient.Tag{Key: "status", Value: "200"})
+	s.Count("requests", 1, failed)
+	s.CountF("requests", 0.5)
+	s.Incr("in_flight")
+	s.Incr("in_flight")
+	s.Decr("in_flight")
+	s.Timer("latency", 10)
+	s.TimerDuration("latency", 1500*time.Microsecond)
+	s.AverageTimerF("latency", 2.5, failed)
+	s.Gauge("temp", 20)
+	s.GaugeF("temp", 21.5)
+	s.Ratio("hit_rate", 3, 4)
+	s.Unique("users", "a")
+	assert.NoError(t, s.Flush())
+	s.Stop()
+
+	r := s.(*Recorder)
+
+	assert.Equal(t, 3.5, r.CountOf("requests"))
+	assert.Equal(t, 1.0, r.CountOf("requests", failed))
+	assert.Zero(t, r.CountOf("missing"))
+	assert.Equal(t, 1.0, r.CountOf("in_flight"))
+
+	assert.Equal(t, []float64{10, 1.5, 2.5}, r.TimersFor("latency"))
+	assert.Equal(t, []float64{2.5}, r.TimersFor("latency", failed))
+
+	temp, ok := r.GaugeOf("temp")
+	assert.True(t, ok)
+	assert.Equal(t, 21.5, temp)
+
+	_, ok = r.GaugeOf("missing")
+	assert.False(t, ok)
+
+	ratio := r.SamplesFor("hit_rate")
+	assert.Equal(t, []Sample{{Name: "hit_rate", Unit: buckyclient.UnitGauge, Action: buckyclient.ActionRatio, Value: 3, Denominator: 4}}, ratio)
+	assert.Equal(t, "a", r.SamplesFor("users")[0].Member)
+
+	assert.Len(t, r.Samples(), 13)
+	assert.Equal(t, 1, r.Flushes())
+	assert.True(t, r.Stopped())
+
+	r.Reset()
+	assert.Empty(t, r.Samples())
+	assert.Zero(t, r.Flushes())
+	assert.False(t, r.Stopped())
+}
+
+func TestRecorder_Recorder_Concurrent(t *testing.T) {
+	r := &Recorder{}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.Count("hits", 1)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 10.0, r.CountOf("hits"))
+}
@@ -0,0 +1,67 @@
+package buckytest
+
+import (
+	"context"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/matzhouse/go-bucky-client"
+)
+
+// Transport is a buckyclient.Transport that keeps every payload it is
+// sent instead of sending it anywhere. It is safe for concurrent use.
+type Transport struct {
+	m        sync.Mutex
+	payloads []string
+}
+
+// Send keeps the payload
+func (t *Transport) Send(ctx context.Context, payload []byte) error {
+	t.m.Lock()
+	t.payloads = append(t.payloads, string(payload))
+	t.m.Unlock()
+
+	return nil
+}
+
+// Payloads returns every payload sent so far, in order
+func (t *Transport) Payloads() []string {
+	t.m.Lock()
+	defer t.m.Unlock()
+
+	return append([]string(nil), t.payloads...)
+}
+
+// Lines returns every line sent so far, in order
+func (t *Transport) Lines() []string {
+	t.m.Lock()
+	defer t.m.Unlock()
+
+	joined := strings.TrimSuffix(strings.Join(t.payloads, ""), "\n")
+	if joined == "" {
+		return nil
+	}
+
+	return strings.Split(joined, "\n")
+}
+
+// NewClient returns a client that sends every flush to a new Transport,
+// with its logging discarded. The client is closed when the test ends.
+func NewClient(t testing.TB, opts ...buckyclient.Option) (*buckyclient.Client, *Transport) {
+	t.Helper()
+
+	tr := &Transport{}
+
+	c, err := buckyclient.NewClient("http://localhost:8005/bucky/v1/send", 60, append(opts[:len(opts):len(opts)], buckyclient.WithTransport(tr))...)
+	if err != nil {
+		t.Fatalf("buckyclient.NewClient: %v", err)
+	}
+
+	c.SetLogger(log.New(io.Discard, "", 0))
+	t.Cleanup(func() { c.Close() })
+
+	return c, tr
+}
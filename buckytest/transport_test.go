@@ -0,0 +1,20 @@
+package buckytest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransport_NewClient(t *testing.T) {
+	c, tr := NewClient(t)
+
+	assert.Nil(t, tr.Lines())
+
+	c.Count("a.metric", 1)
+	c.Timer("b.metric", 2)
+	assert.NoError(t, c.Flush())
+
+	assert.Len(t, tr.Payloads(), 1)
+	assert.ElementsMatch(t, []string{"a.metric:1|c", "b.metric:2|ms"}, tr.Lines())
+}
@@ -0,0 +1,130 @@
+package buckyclient
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// BudgetDroppedMetric counts samples dropped by WithRecordBudget
+const BudgetDroppedMetric = "buckyclient.budget_dropped"
+
+// WithRecordBudget bounds how long a recording call may wait to hand its
+// sample over, e.g. 50µs. A sample that can't be handed over in time,
+// because the client is busy aggregating or flushing, is dropped and
+// counted in BudgetDroppedMetric instead, so instrumentation never adds
+// noticeable latency to the caller.
+//
+// Without a budget a recording call waits for room in the input buffer
+// when it is full; see WithInputBuffer.
+func WithRecordBudget(d time.Duration) Option {
+	return func(c *Client) error {
+		if d <= 0 {
+			return invalidOption("WithRecordBudget", "budget must be positive")
+		}
+
+		c.budget = d
+		return nil
+	}
+}
+
+// recordWithBudget hands a sample over within the budget or drops it.
+// High priority samples wait as long as it takes, and low priority ones
+// aren't given any time at all.
+func (c *Client) recordWithBudget(metric MetricWithAmount) {
+	priority := c.priority(metric.name)
+
+	if priority == PriorityHigh {
+		if c.batcher != nil {
+			c.recordBatched(metric)
+		} else {
+			c.send(metric)
+		}
+
+		return
+	}
+
+	if c.batcher != nil {
+		// Gauges skip the shards as they do in recordBatched
+		if metric.Action == ActionLast {
+			if !c.aggregateSamples([]MetricWithAmount{metric}, false) {
+				c.dropOverBudget()
+			}
+
+			return
+		}
+
+		if !c.batcher.tryAdd(c, metric) {
+			c.dropOverBudget()
+		}
+
+		return
+	}
+
+	select {
+	case c.input <- metric:
+		atomic.AddUint64(&c.inputSent, 1)
+		return
+	default:
+	}
+
+	if priority == PriorityLow {
+		c.dropOverBudget()
+		return
+	}
+
+	timer := time.NewTimer(c.budget)
+	defer timer.Stop()
+
+	select {
+	case c.input <- metric:
+		atomic.AddUint64(&c.inputSent, 1)
+	case <-timer.C:
+		c.dropOverBudget()
+	}
+}
+
+func (c *Client) dropOverBudget() {
+	atomic.AddUint64(&c.budgetDropped, 1)
+}
+
+// tryAdd adds a sample to the first shard it can lock without waiting.
+// A batch it fills is aggregated in the background so the caller doesn't
+// wait for the client lock either.
+func (b *batcher) tryAdd(c *Client, metric MetricWithAmount) bool {
+	start := shardIndex(len(b.shards))
+
+	for i := range b.shards {
+		s := &b.shards[(start+i)%len(b.shards)]
+		if !s.m.TryLock() {
+			continue
+		}
+
+		s.batch = append(s.batch, metric)
+
+		var full []MetricWithAmount
+		if len(s.batch) >= b.size {
+			full = s.batch
+			s.batch = b.get()
+		}
+
+		s.m.Unlock()
+
+		if full != nil {
+			c.goroutines.spawn("aggregateBatch", func() { c.aggregateBatch(full) })
+		}
+
+		return true
+	}
+
+	return false
+}
+
+// addBudgetMetrics adds the count of dropped samples - c.m must be held
+func (c *Client) addBudgetMetrics() {
+	dropped := atomic.SwapUint64(&c.budgetDropped, 0)
+	if dropped == 0 {
+		return
+	}
+
+	c.aggregate(MetricWithAmount{Metric{name: BudgetDroppedMetric, unit: UnitCount}, Amount{Value: int(dropped)}, ActionSum})
+}
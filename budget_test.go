@@ -0,0 +1,73 @@
+package buckyclient
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBudget_Client_Count_Dropped(t *testing.T) {
+	// Nothing reads the input channel, so every sample waits out the budget
+	c := &Client{
+		metrics: make(map[Metric]Value),
+		input:   make(chan MetricWithAmount),
+	}
+
+	assert.NoError(t, WithRecordBudget(time.Millisecond)(c))
+
+	start := time.Now()
+	c.Count("a", 1)
+	c.Count("a", 1)
+
+	assert.True(t, time.Since(start) < time.Second)
+
+	c.m.Lock()
+	c.addBudgetMetrics()
+	assert.Equal(t, int64(2), c.metrics[Metric{name: BudgetDroppedMetric, unit: UnitCount}].Sum.Value)
+
+	// the count starts again after each flush
+	delete(c.metrics, Metric{name: BudgetDroppedMetric, unit: UnitCount})
+	c.addBudgetMetrics()
+	assert.Empty(t, c.metrics)
+	c.m.Unlock()
+}
+
+func TestBudget_Client_Count_Delivered(t *testing.T) {
+	c := &Client{
+		metrics: make(map[Metric]Value),
+		input:   make(chan MetricWithAmount),
+	}
+
+	assert.NoError(t, WithRecordBudget(time.Second)(c))
+
+	go c.inputProcessor()
+	defer close(c.input)
+
+	c.Count("a", 1)
+	c.Count("a", 1)
+
+	assert.Equal(t, uint64(0), c.budgetDropped)
+}
+
+func TestBudget_Client_Count_Batched(t *testing.T) {
+	c := newBatchingClient(2)
+	c.batcher.shards = c.batcher.shards[:1]
+
+	assert.NoError(t, WithRecordBudget(time.Millisecond)(c))
+
+	c.Count("a", 1)
+
+	// a held shard can't be waited for
+	c.batcher.shards[0].m.Lock()
+	c.Count("a", 1)
+	c.batcher.shards[0].m.Unlock()
+
+	assert.Equal(t, uint64(1), c.budgetDropped)
+	assert.Equal(t, 1, c.PendingLines())
+	assert.Equal(t, int64(1), c.metrics[Metric{name: "a", unit: UnitCount}].Sum.Value)
+}
+
+func TestBudget_WithRecordBudget_Invalid(t *testing.T) {
+	assert.Error(t, WithRecordBudget(0)(&Client{}))
+}
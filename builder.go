@@ -0,0 +1,108 @@
+package buckyclient
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ConfigError lists every problem found when building a client
+type ConfigError struct {
+	Errors []error
+}
+
+func (e *ConfigError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = err.Error()
+	}
+
+	return fmt.Sprintf("%d configuration errors: %s", len(e.Errors), strings.Join(msgs, "; "))
+}
+
+// Unwrap returns every error so errors.Is and errors.As can match any of them
+func (e *ConfigError) Unwrap() []error {
+	return e.Errors
+}
+
+// HostStep is the first stage of a Builder. A host has to be given before
+// anything else can be configured, so a client can't be built without one.
+type HostStep struct{}
+
+// Builder starts building a client:
+//
+//	cl, err := buckyclient.Builder().
+//		Host("http://localhost:8005/bucky/v1/send").
+//		Interval(time.Minute).
+//		Build()
+//
+// Nothing is checked until Build, which reports every misconfiguration
+// together in a ConfigError.
+func Builder() HostStep {
+	return HostStep{}
+}
+
+// Host sets the full URL of the bucky server, or a URL with a scheme
+// given to RegisterScheme, such as udp://host:port
+func (HostStep) Host(u string) *ClientBuilder {
+	return &ClientBuilder{host: u, interval: DefaultInterval}
+}
+
+// ClientBuilder collects configuration until Build is called
+type ClientBuilder struct {
+	host     string
+	interval time.Duration
+	opts     []Option
+}
+
+// Interval sets how often metrics are sent. It defaults to DefaultInterval.
+func (b *ClientBuilder) Interval(d time.Duration) *ClientBuilder {
+	b.interval = d
+	return b
+}
+
+// Transport sets the http transport used for flushes
+func (b *ClientBuilder) Transport(rt http.RoundTripper) *ClientBuilder {
+	b.opts = append(b.opts, WithRoundTripper(rt))
+	return b
+}
+
+// Options adds any of the client options
+func (b *ClientBuilder) Options(opts ...Option) *ClientBuilder {
+	b.opts = append(b.opts, opts...)
+	return b
+}
+
+// Build checks the whole configuration and starts the client. If anything
+// is wrong no client is started and the ConfigError lists every problem.
+// A server that fails WithStartupVerification is reported the same way.
+func (b *ClientBuilder) Build() (*Client, error) {
+	var errs []error
+
+	if u, err := url.Parse(b.host); err != nil {
+		errs = append(errs, fmt.Errorf("Host: %w", err))
+	} else if (u.Scheme != "http" && u.Scheme != "https" && schemeOpener(u.Scheme) == nil) || u.Host == "" {
+		errs = append(errs, fmt.Errorf("Host: %q is not an http, https or registered URL", b.host))
+	}
+
+	if b.interval <= 0 {
+		errs = append(errs, fmt.Errorf("Interval: must be positive, got %s", b.interval))
+	}
+
+	cl, optErrs := newClient(b.host, b.interval, b.opts)
+	errs = append(errs, optErrs...)
+
+	if len(errs) > 0 {
+		// Options may already have opened a socket or a log handle
+		cl.release()
+		return nil, &ConfigError{Errors: errs}
+	}
+
+	if err := cl.launch(); err != nil {
+		return nil, &ConfigError{Errors: []error{err}}
+	}
+
+	return cl, nil
+}
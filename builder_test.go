@@ -0,0 +1,137 @@
+package buckyclient
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuilder_Build(t *testing.T) {
+	var rt http.RoundTripper = &http.Transport{}
+
+	cl, err := Builder().
+		Host("http://localhost:8005/bucky/v1/send").
+		Interval(2 * time.Minute).
+		Transport(rt).
+		Options(WithHeartbeat("myapp.alive")).
+		Build()
+
+	assert.NoError(t, err)
+	cl.SetLogger(log.New(ioutil.Discard, "", 0))
+	defer cl.Stop()
+
+	assert.Equal(t, 2*time.Minute, cl.interval)
+	assert.Equal(t, rt, cl.http.Transport)
+	assert.Equal(t, "myapp.alive", cl.heartbeat)
+}
+
+func TestBuilder_Build_SubMinuteInterval(t *testing.T) {
+	cl, err := Builder().
+		Host("http://localhost:8005/bucky/v1/send").
+		Interval(500 * time.Millisecond).
+		Build()
+
+	assert.NoError(t, err)
+	cl.SetLogger(log.New(ioutil.Discard, "", 0))
+	defer cl.Stop()
+
+	assert.Equal(t, 500*time.Millisecond, cl.interval)
+}
+
+func TestBuilder_Build_ListsEveryError(t *testing.T) {
+	cl, err := Builder().
+		Host("localhost:8005").
+		Interval(0).
+		Options(WithTopK(0), WithHeartbeat("")).
+		Build()
+
+	assert.Nil(t, cl)
+
+	var configErr *ConfigError
+	assert.True(t, errors.As(err, &configErr))
+	assert.Len(t, configErr.Errors, 4)
+
+	assert.Contains(t, err.Error(), "4 configuration errors")
+	assert.Contains(t, err.Error(), "Host:")
+	assert.Contains(t, err.Error(), "Interval:")
+	assert.Contains(t, err.Error(), "WithTopK: k must be positive")
+	assert.Contains(t, err.Error(), "WithHeartbeat: name must not be empty")
+	assert.True(t, errors.Is(err, ErrInvalidOption))
+}
+
+func TestBuilder_Build_ReleasesOnError(t *testing.T) {
+	rt, sink := &recordingTransport{}, &recordingTransport{}
+
+	_, err := Builder().
+		Host("http://localhost:8005/bucky/v1/send").
+		Options(WithTransport(rt), WithFanOut(sink), WithHeartbeat("")).
+		Build()
+
+	assert.Error(t, err)
+	assert.True(t, rt.closed, "the transport is closed")
+	assert.True(t, sink.closed, "the sinks are closed")
+}
+
+func TestBuilder_Build_StartupVerification(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer ts.Close()
+
+	_, err := Builder().
+		Host(ts.URL).
+		Options(WithStartupVerification()).
+		Build()
+
+	var configErr *ConfigError
+	assert.True(t, errors.As(err, &configErr))
+	assert.ErrorIs(t, err, ErrVerificationFailed)
+}
+
+func TestBuilder_Build_ControlEndpoint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bucky.sock")
+
+	cl, err := Builder().
+		Host("http://localhost:8005/bucky/v1/send").
+		Options(WithControlEndpoint("unix:" + path)).
+		Build()
+	if !assert.NoError(t, err) {
+		return
+	}
+	cl.SetLogger(log.New(ioutil.Discard, "", 0))
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", path)
+		},
+	}}
+
+	resp, err := client.Get("http://bucky/stats")
+	if assert.NoError(t, err) {
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+
+	client.CloseIdleConnections()
+	assert.NoError(t, cl.Close())
+}
+
+func TestBuilder_Build_TransportAndDialer(t *testing.T) {
+	_, err := Builder().
+		Host("http://localhost:8005/").
+		Transport(&http.Transport{}).
+		Options(WithDialContext((&net.Dialer{}).DialContext)).
+		Build()
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "can't be used with a custom round tripper")
+}
@@ -0,0 +1,85 @@
+package buckyclient
+
+import (
+	"runtime/debug"
+	"sync/atomic"
+)
+
+// DefaultBuildInfoMetric is the counter WithBuildInfo sends without a name
+const DefaultBuildInfoMetric = "buckyclient.build_info"
+
+// readBuildInfo is replaced in tests
+var readBuildInfo = debug.ReadBuildInfo
+
+// buildInfo is the counter sent by WithBuildInfo
+type buildInfo struct {
+	name string
+	tags []Tag
+}
+
+// WithBuildInfo counts name once when the client starts, and again with
+// the first flush that succeeds after failing, tagged with the program's
+// version, VCS commit and Go version as recorded by the go tool. Graphed
+// as events, these are deploy and reconnect markers. The given tags are
+// added to them, and an empty name is DefaultBuildInfoMetric.
+func WithBuildInfo(name string, tags ...Tag) Option {
+	return func(c *Client) error {
+		if name == "" {
+			name = DefaultBuildInfoMetric
+		}
+
+		c.buildInfo = &buildInfo{name: name, tags: append(buildInfoTags(), tags...)}
+		return nil
+	}
+}
+
+// buildInfoTags returns the version tags of the running program
+func buildInfoTags() []Tag {
+	bi, ok := readBuildInfo()
+	if !ok {
+		return nil
+	}
+
+	tags := []Tag{{"go", bi.GoVersion}}
+
+	if bi.Main.Version != "" {
+		tags = append(tags, Tag{"version", bi.Main.Version})
+	}
+
+	for _, s := range bi.Settings {
+		switch {
+		case s.Key == "vcs.revision" && len(s.Value) > 12:
+			tags = append(tags, Tag{"commit", s.Value[:12]})
+		case s.Key == "vcs.revision" && s.Value != "":
+			tags = append(tags, Tag{"commit", s.Value})
+		case s.Key == "vcs.modified" && s.Value == "true":
+			tags = append(tags, Tag{"dirty", "true"})
+		}
+	}
+
+	return tags
+}
+
+// recordBuildInfo counts the build info metric, if there is one
+func (c *Client) recordBuildInfo() {
+	if c.buildInfo != nil {
+		c.record(c.buildInfo.name, 1, UnitCount, ActionSum, c.buildInfo.tags)
+	}
+}
+
+// reconnected tracks whether flushes are failing, recording the build info
+// again once they succeed
+func (c *Client) reconnected(err error) {
+	if c.buildInfo == nil {
+		return
+	}
+
+	if err != nil {
+		atomic.StoreInt32(&c.disconnected, 1)
+		return
+	}
+
+	if atomic.SwapInt32(&c.disconnected, 0) == 1 {
+		c.recordBuildInfo()
+	}
+}
@@ -0,0 +1,76 @@
+package buckyclient
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"log"
+	"runtime/debug"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func stubBuildInfo(t *testing.T, bi *debug.BuildInfo) {
+	old := readBuildInfo
+	readBuildInfo = func() (*debug.BuildInfo, bool) { return bi, bi != nil }
+	t.Cleanup(func() { readBuildInfo = old })
+}
+
+func TestBuildInfo_WithBuildInfo(t *testing.T) {
+	stubBuildInfo(t, &debug.BuildInfo{
+		GoVersion: "go1.22.1",
+		Main:      debug.Module{Path: "example.com/app", Version: "v1.4.0"},
+		Settings: []debug.BuildSetting{
+			{Key: "vcs.revision", Value: "0123456789abcdef0123"},
+			{Key: "vcs.modified", Value: "true"},
+		},
+	})
+
+	c := &Client{}
+	assert.NoError(t, WithBuildInfo("", Tag{"service", "api"})(c))
+
+	assert.Equal(t, DefaultBuildInfoMetric, c.buildInfo.name)
+	assert.Equal(t, "commit:0123456789ab,dirty:true,go:go1.22.1,service:api,version:v1.4.0", canonicalTags(c.buildInfo.tags))
+}
+
+func TestBuildInfo_WithBuildInfo_Unavailable(t *testing.T) {
+	stubBuildInfo(t, nil)
+
+	c := &Client{}
+	assert.NoError(t, WithBuildInfo("deploys")(c))
+
+	assert.Equal(t, "deploys", c.buildInfo.name)
+	assert.Empty(t, c.buildInfo.tags)
+}
+
+func TestBuildInfo_Client_reconnected(t *testing.T) {
+	stubBuildInfo(t, &debug.BuildInfo{GoVersion: "go1.22.1"})
+
+	c := newBatchingClient(1)
+	WithBuildInfo("deploys")(c)
+
+	c.reconnected(nil)
+	c.reconnected(errors.New("unreachable"))
+	c.reconnected(errors.New("unreachable"))
+	c.reconnected(nil)
+	c.reconnected(nil)
+
+	buf := &bytes.Buffer{}
+	c.formatMetricsForFlush(buf)
+	assert.Equal(t, []string{"deploys:1|c|#go:go1.22.1"}, splitLines(buf.String()))
+}
+
+func TestBuildInfo_NewClient(t *testing.T) {
+	stubBuildInfo(t, &debug.BuildInfo{GoVersion: "go1.22.1"})
+	rt := &recordingTransport{}
+
+	c, err := NewClient("", 0, WithTransport(rt), WithBuildInfo(""))
+	assert.NoError(t, err)
+	c.SetLogger(log.New(ioutil.Discard, "", 0))
+
+	assert.NoError(t, c.Close())
+
+	assert.Equal(t, DefaultBuildInfoMetric+":1|c|#go:go1.22.1\n", strings.Join(rt.payloads, ""))
+}
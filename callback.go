@@ -0,0 +1,58 @@
+package buckyclient
+
+import "time"
+
+// FlushResult describes one payload the client tried to send
+type FlushResult struct {
+	FlushInfo
+
+	// Target is the URL the payload was sent to
+	Target string
+
+	// Payload is exactly what was sent, before any compression. It is a
+	// copy, so it can be kept.
+	Payload []byte
+
+	// Err is nil if the server accepted the payload
+	Err error
+}
+
+// WithFlushCallback adds a function that is called after every payload the
+// client sends, whether it succeeded or not, e.g. to archive payloads or
+// replay them into another system. Payloads retried from the retry queue
+// are reported again with every attempt. Callbacks are called in the
+// order they were added, on the goroutine doing the flush, so a slow
+// callback delays the next flush.
+func WithFlushCallback(fn func(FlushResult)) Option {
+	return func(c *Client) error {
+		if fn == nil {
+			return invalidOption("WithFlushCallback", "callback must not be nil")
+		}
+
+		c.flushCallbacks = append(c.flushCallbacks, fn)
+		return nil
+	}
+}
+
+// flushed records the result of a send for the dashboard and passes it to
+// every flush callback
+func (c *Client) flushed(info FlushInfo, target string, payload []byte, err error) {
+	c.history.add(info, target, payload, err, time.Now())
+	c.reconnected(err)
+	c.countPost(payload, err)
+
+	if len(c.flushCallbacks) == 0 {
+		return
+	}
+
+	result := FlushResult{
+		FlushInfo: info,
+		Target:    target,
+		Payload:   append([]byte(nil), payload...),
+		Err:       err,
+	}
+
+	for _, fn := range c.flushCallbacks {
+		fn(result)
+	}
+}
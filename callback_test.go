@@ -0,0 +1,53 @@
+package buckyclient
+
+import (
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCallback_Client_flush_FlushCallback(t *testing.T) {
+	status := http.StatusOK
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	var results []FlushResult
+
+	c := &Client{
+		hostURL:    srv.URL,
+		http:       &http.Client{},
+		logger:     log.New(ioutil.Discard, "", 0),
+		metrics:    make(map[Metric]Value),
+		bufferPool: newBufferPool(),
+	}
+
+	assert.NoError(t, WithFlushCallback(func(r FlushResult) { results = append(results, r) })(c))
+
+	c.aggregate(MetricWithAmount{Metric{name: "a", unit: UnitCount}, Amount{Value: 1}, ActionSum})
+	assert.NoError(t, c.flush())
+
+	status = http.StatusInternalServerError
+	c.aggregate(MetricWithAmount{Metric{name: "b", unit: UnitCount}, Amount{Value: 2}, ActionSum})
+	assert.Error(t, c.flush())
+
+	assert.Len(t, results, 2)
+
+	assert.Equal(t, "a:1|c\n", string(results[0].Payload))
+	assert.Equal(t, srv.URL, results[0].Target)
+	assert.Equal(t, uint64(1), results[0].Seq)
+	assert.NoError(t, results[0].Err)
+
+	assert.Equal(t, "b:2|c\n", string(results[1].Payload))
+	assert.EqualError(t, results[1].Err, "Non-success HTTP Status Code (500)")
+}
+
+func TestCallback_WithFlushCallback_Nil(t *testing.T) {
+	assert.Error(t, WithFlushCallback(nil)(&Client{}))
+}
@@ -0,0 +1,43 @@
+package buckyclient
+
+import (
+	"runtime"
+	"strconv"
+)
+
+// callerLog remembers which metric name and call site pairs have been
+// logged so each one is only reported once
+type callerLog struct {
+	seen map[string]bool
+}
+
+// logCaller logs the file, line and function that recorded a metric when
+// WithCallerDebug is enabled. It has to be called directly from one of the
+// recording methods so the caller is found at the right depth.
+func (c *Client) logCaller(name string) {
+	if c.callers == nil {
+		return
+	}
+
+	pc, file, line, ok := runtime.Caller(2)
+	if !ok {
+		return
+	}
+
+	site := file + ":" + strconv.Itoa(line)
+
+	fn := "unknown"
+	if f := runtime.FuncForPC(pc); f != nil {
+		fn = f.Name()
+	}
+
+	c.callersMu.Lock()
+	key := name + " " + site
+	seen := c.callers.seen[key]
+	c.callers.seen[key] = true
+	c.callersMu.Unlock()
+
+	if !seen {
+		c.logger.Printf("metric %s recorded from %s (%s)", name, site, fn)
+	}
+}
@@ -0,0 +1,46 @@
+package buckyclient
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCaller_Client_logCaller(t *testing.T) {
+	buf := &bytes.Buffer{}
+
+	cl := &Client{
+		logger: log.New(buf, "", 0),
+		input:  make(chan MetricWithAmount, 10),
+	}
+	assert.NoError(t, WithCallerDebug()(cl))
+
+	for i := 0; i < 3; i++ {
+		cl.Count("myapp.facet", 1) // same call site every time
+	}
+	cl.Timer("myapp.timer", 1)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+
+	assert.Len(t, lines, 2)
+	assert.Contains(t, lines[0], "metric myapp.facet recorded from ")
+	assert.Contains(t, lines[0], "caller_test.go:")
+	assert.Contains(t, lines[0], "TestCaller_Client_logCaller")
+	assert.Contains(t, lines[1], "metric myapp.timer recorded from ")
+}
+
+func TestCaller_Client_logCaller_Disabled(t *testing.T) {
+	buf := &bytes.Buffer{}
+
+	cl := &Client{
+		logger: log.New(buf, "", 0),
+		input:  make(chan MetricWithAmount, 10),
+	}
+
+	cl.Count("myapp.facet", 1)
+
+	assert.Equal(t, "", buf.String())
+}
@@ -0,0 +1,149 @@
+package buckyclient
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultProbeTimeout bounds the capability request made by
+// WithCapabilityProbe
+const DefaultProbeTimeout = 5 * time.Second
+
+// errUnsupportedEncoding is returned by postBody when the server rejects a
+// compressed payload with 415 Unsupported Media Type
+var errUnsupportedEncoding = errors.New("Unsupported content encoding")
+
+// capabilities caches what the server said it accepts
+type capabilities struct {
+	probed bool
+	gzip   bool
+}
+
+// WithCapabilityProbe makes the client ask the bucky server what it
+// supports before the first flush, by sending an OPTIONS request and
+// reading the Accept-Encoding header of the response. If the server lists
+// gzip, payloads are compressed from then on. A server that doesn't answer
+// OPTIONS is sent plain text as before, and so is one that later rejects
+// gzip with 415 Unsupported Media Type. The answer is kept for the life of
+// the client; the probe is only tried again if it couldn't reach the server.
+func WithCapabilityProbe() Option {
+	return func(c *Client) error {
+		c.probe = true
+		return nil
+	}
+}
+
+// useGzip reports whether a flush should be compressed, probing the server
+// first if that hasn't been done yet
+func (c *Client) useGzip(info FlushInfo) bool {
+	if !c.probe {
+		return false
+	}
+
+	c.capsMu.Lock()
+	defer c.capsMu.Unlock()
+
+	if !c.caps.probed {
+		caps, err := c.probeCapabilities()
+		if err != nil {
+			c.warnf(info, "capability probe - %v", err)
+			return false
+		}
+
+		c.logf(info, "capability probe - gzip %t", caps.gzip)
+		c.caps = caps
+	}
+
+	return c.caps.gzip
+}
+
+// disableGzip stops compressing payloads after the server rejected one
+func (c *Client) disableGzip(info FlushInfo) {
+	c.capsMu.Lock()
+	c.caps.gzip = false
+	c.capsMu.Unlock()
+
+	c.logf(info, "gzip rejected by server - sending plain text")
+}
+
+// probeCapabilities sends an OPTIONS request to the bucky server. Only a
+// failure to get any response is an error; a server that rejects the
+// request simply has no capabilities.
+func (c *Client) probeCapabilities() (capabilities, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultProbeTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "OPTIONS", c.hostURL, nil)
+	if err != nil {
+		return capabilities{}, err
+	}
+
+	c.setHeaders(req.Header)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return capabilities{}, err
+	}
+
+	// Drain the body so the connection goes back in the pool
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+
+	caps := capabilities{probed: true}
+
+	if resp.StatusCode < 300 {
+		caps.gzip = acceptsEncoding(resp.Header, "gzip")
+	}
+
+	return caps, nil
+}
+
+// acceptsEncoding reports whether the Accept-Encoding header lists the
+// encoding without a zero quality value
+func acceptsEncoding(h http.Header, encoding string) bool {
+	for _, value := range h.Values("Accept-Encoding") {
+		for _, part := range strings.Split(value, ",") {
+			fields := strings.Split(part, ";")
+			if !strings.EqualFold(strings.TrimSpace(fields[0]), encoding) {
+				continue
+			}
+
+			disabled := false
+			for _, param := range fields[1:] {
+				param = strings.TrimSpace(param)
+				if !strings.HasPrefix(param, "q=") {
+					continue
+				}
+
+				if q, err := strconv.ParseFloat(param[2:], 64); err == nil && q == 0 {
+					disabled = true
+				}
+			}
+
+			if !disabled {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// gzipPayload compresses a payload into a new buffer
+func gzipPayload(payload []byte) *bytes.Buffer {
+	buf := &bytes.Buffer{}
+
+	zw := gzip.NewWriter(buf)
+	zw.Write(payload)
+	zw.Close()
+
+	return buf
+}
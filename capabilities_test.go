@@ -0,0 +1,142 @@
+package buckyclient
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// capabilityServer advertises the given Accept-Encoding on OPTIONS and
+// returns whatever body it receives, decompressed, on bodies
+func capabilityServer(acceptEncoding string, bodies chan<- string, probes *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "OPTIONS" {
+			atomic.AddInt32(probes, 1)
+			if acceptEncoding == "" {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+
+			w.Header().Set("Accept-Encoding", acceptEncoding)
+			return
+		}
+
+		body := r.Body
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			zr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+
+			body = zr
+		}
+
+		b, _ := ioutil.ReadAll(body)
+		bodies <- r.Header.Get("Content-Encoding") + "|" + string(b)
+	}))
+}
+
+func newProbeClient(url string) *Client {
+	c := &Client{
+		hostURL:    url,
+		http:       &http.Client{},
+		logger:     log.New(ioutil.Discard, "", 0),
+		metrics:    make(map[Metric]Value),
+		bufferPool: newBufferPool(),
+	}
+
+	WithCapabilityProbe()(c)
+	return c
+}
+
+func TestCapabilities_Client_flush_Gzip(t *testing.T) {
+	bodies := make(chan string, 2)
+	var probes int32
+
+	srv := capabilityServer("identity, gzip", bodies, &probes)
+	defer srv.Close()
+
+	c := newProbeClient(srv.URL)
+
+	for i := 0; i < 2; i++ {
+		c.aggregate(MetricWithAmount{Metric{name: "a", unit: UnitCount}, Amount{Value: 1}, ActionSum})
+		assert.NoError(t, c.flush())
+		assert.Equal(t, "gzip|a:1|c\n", <-bodies)
+	}
+
+	// the answer is cached
+	assert.Equal(t, int32(1), atomic.LoadInt32(&probes))
+}
+
+func TestCapabilities_Client_flush_NoOptions(t *testing.T) {
+	bodies := make(chan string, 2)
+	var probes int32
+
+	srv := capabilityServer("", bodies, &probes)
+	defer srv.Close()
+
+	c := newProbeClient(srv.URL)
+
+	for i := 0; i < 2; i++ {
+		c.aggregate(MetricWithAmount{Metric{name: "a", unit: UnitCount}, Amount{Value: 1}, ActionSum})
+		assert.NoError(t, c.flush())
+		assert.Equal(t, "|a:1|c\n", <-bodies)
+	}
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&probes))
+}
+
+func TestCapabilities_Client_flush_GzipRejected(t *testing.T) {
+	bodies := make(chan string, 2)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "OPTIONS" {
+			w.Header().Set("Accept-Encoding", "gzip")
+			return
+		}
+
+		if r.Header.Get("Content-Encoding") != "" {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+
+		b, _ := ioutil.ReadAll(r.Body)
+		bodies <- string(b)
+	}))
+	defer srv.Close()
+
+	c := newProbeClient(srv.URL)
+
+	c.aggregate(MetricWithAmount{Metric{name: "a", unit: UnitCount}, Amount{Value: 1}, ActionSum})
+	assert.NoError(t, c.flush())
+	assert.Equal(t, "a:1|c\n", <-bodies)
+	assert.False(t, c.caps.gzip)
+}
+
+func TestCapabilities_acceptsEncoding(t *testing.T) {
+	for value, want := range map[string]bool{
+		"gzip":               true,
+		"GZIP":               true,
+		"identity, gzip":     true,
+		"gzip;q=0.5":         true,
+		"gzip;q=0":           false,
+		"gzip; q=0.000":      false,
+		"deflate, identity":  false,
+		"":                   false,
+		"br;q=1, gzip;q=0.1": true,
+	} {
+		h := http.Header{}
+		if value != "" {
+			h.Set("Accept-Encoding", value)
+		}
+
+		assert.Equal(t, want, acceptsEncoding(h, "gzip"), value)
+	}
+}
@@ -4,78 +4,354 @@ package buckyclient
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
 	"errors"
-	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
+	"math"
+	"net"
 	"net/http"
 	"os"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 // Client contains all the data necessary for sending
 // the metrics to the buckyserver
 type Client struct {
-	hostURL  string        // full URL of the buckyserver
-	http     *http.Client  // Standard http client
-	logger   *log.Logger   // logger
-	interval time.Duration // Interval in seconds between sending metrics to buckyserver
+	flushSeq           uint64 // Sequence number of the last flush, first for 64-bit alignment
+	budgetDropped      uint64 // Samples dropped for going over the record budget
+	cardinalityDropped uint64 // Samples and aggregates dropped by WithMaxMetrics
+	bufferBytes        int64  // Capacity of the last payload buffer, see Stats
+	inputSent          uint64 // Samples handed over to the input buffer
+	inputHandled       uint64 // Samples taken from the input buffer and aggregated
+	inputDropped       uint64 // Samples dropped for a full input buffer
+	postsAttempted     uint64 // Posts since the last telemetry report
+	postsFailed        uint64 // Failed posts since the last telemetry report
+	linesSent          uint64 // Lines posted since the last telemetry report
+	bytesSent          uint64 // Bytes posted since the last telemetry report
+	tracing            int32  // Whether samples are traced, see tracer
+	disconnected       int32  // Whether the last flush failed, see WithBuildInfo
+
+	hostURL   string        // full URL of the buckyserver
+	http      *http.Client  // Standard http client
+	logger    *log.Logger   // logger
+	logCloser io.Closer     // Closed with the client, for loggers that hold a handle
+	interval  time.Duration // Interval between sending metrics to buckyserver
+
+	m           sync.Mutex         // mutex for protecting Metrics
+	metrics     map[Metric]Value   // Holds the current set of metrics ready for sending at every interval
+	exposed     map[Metric]exposed // Flushed metrics PrometheusHandler serves, nil until it is called
+	windowStart time.Time          // When the default window started
+
+	input       chan MetricWithAmount // Samples waiting to be aggregated
+	inputBuffer int                   // Size of input
+	overflow    OverflowPolicy        // What recording does when input is full
+
+	stop      chan bool
+	stopped   chan bool       // Closed by the sender once it has stopped
+	stopOnce  sync.Once       // Stop only runs once
+	stopping  chan struct{}   // Closed as soon as Stop is called
+	stopCtx   context.Context // Bounds the final flush, set before stop is sent
+	stopErr   error           // Error of the final flush, set before stopped is closed
+	closed    int32           // Set to 1 once Stop has been called
+	done      chan struct{}   // Closed by Close to end every goroutine
+	closeOnce sync.Once       // Close only shuts down once
+	disabled  int32           // Set to 1 while recording and flushing are turned off
 
-	m       sync.Mutex       // mutex for protecting Metrics
-	metrics map[Metric]Value // Holds the current set of metrics ready for sending at every interval
+	bufferPool *sync.Pool
 
-	input chan MetricWithAmount
+	errorHandler func(error)      // Called with any error from a flush
+	emptyFlush   EmptyFlushPolicy // What to do when an interval has no metrics
+	heartbeat    string           // Counter sent with every flush, if set
+	spool        *spool           // Failed payloads waiting to be retried
 
-	stop    chan bool
-	stopped chan bool
+	unitIntervals map[Unit]time.Duration // Units flushed on their own interval
 
-	bufferPool *sync.Pool
+	dialContext  func(ctx context.Context, network, addr string) (net.Conn, error) // Dials flush connections
+	roundTripper http.RoundTripper                                                 // Replaces the default transport
+	transport    Transport                                                         // Sends payloads, posting over http if nil
+	sinks        []Transport                                                       // Also sent every payload, see WithFanOut
+	ownTransport bool                                                              // Whether the transport was made for the host
+	pooled       bool                                                              // The ClientPool closes the transports given as options
+
+	capsMu sync.Mutex   // Protects caps
+	caps   capabilities // What the server said it supports
+	probe  bool         // Whether to ask the server for its capabilities
+
+	batcher *batcher // Collects samples into batches, if enabled
+
+	targetSelector func(Snapshot) string // Picks the URL for each flush
+
+	fallbacks      []string      // Tried when the host fails, see WithFailover
+	primaryRecheck time.Duration // How long to stay on a fallback, 0 for DefaultPrimaryRecheck
+	failover       *failover     // Which server is used, nil without fallbacks
+
+	budget time.Duration // How long a recording call may wait, if bounded
+
+	priorities priorities // Which metrics are dropped last
+	maxMetrics int        // Most metrics aggregated between flushes, if limited
+
+	collectors []collector // Add gauges to every default flush
+
+	observedMu sync.Mutex                // mutex for protecting observed
+	observed   map[Metric]func() float64 // Gauges registered with RegisterGauge
+
+	runtimeInterval time.Duration // How often runtime metrics are sampled, if at all
+
+	flushCallbacks []func(FlushResult) // Told about every payload sent
+
+	statusPolicy StatusPolicy // What to do with payloads the server rejects
+
+	retry       retryPolicy   // How failed posts are retried within a flush
+	maxInterval time.Duration // Longest interval WithAdaptiveInterval backs off to
+
+	buildInfo *buildInfo // Sent at startup and after reconnecting, nil unless WithBuildInfo
+	verify    bool       // Whether NewClient checks the server accepts payloads
+
+	digestSketches bool // Whether digests are sent serialized as well
+	tagRollups     bool // Whether tagged metrics are also sent untagged
+	strict         bool // Whether invariants are checked before every flush
+
+	timerResolution TimerResolution // How precisely durations are sent
+
+	memoryMetrics bool // Whether Stats is sent with the default flush
+
+	clock Clock // Where timestamps come from, nil for the system clock
+
+	telemetry     string // Prefix of the client's own health metrics, empty for none
+	telemetryTags string // canonical, tells the telemetry of a pool's clients apart
+
+	prefix string  // Put in front of every name as it is sent
+	parent *Client // The client a scope records on, nil unless this is one
+	scope  string  // Put in front of names recorded on a scope
+	tags   []Tag   // Added to every sample recorded on the client or scope
+
+	controlAddr string       // Where WithControlEndpoint serves, empty for nowhere
+	control     *http.Server // Serves the control endpoint once listening
+
+	tlsConfig *tls.Config // TLS config of the default transport, nil for the defaults
+
+	httpTimeout time.Duration // Timeout of the default http client, 0 for DefaultHTTPTimeout
+	httpClient  *http.Client  // Http client given with WithHTTPClient
+
+	maxPayload int // Largest payload posted in one go, 0 for no limit
+
+	nameCase     NameCase     // How metric names are normalized
+	aggregations aggregations // Actions that replace the recorded ones, by name
+
+	mergeBack    int // Most metrics held after failed flushes, 0 unless merging them back
+	mergeDropped int // Aggregates that didn't fit, guarded by m
+
+	tracer tracer // Where recorded samples are traced to
+
+	tagFormat TagFormat // How tags are written on the wire
+	format    Format    // How payloads are written
+
+	history flushHistory // Recent flushes, for the dashboard
+
+	goroutines goroutines // Every goroutine the client started, for Close
+
+	headers    http.Header       // Static headers added to every flush request
+	headerFunc func(http.Header) // Called to add dynamic headers to every flush request
+
+	warmup bool // Check the server can be reached when the client is created
+
+	quietStart time.Duration // How long scheduled flushes wait after starting
+
+	gate             flushGate        // Makes sure flushes don't overlap
+	flushConcurrency FlushConcurrency // What an ad-hoc flush does when one is running
+
+	ewmaMu sync.Mutex       // mutex for protecting ewmas
+	ewmas  map[Metric]*ewma // Moving averages kept between flushes
+
+	topkMu sync.Mutex              // mutex for protecting topks
+	topks  map[Metric]*spaceSaving // Hot keys seen this interval
+	topK   int                     // How many keys TopK tracks
+
+	hllMu sync.Mutex              // mutex for protecting hlls
+	hlls  map[Metric]*hyperLogLog // Distinct counts for this interval
+
+	callersMu sync.Mutex // mutex for protecting callers
+	callers   *callerLog // Call sites already logged, nil unless debugging
 }
 
 var (
 	// ErrNoMetrics is returned when there are not metrics to flush
 	ErrNoMetrics = errors.New("No metrics to flush")
+
+	// ErrInvalidUnit is returned when a sample uses a unit the client can't flush
+	ErrInvalidUnit = errors.New("Invalid metric unit")
+
+	// ErrInvalidAction is returned when a sample uses an unknown aggregation action
+	ErrInvalidAction = errors.New("Invalid metric action")
+
+	// ErrOverflow is returned when a total no longer fits in an int64 or
+	// float64 and was clamped
+	ErrOverflow = errors.New("Metric value overflowed")
+
+	// ErrInvalidValue is returned when a fractional sample is NaN or infinite
+	ErrInvalidValue = errors.New("Invalid metric value")
+
+	// ErrActionConflict is returned when a sample's action differs from the
+	// one its metric was first recorded with in the interval
+	ErrActionConflict = errors.New("Metric recorded with conflicting actions")
+
+	// ErrInvalidInterval is returned by NewClient for a negative interval or
+	// one too long to be a time.Duration
+	ErrInvalidInterval = errors.New("Invalid interval")
 )
 
-// NewClient returns a client that can send data to a bucky server
-// It takes an interval value in seconds
-func NewClient(host string, interval int) (cl *Client, err error) {
+// MetricError is passed to the error handler when a single metric
+// could not be recorded as given
+type MetricError struct {
+	Name string
+	Err  error
+}
 
-	// We should never send more often than once per minute
-	if interval < 60 {
-		interval = 60
-	}
+func (e *MetricError) Error() string {
+	return e.Name + ": " + e.Err.Error()
+}
 
-	intSecond := fmt.Sprintf("%ds", interval)
+// Unwrap returns the underlying error
+func (e *MetricError) Unwrap() error {
+	return e.Err
+}
 
-	intDur, err := time.ParseDuration(intSecond)
+// DefaultInterval is how often metrics are sent when no interval is given
+const DefaultInterval = 60 * time.Second
+
+// NewClient returns a client that can send data to a bucky server, or
+// with the transport of a scheme given to RegisterScheme, such as
+// udp://host:port once transport/udp is imported.
+// It takes an interval value in seconds, or 0 for DefaultInterval, and
+// any number of options. Use WithInterval for intervals that aren't a
+// whole number of seconds.
+func NewClient(host string, interval int, opts ...Option) (cl *Client, err error) {
+	d := DefaultInterval
+
+	if interval < 0 || int64(interval) > math.MaxInt64/int64(time.Second) {
+		return nil, ErrInvalidInterval
+	} else if interval > 0 {
+		d = time.Duration(interval) * time.Second
+	}
 
-	if err != nil {
+	cl, errs := newClient(host, d, opts)
+	if len(errs) > 0 {
+		cl.release()
+		return nil, errs[0]
+	}
+
+	if err := cl.launch(); err != nil {
 		return nil, err
 	}
 
-	cl = &Client{
+	return cl, nil
+
+}
+
+// launch verifies the server if asked to, opens the control endpoint and
+// starts the client, releasing it if either fails
+func (c *Client) launch() error {
+	if c.verify {
+		if err := c.verifyStartup(); err != nil {
+			c.release()
+			return err
+		}
+	}
+
+	if err := c.listenControl(); err != nil {
+		c.release()
+		return err
+	}
+
+	c.start()
+
+	return nil
+}
+
+// newClient creates a client and applies every option, returning all of
+// the errors rather than stopping at the first one
+func newClient(host string, interval time.Duration, opts []Option) (*Client, []error) {
+	var errs []error
+
+	cl := &Client{
 		hostURL:    host,
-		http:       &http.Client{},
 		logger:     log.New(os.Stderr, "", log.Ldate|log.Ltime|log.Lshortfile),
-		interval:   intDur,
-		input:      make(chan MetricWithAmount),
+		interval:   interval,
 		stop:       make(chan bool, 1),
 		stopped:    make(chan bool, 1),
+		done:       make(chan struct{}),
+		stopping:   make(chan struct{}),
 		metrics:    make(map[Metric]Value),
 		bufferPool: newBufferPool(),
 	}
 
+	for _, opt := range opts {
+		if err := opt(cl); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	cl.windowStart = cl.now()
+
+	if cl.inputBuffer == 0 {
+		cl.inputBuffer = DefaultInputBuffer
+	}
+
+	cl.input = make(chan MetricWithAmount, cl.inputBuffer)
+
+	if cl.quietStart > 0 && cl.verify {
+		errs = append(errs, invalidOption("WithQuietStart", "can't be combined with WithStartupVerification"))
+	}
+
+	if cl.retry.max > 0 && cl.spool == nil && cl.mergeBack == 0 {
+		cl.spool = newSpool(DefaultRetryQueueAge, DefaultRetryQueueBytes)
+	}
+
+	if cl.transport == nil {
+		if t, err := schemeTransport(host); err != nil {
+			errs = append(errs, err)
+		} else if t != nil {
+			cl.transport = t
+			cl.ownTransport = true
+		}
+	}
+
+	if len(cl.fallbacks) > 0 {
+		errs = append(errs, cl.setUpFailover()...)
+	}
+
+	httpClient, httpErrs := cl.newHTTPClient()
+	cl.http = httpClient
+	errs = append(errs, httpErrs...)
+
+	return cl, errs
+}
+
+// start warms up the connection if asked to, then starts the goroutines
+// that aggregate and send metrics
+func (c *Client) start() {
+	// A quiet start warms up once it is over
+	if c.quietStart == 0 {
+		c.warmUpIfAsked()
+	}
+
+	c.recordBuildInfo()
+
 	// start the sender
-	cl.sender()
+	c.sender()
 
 	// So we process the input channel
-	go cl.inputProcessor()
-
-	return cl, nil
+	c.goroutines.spawn("inputProcessor", c.inputProcessor)
 
+	if c.runtimeInterval > 0 {
+		c.goroutines.spawn("runtimeMetrics", c.sampleRuntime)
+	}
 }
 
 func newBufferPool() *sync.Pool {
@@ -86,107 +362,582 @@ func newBufferPool() *sync.Pool {
 	}
 }
 
-// Count returns nothing and allows a counter to be incremented by a value
-func (c *Client) Count(name string, value int) {
-	go c.send(name, value, "c", "sum") // for a counter
+// Count returns nothing and allows a counter to be incremented by a value.
+// A negative value is subtracted, so a counter can track something that
+// goes up and down, such as requests in flight, and one that comes back
+// to zero in an interval is sent as 0.
+func (c *Client) Count(name string, value int, tags ...Tag) {
+	tags = c.withTags(tags)
+	c, name = c.scoped(name)
+	c.logCaller(name)
+	c.record(name, value, UnitCount, ActionSum, tags) // for a counter
+}
+
+// Incr is Count with a value of 1
+func (c *Client) Incr(name string, tags ...Tag) {
+	tags = c.withTags(tags)
+	c, name = c.scoped(name)
+	c.logCaller(name)
+	c.record(name, 1, UnitCount, ActionSum, tags)
+}
+
+// Decr is Count with a value of -1
+func (c *Client) Decr(name string, tags ...Tag) {
+	tags = c.withTags(tags)
+	c, name = c.scoped(name)
+	c.logCaller(name)
+	c.record(name, -1, UnitCount, ActionSum, tags)
 }
 
 // Timer returns nothing and allows a timer metric to be set
-func (c *Client) Timer(name string, value int) {
-	go c.send(name, value, "ms", "sum") // timer, so count in milliseconds
+func (c *Client) Timer(name string, value int, tags ...Tag) {
+	tags = c.withTags(tags)
+	c, name = c.scoped(name)
+	c.logCaller(name)
+	c.record(name, value, UnitMillisecond, ActionSum, tags) // timer, so count in milliseconds
+}
+
+// Gauge returns nothing and allows a gauge to be set. The last value
+// set in an interval is the one that is sent.
+func (c *Client) Gauge(name string, value int, tags ...Tag) {
+	tags = c.withTags(tags)
+	c, name = c.scoped(name)
+	c.logCaller(name)
+	c.record(name, value, UnitGauge, ActionLast, tags)
+}
+
+// GaugeDelta returns nothing and allows a gauge to be adjusted by a value
+// rather than set. Adjustments within an interval add up, and apply to
+// the value if Gauge set it in the same interval. Otherwise the total is
+// sent with a sign, e.g. name:+5|g, for the server to apply to the value
+// it already has.
+func (c *Client) GaugeDelta(name string, delta int, tags ...Tag) {
+	tags = c.withTags(tags)
+	c, name = c.scoped(name)
+	c.logCaller(name)
+	c.recordAmount(name, Amount{Value: delta, Delta: true}, UnitGauge, ActionLast, tags)
+}
+
+// Ratio returns nothing and allows a percentage gauge to be recorded.
+// Numerators and denominators are summed over the interval and sent as
+// 100 * numerator / denominator, so Ratio("cache.hit_rate", hits, lookups)
+// gives the hit rate for the whole interval. Nothing is sent for an
+// interval where the denominators add up to zero.
+func (c *Client) Ratio(name string, numerator, denominator int, tags ...Tag) {
+	tags = c.withTags(tags)
+	c, name = c.scoped(name)
+	c.logCaller(name)
+	c.recordAmount(name, Amount{Value: numerator, Denominator: denominator}, UnitGauge, ActionRatio, tags)
 }
 
 // AverageTimer returns nothing and allows a timer metric to be set
-func (c *Client) AverageTimer(name string, value int) {
-	go c.send(name, value, "ms", "avg") // timer, so count in milliseconds
+func (c *Client) AverageTimer(name string, value int, tags ...Tag) {
+	tags = c.withTags(tags)
+	c, name = c.scoped(name)
+	c.logCaller(name)
+	c.record(name, value, UnitMillisecond, ActionAvg, tags) // timer, so count in milliseconds
 }
 
-// Send is used to record a metric and have it send to
-// the bucky server - this is thread safe
-func (c *Client) send(name string, value int, unit string, action string) {
-	m := Metric{
-		name: name,
-		unit: unit,
+// CountF is Count for fractional values. Once a counter has been given a
+// fractional value it is sent with a decimal point for the rest of the
+// interval.
+func (c *Client) CountF(name string, value float64, tags ...Tag) {
+	tags = c.withTags(tags)
+	c, name = c.scoped(name)
+	c.logCaller(name)
+	c.recordFloat(name, value, UnitCount, ActionSum, tags)
+}
+
+// TimerF is Timer for fractional milliseconds, e.g. for sub-millisecond
+// latencies
+func (c *Client) TimerF(name string, value float64, tags ...Tag) {
+	tags = c.withTags(tags)
+	c, name = c.scoped(name)
+	c.logCaller(name)
+	c.recordFloat(name, value, UnitMillisecond, ActionSum, tags)
+}
+
+// AverageTimerF is AverageTimer for fractional milliseconds
+func (c *Client) AverageTimerF(name string, value float64, tags ...Tag) {
+	tags = c.withTags(tags)
+	c, name = c.scoped(name)
+	c.logCaller(name)
+	c.recordFloat(name, value, UnitMillisecond, ActionAvg, tags)
+}
+
+// GaugeF is Gauge for fractional values
+func (c *Client) GaugeF(name string, value float64, tags ...Tag) {
+	tags = c.withTags(tags)
+	c, name = c.scoped(name)
+	c.logCaller(name)
+	c.recordFloat(name, value, UnitGauge, ActionLast, tags)
+}
+
+// GaugeDeltaF is GaugeDelta for a fractional adjustment
+func (c *Client) GaugeDeltaF(name string, delta float64, tags ...Tag) {
+	tags = c.withTags(tags)
+	c, name = c.scoped(name)
+	c.logCaller(name)
+	c.recordAmount(name, Amount{Float: delta, IsFloat: true, Delta: true}, UnitGauge, ActionLast, tags)
+}
+
+// Histogram returns nothing and allows a timer to be recorded for
+// percentiles. Every interval it sends name.min, name.max, name.mean,
+// name.p50, name.p90 and name.p99 in milliseconds.
+func (c *Client) Histogram(name string, value int, tags ...Tag) {
+	tags = c.withTags(tags)
+	c, name = c.scoped(name)
+	c.logCaller(name)
+	c.record(name, value, UnitMillisecond, ActionHistogram, tags)
+}
+
+// Digest returns nothing and allows a timer to be recorded for
+// percentiles like Histogram, using a t-digest instead of a sample of the
+// values. It sends the same lines, but its percentiles stay accurate near
+// the extremes however many samples there are, and with
+// WithDigestSketches the digest itself can be sent for the server to merge.
+func (c *Client) Digest(name string, value int, tags ...Tag) {
+	tags = c.withTags(tags)
+	c, name = c.scoped(name)
+	c.logCaller(name)
+	c.record(name, value, UnitMillisecond, ActionDigest, tags)
+}
+
+// Unique returns nothing and allows a value to be counted once per
+// interval however often it is seen. The number of unique values is sent
+// with the statsd set unit, e.g. Unique("users", id) sends users:42|s. Every
+// value is kept until the flush, so for very many values Distinct, which
+// estimates, uses far less memory.
+func (c *Client) Unique(name string, value string, tags ...Tag) {
+	tags = c.withTags(tags)
+	c, name = c.scoped(name)
+	c.logCaller(name)
+	c.recordAmount(name, Amount{Member: value}, UnitSet, ActionUnique, tags)
+}
+
+// Record allows a sample to be recorded with an explicit unit and action.
+// Unknown units or actions are rejected rather than creating a metric
+// that would never be flushed.
+func (c *Client) Record(name string, value int, unit Unit, action Action, tags ...Tag) error {
+	tags = c.withTags(tags)
+	c, name = c.scoped(name)
+	c.logCaller(name)
+
+	if !unit.Valid() {
+		return ErrInvalidUnit
+	}
+
+	if !action.Valid() {
+		return ErrInvalidAction
+	}
+
+	if action == ActionUnique {
+		// Sets count distinct values, so the value is the member
+		c.recordAmount(name, Amount{Member: strconv.Itoa(value)}, unit, action, tags)
+		return nil
 	}
 
-	a := Amount{Value: value}
+	c.record(name, value, unit, action, tags)
+
+	return nil
+}
+
+// RecordFloat is Record for fractional values. Ratios need a separate
+// denominator and sets a member, so ActionRatio and ActionUnique are
+// rejected.
+func (c *Client) RecordFloat(name string, value float64, unit Unit, action Action, tags ...Tag) error {
+	tags = c.withTags(tags)
+	c, name = c.scoped(name)
+	c.logCaller(name)
+
+	if !unit.Valid() {
+		return ErrInvalidUnit
+	}
+
+	if !action.Valid() || action == ActionRatio || action == ActionUnique {
+		return ErrInvalidAction
+	}
+
+	c.recordFloat(name, value, unit, action, tags)
+
+	return nil
+}
+
+// recordFloat is record for fractional values
+func (c *Client) recordFloat(name string, value float64, unit Unit, action Action, tags []Tag) {
+	c.recordAmount(name, Amount{Float: value, IsFloat: true}, unit, action, tags)
+}
 
-	c.input <- MetricWithAmount{m, a, action}
+// record hands a sample on to be aggregated, unless the client is disabled
+func (c *Client) record(name string, value int, unit Unit, action Action, tags []Tag) {
+	c.recordAmount(name, Amount{Value: value}, unit, action, tags)
+}
+
+// recordAmount is record for samples that need more than a single value
+func (c *Client) recordAmount(name string, amount Amount, unit Unit, action Action, tags []Tag) {
+	if !c.Enabled() {
+		return
+	}
+
+	m := Metric{name: c.normalizeName(name), unit: unit, tags: canonicalTags(tags)}
+	action = c.aggregation(m.name, action)
+
+	if c.budget > 0 {
+		c.recordWithBudget(MetricWithAmount{m, amount, action})
+		return
+	}
+
+	if c.batcher != nil {
+		c.recordBatched(MetricWithAmount{m, amount, action})
+		return
+	}
+
+	c.send(MetricWithAmount{m, amount, action})
 }
 
 // SetLogger allows you to specify an external logger
 // otherwise it uses the Stderr
 func (c *Client) SetLogger(logger *log.Logger) {
+	c = c.root()
+
 	c.logger = logger
 }
 
 func (c *Client) formatMetricsForFlush(buf *bytes.Buffer) {
+	c.formatMetrics(buf, func(Unit) bool { return true })
+}
+
+// formatMetrics writes every metric with a unit accepted by owns
+func (c *Client) formatMetrics(buf *bytes.Buffer, owns func(Unit) bool) {
+	at := c.now()
+
 	for k, v := range c.metrics {
-		buf.WriteString(k.name)
-		buf.WriteRune(':')
-
-		// I blame @bradfitz for this: http://yapcasia.org/2015/talk/show/6bde6c69-187a-11e5-aca1-525412004261
-		if v.Avg != nil {
-			buf.Write(strconv.AppendInt([]byte(""), int64(v.Avg.Avg), 10))
-		} else if v.Sum != nil {
-			buf.Write(strconv.AppendInt([]byte(""), int64(v.Sum.Value), 10))
+		if owns(k.unit) {
+			c.writeMetric(buf, k, v, at)
 		}
+	}
+}
+
+// formatMap writes every metric in a map taken by takeMetrics, as of at
+func (c *Client) formatMap(buf *bytes.Buffer, metrics map[Metric]Value, at time.Time) {
+	for k, v := range metrics {
+		c.writeMetric(buf, k, v, at)
+	}
+}
+
+// writeMetric writes the lines of a metric, and its sketch if
+// WithDigestSketches asks for it. at is the timestamp of formats that
+// have one.
+func (c *Client) writeMetric(buf *bytes.Buffer, k Metric, v Value, at time.Time) {
+	if c.format == FormatInflux {
+		c.writeInfluxMetric(buf, k, v, at)
+		return
+	}
+
+	v.eachLine(k.name, func(name string, value number) {
+		writeTaggedLine(buf, c.prefix+name, value, k.unit, k.tags, c.tagFormat)
+	})
 
-		buf.WriteRune('|')
-		buf.WriteString(k.unit)
-		buf.WriteRune('\n')
+	if c.digestSketches && v.Digest != nil && v.Digest.count > 0 {
+		writeTaggedLine(buf, c.prefix+k.name, number{raw: v.Digest.sketch()}, UnitDigest, k.tags, c.tagFormat)
 	}
 }
 
+// takeMetrics removes the metrics with a unit accepted by owns and returns
+// them - c.m must be held. When every unit is owned the whole map is
+// swapped for a fresh one, so the lock is held for the same short time
+// however many metrics there are.
+func (c *Client) takeMetrics(owns func(Unit) bool, ownsAll bool) map[Metric]Value {
+	if ownsAll {
+		taken := c.metrics
+		c.metrics = make(map[Metric]Value, len(taken))
+
+		return taken
+	}
+
+	taken := make(map[Metric]Value)
+	for k, v := range c.metrics {
+		if owns(k.unit) {
+			taken[k] = v
+			delete(c.metrics, k)
+		}
+	}
+
+	return taken
+}
+
+// countMetrics returns how many metrics have a unit accepted by owns
+func (c *Client) countMetrics(owns func(Unit) bool) int {
+	n := 0
+
+	for k := range c.metrics {
+		if owns(k.unit) {
+			n++
+		}
+	}
+
+	return n
+}
+
 // flush actually sends the data. It can be called after
 // a specific time interval, or when stopping the client
 func (c *Client) flush() error {
+	return c.flushContext(context.Background())
+}
+
+// flushContext is flush, giving up on waiting and sending once ctx is done
+func (c *Client) flushContext(ctx context.Context) error {
+	return c.gate.run(ctx, FlushSerialize, func() error {
+		return c.flushUngated(ctx)
+	})
+}
+
+// flushUngated flushes every metric - callers must go through c.gate
+func (c *Client) flushUngated(ctx context.Context) error {
+	info := c.nextFlushInfo()
+	info.Window = c.closeWindow(nil, c.now())
+
+	if err := c.flushWithInfo(ctx, info, nil); err != nil {
+		return &FlushError{FlushInfo: info, Err: err}
+	}
+
+	return nil
+}
+
+// flushWindow sends only the metrics belonging to one window. Windows
+// other than the default one never send anything when they are empty.
+func (c *Client) flushWindow(w *window) error {
+	ctx := context.Background()
+
+	return c.gate.run(ctx, FlushSerialize, func() error {
+		info := c.nextFlushInfo()
+		info.Window = c.closeWindow(w, c.now())
+
+		err := c.flushWithInfo(ctx, info, w)
+		if err == nil || (w.units != nil && errors.Is(err, ErrNoMetrics)) {
+			return nil
+		}
+
+		return &FlushError{FlushInfo: info, Err: err}
+	})
+}
+
+// flushWithInfo sends the metrics owned by the window, or every metric
+// when the window is nil
+func (c *Client) flushWithInfo(ctx context.Context, info FlushInfo, w *window) error {
+	owns := func(u Unit) bool { return w == nil || w.owns(c, u) }
+	isDefault := w == nil || w.units == nil
+
+	c.drainBatches()
+	c.checkStrict()
+
+	// Collectors may read files, so run them before taking the lock
+	var collected []MetricWithAmount
+	if isDefault {
+		collected = c.runCollectors()
+	}
+
 	// collect all the metrics
 	c.m.Lock()
 
-	if len(c.metrics) == 0 {
+	// Our own metrics go out with the default window
+	if isDefault {
+		for _, metric := range collected {
+			c.aggregate(metric)
+		}
+
+		if c.heartbeat != "" {
+			c.aggregate(MetricWithAmount{Metric{name: c.heartbeat, unit: UnitCount}, Amount{Value: 1}, ActionSum})
+		}
+
+		// Measured first so our own metrics don't count
+		c.addMemoryMetrics()
+		c.addTelemetry()
+		c.addSpoolMetrics()
+		c.addBudgetMetrics()
+		c.addDroppedMetrics()
+		c.addCardinalityMetrics()
+		c.addFailoverMetrics()
+		c.addMergeMetrics()
+		c.addEWMAs(c.now())
+		c.addTopKs()
+		c.addDistincts()
+	}
+
+	if c.countMetrics(owns) == 0 {
 		c.m.Unlock() // Remember to unlock as we don't unlock when the function ends
-		return ErrNoMetrics
+
+		if err := c.retrySpool(ctx, info); err != nil {
+			return err
+		}
+
+		if w != nil && w.units != nil {
+			return ErrNoMetrics
+		}
+
+		return c.flushEmpty(ctx, info)
 	}
 
+	// Take the metrics out so recording can carry on while they are
+	// formatted and sent
+	snapshot := c.takeMetrics(owns, w == nil || (isDefault && len(c.unitIntervals) == 0))
+	c.exposeFlushed(snapshot)
+	c.m.Unlock()
+
+	c.addRollups(snapshot)
+
+	// Older payloads go first so the server sees them in order
+	err := c.retrySpool(ctx, info)
+
+	for _, metrics := range c.splitPayload(snapshot, info.Window.End) {
+		err = c.sendMetrics(ctx, info, metrics, err)
+	}
+
+	return err
+}
+
+// sendMetrics formats and posts metrics taken for a flush. If err says
+// an earlier post of the flush failed they aren't posted, but kept as if
+// this one had failed too.
+func (c *Client) sendMetrics(ctx context.Context, info FlushInfo, metrics map[Metric]Value, err error) error {
 	buf := c.bufferPool.Get().(*bytes.Buffer)
 	buf.Reset()
 
-	c.formatMetricsForFlush(buf)
+	c.formatMap(buf, metrics, info.Window.End)
 
-	c.Reset()
-	c.m.Unlock()
+	// Sending consumes the buffer, so hold on to the bytes in case it fails
+	payload := buf.Bytes()
+
+	if err == nil {
+		err = c.postWithRetry(ctx, info, buf)
+	}
+
+	if c.rejected(err) {
+		// Sending it again would only get the same answer
+		err = c.reject(info, payload, err)
+	} else if err != nil && c.mergeBack > 0 {
+		c.restoreMetrics(metrics)
+	} else if err != nil && c.spool != nil {
+		c.spool.push(payload, info.Window, time.Now())
+	}
 
+	atomic.StoreInt64(&c.bufferBytes, int64(buf.Cap()))
+	c.bufferPool.Put(buf)
+
+	return err
+}
+
+// flushEmpty decides what an interval without any metrics should send
+func (c *Client) flushEmpty(ctx context.Context, info FlushInfo) error {
+	switch c.emptyFlush {
+	case EmptyFlushHeartbeat:
+		buf := c.bufferPool.Get().(*bytes.Buffer)
+		buf.Reset()
+
+		c.formatHeartbeat(buf)
+
+		err := c.post(ctx, info, buf)
+
+		c.bufferPool.Put(buf)
+
+		return err
+
+	case EmptyFlushKeepAlive:
+		return c.post(ctx, info, &bytes.Buffer{})
+	}
+
+	return ErrNoMetrics
+}
+
+func (c *Client) formatHeartbeat(buf *bytes.Buffer) {
+	name := c.heartbeat
+	if name == "" {
+		name = DefaultHeartbeatName
+	}
+
+	writeLine(buf, c.prefix+name, number{i: 1}, UnitCount)
+}
+
+// post sends a formatted payload with the transport
+func (c *Client) post(ctx context.Context, info FlushInfo, buf *bytes.Buffer) error {
+	payload := buf.Bytes()
+
+	fc := &flushContext{info: info}
+	err := c.flushTransport().Send(context.WithValue(ctx, flushContextKey{}, fc), payload)
+
+	c.flushed(info, fc.target, payload, err)
+
+	return err
+}
+
+// postBody sends one payload to target, with the given Content-Encoding if
+// it isn't empty
+func (c *Client) postBody(ctx context.Context, info FlushInfo, target string, buf *bytes.Buffer, encoding string) error {
 	// The request will only accept a ReadCloser for the body - this method
 	// fakes it by adding a nop close method.
 	body := ioutil.NopCloser(buf)
 
-	// Send the string on to the server
-	resp, err := c.http.Post(c.hostURL, "text/plain", body)
+	req, err := http.NewRequestWithContext(ctx, "POST", target, body)
+	if err != nil {
+		c.warnf(info, "http request - %v", err)
+		return err
+	}
 
-	c.bufferPool.Put(buf)
+	req.Header.Set("Content-Type", "text/plain")
+	if encoding != "" {
+		req.Header.Set("Content-Encoding", encoding)
+	}
+
+	info.Window.setHeaders(req.Header)
+	c.setHeaders(req.Header)
+
+	// Send the string on to the server
+	resp, err := c.http.Do(req)
 
 	if err != nil {
-		c.logger.Println("http client - ", err)
+		c.warnf(info, "http client - %v", err)
 		return err
 	}
 
+	resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnsupportedMediaType && encoding != "" {
+		return errUnsupportedEncoding
+	}
+
 	if resp.StatusCode > 299 {
-		c.logger.Println("status code above 200 received - ", resp.StatusCode)
-		// Could just drop the data here - not much point sending it on
-		// but we should probably tweak the interval
+		c.warnf(info, "status code above 200 received - %d", resp.StatusCode)
+		// WithAdaptiveInterval backs off if this keeps happening
 
-		return fmt.Errorf("Non-success HTTP Status Code (%d)", resp.StatusCode)
+		return &StatusError{StatusCode: resp.StatusCode}
 	}
 
 	return nil
 }
 
+// handleError passes a flush error on to the error handler, if one is set.
+// Empty windows are only reported when EmptyFlushError is configured.
+func (c *Client) handleError(err error) {
+	if err == nil || c.errorHandler == nil {
+		return
+	}
+
+	if errors.Is(err, ErrNoMetrics) && c.emptyFlush != EmptyFlushError {
+		return
+	}
+
+	c.errorHandler(err)
+}
+
 func (c *Client) flushInputChannel() {
 	for {
 		select {
-		case metric := <-c.input:
-			c.handleMetricWithValue(metric)
+		case metric, ok := <-c.input:
+			if !ok {
+				return
+			}
+
+			c.handleInput(metric)
 		default:
 			return
 		}
@@ -195,6 +946,8 @@ func (c *Client) flushInputChannel() {
 
 // Reset resets the client map to nil after data has been sent
 func (c *Client) Reset() {
+	c = c.root()
+
 	for k := range c.metrics {
 		delete(c.metrics, k)
 	}
@@ -202,55 +955,178 @@ func (c *Client) Reset() {
 
 func (c *Client) handleMetricWithValue(metric MetricWithAmount) {
 	// Protect c.Metrics!
+	var traced []TraceEvent
+
+	var err error
+
 	c.m.Lock()
-	defer c.m.Unlock()
+	if c.admit(metric.Metric) {
+		err = c.aggregate(metric)
+	}
+	if c.tracingEnabled() {
+		traced = append(traced, c.traceEvent(metric, err))
+	}
+	c.m.Unlock()
+
+	// Report outside the lock in case the handler records metrics itself
+	c.handleError(err)
+
+	if traced != nil {
+		c.emitTrace(traced)
+	}
+}
+
+// aggregate folds a sample into the metrics map - c.m must be held.
+// Totals that would overflow are clamped and reported as an error.
+func (c *Client) aggregate(metric MetricWithAmount) error {
+	return aggregateInto(c.metrics, metric)
+}
+
+// aggregateInto folds a sample into any metrics map
+func aggregateInto(metrics map[Metric]Value, metric MetricWithAmount) error {
+	var overflow bool
+
+	if metric.Amount.IsFloat && (math.IsNaN(metric.Amount.Float) || math.IsInf(metric.Amount.Float, 0)) {
+		return &MetricError{Name: metric.name, Err: ErrInvalidValue}
+	}
+
+	if existing, ok := metrics[metric.Metric]; ok && existing.action() != metric.Action {
+		return &MetricError{Name: metric.name, Err: ErrActionConflict}
+	}
 
 	v := Value{}
 
 	switch metric.Action {
-	case "sum":
-		
+	case ActionSum:
+
 		// Check if we have the metric already
-		if _, ok := c.metrics[metric.Metric]; ok {
-			c.metrics[metric.Metric].Sum.Value = metric.Amount.Value + c.metrics[metric.Metric].Sum.Value
+		if existing, ok := metrics[metric.Metric]; ok {
+			overflow = existing.Sum.add(metric.Amount)
 		} else {
-			v.Sum = &Sum{
-				Value: metric.Amount.Value,
-			}
+			v.Sum = &Sum{}
+			v.Sum.add(metric.Amount)
+
+			metrics[metric.Metric] = v
+		}
+
+	case ActionLast:
+		last := &Last{Delta: true}
+
+		if existing, ok := metrics[metric.Metric]; ok {
+			last = existing.Last
+		} else {
+			v.Last = last
+			metrics[metric.Metric] = v
+		}
+
+		overflow = last.update(metric.Amount)
 
-			c.metrics[metric.Metric] = v
+	case ActionRatio:
+		ratio := &Ratio{}
+
+		if existing, ok := metrics[metric.Metric]; ok {
+			ratio = existing.Ratio
+		} else {
+			v.Ratio = ratio
+			metrics[metric.Metric] = v
+		}
+
+		var denOverflow bool
+
+		ratio.Numerator, overflow = addInt64(ratio.Numerator, int64(metric.Amount.Value))
+		ratio.Denominator, denOverflow = addInt64(ratio.Denominator, int64(metric.Amount.Denominator))
+		overflow = overflow || denOverflow
+
+	case ActionAvg:
+		avg := &Average{}
+
+		if existing, ok := metrics[metric.Metric]; ok {
+			avg = existing.Avg
+		} else {
+			v.Avg = avg
+			metrics[metric.Metric] = v
 		}
-		
-	case "avg":
-		var avgResult int
-		var newCount int
-
-		if _, ok := c.metrics[metric.Metric]; ok {
-			newCount = c.metrics[metric.Metric].Avg.Count + 1
-			avgResult = (c.metrics[metric.Metric].Avg.Avg*c.metrics[metric.Metric].Avg.Count + metric.Amount.Value) / newCount
-
-			c.metrics[metric.Metric].Avg.Total += metric.Amount.Value
-			c.metrics[metric.Metric].Avg.Count = newCount
-			c.metrics[metric.Metric].Avg.Avg = avgResult
+
+		overflow = avg.add(metric.Amount)
+
+	case ActionUnique:
+		if existing, ok := metrics[metric.Metric]; ok {
+			existing.Set.add(metric.Amount.Member)
 		} else {
-			v.Avg = &Average{}
+			v.Set = &Set{}
+			v.Set.add(metric.Amount.Member)
 
-			newCount = v.Avg.Count + 1
-			avgResult = (v.Avg.Avg*v.Avg.Count + metric.Amount.Value) / newCount
+			metrics[metric.Metric] = v
+		}
 
-			v.Avg.Total += metric.Amount.Value
-			v.Avg.Count = newCount
-			v.Avg.Avg = avgResult
+	case ActionHistogram:
+		if existing, ok := metrics[metric.Metric]; ok {
+			existing.Hist.add(metric.Amount)
+		} else {
+			v.Hist = &Histogram{}
+			v.Hist.add(metric.Amount)
 
-			c.metrics[metric.Metric] = v
+			metrics[metric.Metric] = v
 		}
+
+	case ActionDigest:
+		if existing, ok := metrics[metric.Metric]; ok {
+			existing.Digest.add(metric.Amount)
+		} else {
+			v.Digest = NewTDigest(DefaultDigestCompression)
+			v.Digest.add(metric.Amount)
+
+			metrics[metric.Metric] = v
+		}
+	}
+
+	if overflow {
+		return &MetricError{Name: metric.name, Err: ErrOverflow}
+	}
+
+	return nil
+}
+
+// addInt64 adds two values, clamping to the int64 range on overflow
+func addInt64(a, b int64) (int64, bool) {
+	if b > 0 && a > math.MaxInt64-b {
+		return math.MaxInt64, true
+	}
+
+	if b < 0 && a < math.MinInt64-b {
+		return math.MinInt64, true
 	}
 
+	return a + b, false
+}
+
+// addFloat64 adds two values, clamping to ±MaxFloat64 rather than
+// reaching an infinity, which can't be sent
+func addFloat64(a, b float64) (float64, bool) {
+	sum := a + b
+
+	switch {
+	case math.IsInf(sum, 1):
+		return math.MaxFloat64, true
+	case math.IsInf(sum, -1):
+		return -math.MaxFloat64, true
+	}
+
+	return sum, false
 }
 
 func (c *Client) inputProcessor() {
-	for metric := range c.input {
-		c.handleMetricWithValue(metric)
+	for {
+		select {
+		case metric, ok := <-c.input:
+			if !ok {
+				return
+			}
+
+			c.handleInput(metric)
+		case <-c.done:
+			return
+		}
 	}
 }
 
@@ -258,9 +1134,14 @@ func (c *Client) inputProcessor() {
 // The chan is returned from this func
 func (c *Client) sender() (err error) {
 
-	//currentInt := c.interval // for the backoff we'll need to use the initial value as a reset
+	c.goroutines.spawn("sender", func() {
+
+		windows := c.windows(time.Now())
+		warm := c.quietStart == 0
 
-	go func(c *Client) {
+		if !warm {
+			c.quiet(windows, time.Now())
+		}
 
 		for {
 
@@ -272,65 +1153,427 @@ func (c *Client) sender() (err error) {
 				// Make sure we don't have things left on the channel that aren't in the metrics map
 				c.flushInputChannel()
 
-				c.logger.Println("Flushing last remaining metrics because of shutdown")
-				c.flush()
-				c.logger.Println("Metrics flushed")
+				if c.Enabled() {
+					c.logger.Println("Flushing last remaining metrics because of shutdown")
+
+					err := c.flushContext(c.stopCtx)
+					c.handleError(err)
+
+					if !errors.Is(err, ErrNoMetrics) {
+						c.stopErr = err
+					}
+
+					c.logger.Println("Metrics flushed")
+				}
 
-				c.stopped <- true
+				close(c.stopped)
 
-			case <-time.After(c.interval):
+				return
 
-				c.flush()
+			case <-time.After(time.Until(nextDue(windows))):
+
+				if !warm {
+					c.warmUpIfAsked()
+					warm = true
+				}
+
+				now := time.Now()
+
+				for _, w := range windows {
+					if !now.Before(w.next) {
+						var err error
+						if c.Enabled() {
+							err = c.flushWindow(w)
+							c.handleError(err)
+						}
+
+						w.next = now.Add(c.nextInterval(w, err))
+					}
+				}
 			}
 
 		} // for
 
-	}(c)
+	})
 
 	return nil
 
 }
 
-// Stop nicely stops the client
+// Stop nicely stops the client, waiting for the final flush however long
+// it takes. It is safe to call more than once, and calls made while the
+// client is stopping wait for it to finish.
 func (c *Client) Stop() {
-	c.logger.Println("Stopping bucky client")
-	c.stop <- true
+	c.StopContext(context.Background())
+}
+
+// StopWithResult stops the client like Stop and returns the error of the
+// final flush, so shutdown code can tell when the last metrics were lost.
+// It is nil when the flush succeeded or there was nothing to send, and
+// every call returns the same result.
+func (c *Client) StopWithResult() error {
+	return c.StopContext(context.Background())
+}
+
+// StopContext stops the client like StopWithResult, but only waits for the
+// final flush until ctx is done. The first call's ctx also bounds the
+// flush itself, so metrics it couldn't send in time are kept as for any
+// failed flush and go nowhere once the client has stopped. It returns
+// ctx's error if the client didn't stop in time.
+func (c *Client) StopContext(ctx context.Context) error {
+	c = c.root()
+
+	first := false
+
+	c.stopOnce.Do(func() {
+		first = true
+
+		atomic.StoreInt32(&c.closed, 1)
+		if c.stopping != nil {
+			close(c.stopping)
+		}
+
+		c.logger.Println("Stopping bucky client")
+
+		// Read by the sender once it is told to stop
+		c.stopCtx = ctx
+		c.stop <- true
+	})
 
 	// Wait until it actually stops
-	<-c.stopped
-	c.logger.Println("Client stopped")
+	select {
+	case <-c.stopped:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	if first {
+		c.logger.Println("Client stopped")
+	}
+
+	return c.stopErr
+}
+
+// Unit is the suffix written after a value on the wire
+type Unit string
+
+const (
+	// UnitCount is used for counters
+	UnitCount Unit = "c"
+
+	// UnitMillisecond is used for timers
+	UnitMillisecond Unit = "ms"
+
+	// UnitGauge is used for gauges
+	UnitGauge Unit = "g"
+
+	// UnitSet is used for the number of unique values seen
+	UnitSet Unit = "s"
+)
+
+// Valid reports whether the unit is one the client knows how to flush
+func (u Unit) Valid() bool {
+	switch u {
+	case UnitCount, UnitMillisecond, UnitGauge, UnitSet:
+		return true
+	}
+
+	return false
+}
+
+// Action controls how samples for the same metric are aggregated
+// within an interval. A metric, its name, unit and tags, has one action
+// per interval: the first sample decides, and samples with another action
+// are dropped and reported as a MetricError with ErrActionConflict until
+// the next flush. Timer and AverageTimer on the same name conflict, for
+// example, while Timer and Gauge don't as their units differ.
+type Action string
+
+const (
+	// ActionSum adds every sample together
+	ActionSum Action = "sum"
+
+	// ActionAvg keeps a running average of the samples
+	ActionAvg Action = "avg"
+
+	// ActionLast keeps the most recent sample
+	ActionLast Action = "last"
+
+	// ActionRatio sums numerators and denominators to send a percentage
+	ActionRatio Action = "ratio"
+
+	// ActionHistogram keeps the samples to send percentiles
+	ActionHistogram Action = "histogram"
+
+	// ActionUnique keeps the distinct members to send how many there were
+	ActionUnique Action = "unique"
+
+	// ActionDigest keeps a t-digest of the samples to send percentiles
+	ActionDigest Action = "digest"
+)
+
+// Valid reports whether the action is one the client knows how to aggregate
+func (a Action) Valid() bool {
+	switch a {
+	case ActionSum, ActionAvg, ActionLast, ActionRatio, ActionHistogram, ActionUnique, ActionDigest:
+		return true
+	}
+
+	return false
 }
 
 // Metric represents a metric to be sent over the wire
 type Metric struct {
 	name string
-	unit string
+	unit Unit
+	tags string // canonical, see canonicalTags
 }
 
+// MetricWithAmount is a single sample waiting to be aggregated
 type MetricWithAmount struct {
 	Metric
 	Amount
-	Action string
+	Action Action
 }
 
 // Value holds the different types of values
 type Value struct {
-	Avg *Average
-	Sum *Sum
+	Avg    *Average
+	Sum    *Sum
+	Last   *Last
+	Ratio  *Ratio
+	Hist   *Histogram
+	Set    *Set
+	Digest *TDigest
+
+	rollup bool // Added by WithTagRollups rather than recorded
+}
+
+// action returns the action the value aggregates with
+func (v Value) action() Action {
+	switch {
+	case v.Sum != nil:
+		return ActionSum
+	case v.Avg != nil:
+		return ActionAvg
+	case v.Last != nil:
+		return ActionLast
+	case v.Ratio != nil:
+		return ActionRatio
+	case v.Hist != nil:
+		return ActionHistogram
+	case v.Set != nil:
+		return ActionUnique
+	case v.Digest != nil:
+		return ActionDigest
+	}
+
+	return ""
+}
+
+// eachLine calls fn with every line the value sends for an interval.
+// Histograms send several lines, everything else at most one.
+func (v Value) eachLine(name string, fn func(name string, value number)) {
+	if v.Hist != nil {
+		v.Hist.eachLine(name, fn)
+		return
+	}
+
+	if v.Digest != nil {
+		v.Digest.eachLine(name, fn)
+		return
+	}
+
+	value, ok := v.flushValue()
+	if !ok {
+		return
+	}
+
+	// A leading minus makes a gauge an adjustment, so a negative value is
+	// sent as statsd does, by setting it to zero and adjusting from there
+	if v.Last != nil && !v.Last.Delta && value.negative() {
+		fn(name, number{})
+		value.signed = true
+	}
+
+	fn(name, value)
 }
 
+// flushValue returns the value to send for an interval, if there is one
+func (v Value) flushValue() (number, bool) {
+	switch {
+	case v.Avg != nil:
+		if v.Avg.IsFloat {
+			return number{f: v.Avg.FloatTotal / float64(v.Avg.Count), isFloat: true}, true
+		}
+
+		return number{i: v.Avg.Avg}, true
+	case v.Sum != nil:
+		return number{i: v.Sum.Value, f: v.Sum.Float, isFloat: v.Sum.IsFloat}, true
+	case v.Last != nil:
+		return number{i: v.Last.Value, f: v.Last.Float, isFloat: v.Last.IsFloat, signed: v.Last.Delta}, true
+	case v.Ratio != nil:
+		if v.Ratio.Denominator == 0 {
+			return number{}, false
+		}
+
+		return number{i: v.Ratio.percent()}, true
+	case v.Set != nil:
+		return number{i: int64(len(v.Set.Members))}, true
+	}
+
+	return number{}, false
+}
+
+// Amount is the value of a single sample
 type Amount struct {
-	Value int
+	Value       int
+	Denominator int    // Only used by ratios
+	Member      string // Only used by sets
+	Delta       bool   // Only used by gauges, see GaugeDelta
+
+	// Float is used instead of Value when IsFloat is set. Ratios don't
+	// support fractional samples.
+	Float   float64
+	IsFloat bool
 }
 
-// Average holds average data for a metric
+// float returns the sample as a float64, whichever way it was recorded
+func (a Amount) float() float64 {
+	if a.IsFloat {
+		return a.Float
+	}
+
+	return float64(a.Value)
+}
+
+// Average holds average data for a metric. Once a fractional sample has
+// been added IsFloat is set and FloatTotal holds the whole total instead
+// of Total.
 type Average struct {
-	Count int
-	Total int
-	Avg   int
+	Count int64
+	Total int64
+	Avg   int64
+
+	FloatTotal float64
+	IsFloat    bool
 }
 
-// Sum holds sum data for a metric
+// add includes a sample in the average, reporting whether the total overflowed
+func (a *Average) add(amount Amount) (overflow bool) {
+	a.Count++
+
+	if amount.IsFloat && !a.IsFloat {
+		a.FloatTotal = float64(a.Total)
+		a.IsFloat = true
+	}
+
+	if a.IsFloat {
+		a.FloatTotal, overflow = addFloat64(a.FloatTotal, amount.float())
+		return overflow
+	}
+
+	a.Total, overflow = addInt64(a.Total, int64(amount.Value))
+	a.Avg = a.Total / a.Count
+
+	return overflow
+}
+
+// Sum holds sum data for a metric. Once a fractional sample has been added
+// IsFloat is set and Float holds the whole sum instead of Value.
 type Sum struct {
-	Value int
+	Value int64
+
+	Float   float64
+	IsFloat bool
+}
+
+// add includes a sample in the sum, reporting whether it overflowed
+func (s *Sum) add(amount Amount) (overflow bool) {
+	if amount.IsFloat && !s.IsFloat {
+		s.Float = float64(s.Value)
+		s.IsFloat = true
+	}
+
+	if s.IsFloat {
+		s.Float, overflow = addFloat64(s.Float, amount.float())
+		return overflow
+	}
+
+	s.Value, overflow = addInt64(s.Value, int64(amount.Value))
+	return overflow
+}
+
+// Last holds the most recent value of a gauge. IsFloat says whether
+// the value was fractional, in which case it is in Float. Delta is set
+// while the gauge has only been adjusted, so the value is the total
+// adjustment rather than the gauge itself.
+type Last struct {
+	Value int64
+
+	Float   float64
+	IsFloat bool
+
+	Delta bool
+}
+
+// update includes a sample, which replaces the value unless it is an
+// adjustment, reporting whether an adjustment overflowed
+func (l *Last) update(amount Amount) (overflow bool) {
+	return l.apply(Last{Value: int64(amount.Value), Float: amount.Float, IsFloat: amount.IsFloat, Delta: amount.Delta})
+}
+
+// apply updates the gauge with a later one, which replaces it unless it
+// is an adjustment
+func (l *Last) apply(o Last) (overflow bool) {
+	if !o.Delta {
+		*l = o
+		return false
+	}
+
+	sum := Sum{Value: l.Value, Float: l.Float, IsFloat: l.IsFloat}
+	overflow = sum.merge(Sum{Value: o.Value, Float: o.Float, IsFloat: o.IsFloat})
+	l.Value, l.Float, l.IsFloat = sum.Value, sum.Float, sum.IsFloat
+
+	return overflow
+}
+
+// Set holds the distinct members seen in an interval
+type Set struct {
+	Members map[string]struct{}
+}
+
+// add includes a member, which is only counted once however often it is added
+func (s *Set) add(member string) {
+	if s.Members == nil {
+		s.Members = make(map[string]struct{})
+	}
+
+	s.Members[member] = struct{}{}
+}
+
+// Ratio holds the totals of a percentage gauge
+type Ratio struct {
+	Numerator   int64
+	Denominator int64
+}
+
+// percent returns the numerator as a percentage of the denominator, which
+// must not be zero. Numerators too big to multiply by 100 are worked out
+// in float64 and clamped to the int64 range.
+func (r Ratio) percent() int64 {
+	if r.Numerator <= math.MaxInt64/100 && r.Numerator >= math.MinInt64/100 {
+		return 100 * r.Numerator / r.Denominator
+	}
+
+	p := 100 * float64(r.Numerator) / float64(r.Denominator)
+
+	switch {
+	case p >= math.MaxInt64:
+		return math.MaxInt64
+	case p <= math.MinInt64:
+		return math.MinInt64
+	}
+
+	return int64(p)
 }
@@ -2,6 +2,11 @@ package buckyclient
 
 import (
 	"bytes"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
 	"strconv"
 	"testing"
 )
@@ -32,3 +37,60 @@ func BenchmarkFormatMetricsForFlush(b *testing.B) {
 		client.bufferPool.Put(buf)
 	}
 }
+
+// BenchmarkRecordDuringFlush records from every P while another goroutine
+// flushes as fast as it can, showing how long recording waits for flushes
+func BenchmarkRecordDuringFlush(b *testing.B) {
+	for _, n := range []int{100, 10000} {
+		b.Run(strconv.Itoa(n), func(b *testing.B) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				io.Copy(ioutil.Discard, r.Body)
+			}))
+			defer srv.Close()
+
+			client := &Client{
+				hostURL:    srv.URL,
+				http:       &http.Client{},
+				logger:     log.New(ioutil.Discard, "", 0),
+				metrics:    make(map[Metric]Value),
+				bufferPool: newBufferPool(),
+			}
+
+			names := make([]Metric, n)
+			for i := range names {
+				names[i] = Metric{name: "test_" + strconv.Itoa(i), unit: UnitCount}
+			}
+
+			stop := make(chan struct{})
+			flushed := make(chan struct{})
+
+			go func() {
+				defer close(flushed)
+
+				for {
+					select {
+					case <-stop:
+						return
+					default:
+						client.flush()
+					}
+				}
+			}()
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+				for pb.Next() {
+					client.handleMetricWithValue(MetricWithAmount{names[i%n], Amount{Value: 1}, ActionSum})
+					i++
+				}
+			})
+
+			b.StopTimer()
+			close(stop)
+			<-flushed
+		})
+	}
+}
@@ -1,16 +1,18 @@
 package buckyclient
 
 import (
-	// "errors"
-
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
+	"math"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strconv"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -38,21 +40,46 @@ func mockBuckyServer(code int) *httptest.Server {
 
 func TestClient_Client_NewClient(t *testing.T) {
 	cl, err := NewClient("", 20)
-	defer func() {
-		close(cl.stop)
-		close(cl.stopped)
-		close(cl.input)
-	}()
+	cl.SetLogger(log.New(ioutil.Discard, "", 0))
+	defer cl.Stop()
 
 	assert.NoError(t, err)
-	assert.Equal(t, cl.interval, 60*time.Second)
+	assert.Equal(t, cl.interval, 20*time.Second)
+}
+
+func TestClient_Client_NewClient_DefaultInterval(t *testing.T) {
+	cl, err := NewClient("", 0)
+	cl.SetLogger(log.New(ioutil.Discard, "", 0))
+	defer cl.Stop()
+
+	assert.NoError(t, err)
+	assert.Equal(t, cl.interval, DefaultInterval)
+}
+
+func TestClient_Client_NewClient_WithInterval(t *testing.T) {
+	cl, err := NewClient("", 0, WithInterval(250*time.Millisecond))
+	cl.SetLogger(log.New(ioutil.Discard, "", 0))
+	defer cl.Stop()
+
+	assert.NoError(t, err)
+	assert.Equal(t, cl.interval, 250*time.Millisecond)
+
+	_, err = NewClient("", 0, WithInterval(0))
+	assert.True(t, errors.Is(err, ErrInvalidOption))
 }
 
 func TestClient_Client_NewClient_Error(t *testing.T) {
-	cl, err := NewClient("", 1<<48) // Cause an integer overflow in time.ParseDuration
+	// Only an int of 64 bits can hold too many seconds for a time.Duration
+	if strconv.IntSize == 64 {
+		tooLong := int64(1 << 48)
 
-	assert.Error(t, err)
-	assert.Nil(t, cl)
+		cl, err := NewClient("", int(tooLong))
+		assert.Equal(t, ErrInvalidInterval, err)
+		assert.Nil(t, cl)
+	}
+
+	_, err := NewClient("", -1)
+	assert.Equal(t, ErrInvalidInterval, err)
 }
 
 func TestClient_Client_Count(t *testing.T) {
@@ -77,17 +104,97 @@ func TestClient_Client_Count(t *testing.T) {
 
 	cl.Count(name, value)
 
-	time.Sleep(time.Millisecond * 20) // Give the goroutine a chance to run
-
 	assert.Equal(t, len(cl.input), 1)
 
 	metric := <-cl.input
 
 	assert.Equal(t, metric.name, name)
-	assert.Equal(t, metric.unit, "c")
+	assert.Equal(t, metric.unit, UnitCount)
 	assert.Equal(t, metric.Amount.Value, value)
 }
 
+func TestClient_Client_IncrDecr(t *testing.T) {
+	c := newBatchingClient(1)
+
+	c.Incr("in_flight")
+	c.Incr("in_flight")
+	c.Decr("in_flight")
+
+	c.Incr("balanced", Tag{Key: "pool", Value: "a"})
+	c.Decr("balanced", Tag{Key: "pool", Value: "a"})
+
+	c.Decr("draining")
+	c.Count("draining", -2)
+
+	buf := &bytes.Buffer{}
+	c.formatMetricsForFlush(buf)
+
+	// An interval that nets to zero still sends the counter
+	assert.ElementsMatch(t, []string{"in_flight:1|c", "balanced:0|c|#pool:a", "draining:-3|c"}, splitLines(buf.String()))
+}
+
+func TestClient_Client_handleMetricWithValue_NegativeOverflow(t *testing.T) {
+	var got error
+
+	cl := &Client{
+		metrics:      make(map[Metric]Value),
+		errorHandler: func(err error) { got = err },
+	}
+
+	metric := Metric{name: "m.et.ric", unit: UnitCount}
+
+	// A single sample is an int, so start from a total near the limit
+	cl.metrics[metric] = Value{Sum: &Sum{Value: math.MinInt64 + 1}}
+	cl.handleMetricWithValue(MetricWithAmount{metric, Amount{Value: -1}, ActionSum})
+	assert.NoError(t, got)
+
+	cl.handleMetricWithValue(MetricWithAmount{metric, Amount{Value: -1}, ActionSum})
+
+	assert.True(t, errors.Is(got, ErrOverflow))
+	assert.Equal(t, cl.metrics[metric].Sum, &Sum{Value: math.MinInt64})
+}
+
+func TestClient_Client_GaugeDelta(t *testing.T) {
+	c := newBatchingClient(1)
+
+	// Only adjusted, so the total adjustment is sent
+	c.GaugeDelta("in_flight", 5)
+	c.GaugeDelta("in_flight", -3)
+	c.GaugeDelta("draining", -2)
+	c.GaugeDeltaF("load", 0.5)
+
+	// Set, so later adjustments apply to the value...
+	c.GaugeDelta("queue", 4)
+	c.Gauge("queue", 10)
+	c.GaugeDelta("queue", -3)
+
+	// ...and a later set replaces them
+	c.GaugeDelta("workers", 2)
+	c.Gauge("workers", 8)
+
+	// A negative value is set statsd style, since -4 would be an adjustment
+	c.Gauge("balance", 1)
+	c.GaugeDelta("balance", -5)
+
+	buf := &bytes.Buffer{}
+	c.formatMetricsForFlush(buf)
+
+	lines := splitLines(buf.String())
+	assert.ElementsMatch(t, []string{"in_flight:+2|g", "draining:-2|g", "load:+0.5|g", "queue:7|g", "workers:8|g", "balance:0|g", "balance:-4|g"}, lines)
+	assert.Less(t, indexOf(lines, "balance:0|g"), indexOf(lines, "balance:-4|g"))
+}
+
+// indexOf returns where s is in lines, or -1
+func indexOf(lines []string, s string) int {
+	for i, line := range lines {
+		if line == s {
+			return i
+		}
+	}
+
+	return -1
+}
+
 func TestClient_Client_Timer(t *testing.T) {
 	name := "myapp.facet"
 	value := 1
@@ -110,14 +217,12 @@ func TestClient_Client_Timer(t *testing.T) {
 
 	cl.Timer(name, value)
 
-	time.Sleep(time.Millisecond * 20) // Give the goroutine a chance to run
-
 	assert.Equal(t, len(cl.input), 1)
 
 	metric := <-cl.input
 
 	assert.Equal(t, metric.name, name)
-	assert.Equal(t, metric.unit, "ms")
+	assert.Equal(t, metric.unit, UnitMillisecond)
 	assert.Equal(t, metric.Amount.Value, value)
 }
 
@@ -145,12 +250,14 @@ func TestClient_Client_AverageTimer(t *testing.T) {
 
 	cl.AverageTimer(name, 3)
 
-	time.Sleep(time.Millisecond * 20)
-
+	// Samples are handed over in the order they were recorded
 	metric := <-cl.input
 
 	assert.Equal(t, metric.name, name)
-	assert.Equal(t, metric.unit, "ms")
+	assert.Equal(t, metric.unit, UnitMillisecond)
+	assert.Equal(t, metric.Amount.Value, value)
+
+	metric = <-cl.input
 	assert.Equal(t, metric.Amount.Value, 3)
 }
 
@@ -418,3 +525,238 @@ func TestClient_Client_Stop(t *testing.T) {
 	assert.Contains(t, buf.String(), "Stopping bucky client")
 	assert.Contains(t, buf.String(), "Client stopped")
 }
+
+func TestClient_Client_StopWithResult(t *testing.T) {
+	var status int32
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(int(atomic.LoadInt32(&status)))
+	}))
+	defer ts.Close()
+
+	for _, tc := range []struct {
+		name    string
+		status  int32
+		record  bool
+		wantErr bool
+	}{
+		{"failed", http.StatusInternalServerError, true, true},
+		{"flushed", http.StatusOK, true, false},
+		{"nothing to flush", http.StatusInternalServerError, false, false},
+	} {
+		atomic.StoreInt32(&status, tc.status)
+
+		cl, err := NewClient(ts.URL, 0)
+		assert.NoError(t, err)
+		cl.SetLogger(log.New(ioutil.Discard, "", 0))
+
+		if tc.record {
+			cl.Count("hits", 1)
+
+			// Samples are handed over in the background
+			assert.Eventually(t, func() bool { return cl.PendingLines() == 1 }, time.Second, time.Millisecond)
+		}
+
+		err = cl.StopWithResult()
+		assert.Equal(t, tc.wantErr, err != nil, "%s: %v", tc.name, err)
+		assert.Equal(t, err, cl.StopWithResult(), tc.name)
+
+		cl.Close()
+	}
+}
+
+func TestClient_Client_Record(t *testing.T) {
+	cl := &Client{
+		input: make(chan MetricWithAmount, 10),
+	}
+	defer close(cl.input)
+
+	err := cl.Record("myapp.facet", 4, UnitMillisecond, ActionAvg)
+	assert.NoError(t, err)
+
+	metric := <-cl.input
+
+	assert.Equal(t, metric.name, "myapp.facet")
+	assert.Equal(t, metric.unit, UnitMillisecond)
+	assert.Equal(t, metric.Action, ActionAvg)
+	assert.Equal(t, metric.Amount.Value, 4)
+}
+
+func TestClient_Client_Record_Invalid(t *testing.T) {
+	cl := &Client{
+		input: make(chan MetricWithAmount, 10),
+	}
+	defer close(cl.input)
+
+	assert.Equal(t, ErrInvalidUnit, cl.Record("myapp.facet", 1, Unit("h"), ActionSum))
+	assert.Equal(t, ErrInvalidAction, cl.Record("myapp.facet", 1, UnitCount, Action("max")))
+
+	time.Sleep(time.Millisecond * 20)
+
+	assert.Equal(t, len(cl.input), 0)
+}
+
+func TestClient_Client_handleMetricWithValue_Overflow(t *testing.T) {
+	var got error
+
+	cl := &Client{
+		metrics:      make(map[Metric]Value),
+		errorHandler: func(err error) { got = err },
+	}
+
+	metric := Metric{name: "m.et.ric", unit: UnitCount}
+
+	// A single sample is an int, so start from a total at the limit
+	cl.metrics[metric] = Value{Sum: &Sum{Value: math.MaxInt64}}
+	cl.handleMetricWithValue(MetricWithAmount{metric, Amount{Value: 0}, ActionSum})
+	assert.NoError(t, got)
+
+	cl.handleMetricWithValue(MetricWithAmount{metric, Amount{Value: 1}, ActionSum})
+
+	assert.True(t, errors.Is(got, ErrOverflow))
+	assert.Contains(t, got.Error(), "m.et.ric")
+	assert.Equal(t, cl.metrics[metric].Sum, &Sum{Value: math.MaxInt64})
+}
+
+func TestClient_Client_handleMetricWithValue_AverageOverflow(t *testing.T) {
+	var got error
+
+	cl := &Client{
+		metrics:      make(map[Metric]Value),
+		errorHandler: func(err error) { got = err },
+	}
+
+	metric := Metric{name: "m.et.ric", unit: UnitMillisecond}
+
+	cl.metrics[metric] = Value{Avg: &Average{Count: 1, Total: math.MinInt64, Avg: math.MinInt64}}
+	cl.handleMetricWithValue(MetricWithAmount{metric, Amount{Value: -1}, ActionAvg})
+
+	assert.True(t, errors.Is(got, ErrOverflow))
+	assert.Equal(t, cl.metrics[metric].Avg.Total, int64(math.MinInt64))
+	assert.Equal(t, cl.metrics[metric].Avg.Count, int64(2))
+}
+
+func TestClient_Client_handleMetricWithValue_FloatOverflow(t *testing.T) {
+	var errs []error
+
+	cl := &Client{
+		metrics:      make(map[Metric]Value),
+		errorHandler: func(err error) { errs = append(errs, err) },
+	}
+
+	sum := Metric{name: "sum", unit: UnitCount}
+	avg := Metric{name: "avg", unit: UnitMillisecond}
+	gauge := Metric{name: "gauge", unit: UnitGauge}
+
+	for i := 0; i < 2; i++ {
+		cl.handleMetricWithValue(MetricWithAmount{sum, Amount{Float: math.MaxFloat64, IsFloat: true}, ActionSum})
+		cl.handleMetricWithValue(MetricWithAmount{avg, Amount{Float: -math.MaxFloat64, IsFloat: true}, ActionAvg})
+		cl.handleMetricWithValue(MetricWithAmount{gauge, Amount{Float: math.MaxFloat64, IsFloat: true, Delta: true}, ActionLast})
+	}
+
+	assert.Len(t, errs, 3)
+	for _, err := range errs {
+		assert.ErrorIs(t, err, ErrOverflow)
+	}
+
+	assert.Equal(t, math.MaxFloat64, cl.metrics[sum].Sum.Float)
+	assert.Equal(t, -math.MaxFloat64, cl.metrics[avg].Avg.FloatTotal)
+	assert.Equal(t, math.MaxFloat64, cl.metrics[gauge].Last.Float)
+
+	// The clamped totals are still lines the repo can parse
+	for _, m := range []Metric{sum, avg, gauge} {
+		v, _ := cl.metrics[m].flushValue()
+		_, _, _, err := ParseLineFloat(fmt.Sprintf("%s:%s|%s", m.name, v.append(nil), m.unit))
+		assert.NoError(t, err)
+	}
+}
+
+func TestClient_Ratio_percent(t *testing.T) {
+	tests := []struct {
+		ratio Ratio
+		want  int64
+	}{
+		{Ratio{Numerator: 1, Denominator: 4}, 25},
+		{Ratio{Numerator: -3, Denominator: 4}, -75},
+		{Ratio{Numerator: math.MaxInt64, Denominator: math.MaxInt64}, 100},
+		{Ratio{Numerator: math.MaxInt64 / 2, Denominator: math.MaxInt64}, 50},
+		{Ratio{Numerator: math.MinInt64, Denominator: math.MaxInt64}, -100},
+		{Ratio{Numerator: math.MaxInt64, Denominator: 1}, math.MaxInt64},
+		{Ratio{Numerator: math.MinInt64, Denominator: 1}, math.MinInt64},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, tt.ratio.percent(), "%+v", tt.ratio)
+	}
+}
+
+func TestClient_Client_takeMetrics(t *testing.T) {
+	c := &Client{metrics: map[Metric]Value{
+		{name: "a", unit: UnitCount}:       {Sum: &Sum{Value: 1}},
+		{name: "b", unit: UnitMillisecond}: {Sum: &Sum{Value: 2}},
+	}}
+
+	// Only counters
+	taken := c.takeMetrics(func(u Unit) bool { return u == UnitCount }, false)
+	assert.Equal(t, map[Metric]Value{{name: "a", unit: UnitCount}: {Sum: &Sum{Value: 1}}}, taken)
+	assert.Len(t, c.metrics, 1)
+
+	// Everything, which swaps the map
+	taken = c.takeMetrics(func(Unit) bool { return true }, true)
+	assert.Len(t, taken, 1)
+	assert.Empty(t, c.metrics)
+
+	// Recording after the swap doesn't touch what was taken
+	c.metrics[Metric{name: "c", unit: UnitCount}] = Value{}
+	assert.Len(t, taken, 1)
+}
+
+func TestClient_aggregateInto_ActionConflict(t *testing.T) {
+	actions := []Action{ActionSum, ActionAvg, ActionLast, ActionRatio, ActionHistogram, ActionUnique, ActionDigest}
+
+	for _, first := range actions {
+		for _, second := range actions {
+			metrics := make(map[Metric]Value)
+			m := Metric{name: "mixed", unit: UnitMillisecond}
+
+			assert.NoError(t, aggregateInto(metrics, MetricWithAmount{m, Amount{Value: 1, Denominator: 1, Member: "a"}, first}))
+			err := aggregateInto(metrics, MetricWithAmount{m, Amount{Value: 2, Denominator: 1, Member: "b"}, second})
+
+			if first == second {
+				assert.NoError(t, err, "%s then %s", first, second)
+				continue
+			}
+
+			assert.ErrorIs(t, err, ErrActionConflict, "%s then %s", first, second)
+			assert.Equal(t, first, metrics[m].action(), "the first action is kept")
+		}
+	}
+}
+
+func TestClient_Client_MixedActions(t *testing.T) {
+	var handled []error
+
+	c := newBatchingClient(1)
+	c.errorHandler = func(err error) { handled = append(handled, err) }
+
+	c.Timer("latency", 10)
+	c.AverageTimer("latency", 30)
+	c.Timer("latency", 5)
+	c.Gauge("latency", 7) // another unit, so another metric
+
+	b := c.Batch()
+	b.AverageTimer("latency", 1)
+	b.Submit()
+
+	buf := &bytes.Buffer{}
+	c.formatMetricsForFlush(buf)
+	assert.ElementsMatch(t, []string{"latency:15|ms", "latency:7|g"}, splitLines(buf.String()))
+
+	assert.Len(t, handled, 2)
+	for _, err := range handled {
+		var metricErr *MetricError
+		assert.True(t, errors.As(err, &metricErr))
+		assert.Equal(t, "latency", metricErr.Name)
+		assert.ErrorIs(t, err, ErrActionConflict)
+	}
+}
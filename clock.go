@@ -0,0 +1,35 @@
+package buckyclient
+
+import "time"
+
+// Clock tells the time. WithClock uses it for the timestamps the client
+// sends: window boundaries and the time in a Snapshot.
+type Clock interface {
+	Now() time.Time
+}
+
+// WithClock takes timestamps from clock instead of the system clock, for
+// containers whose wall clock can't be trusted, e.g. an NTP-disciplined
+// or hybrid logical clock. Only timestamps and EWMA rates come from it:
+// intervals, retry backoff and retry queue ages still follow the
+// process's own clock, so a clock that jumps can't make the client flush
+// early or late.
+func WithClock(clock Clock) Option {
+	return func(c *Client) error {
+		if clock == nil {
+			return invalidOption("WithClock", "clock must not be nil")
+		}
+
+		c.clock = clock
+		return nil
+	}
+}
+
+// now returns the time to stamp on what the client sends
+func (c *Client) now() time.Time {
+	if c.clock == nil {
+		return time.Now()
+	}
+
+	return c.clock.Now()
+}
@@ -0,0 +1,43 @@
+package buckyclient
+
+import (
+	"io/ioutil"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeClock returns each of times in turn, then the last one again
+type fakeClock struct {
+	times []time.Time
+}
+
+func (f *fakeClock) Now() time.Time {
+	now := f.times[0]
+	if len(f.times) > 1 {
+		f.times = f.times[1:]
+	}
+
+	return now
+}
+
+func TestClock_WithClock(t *testing.T) {
+	start := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	end := start.Add(time.Minute)
+
+	rt := &recordingTransport{}
+	cl, errs := newClient("", DefaultInterval, []Option{WithTransport(rt), WithClock(&fakeClock{times: []time.Time{start, end}})})
+	assert.Empty(t, errs)
+	cl.SetLogger(log.New(ioutil.Discard, "", 0))
+
+	cl.metrics[Metric{name: "hits", unit: UnitCount}] = Value{Sum: &Sum{Value: 1}}
+	assert.NoError(t, cl.flush())
+
+	assert.Equal(t, Window{Start: start, End: end}, rt.infos[0].Window)
+}
+
+func TestClock_WithClock_Nil(t *testing.T) {
+	assert.ErrorIs(t, WithClock(nil)(&Client{}), ErrInvalidOption)
+}
@@ -0,0 +1,225 @@
+// Command bucky-demo starts a mock bucky server, sends it synthetic
+// traffic covering every metric type and prints each payload it receives.
+// It is also a quick end-to-end check for new encoders and transports:
+//
+//	go run ./cmd/bucky-demo -duration 10s -rate 200
+//	go run ./cmd/bucky-demo -gzip
+//	go run ./cmd/bucky-demo -schema
+package main
+
+import (
+	"compress/gzip"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/matzhouse/go-bucky-client"
+)
+
+const sendPath = "/bucky/v1/send"
+
+func init() {
+	buckyclient.Describe("demo.requests", buckyclient.UnitCount, "Synthetic requests")
+	buckyclient.Describe("demo.latency", buckyclient.UnitMillisecond, "Average synthetic latency")
+	buckyclient.Describe("demo.latency_total", buckyclient.UnitMillisecond, "Total synthetic latency")
+	buckyclient.Describe("demo.latency_hist", buckyclient.UnitMillisecond, "Synthetic latency percentiles")
+	buckyclient.Describe("demo.queue_depth", buckyclient.UnitGauge, "Last synthetic queue depth")
+	buckyclient.Describe("demo.error_rate", buckyclient.UnitGauge, "Percentage of synthetic requests that failed")
+	buckyclient.Describe("demo.users", buckyclient.UnitGauge, "Estimated distinct synthetic users")
+}
+
+// config is everything that can be set with flags
+type config struct {
+	interval time.Duration
+	duration time.Duration
+	rate     int
+	users    int
+	gzip     bool
+	seed     int64
+}
+
+func main() {
+	cfg := config{}
+
+	flag.DurationVar(&cfg.interval, "interval", 2*time.Second, "how often metrics are sent")
+	flag.DurationVar(&cfg.duration, "duration", 5*time.Second, "how long to send traffic for")
+	flag.IntVar(&cfg.rate, "rate", 50, "synthetic requests per second")
+	flag.IntVar(&cfg.users, "users", 100, "number of distinct synthetic users")
+	flag.BoolVar(&cfg.gzip, "gzip", false, "have the mock server offer gzip")
+	flag.Int64Var(&cfg.seed, "seed", 1, "seed for the synthetic traffic")
+	schema := flag.Bool("schema", false, "print the metrics schema as JSON and exit")
+	flag.Parse()
+
+	if *schema {
+		if err := buckyclient.WriteSchema(os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+
+		return
+	}
+
+	if err := cfg.validate(); err != nil {
+		fmt.Fprintln(flag.CommandLine.Output(), err)
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := run(cfg, os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// validate rejects flags the traffic can't be generated with
+func (cfg config) validate() error {
+	switch {
+	case cfg.rate <= 0 || time.Duration(cfg.rate) > time.Second:
+		return fmt.Errorf("-rate must be between 1 and %d", time.Second)
+	case cfg.duration <= 0:
+		return errors.New("-duration must be positive")
+	case cfg.interval <= 0:
+		return errors.New("-interval must be positive")
+	case cfg.users <= 0:
+		return errors.New("-users must be positive")
+	}
+
+	return nil
+}
+
+// run serves a mock bucky server, sends it traffic for cfg.duration and
+// writes every payload it receives to out
+func run(cfg config, out io.Writer) error {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+
+	srv := &http.Server{Handler: mockServer(cfg.gzip, out)}
+	go srv.Serve(l)
+	defer srv.Close()
+
+	opts := []buckyclient.Option{
+		buckyclient.WithInterval(cfg.interval),
+		buckyclient.WithErrorHandler(func(err error) { log.Println(err) }),
+	}
+
+	if cfg.gzip {
+		opts = append(opts, buckyclient.WithCapabilityProbe())
+	}
+
+	bc, err := buckyclient.NewClient("http://"+l.Addr().String()+sendPath, 0, opts...)
+	if err != nil {
+		return err
+	}
+
+	bc.SetLogger(log.New(ioutil.Discard, "", 0))
+
+	generate(bc, cfg)
+
+	// Stop sends whatever is left
+	bc.Stop()
+
+	return nil
+}
+
+// generate records synthetic traffic until cfg.duration has passed
+func generate(bc *buckyclient.Client, cfg config) {
+	r := rand.New(rand.NewSource(cfg.seed))
+	tick := time.NewTicker(time.Second / time.Duration(cfg.rate))
+	defer tick.Stop()
+
+	done := time.After(cfg.duration)
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-tick.C:
+		}
+
+		latency := 5 + r.Intn(200)
+		failed := r.Intn(20) == 0
+		user := "user" + strconv.Itoa(r.Intn(cfg.users))
+
+		bc.Count("demo.requests", 1)
+		bc.AverageTimer("demo.latency", latency)
+		bc.Timer("demo.latency_total", latency)
+		bc.Histogram("demo.latency_hist", latency)
+		bc.Gauge("demo.queue_depth", r.Intn(50))
+		bc.Distinct("demo.users", user)
+		bc.TopK("demo.top_users", user)
+		bc.EWMA("demo.requests", 1)
+		bc.LatencyBuckets("demo.latency", time.Duration(latency)*time.Millisecond,
+			[]time.Duration{10 * time.Millisecond, 50 * time.Millisecond, 100 * time.Millisecond})
+
+		errors := 0
+		if failed {
+			errors = 1
+		}
+
+		bc.Ratio("demo.error_rate", errors, 1)
+	}
+}
+
+// mockServer prints every payload it is sent. With offerGzip it says it
+// accepts gzip in reply to OPTIONS and decompresses what it gets.
+func mockServer(offerGzip bool, out io.Writer) http.Handler {
+	var m sync.Mutex
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(sendPath, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "OPTIONS":
+			if offerGzip {
+				w.Header().Set("Accept-Encoding", "gzip")
+			}
+
+			return
+		case "POST":
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		body := io.Reader(r.Body)
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			zr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+
+			body = zr
+		}
+
+		b, err := ioutil.ReadAll(body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		m.Lock()
+		fmt.Fprintf(out, "--- %s %s (%s)\n%s", r.Header.Get(buckyclient.WindowStartHeader),
+			r.Header.Get(buckyclient.WindowEndHeader), encoding(r), b)
+		m.Unlock()
+	})
+
+	return mux
+}
+
+func encoding(r *http.Request) string {
+	if e := r.Header.Get("Content-Encoding"); e != "" {
+		return e
+	}
+
+	return "identity"
+}
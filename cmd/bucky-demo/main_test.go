@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// syncBuffer is written to by the mock server and read by the test
+type syncBuffer struct {
+	sync.Mutex
+	bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.Lock()
+	defer b.Unlock()
+
+	return b.Buffer.Write(p)
+}
+
+func TestDemo_run(t *testing.T) {
+	for _, gzip := range []bool{false, true} {
+		out := &syncBuffer{}
+
+		err := run(config{
+			interval: 50 * time.Millisecond,
+			duration: 200 * time.Millisecond,
+			rate:     200,
+			users:    10,
+			gzip:     gzip,
+			seed:     1,
+		}, out)
+		assert.NoError(t, err)
+
+		got := out.String()
+		for _, line := range []string{
+			"demo.requests:",
+			"demo.latency:",
+			"demo.latency_total:",
+			"demo.latency_hist.p99:",
+			"demo.queue_depth:",
+			"demo.error_rate:",
+			"demo.users:",
+			"demo.latency.le_10ms:",
+			"demo.requests.m1_rate:",
+			"demo.top_users.",
+		} {
+			assert.Contains(t, got, line)
+		}
+
+		if gzip {
+			assert.Contains(t, got, "(gzip)")
+		} else {
+			assert.False(t, strings.Contains(got, "(gzip)"))
+		}
+	}
+}
+
+func TestDemo_config_validate(t *testing.T) {
+	valid := config{interval: time.Second, duration: time.Second, rate: 50, users: 10}
+	assert.NoError(t, valid.validate())
+
+	for _, change := range []func(*config){
+		func(c *config) { c.rate = 0 },
+		func(c *config) { c.rate = -5 },
+		func(c *config) { c.rate = int(time.Second) + 1 },
+		func(c *config) { c.duration = 0 },
+		func(c *config) { c.duration = -time.Second },
+		func(c *config) { c.interval = 0 },
+		func(c *config) { c.users = 0 },
+	} {
+		cfg := valid
+		change(&cfg)
+
+		assert.Error(t, cfg.validate(), "%+v", cfg)
+	}
+}
@@ -0,0 +1,20 @@
+package buckyclient
+
+// collector reports values, as gauges, when the default window is flushed
+type collector func(gauge func(name string, value int64))
+
+// runCollectors returns the gauges reported by every collector and
+// registered gauge
+func (c *Client) runCollectors() []MetricWithAmount {
+	metrics := c.observeGauges()
+
+	gauge := func(name string, value int64) {
+		metrics = append(metrics, MetricWithAmount{Metric{name: name, unit: UnitGauge}, Amount{Value: int(value)}, ActionLast})
+	}
+
+	for _, collect := range c.collectors {
+		collect(gauge)
+	}
+
+	return metrics
+}
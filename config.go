@@ -0,0 +1,247 @@
+package buckyclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// DefaultRetryBase is the first wait of a Config's retries when it doesn't
+// give one
+const DefaultRetryBase = time.Second
+
+// ErrNoHost is returned by NewClientFromConfig when the config has no host
+var ErrNoHost = errors.New("Config host is not set")
+
+// Config is the configuration of a client as it is kept in a JSON or YAML
+// file shipped alongside the app. Every field left out is left at its
+// default, and every field maps onto the option it names:
+//
+//	host: https://bucky.example.com/bucky/v1/send
+//	interval: 30s
+//	prefix: myapp.
+//	tags: {env: prod}
+//	transport:
+//	  timeout: 5s
+//	  bearer_token: secret
+//	retry:
+//	  max: 3
+//	buffer:
+//	  max_metrics: 10000
+//
+// The yaml tags work with any YAML library that honours them and
+// encoding.TextUnmarshaler, such as gopkg.in/yaml.v3, so this package
+// doesn't need to depend on one.
+type Config struct {
+	Host      string            `json:"host" yaml:"host"`
+	Fallbacks []string          `json:"fallbacks,omitempty" yaml:"fallbacks,omitempty"` // See WithFailover
+	Interval  Duration          `json:"interval,omitempty" yaml:"interval,omitempty"`   // See WithInterval
+	Prefix    string            `json:"prefix,omitempty" yaml:"prefix,omitempty"`       // See WithPrefix
+	Tags      map[string]string `json:"tags,omitempty" yaml:"tags,omitempty"`           // See WithTags
+	Enabled   *bool             `json:"enabled,omitempty" yaml:"enabled,omitempty"`     // See WithEnabled
+
+	Transport TransportConfig `json:"transport,omitempty" yaml:"transport,omitempty"`
+	Retry     RetryConfig     `json:"retry,omitempty" yaml:"retry,omitempty"`
+	Buffer    BufferConfig    `json:"buffer,omitempty" yaml:"buffer,omitempty"`
+}
+
+// TransportConfig is how a Config's payloads are posted
+type TransportConfig struct {
+	Timeout         Duration          `json:"timeout,omitempty" yaml:"timeout,omitempty"`                     // See WithHTTPTimeout
+	BearerToken     string            `json:"bearer_token,omitempty" yaml:"bearer_token,omitempty"`           // See WithBearerToken
+	Headers         map[string]string `json:"headers,omitempty" yaml:"headers,omitempty"`                     // See WithHeader
+	MaxPayloadBytes int               `json:"max_payload_bytes,omitempty" yaml:"max_payload_bytes,omitempty"` // See WithMaxPayloadBytes
+}
+
+// RetryConfig is how a Config's failed payloads are retried. Setting max
+// turns on WithRetry, with a base of DefaultRetryBase unless one is given,
+// and setting either queue field turns on WithRetryQueue, with the
+// default of the other.
+type RetryConfig struct {
+	Max        int      `json:"max,omitempty" yaml:"max,omitempty"`
+	Base       Duration `json:"base,omitempty" yaml:"base,omitempty"`
+	QueueAge   Duration `json:"queue_age,omitempty" yaml:"queue_age,omitempty"`
+	QueueBytes int      `json:"queue_bytes,omitempty" yaml:"queue_bytes,omitempty"`
+}
+
+// BufferConfig is how much a Config's client holds between flushes
+type BufferConfig struct {
+	Input         int `json:"input,omitempty" yaml:"input,omitempty"`                   // See WithInputBuffer
+	SampleBatches int `json:"sample_batches,omitempty" yaml:"sample_batches,omitempty"` // See WithSampleBatching
+	MaxMetrics    int `json:"max_metrics,omitempty" yaml:"max_metrics,omitempty"`       // See WithMaxMetrics
+}
+
+// Duration is a time.Duration written as 30s or 1m30s in a config file.
+// A plain number is a number of seconds, as NewClient takes.
+type Duration time.Duration
+
+// MarshalText writes the duration as time.Duration.String does
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// UnmarshalText parses a duration such as 30s, or a number of seconds
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := parseDuration(string(text))
+	if err != nil {
+		return err
+	}
+
+	*d = Duration(v)
+	return nil
+}
+
+// UnmarshalJSON takes a JSON string as UnmarshalText does, or a JSON
+// number of seconds
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+
+		return d.UnmarshalText([]byte(s))
+	}
+
+	seconds, err := strconv.ParseFloat(string(data), 64)
+	if err != nil {
+		return fmt.Errorf("%s is not a duration or a number of seconds", data)
+	}
+
+	*d = Duration(seconds * float64(time.Second))
+	return nil
+}
+
+// LoadConfig reads a JSON config file. Unknown fields are an error, so a
+// misspelt setting doesn't go unnoticed.
+func LoadConfig(path string) (Config, error) {
+	var cfg Config
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(&cfg); err != nil {
+		return cfg, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return cfg, nil
+}
+
+// NewClientFromConfig creates a client configured by cfg. The options given
+// are applied after those from the config, so code can still override
+// them.
+func NewClientFromConfig(cfg Config, opts ...Option) (*Client, error) {
+	if cfg.Host == "" {
+		return nil, ErrNoHost
+	}
+
+	return NewClient(cfg.Host, 0, append(cfg.Options(), opts...)...)
+}
+
+// Options returns the options the config asks for, for use with NewClient
+// or a Builder
+func (cfg Config) Options() []Option {
+	var opts []Option
+
+	if len(cfg.Fallbacks) > 0 {
+		opts = append(opts, WithFailover(cfg.Fallbacks...))
+	}
+
+	if cfg.Interval != 0 {
+		opts = append(opts, WithInterval(time.Duration(cfg.Interval)))
+	}
+
+	if cfg.Prefix != "" {
+		opts = append(opts, WithPrefix(cfg.Prefix))
+	}
+
+	if len(cfg.Tags) > 0 {
+		var tags []Tag
+		for _, key := range sortedKeys(cfg.Tags) {
+			tags = append(tags, Tag{Key: key, Value: cfg.Tags[key]})
+		}
+
+		opts = append(opts, WithTags(tags...))
+	}
+
+	if cfg.Enabled != nil {
+		opts = append(opts, WithEnabled(*cfg.Enabled))
+	}
+
+	t := cfg.Transport
+	if t.Timeout != 0 {
+		opts = append(opts, WithHTTPTimeout(time.Duration(t.Timeout)))
+	}
+
+	if t.BearerToken != "" {
+		opts = append(opts, WithBearerToken(t.BearerToken))
+	}
+
+	for _, key := range sortedKeys(t.Headers) {
+		opts = append(opts, WithHeader(key, t.Headers[key]))
+	}
+
+	if t.MaxPayloadBytes != 0 {
+		opts = append(opts, WithMaxPayloadBytes(t.MaxPayloadBytes))
+	}
+
+	r := cfg.Retry
+	if r.Max != 0 || r.Base != 0 {
+		base := time.Duration(r.Base)
+		if base == 0 {
+			base = DefaultRetryBase
+		}
+
+		opts = append(opts, WithRetry(r.Max, base))
+	}
+
+	if r.QueueAge != 0 || r.QueueBytes != 0 {
+		age, size := time.Duration(r.QueueAge), r.QueueBytes
+		if age == 0 {
+			age = DefaultRetryQueueAge
+		}
+
+		if size == 0 {
+			size = DefaultRetryQueueBytes
+		}
+
+		opts = append(opts, WithRetryQueue(age, size))
+	}
+
+	b := cfg.Buffer
+	if b.Input != 0 {
+		opts = append(opts, WithInputBuffer(b.Input))
+	}
+
+	if b.SampleBatches != 0 {
+		opts = append(opts, WithSampleBatching(b.SampleBatches))
+	}
+
+	if b.MaxMetrics != 0 {
+		opts = append(opts, WithMaxMetrics(b.MaxMetrics))
+	}
+
+	return opts
+}
+
+// sortedKeys returns the keys of m in order, so options are applied the
+// same way every time
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,99 @@
+package buckyclient
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testConfig = `{
+	"host": "http://localhost:8005/bucky/v1/send",
+	"fallbacks": ["http://localhost:8006/bucky/v1/send"],
+	"interval": "15s",
+	"prefix": "myapp",
+	"tags": {"region": "eu", "env": "prod"},
+	"enabled": false,
+	"transport": {"timeout": 2, "bearer_token": "secret", "headers": {"X-Team": "payments"}},
+	"retry": {"max": 3, "queue_bytes": 4096},
+	"buffer": {"input": 64, "max_metrics": 100}
+}`
+
+func TestConfig_NewClientFromConfig(t *testing.T) {
+	var cfg Config
+	if !assert.NoError(t, json.Unmarshal([]byte(testConfig), &cfg)) {
+		return
+	}
+
+	c, err := NewClientFromConfig(cfg, WithMaxMetrics(50))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer c.Close()
+	c.SetLogger(log.New(ioutil.Discard, "", 0))
+
+	assert.Equal(t, 15*time.Second, c.interval)
+	assert.Equal(t, "myapp.", c.prefix)
+	assert.Equal(t, []Tag{{"env", "prod"}, {"region", "eu"}}, c.tags)
+	assert.False(t, c.Enabled())
+	assert.Equal(t, 2*time.Second, c.httpTimeout)
+	assert.Equal(t, "Bearer secret", c.headers.Get("Authorization"))
+	assert.Equal(t, "payments", c.headers.Get("X-Team"))
+	assert.Equal(t, retryPolicy{max: 3, base: DefaultRetryBase}, c.retry)
+	assert.Equal(t, DefaultRetryQueueAge, c.spool.maxAge)
+	assert.Equal(t, 4096, c.spool.maxBytes)
+	assert.Equal(t, 64, c.inputBuffer)
+	assert.Equal(t, 50, c.maxMetrics, "options given in code win")
+	assert.Len(t, c.Endpoints(), 2)
+}
+
+func TestConfig_NewClientFromConfig_Invalid(t *testing.T) {
+	_, err := NewClientFromConfig(Config{})
+	assert.True(t, errors.Is(err, ErrNoHost))
+
+	_, err = NewClientFromConfig(Config{Host: "http://localhost", Buffer: BufferConfig{MaxMetrics: -1}})
+	assert.True(t, errors.Is(err, ErrInvalidOption))
+}
+
+func TestConfig_Duration(t *testing.T) {
+	for text, want := range map[string]time.Duration{`"1m30s"`: 90 * time.Second, `"10"`: 10 * time.Second, `2.5`: 2500 * time.Millisecond} {
+		var d Duration
+		assert.NoError(t, json.Unmarshal([]byte(text), &d), text)
+		assert.Equal(t, want, time.Duration(d), text)
+	}
+
+	var d Duration
+	assert.Error(t, d.UnmarshalText([]byte("soon")))
+	assert.Error(t, json.Unmarshal([]byte(`true`), &d))
+
+	b, err := json.Marshal(Duration(90 * time.Second))
+	assert.NoError(t, err)
+	assert.Equal(t, `"1m30s"`, string(b))
+}
+
+func TestConfig_LoadConfig(t *testing.T) {
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "bucky.json")
+	assert.NoError(t, os.WriteFile(path, []byte(testConfig), 0o600))
+
+	cfg, err := LoadConfig(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "http://localhost:8005/bucky/v1/send", cfg.Host)
+	assert.Equal(t, Duration(15*time.Second), cfg.Interval)
+
+	typo := filepath.Join(dir, "typo.json")
+	assert.NoError(t, os.WriteFile(typo, []byte(`{"host": "http://localhost", "intreval": "15s"}`), 0o600))
+
+	_, err = LoadConfig(typo)
+	assert.ErrorContains(t, err, "intreval")
+
+	_, err = LoadConfig(filepath.Join(dir, "missing.json"))
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+}
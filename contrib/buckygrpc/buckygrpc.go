@@ -0,0 +1,49 @@
+//go:build grpc
+
+// Package buckygrpc records metrics for gRPC servers and clients on a
+// bucky client, with interceptors that time every call and count its
+// status code by method:
+//
+//	server := grpc.NewServer(
+//		grpc.ChainUnaryInterceptor(buckygrpc.UnaryServerInterceptor(c)),
+//		grpc.ChainStreamInterceptor(buckygrpc.StreamServerInterceptor(c)),
+//	)
+//
+// It needs google.golang.org/grpc, so it is only built with the grpc
+// build tag and the rest of the module doesn't depend on it.
+package buckygrpc
+
+import (
+	"strings"
+	"time"
+
+	"github.com/matzhouse/go-bucky-client"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// ServerCallsMetric counts calls a server handled, tagged with method
+	// and code
+	ServerCallsMetric = "grpc.server.calls"
+
+	// ServerDurationMetric is a histogram of how long a server took to
+	// handle calls, tagged with method
+	ServerDurationMetric = "grpc.server.duration"
+
+	// ClientCallsMetric counts calls a client made, tagged with method and
+	// code
+	ClientCallsMetric = "grpc.client.calls"
+
+	// ClientDurationMetric is a histogram of how long calls a client made
+	// took, tagged with method. A stream is timed until it ends.
+	ClientDurationMetric = "grpc.client.duration"
+)
+
+// record records a call to fullMethod, e.g. /pkg.Service/Method, that
+// started at start and ended with err
+func record(c *buckyclient.Client, calls, duration, fullMethod string, start time.Time, err error) {
+	method := buckyclient.Tag{Key: "method", Value: strings.TrimPrefix(fullMethod, "/")}
+
+	c.HistogramDuration(duration, time.Since(start), method)
+	c.Count(calls, 1, method, buckyclient.Tag{Key: "code", Value: status.Code(err).String()})
+}
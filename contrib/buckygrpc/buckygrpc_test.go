@@ -0,0 +1,104 @@
+//go:build grpc
+
+package buckygrpc
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/matzhouse/go-bucky-client/buckytest"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestServer_UnaryServerInterceptor(t *testing.T) {
+	c, tr := buckytest.NewClient(t)
+	intercept := UnaryServerInterceptor(c)
+	info := &grpc.UnaryServerInfo{FullMethod: "/users.Users/Get"}
+
+	ok := func(ctx context.Context, req interface{}) (interface{}, error) { return "user", nil }
+	missing := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, status.Error(codes.NotFound, "no such user")
+	}
+
+	for _, handler := range []grpc.UnaryHandler{ok, ok, missing} {
+		intercept(context.Background(), nil, info, handler)
+	}
+
+	assert.NoError(t, c.Flush())
+
+	lines := tr.Lines()
+	assert.Contains(t, lines, "grpc.server.calls:2|c|#code:OK,method:users.Users/Get")
+	assert.Contains(t, lines, "grpc.server.calls:1|c|#code:NotFound,method:users.Users/Get")
+}
+
+func TestServer_StreamServerInterceptor(t *testing.T) {
+	c, tr := buckytest.NewClient(t)
+
+	err := StreamServerInterceptor(c)(nil, nil, &grpc.StreamServerInfo{FullMethod: "/users.Users/List"}, func(srv interface{}, ss grpc.ServerStream) error {
+		return status.Error(codes.Unavailable, "draining")
+	})
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+
+	assert.NoError(t, c.Flush())
+	assert.Contains(t, tr.Lines(), "grpc.server.calls:1|c|#code:Unavailable,method:users.Users/List")
+}
+
+func TestClient_UnaryClientInterceptor(t *testing.T) {
+	c, tr := buckytest.NewClient(t)
+
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		return status.Error(codes.DeadlineExceeded, "too slow")
+	}
+
+	err := UnaryClientInterceptor(c)(context.Background(), "/users.Users/Get", nil, nil, nil, invoker)
+	assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+
+	assert.NoError(t, c.Flush())
+	assert.Contains(t, tr.Lines(), "grpc.client.calls:1|c|#code:DeadlineExceeded,method:users.Users/Get")
+}
+
+// fakeStream returns io.EOF after a number of messages
+type fakeStream struct {
+	grpc.ClientStream
+	left int
+}
+
+func (s *fakeStream) RecvMsg(m interface{}) error {
+	if s.left == 0 {
+		return io.EOF
+	}
+
+	s.left--
+	return nil
+}
+
+func TestClient_StreamClientInterceptor(t *testing.T) {
+	c, tr := buckytest.NewClient(t)
+
+	intercept := StreamClientInterceptor(c)
+	streamer := func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return &fakeStream{left: 2}, nil
+	}
+
+	// Recorded when the stream ends, and only once
+	cs, err := intercept(context.Background(), &grpc.StreamDesc{ServerStreams: true}, nil, "/users.Users/List", streamer)
+	assert.NoError(t, err)
+	for cs.RecvMsg(nil) == nil {
+	}
+	cs.RecvMsg(nil)
+
+	// Without server streaming the response ends the call
+	cs, err = intercept(context.Background(), &grpc.StreamDesc{ClientStreams: true}, nil, "/users.Users/Import", streamer)
+	assert.NoError(t, err)
+	assert.NoError(t, cs.RecvMsg(nil))
+
+	assert.NoError(t, c.Flush())
+
+	lines := tr.Lines()
+	assert.Contains(t, lines, "grpc.client.calls:1|c|#code:OK,method:users.Users/List")
+	assert.Contains(t, lines, "grpc.client.calls:1|c|#code:OK,method:users.Users/Import")
+}
@@ -0,0 +1,74 @@
+//go:build grpc
+
+package buckygrpc
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/matzhouse/go-bucky-client"
+	"google.golang.org/grpc"
+)
+
+// UnaryClientInterceptor records every unary call a client makes on c
+func UnaryClientInterceptor(c *buckyclient.Client) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		record(c, ClientCallsMetric, ClientDurationMetric, method, start, err)
+
+		return err
+	}
+}
+
+// StreamClientInterceptor records every streaming call a client makes on
+// c. The call is recorded when receiving from the stream ends, so streams
+// the caller abandons without reading to the end aren't recorded.
+func StreamClientInterceptor(c *buckyclient.Client) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		start := time.Now()
+
+		cs, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			record(c, ClientCallsMetric, ClientDurationMetric, method, start, err)
+			return nil, err
+		}
+
+		return &clientStream{ClientStream: cs, c: c, method: method, start: start, serverStreams: desc.ServerStreams}, nil
+	}
+}
+
+// clientStream records its call once receiving from it fails or ends
+type clientStream struct {
+	grpc.ClientStream
+
+	c             *buckyclient.Client
+	method        string
+	start         time.Time
+	serverStreams bool // Whether the server sends more than one message
+	once          sync.Once
+}
+
+func (s *clientStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+
+	// Without server streaming the one response ends the call
+	if err != nil || !s.serverStreams {
+		s.done(err)
+	}
+
+	return err
+}
+
+// done records the call, where io.EOF is a stream that ended cleanly
+func (s *clientStream) done(err error) {
+	if err == io.EOF {
+		err = nil
+	}
+
+	s.once.Do(func() {
+		record(s.c, ClientCallsMetric, ClientDurationMetric, s.method, s.start, err)
+	})
+}
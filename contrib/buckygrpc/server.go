@@ -0,0 +1,34 @@
+//go:build grpc
+
+package buckygrpc
+
+import (
+	"context"
+	"time"
+
+	"github.com/matzhouse/go-bucky-client"
+	"google.golang.org/grpc"
+)
+
+// UnaryServerInterceptor records every unary call a server handles on c
+func UnaryServerInterceptor(c *buckyclient.Client) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		record(c, ServerCallsMetric, ServerDurationMetric, info.FullMethod, start, err)
+
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor records every streaming call a server handles
+// on c, timed until the handler returns
+func StreamServerInterceptor(c *buckyclient.Client) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		err := handler(srv, ss)
+		record(c, ServerCallsMetric, ServerDurationMetric, info.FullMethod, start, err)
+
+		return err
+	}
+}
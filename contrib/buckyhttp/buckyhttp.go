@@ -0,0 +1,103 @@
+// Package buckyhttp records metrics for net/http servers and clients on a
+// bucky client, so every service doesn't have to write the same wrapper
+// around Count and Timer.
+package buckyhttp
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Unmatched is the route of a request nothing routed, so requests for
+// unknown paths share one series instead of adding one each
+const Unmatched = "unmatched"
+
+// Option configures how requests are recorded
+type Option func(*config)
+
+type config struct {
+	route  func(r *http.Request) string
+	target func(r *http.Request) string
+}
+
+func newConfig(opts []Option) config {
+	var cfg config
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	return cfg
+}
+
+// WithRoute names the route of a request with fn instead of its
+// ServeMux pattern, e.g. for a third party router. Only return a few
+// distinct values: one series is kept per route, so never return raw
+// paths that contain IDs. An empty route leaves the route tag out.
+func WithRoute(fn func(r *http.Request) string) Option {
+	return func(cfg *config) {
+		if fn != nil {
+			cfg.route = fn
+		}
+	}
+}
+
+// WithTarget names the dependency an outbound request went to with fn
+// instead of the host of its URL, e.g. to group the hosts of one service
+// together. It only applies to RoundTripper, and the same advice about
+// distinct values as for WithRoute holds.
+func WithTarget(fn func(r *http.Request) string) Option {
+	return func(cfg *config) {
+		if fn != nil {
+			cfg.target = fn
+		}
+	}
+}
+
+// targetOf returns the name of the dependency a request goes to
+func (cfg config) targetOf(r *http.Request) string {
+	if cfg.target != nil {
+		return cfg.target(r)
+	}
+
+	return r.URL.Host
+}
+
+// muxRoute returns the pattern mux routes a request to, without the
+// method as that is a tag of its own, or Unmatched
+func muxRoute(mux *http.ServeMux) func(r *http.Request) string {
+	return func(r *http.Request) string {
+		_, pattern := mux.Handler(r)
+		if pattern == "" {
+			return Unmatched
+		}
+
+		if i := strings.IndexByte(pattern, ' '); i >= 0 {
+			return strings.TrimLeft(pattern[i:], " \t")
+		}
+
+		return pattern
+	}
+}
+
+// routeFor returns how the routes of requests to h are named
+func (cfg config) routeFor(h http.Handler) func(r *http.Request) string {
+	if cfg.route != nil {
+		return cfg.route
+	}
+
+	if mux, ok := h.(*http.ServeMux); ok {
+		return muxRoute(mux)
+	}
+
+	return func(*http.Request) string { return "" }
+}
+
+// statusClass returns the class of a status code, e.g. 2xx
+func statusClass(code int) string {
+	if code < 100 || code > 599 {
+		return "unknown"
+	}
+
+	return strconv.Itoa(code/100) + "xx"
+}
@@ -0,0 +1,98 @@
+package buckyhttp
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/matzhouse/go-bucky-client"
+)
+
+const (
+	// ServerRequestsMetric counts requests, tagged with route, method and
+	// status_class
+	ServerRequestsMetric = "http.server.requests"
+
+	// ServerDurationMetric is a histogram of how long requests took,
+	// tagged with route and method
+	ServerDurationMetric = "http.server.duration"
+)
+
+// Middleware returns a function that wraps a handler so every request it
+// serves is recorded on c:
+//
+//	http.ListenAndServe(":8080", buckyhttp.Middleware(c)(mux))
+//
+// When the wrapped handler is a ServeMux the route is the pattern it
+// matched; anything else is recorded without a route unless WithRoute
+// names them. A request whose handler panics is recorded as a 500.
+func Middleware(c *buckyclient.Client, opts ...Option) func(http.Handler) http.Handler {
+	cfg := newConfig(opts)
+
+	return func(next http.Handler) http.Handler {
+		routeOf := cfg.routeFor(next)
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			sw := &statusWriter{ResponseWriter: w}
+			start := time.Now()
+			served := false
+
+			defer func() {
+				status := sw.status
+				switch {
+				case !served:
+					status = http.StatusInternalServerError
+				case status == 0:
+					status = http.StatusOK
+				}
+
+				tags := []buckyclient.Tag{{Key: "method", Value: r.Method}}
+				if route := routeOf(r); route != "" {
+					tags = append(tags, buckyclient.Tag{Key: "route", Value: route})
+				}
+
+				c.HistogramDuration(ServerDurationMetric, time.Since(start), tags...)
+				c.Count(ServerRequestsMetric, 1, append(tags, buckyclient.Tag{Key: "status_class", Value: statusClass(status)})...)
+			}()
+
+			next.ServeHTTP(sw, r)
+			served = true
+		})
+	}
+}
+
+// statusWriter remembers the status code a handler sent
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	// Informational responses come before the real one
+	if w.status == 0 && code >= 200 {
+		w.status = code
+	}
+
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush lets streaming handlers that check for http.Flusher keep working
+func (w *statusWriter) Flush() {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Unwrap gives http.ResponseController the original writer
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
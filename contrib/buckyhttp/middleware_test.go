@@ -0,0 +1,86 @@
+package buckyhttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/matzhouse/go-bucky-client/buckytest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddleware_Middleware(t *testing.T) {
+	c, tr := buckytest.NewClient(t)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("id") == "0" {
+			http.Error(w, "no such user", http.StatusNotFound)
+			return
+		}
+
+		w.Write([]byte("ok"))
+	})
+	mux.HandleFunc("/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	})
+
+	h := Middleware(c)(mux)
+
+	for _, path := range []string{"/users/1", "/users/2", "/users/0", "/missing", "/panic"} {
+		func() {
+			defer func() { recover() }()
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+		}()
+	}
+
+	assert.NoError(t, c.Flush())
+
+	lines := tr.Lines()
+	assert.Contains(t, lines, "http.server.requests:2|c|#method:GET,route:/users/{id},status_class:2xx")
+	assert.Contains(t, lines, "http.server.requests:1|c|#method:GET,route:/users/{id},status_class:4xx")
+	assert.Contains(t, lines, "http.server.requests:1|c|#method:GET,route:unmatched,status_class:4xx")
+	assert.Contains(t, lines, "http.server.requests:1|c|#method:GET,route:/panic,status_class:5xx")
+
+	found := false
+	for _, line := range lines {
+		found = found || strings.HasPrefix(line, "http.server.duration.p99:") && strings.HasSuffix(line, "|ms|#method:GET,route:/users/{id}")
+	}
+	assert.True(t, found, "no latency histogram in %v", lines)
+}
+
+func TestMiddleware_Middleware_WithRoute(t *testing.T) {
+	c, tr := buckytest.NewClient(t)
+
+	h := Middleware(c, WithRoute(func(r *http.Request) string { return "api" }))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusEarlyHints)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/anything", nil))
+
+	assert.NoError(t, c.Flush())
+	assert.Contains(t, tr.Lines(), "http.server.requests:1|c|#method:POST,route:api,status_class:2xx")
+}
+
+func TestMiddleware_Middleware_NoRoute(t *testing.T) {
+	c, tr := buckytest.NewClient(t)
+
+	h := Middleware(c)(http.NotFoundHandler())
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/users/1", nil))
+
+	assert.NoError(t, c.Flush())
+	assert.Contains(t, tr.Lines(), "http.server.requests:1|c|#method:GET,status_class:4xx")
+}
+
+func TestMiddleware_statusWriter_Flush(t *testing.T) {
+	rec := httptest.NewRecorder()
+	sw := &statusWriter{ResponseWriter: rec}
+
+	var w http.ResponseWriter = sw
+	w.(http.Flusher).Flush()
+
+	assert.Equal(t, http.StatusOK, sw.status)
+	assert.True(t, rec.Flushed)
+}
@@ -0,0 +1,62 @@
+package buckyhttp
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/matzhouse/go-bucky-client"
+)
+
+const (
+	// ClientRequestsMetric counts outbound requests, tagged with target,
+	// method and status_class, which is "error" when no response came back
+	ClientRequestsMetric = "http.client.requests"
+
+	// ClientDurationMetric is a histogram of how long outbound requests
+	// took to get a response, tagged with target and method
+	ClientDurationMetric = "http.client.duration"
+)
+
+// RoundTripper returns a RoundTripper that records every request sent
+// through next on c, so the latency and errors of each dependency show
+// up without instrumenting every call:
+//
+//	httpClient := &http.Client{Transport: buckyhttp.RoundTripper(http.DefaultTransport, c)}
+//
+// The target is the host of the request URL unless WithTarget names it.
+// The time is taken until the response headers arrive, as the body is read
+// after RoundTrip returns. A nil next is http.DefaultTransport.
+func RoundTripper(next http.RoundTripper, c *buckyclient.Client, opts ...Option) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	return &roundTripper{next: next, c: c, cfg: newConfig(opts)}
+}
+
+type roundTripper struct {
+	next http.RoundTripper
+	c    *buckyclient.Client
+	cfg  config
+}
+
+func (rt *roundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := rt.next.RoundTrip(r)
+	elapsed := time.Since(start)
+
+	tags := []buckyclient.Tag{{Key: "method", Value: r.Method}}
+	if target := rt.cfg.targetOf(r); target != "" {
+		tags = append(tags, buckyclient.Tag{Key: "target", Value: target})
+	}
+
+	class := "error"
+	if err == nil {
+		class = statusClass(resp.StatusCode)
+	}
+
+	rt.c.HistogramDuration(ClientDurationMetric, elapsed, tags...)
+	rt.c.Count(ClientRequestsMetric, 1, append(tags, buckyclient.Tag{Key: "status_class", Value: class})...)
+
+	return resp, err
+}
@@ -0,0 +1,68 @@
+package buckyhttp
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/matzhouse/go-bucky-client/buckytest"
+	"github.com/stretchr/testify/assert"
+)
+
+// roundTripFunc is a RoundTripper made from a function
+type roundTripFunc func(r *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestRoundTripper_RoundTripper(t *testing.T) {
+	c, tr := buckytest.NewClient(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	// Colons in tag values are replaced
+	host := strings.ReplaceAll(strings.TrimPrefix(server.URL, "http://"), ":", "_")
+	client := &http.Client{Transport: RoundTripper(nil, c)}
+
+	for _, path := range []string{"/", "/", "/missing"} {
+		resp, err := client.Get(server.URL + path)
+		if assert.NoError(t, err) {
+			resp.Body.Close()
+		}
+	}
+
+	assert.NoError(t, c.Flush())
+
+	lines := tr.Lines()
+	assert.Contains(t, lines, "http.client.requests:2|c|#method:GET,status_class:2xx,target:"+host)
+	assert.Contains(t, lines, "http.client.requests:1|c|#method:GET,status_class:4xx,target:"+host)
+
+	found := false
+	for _, line := range lines {
+		found = found || strings.HasPrefix(line, "http.client.duration.max:") && strings.HasSuffix(line, "|ms|#method:GET,target:"+host)
+	}
+	assert.True(t, found, "no latency histogram in %v", lines)
+}
+
+func TestRoundTripper_RoundTripper_Error(t *testing.T) {
+	c, tr := buckytest.NewClient(t)
+
+	failed := errors.New("connection refused")
+	rt := RoundTripper(roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		return nil, failed
+	}), c, WithTarget(func(r *http.Request) string { return "payments" }))
+
+	_, err := rt.RoundTrip(httptest.NewRequest("POST", "http://10.0.0.1/charge", nil))
+	assert.Equal(t, failed, err)
+
+	assert.NoError(t, c.Flush())
+	assert.Contains(t, tr.Lines(), "http.client.requests:1|c|#method:POST,status_class:error,target:payments")
+}
@@ -0,0 +1,132 @@
+// Package buckysql records metrics for database/sql on a bucky client, by
+// wrapping the driver so every query is timed without touching the code
+// that runs it:
+//
+//	db := sql.OpenDB(buckysql.WrapConnector(connector, c))
+//	buckysql.RegisterPoolGauges(c, db)
+//
+// Every call the driver makes is recorded with an op tag of query, exec,
+// prepare, begin, commit or rollback. To tell databases apart, wrap with
+// a client from c.WithAdditionalTags.
+package buckysql
+
+import (
+	"context"
+	"database/sql/driver"
+	"time"
+
+	"github.com/matzhouse/go-bucky-client"
+)
+
+const (
+	// CallsMetric counts calls to the driver, tagged with op
+	CallsMetric = "sql.calls"
+
+	// ErrorsMetric counts driver calls that failed, tagged with op
+	ErrorsMetric = "sql.errors"
+
+	// DurationMetric is a histogram of how long driver calls took, tagged
+	// with op. A query is timed until its rows are ready, not until they
+	// have been read.
+	DurationMetric = "sql.duration"
+)
+
+// Wrap returns a driver that records every call to d on c, for
+// registering with sql.Register under a name of its own
+func Wrap(d driver.Driver, c *buckyclient.Client) driver.Driver {
+	return &wrappedDriver{next: d, c: c}
+}
+
+// WrapConnector returns a connector that records every call to the
+// connections of next on c, for sql.OpenDB
+func WrapConnector(next driver.Connector, c *buckyclient.Client) driver.Connector {
+	return &connector{next: next, c: c}
+}
+
+type wrappedDriver struct {
+	next driver.Driver
+	c    *buckyclient.Client
+}
+
+func (d *wrappedDriver) Open(name string) (driver.Conn, error) {
+	cn, err := d.next.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &conn{next: cn, c: d.c}, nil
+}
+
+func (d *wrappedDriver) OpenConnector(name string) (driver.Connector, error) {
+	if dc, ok := d.next.(driver.DriverContext); ok {
+		next, err := dc.OpenConnector(name)
+		if err != nil {
+			return nil, err
+		}
+
+		return &connector{next: next, c: d.c}, nil
+	}
+
+	return &connector{next: dsnConnector{name: name, driver: d.next}, c: d.c}, nil
+}
+
+type connector struct {
+	next driver.Connector
+	c    *buckyclient.Client
+}
+
+func (cr *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	cn, err := cr.next.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &conn{next: cn, c: cr.c}, nil
+}
+
+func (cr *connector) Driver() driver.Driver {
+	return &wrappedDriver{next: cr.next.Driver(), c: cr.c}
+}
+
+// Close closes the wrapped connector if it needs closing, which sql.DB
+// does when it is closed
+func (cr *connector) Close() error {
+	if closer, ok := cr.next.(interface{ Close() error }); ok {
+		return closer.Close()
+	}
+
+	return nil
+}
+
+// dsnConnector opens connections with a driver that has no connector of
+// its own
+type dsnConnector struct {
+	name   string
+	driver driver.Driver
+}
+
+func (dc dsnConnector) Connect(context.Context) (driver.Conn, error) {
+	return dc.driver.Open(dc.name)
+}
+
+func (dc dsnConnector) Driver() driver.Driver {
+	return dc.driver
+}
+
+// record records a driver call that started at start. ErrSkip isn't a
+// failure but database/sql being told to do it another way, which is
+// recorded when it does.
+func record(c *buckyclient.Client, op string, start time.Time, err error) {
+	if err == driver.ErrSkip {
+		return
+	}
+
+	tag := buckyclient.Tag{Key: "op", Value: op}
+
+	c.HistogramDuration(DurationMetric, time.Since(start), tag)
+	c.Count(CallsMetric, 1, tag)
+
+	if err != nil {
+		c.Count(ErrorsMetric, 1, tag)
+	}
+}
@@ -0,0 +1,172 @@
+package buckysql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/matzhouse/go-bucky-client"
+	"github.com/matzhouse/go-bucky-client/buckytest"
+	"github.com/stretchr/testify/assert"
+)
+
+// errFake is what the fake driver fails with, for a query of "fail"
+var errFake = errors.New("fake failure")
+
+// fakeDriver has the least a driver needs, plus QueryerContext, so the
+// wrapper has to fall back for everything else
+type fakeDriver struct{}
+
+func (fakeDriver) Open(name string) (driver.Conn, error) { return fakeConn{}, nil }
+
+type fakeConn struct{}
+
+func (fakeConn) Prepare(query string) (driver.Stmt, error) { return fakeStmt{query}, nil }
+func (fakeConn) Close() error                              { return nil }
+func (fakeConn) Begin() (driver.Tx, error)                 { return fakeTx{}, nil }
+
+func (fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if query == "fail" {
+		return nil, errFake
+	}
+
+	return &fakeRows{}, nil
+}
+
+type fakeStmt struct{ query string }
+
+func (fakeStmt) Close() error  { return nil }
+func (fakeStmt) NumInput() int { return -1 }
+
+func (s fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	if s.query == "fail" {
+		return nil, errFake
+	}
+
+	return driver.RowsAffected(len(args)), nil
+}
+
+func (fakeStmt) Query(args []driver.Value) (driver.Rows, error) { return &fakeRows{}, nil }
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return errFake }
+
+// fakeRows has one row with one column
+type fakeRows struct{ done bool }
+
+func (*fakeRows) Columns() []string { return []string{"n"} }
+func (*fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+
+	r.done = true
+	dest[0] = int64(1)
+
+	return nil
+}
+
+// openDB opens a pool on the fake driver, wrapped with Wrap
+func openDB(t *testing.T, c *buckyclient.Client) *sql.DB {
+	connector, err := Wrap(fakeDriver{}, c).(driver.DriverContext).OpenConnector("fake")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	db := sql.OpenDB(connector)
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+func TestBuckySQL_Wrap(t *testing.T) {
+	c, tr := buckytest.NewClient(t)
+	db := openDB(t, c)
+
+	var n int
+	assert.NoError(t, db.QueryRow("select 1").Scan(&n))
+	assert.Equal(t, 1, n)
+	assert.ErrorIs(t, db.QueryRow("fail").Scan(&n), errFake)
+
+	// The fake has no ExecerContext, so these are prepared first
+	result, err := db.Exec("insert", 1, "two")
+	assert.NoError(t, err)
+	affected, _ := result.RowsAffected()
+	assert.Equal(t, int64(2), affected)
+	_, err = db.Exec("fail")
+	assert.ErrorIs(t, err, errFake)
+
+	txn, err := db.Begin()
+	assert.NoError(t, err)
+	assert.NoError(t, txn.Commit())
+
+	txn, err = db.Begin()
+	assert.NoError(t, err)
+	assert.ErrorIs(t, txn.Rollback(), errFake)
+
+	_, err = db.BeginTx(context.Background(), &sql.TxOptions{ReadOnly: true})
+	assert.Equal(t, errTxOptions, err)
+
+	assert.NoError(t, c.Flush())
+
+	lines := tr.Lines()
+	for _, want := range []string{
+		"sql.calls:2|c|#op:query",
+		"sql.errors:1|c|#op:query",
+		"sql.calls:2|c|#op:prepare",
+		"sql.calls:2|c|#op:exec",
+		"sql.errors:1|c|#op:exec",
+		"sql.calls:2|c|#op:begin",
+		"sql.calls:1|c|#op:commit",
+		"sql.calls:1|c|#op:rollback",
+		"sql.errors:1|c|#op:rollback",
+	} {
+		assert.Contains(t, lines, want)
+	}
+
+	found := false
+	for _, line := range lines {
+		found = found || strings.HasPrefix(line, "sql.duration.p99:") && strings.HasSuffix(line, "|ms|#op:query")
+	}
+	assert.True(t, found, "no duration histogram in %v", lines)
+}
+
+func TestBuckySQL_RegisterPoolGauges(t *testing.T) {
+	c, tr := buckytest.NewClient(t)
+	db := openDB(t, c)
+	db.SetMaxOpenConns(4)
+
+	assert.NoError(t, db.Ping())
+	RegisterPoolGauges(c, db)
+
+	assert.NoError(t, c.Flush())
+
+	lines := tr.Lines()
+	assert.Contains(t, lines, "sql.pool.open:1|g")
+	assert.Contains(t, lines, "sql.pool.idle:1|g")
+	assert.Contains(t, lines, "sql.pool.in_use:0|g")
+	assert.Contains(t, lines, "sql.pool.max_open:4|g")
+
+	UnregisterPoolGauges(c)
+	c.Count("other", 1)
+	assert.NoError(t, c.Flush())
+
+	assert.Equal(t, "other:1|c", tr.Lines()[len(tr.Lines())-1])
+}
+
+func TestBuckySQL_toValues(t *testing.T) {
+	values, err := toValues([]driver.NamedValue{{Ordinal: 1, Value: "a"}, {Ordinal: 2, Value: int64(2)}})
+	assert.NoError(t, err)
+	assert.Equal(t, []driver.Value{"a", int64(2)}, values)
+
+	_, err = toValues([]driver.NamedValue{{Name: "id", Ordinal: 1, Value: "a"}})
+	assert.Equal(t, errNamedParameters, err)
+}
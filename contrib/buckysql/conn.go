@@ -0,0 +1,254 @@
+package buckysql
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"time"
+
+	"github.com/matzhouse/go-bucky-client"
+)
+
+// errNamedParameters is returned for named arguments to a driver that
+// can't take them
+var errNamedParameters = errors.New("Driver does not support named parameters")
+
+// errTxOptions is returned for transaction options a driver can't take
+var errTxOptions = errors.New("Driver does not support transaction options")
+
+// conn records the calls made on a connection. It implements every
+// optional interface, falling back to what database/sql would do when the
+// wrapped connection doesn't.
+type conn struct {
+	next driver.Conn
+	c    *buckyclient.Client
+}
+
+func (cn *conn) Prepare(query string) (driver.Stmt, error) {
+	return cn.PrepareContext(context.Background(), query)
+}
+
+func (cn *conn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	start := time.Now()
+
+	var s driver.Stmt
+	var err error
+
+	if pc, ok := cn.next.(driver.ConnPrepareContext); ok {
+		s, err = pc.PrepareContext(ctx, query)
+	} else {
+		s, err = cn.next.Prepare(query)
+	}
+
+	record(cn.c, "prepare", start, err)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &stmt{next: s, conn: cn}, nil
+}
+
+func (cn *conn) Close() error {
+	return cn.next.Close()
+}
+
+func (cn *conn) Begin() (driver.Tx, error) {
+	return cn.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (cn *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	start := time.Now()
+
+	var t driver.Tx
+	var err error
+
+	if bt, ok := cn.next.(driver.ConnBeginTx); ok {
+		t, err = bt.BeginTx(ctx, opts)
+	} else if opts != (driver.TxOptions{}) {
+		return nil, errTxOptions
+	} else {
+		t, err = cn.next.Begin()
+	}
+
+	record(cn.c, "begin", start, err)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &tx{next: t, c: cn.c}, nil
+}
+
+func (cn *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	ec, ok := cn.next.(driver.ExecerContext)
+	if !ok {
+		// database/sql prepares a statement instead
+		return nil, driver.ErrSkip
+	}
+
+	start := time.Now()
+	result, err := ec.ExecContext(ctx, query, args)
+	record(cn.c, "exec", start, err)
+
+	return result, err
+}
+
+func (cn *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	qc, ok := cn.next.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	start := time.Now()
+	rows, err := qc.QueryContext(ctx, query, args)
+	record(cn.c, "query", start, err)
+
+	return rows, err
+}
+
+func (cn *conn) Ping(ctx context.Context) error {
+	if p, ok := cn.next.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+
+	return nil
+}
+
+func (cn *conn) ResetSession(ctx context.Context) error {
+	if sr, ok := cn.next.(driver.SessionResetter); ok {
+		return sr.ResetSession(ctx)
+	}
+
+	return nil
+}
+
+func (cn *conn) IsValid() bool {
+	if v, ok := cn.next.(driver.Validator); ok {
+		return v.IsValid()
+	}
+
+	return true
+}
+
+func (cn *conn) CheckNamedValue(nv *driver.NamedValue) error {
+	if nvc, ok := cn.next.(driver.NamedValueChecker); ok {
+		return nvc.CheckNamedValue(nv)
+	}
+
+	return driver.ErrSkip
+}
+
+// stmt records the calls made on a prepared statement
+type stmt struct {
+	next driver.Stmt
+	conn *conn
+}
+
+func (s *stmt) Close() error {
+	return s.next.Close()
+}
+
+func (s *stmt) NumInput() int {
+	return s.next.NumInput()
+}
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	start := time.Now()
+	result, err := s.next.Exec(args)
+	record(s.conn.c, "exec", start, err)
+
+	return result, err
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	start := time.Now()
+	rows, err := s.next.Query(args)
+	record(s.conn.c, "query", start, err)
+
+	return rows, err
+}
+
+func (s *stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	sec, ok := s.next.(driver.StmtExecContext)
+	if !ok {
+		values, err := toValues(args)
+		if err != nil {
+			return nil, err
+		}
+
+		return s.Exec(values)
+	}
+
+	start := time.Now()
+	result, err := sec.ExecContext(ctx, args)
+	record(s.conn.c, "exec", start, err)
+
+	return result, err
+}
+
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	sqc, ok := s.next.(driver.StmtQueryContext)
+	if !ok {
+		values, err := toValues(args)
+		if err != nil {
+			return nil, err
+		}
+
+		return s.Query(values)
+	}
+
+	start := time.Now()
+	rows, err := sqc.QueryContext(ctx, args)
+	record(s.conn.c, "query", start, err)
+
+	return rows, err
+}
+
+// CheckNamedValue asks the statement, then its connection, as
+// database/sql would, since it only asks the connection when the
+// statement can't check values
+func (s *stmt) CheckNamedValue(nv *driver.NamedValue) error {
+	if nvc, ok := s.next.(driver.NamedValueChecker); ok {
+		return nvc.CheckNamedValue(nv)
+	}
+
+	return s.conn.CheckNamedValue(nv)
+}
+
+// toValues turns arguments into the values of the calls without a
+// context
+func toValues(args []driver.NamedValue) ([]driver.Value, error) {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		if arg.Name != "" {
+			return nil, errNamedParameters
+		}
+
+		values[i] = arg.Value
+	}
+
+	return values, nil
+}
+
+// tx records how a transaction ended
+type tx struct {
+	next driver.Tx
+	c    *buckyclient.Client
+}
+
+func (t *tx) Commit() error {
+	start := time.Now()
+	err := t.next.Commit()
+	record(t.c, "commit", start, err)
+
+	return err
+}
+
+func (t *tx) Rollback() error {
+	start := time.Now()
+	err := t.next.Rollback()
+	record(t.c, "rollback", start, err)
+
+	return err
+}
@@ -0,0 +1,48 @@
+package buckysql
+
+import (
+	"database/sql"
+
+	"github.com/matzhouse/go-bucky-client"
+)
+
+// The connection pool gauges sent by RegisterPoolGauges
+const (
+	PoolOpenMetric      = "sql.pool.open"
+	PoolInUseMetric     = "sql.pool.in_use"
+	PoolIdleMetric      = "sql.pool.idle"
+	PoolMaxOpenMetric   = "sql.pool.max_open"
+	PoolWaitCountMetric = "sql.pool.wait_count" // Only goes up, so graph its rate
+	PoolWaitMetric      = "sql.pool.wait_ms"    // Only goes up, so graph its rate
+)
+
+// poolGauges read each gauge from the pool statistics
+var poolGauges = []struct {
+	name  string
+	value func(s sql.DBStats) float64
+}{
+	{PoolOpenMetric, func(s sql.DBStats) float64 { return float64(s.OpenConnections) }},
+	{PoolInUseMetric, func(s sql.DBStats) float64 { return float64(s.InUse) }},
+	{PoolIdleMetric, func(s sql.DBStats) float64 { return float64(s.Idle) }},
+	{PoolMaxOpenMetric, func(s sql.DBStats) float64 { return float64(s.MaxOpenConnections) }},
+	{PoolWaitCountMetric, func(s sql.DBStats) float64 { return float64(s.WaitCount) }},
+	{PoolWaitMetric, func(s sql.DBStats) float64 { return float64(s.WaitDuration.Milliseconds()) }},
+}
+
+// RegisterPoolGauges sends the connection pool statistics of db as gauges
+// with every flush, using Client.RegisterGauge. A pool that was given
+// SetMaxOpenConns and still waits for connections needs a bigger limit.
+func RegisterPoolGauges(c *buckyclient.Client, db *sql.DB) {
+	for _, g := range poolGauges {
+		value := g.value
+		c.RegisterGauge(g.name, func() float64 { return value(db.Stats()) })
+	}
+}
+
+// UnregisterPoolGauges stops sending the gauges of RegisterPoolGauges,
+// e.g. once the pool has been closed
+func UnregisterPoolGauges(c *buckyclient.Client) {
+	for _, g := range poolGauges {
+		c.UnregisterGauge(g.name)
+	}
+}
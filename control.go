@@ -0,0 +1,137 @@
+package buckyclient
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// ControlHandler returns a handler that lets an operator poke a running
+// client without redeploying it:
+//
+//	POST /flush   sends everything recorded so far straight away
+//	POST /pause   turns the client off, as SetEnabled(false)
+//	POST /resume  turns it back on
+//	GET  /stats   Stats as JSON, with whether the client is enabled
+//
+// Serve it on a private address only, as anyone who reaches it can stop
+// metrics being sent; WithControlEndpoint does that for you.
+func (c *Client) ControlHandler() http.Handler {
+	c = c.root()
+
+	mux := http.NewServeMux()
+
+	mux.Handle("/flush", controlCommand("POST", func(w http.ResponseWriter, r *http.Request) {
+		err := c.FlushContext(r.Context())
+
+		switch {
+		case err == nil:
+			w.Write([]byte("flushed\n"))
+		case errors.Is(err, ErrNoMetrics):
+			w.Write([]byte("nothing to flush\n"))
+		default:
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		}
+	}))
+
+	mux.Handle("/pause", controlCommand("POST", func(w http.ResponseWriter, r *http.Request) {
+		c.SetEnabled(false)
+		w.Write([]byte("paused\n"))
+	}))
+
+	mux.Handle("/resume", controlCommand("POST", func(w http.ResponseWriter, r *http.Request) {
+		c.SetEnabled(true)
+		w.Write([]byte("resumed\n"))
+	}))
+
+	mux.Handle("/stats", controlCommand("GET", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(struct {
+			Enabled bool
+			Stats
+		}{c.Enabled(), c.Stats()})
+	}))
+
+	return mux
+}
+
+// controlCommand only lets requests with the given method through
+func controlCommand(method string, fn http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			w.Header().Set("Allow", method)
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		fn(w, r)
+	})
+}
+
+// WithControlEndpoint serves ControlHandler for as long as the client
+// runs, on a unix socket given as "unix:/path/to/socket" or on a loopback
+// address such as "127.0.0.1:9102" or "localhost:9102":
+//
+//	curl -X POST --unix-socket /run/app/bucky.sock http://bucky/flush
+//
+// Other addresses are refused so the endpoint can't be reached from the
+// network by mistake. NewClient and Build fail if it can't listen.
+func WithControlEndpoint(addr string) Option {
+	return func(c *Client) error {
+		if path, ok := strings.CutPrefix(addr, "unix:"); ok {
+			if path == "" {
+				return invalidOption("WithControlEndpoint", "socket path must not be empty")
+			}
+		} else if !loopbackAddr(addr) {
+			return invalidOption("WithControlEndpoint", "address must be a unix socket or on localhost")
+		}
+
+		c.controlAddr = addr
+		return nil
+	}
+}
+
+// loopbackAddr reports whether a host:port can only be reached locally
+func loopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+
+	if host == "localhost" {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// listenControl starts serving the control endpoint, if there is one
+func (c *Client) listenControl() error {
+	if c.controlAddr == "" {
+		return nil
+	}
+
+	network, addr := "tcp", c.controlAddr
+	if path, ok := strings.CutPrefix(addr, "unix:"); ok {
+		network, addr = "unix", path
+
+		// A socket left behind by a process that didn't exit cleanly
+		if fi, err := os.Stat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+			os.Remove(path)
+		}
+	}
+
+	l, err := net.Listen(network, addr)
+	if err != nil {
+		return err
+	}
+
+	c.control = &http.Server{Handler: c.ControlHandler()}
+	c.goroutines.spawn("control", func() { c.control.Serve(l) })
+
+	return nil
+}
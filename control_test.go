@@ -0,0 +1,99 @@
+package buckyclient
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestControl_Client_ControlHandler(t *testing.T) {
+	rt := &recordingTransport{}
+	c := newRetryClient(rt)
+	h := c.ControlHandler()
+
+	do := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w
+	}
+
+	c.aggregate(MetricWithAmount{Metric{name: "hits", unit: UnitCount}, Amount{Value: 1}, ActionSum})
+
+	w := do("GET", "/stats")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var stats struct {
+		Enabled bool
+		Metrics int
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &stats))
+	assert.True(t, stats.Enabled)
+	assert.Equal(t, 1, stats.Metrics)
+
+	w = do("POST", "/flush")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "flushed\n", w.Body.String())
+	assert.Equal(t, []string{"hits:1|c\n"}, rt.payloads)
+
+	assert.Equal(t, "nothing to flush\n", do("POST", "/flush").Body.String())
+
+	assert.Equal(t, http.StatusOK, do("POST", "/pause").Code)
+	assert.False(t, c.Enabled())
+	assert.Equal(t, http.StatusServiceUnavailable, do("POST", "/flush").Code)
+
+	assert.Equal(t, http.StatusOK, do("POST", "/resume").Code)
+	assert.True(t, c.Enabled())
+
+	assert.Equal(t, http.StatusMethodNotAllowed, do("GET", "/flush").Code)
+	assert.Equal(t, http.StatusNotFound, do("POST", "/restart").Code)
+}
+
+func TestControl_WithControlEndpoint_Unix(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer ts.Close()
+
+	path := filepath.Join(t.TempDir(), "bucky.sock")
+
+	c, err := NewClient(ts.URL, 0, WithControlEndpoint("unix:"+path))
+	assert.NoError(t, err)
+	c.SetLogger(log.New(ioutil.Discard, "", 0))
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", path)
+		},
+	}}
+
+	resp, err := client.Post("http://bucky/pause", "", nil)
+	if assert.NoError(t, err) {
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+
+	assert.False(t, c.Enabled())
+
+	client.CloseIdleConnections()
+	assert.NoError(t, c.Close())
+
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err), "the socket is removed on close")
+}
+
+func TestControl_WithControlEndpoint_Invalid(t *testing.T) {
+	for _, addr := range []string{"", "unix:", ":9102", "0.0.0.0:9102", "example.com:9102", "localhost"} {
+		assert.ErrorIs(t, WithControlEndpoint(addr)(&Client{}), ErrInvalidOption, addr)
+	}
+
+	for _, addr := range []string{"localhost:9102", "127.0.0.1:0", "[::1]:9102"} {
+		assert.NoError(t, WithControlEndpoint(addr)(&Client{}), addr)
+	}
+}
@@ -0,0 +1,211 @@
+package buckyclient
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+const (
+	// CPUGoMaxProcsMetric is runtime.GOMAXPROCS
+	CPUGoMaxProcsMetric = "buckyclient.cpu.gomaxprocs"
+
+	// CPUNumCPUMetric is runtime.NumCPU, the CPUs the process may run on
+	CPUNumCPUMetric = "buckyclient.cpu.num_cpu"
+
+	// CPUQuotaMetric is the cgroup CPU limit in thousandths of a CPU. It is
+	// only sent when there is a limit.
+	CPUQuotaMetric = "buckyclient.cpu.quota_millicores"
+
+	// CPUPeriodsMetric is the number of cgroup scheduling periods so far
+	CPUPeriodsMetric = "buckyclient.cpu.periods"
+
+	// CPUThrottledPeriodsMetric is the number of periods the cgroup was
+	// throttled in
+	CPUThrottledPeriodsMetric = "buckyclient.cpu.throttled_periods"
+
+	// CPUThrottledMetric is the total time the cgroup has been throttled for,
+	// in milliseconds
+	CPUThrottledMetric = "buckyclient.cpu.throttled_ms"
+)
+
+// WithCPUCollector reports GOMAXPROCS, the number of usable CPUs and, on
+// Linux, the cgroup CPU quota and throttling counters as gauges with every
+// default flush. The counters only ever go up, so graph their rate of
+// change. Throttling explains latency timers that jump while the CPU looks
+// idle, which is common for containers with a tight quota.
+//
+// Both cgroup v1 and v2 are supported. Values that can't be read, e.g.
+// outside a container or on another OS, are skipped.
+func WithCPUCollector() Option {
+	return func(c *Client) error {
+		c.collectors = append(c.collectors, cpuCollector{root: "/"}.collect)
+		return nil
+	}
+}
+
+// cpuCollector reads cgroup files below root, so tests can fake them
+type cpuCollector struct {
+	root string
+}
+
+func (cc cpuCollector) collect(gauge func(name string, value int64)) {
+	gauge(CPUGoMaxProcsMetric, int64(runtime.GOMAXPROCS(0)))
+	gauge(CPUNumCPUMetric, int64(runtime.NumCPU()))
+
+	v2, v1 := cc.cgroupPaths()
+
+	for _, dir := range cc.candidates(v2, "") {
+		if cc.collectV2(dir, gauge) {
+			return
+		}
+	}
+
+	for _, dir := range append(cc.candidates(v1, "cpu"), cc.candidates(v1, "cpu,cpuacct")...) {
+		if cc.collectV1(dir, gauge) {
+			return
+		}
+	}
+}
+
+// candidates lists the directories to look for cgroup files in, most
+// specific first
+func (cc cpuCollector) candidates(path, controller string) []string {
+	base := filepath.Join(cc.root, "sys/fs/cgroup", controller)
+
+	if path == "" || path == "/" {
+		return []string{base}
+	}
+
+	return []string{filepath.Join(base, path), base}
+}
+
+// cgroupPaths reads the v2 path and the v1 cpu controller path of this
+// process from /proc/self/cgroup
+func (cc cpuCollector) cgroupPaths() (v2, v1 string) {
+	f, err := os.Open(filepath.Join(cc.root, "proc/self/cgroup"))
+	if err != nil {
+		return "", ""
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// hierarchy-ID:controller-list:cgroup-path
+		parts := strings.SplitN(scanner.Text(), ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+
+		if parts[0] == "0" && parts[1] == "" {
+			v2 = parts[2]
+			continue
+		}
+
+		for _, controller := range strings.Split(parts[1], ",") {
+			if controller == "cpu" {
+				v1 = parts[2]
+			}
+		}
+	}
+
+	return v2, v1
+}
+
+// collectV2 reads cpu.max and cpu.stat, returning false if neither exists
+func (cc cpuCollector) collectV2(dir string, gauge func(name string, value int64)) bool {
+	found := false
+
+	// "max 100000" or "<quota> <period>", both in microseconds
+	if b, err := os.ReadFile(filepath.Join(dir, "cpu.max")); err == nil {
+		found = true
+
+		fields := strings.Fields(string(b))
+		if len(fields) == 2 && fields[0] != "max" {
+			quota, err1 := strconv.ParseInt(fields[0], 10, 64)
+			period, err2 := strconv.ParseInt(fields[1], 10, 64)
+
+			if err1 == nil && err2 == nil && period > 0 {
+				gauge(CPUQuotaMetric, quota*1000/period)
+			}
+		}
+	}
+
+	if stat, ok := readStat(filepath.Join(dir, "cpu.stat")); ok {
+		found = true
+
+		gaugeStat(gauge, stat, "nr_periods", CPUPeriodsMetric, 1)
+		gaugeStat(gauge, stat, "nr_throttled", CPUThrottledPeriodsMetric, 1)
+		gaugeStat(gauge, stat, "throttled_usec", CPUThrottledMetric, 1000)
+	}
+
+	return found
+}
+
+// collectV1 reads cpu.cfs_quota_us, cpu.cfs_period_us and cpu.stat,
+// returning false if none of them exist
+func (cc cpuCollector) collectV1(dir string, gauge func(name string, value int64)) bool {
+	found := false
+
+	quota, err1 := readInt(filepath.Join(dir, "cpu.cfs_quota_us"))
+	period, err2 := readInt(filepath.Join(dir, "cpu.cfs_period_us"))
+
+	if err1 == nil && err2 == nil {
+		found = true
+
+		// A quota of -1 means no limit
+		if quota > 0 && period > 0 {
+			gauge(CPUQuotaMetric, quota*1000/period)
+		}
+	}
+
+	if stat, ok := readStat(filepath.Join(dir, "cpu.stat")); ok {
+		found = true
+
+		gaugeStat(gauge, stat, "nr_periods", CPUPeriodsMetric, 1)
+		gaugeStat(gauge, stat, "nr_throttled", CPUThrottledPeriodsMetric, 1)
+		gaugeStat(gauge, stat, "throttled_time", CPUThrottledMetric, 1000000)
+	}
+
+	return found
+}
+
+func gaugeStat(gauge func(name string, value int64), stat map[string]int64, key, name string, divisor int64) {
+	if v, ok := stat[key]; ok {
+		gauge(name, v/divisor)
+	}
+}
+
+// readStat reads a flat keyed file of "key value" lines
+func readStat(path string) (map[string]int64, bool) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+
+	stat := make(map[string]int64)
+	for _, line := range strings.Split(string(b), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+
+		if v, err := strconv.ParseInt(fields[1], 10, 64); err == nil {
+			stat[fields[0]] = v
+		}
+	}
+
+	return stat, true
+}
+
+func readInt(path string) (int64, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseInt(strings.TrimSpace(string(b)), 10, 64)
+}
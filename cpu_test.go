@@ -0,0 +1,90 @@
+package buckyclient
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeRoot writes files below a temporary directory
+func fakeRoot(t *testing.T, files map[string]string) string {
+	root := t.TempDir()
+
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+
+	return root
+}
+
+func collectGauges(cc cpuCollector) map[string]int64 {
+	gauges := make(map[string]int64)
+	cc.collect(func(name string, value int64) { gauges[name] = value })
+
+	return gauges
+}
+
+func TestCPU_cpuCollector_V2(t *testing.T) {
+	root := fakeRoot(t, map[string]string{
+		"proc/self/cgroup":                     "0::/kubepods/pod1\n",
+		"sys/fs/cgroup/kubepods/pod1/cpu.max":  "150000 100000\n",
+		"sys/fs/cgroup/kubepods/pod1/cpu.stat": "usage_usec 100\nnr_periods 40\nnr_throttled 7\nthrottled_usec 25000\n",
+	})
+
+	assert.Equal(t, map[string]int64{
+		CPUGoMaxProcsMetric:       int64(runtime.GOMAXPROCS(0)),
+		CPUNumCPUMetric:           int64(runtime.NumCPU()),
+		CPUQuotaMetric:            1500,
+		CPUPeriodsMetric:          40,
+		CPUThrottledPeriodsMetric: 7,
+		CPUThrottledMetric:        25,
+	}, collectGauges(cpuCollector{root: root}))
+}
+
+func TestCPU_cpuCollector_V2Unlimited(t *testing.T) {
+	// Inside a container the cgroup is mounted at the root
+	root := fakeRoot(t, map[string]string{
+		"proc/self/cgroup":      "0::/\n",
+		"sys/fs/cgroup/cpu.max": "max 100000\n",
+	})
+
+	gauges := collectGauges(cpuCollector{root: root})
+	assert.NotContains(t, gauges, CPUQuotaMetric)
+	assert.NotContains(t, gauges, CPUThrottledMetric)
+	assert.Contains(t, gauges, CPUGoMaxProcsMetric)
+}
+
+func TestCPU_cpuCollector_V1(t *testing.T) {
+	root := fakeRoot(t, map[string]string{
+		"proc/self/cgroup":                            "12:memory:/docker/abc\n4:cpu,cpuacct:/docker/abc\n",
+		"sys/fs/cgroup/cpu,cpuacct/cpu.cfs_quota_us":  "50000\n",
+		"sys/fs/cgroup/cpu,cpuacct/cpu.cfs_period_us": "100000\n",
+		"sys/fs/cgroup/cpu,cpuacct/cpu.stat":          "nr_periods 10\nnr_throttled 2\nthrottled_time 3000000\n",
+	})
+
+	gauges := collectGauges(cpuCollector{root: root})
+	assert.Equal(t, int64(500), gauges[CPUQuotaMetric])
+	assert.Equal(t, int64(10), gauges[CPUPeriodsMetric])
+	assert.Equal(t, int64(2), gauges[CPUThrottledPeriodsMetric])
+	assert.Equal(t, int64(3), gauges[CPUThrottledMetric])
+}
+
+func TestCPU_cpuCollector_NoCgroup(t *testing.T) {
+	gauges := collectGauges(cpuCollector{root: t.TempDir()})
+	assert.Len(t, gauges, 2)
+}
+
+func TestCPU_WithCPUCollector(t *testing.T) {
+	c := &Client{metrics: make(map[Metric]Value)}
+	assert.NoError(t, WithCPUCollector()(c))
+
+	metrics := c.runCollectors()
+	assert.True(t, len(metrics) >= 2)
+	assert.Equal(t, Metric{name: CPUGoMaxProcsMetric, unit: UnitGauge}, metrics[0].Metric)
+	assert.Equal(t, ActionLast, metrics[0].Action)
+}
@@ -0,0 +1,197 @@
+package buckyclient
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// dashboardHistory is how many flushes the dashboard remembers
+const dashboardHistory = 20
+
+// flushRecord is a summary of one payload sent, kept for the dashboard
+type flushRecord struct {
+	At     time.Time
+	Seq    uint64
+	Target string
+	Lines  int
+	Bytes  int
+	Err    string
+}
+
+// flushHistory is a small ring of the most recent flushes. The zero value
+// is ready to use.
+type flushHistory struct {
+	m sync.Mutex
+
+	records []flushRecord // Oldest first
+	failing int           // Failures since the last success
+
+	lastSuccess time.Time
+}
+
+// add records a payload the client tried to send
+func (h *flushHistory) add(info FlushInfo, target string, payload []byte, err error, now time.Time) {
+	r := flushRecord{
+		At:     now,
+		Seq:    info.Seq,
+		Target: target,
+		Lines:  bytes.Count(payload, []byte("\n")),
+		Bytes:  len(payload),
+	}
+
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	if err != nil {
+		r.Err = err.Error()
+		h.failing++
+	} else {
+		h.failing = 0
+		h.lastSuccess = now
+	}
+
+	if len(h.records) == dashboardHistory {
+		copy(h.records, h.records[1:])
+		h.records = h.records[:len(h.records)-1]
+	}
+
+	h.records = append(h.records, r)
+}
+
+// dashboardPage is everything shown on the dashboard
+type dashboardPage struct {
+	Now         time.Time
+	Host        string
+	Enabled     bool
+	Healthy     bool
+	Failing     int
+	LastSuccess time.Time
+	Queued      int
+	QueuedBytes int
+	Pending     []string
+	Flushes     []flushRecord // Newest first
+}
+
+// DashboardHandler returns a handler serving a small HTML page with the
+// aggregates waiting for the next flush, the most recent flushes and
+// whether they are succeeding. It is meant for looking at a single box
+// without a backend, so it has no external assets and refreshes itself
+// every few seconds. Nothing is reset by viewing it.
+func (c *Client) DashboardHandler() http.Handler {
+	c = c.root()
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" && r.Method != "HEAD" {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		buf := &bytes.Buffer{}
+		if err := dashboardTemplate.Execute(buf, c.dashboardPage(time.Now())); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(buf.Bytes())
+	})
+}
+
+// dashboardPage collects what the dashboard shows
+func (c *Client) dashboardPage(now time.Time) dashboardPage {
+	page := dashboardPage{
+		Now:     now,
+		Host:    c.hostURL,
+		Enabled: c.Enabled(),
+	}
+
+	c.drainBatches()
+
+	c.m.Lock()
+
+	buf := &bytes.Buffer{}
+	for k, v := range c.metrics {
+		v.eachLine(k.name, func(name string, value number) {
+			writeTaggedLine(buf, c.prefix+name, value, k.unit, k.tags, c.tagFormat)
+		})
+	}
+
+	c.m.Unlock()
+
+	if buf.Len() > 0 {
+		page.Pending = strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+		sort.Strings(page.Pending)
+	}
+
+	if c.spool != nil {
+		c.spool.m.Lock()
+		page.Queued, page.QueuedBytes = len(c.spool.entries), c.spool.bytes
+		c.spool.m.Unlock()
+	}
+
+	c.history.m.Lock()
+
+	page.Failing, page.LastSuccess = c.history.failing, c.history.lastSuccess
+	for i := len(c.history.records) - 1; i >= 0; i-- {
+		page.Flushes = append(page.Flushes, c.history.records[i])
+	}
+
+	c.history.m.Unlock()
+
+	page.Healthy = page.Enabled && page.Failing == 0
+
+	return page
+}
+
+var dashboardTemplate = template.Must(template.New("dashboard").Funcs(template.FuncMap{
+	"since": func(now, t time.Time) string {
+		if t.IsZero() {
+			return "never"
+		}
+
+		return now.Sub(t).Round(time.Second).String() + " ago"
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="5">
+<title>bucky client</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+td, th { padding: 0.2em 0.8em; text-align: left; border-bottom: 1px solid #ddd; }
+code, td.line { font-family: monospace; }
+.ok { color: #080; }
+.bad { color: #c00; }
+</style>
+</head>
+<body>
+<h1>bucky client</h1>
+
+<h2>Health</h2>
+<table>
+<tr><th>Server</th><td><code>{{.Host}}</code></td></tr>
+<tr><th>Status</th><td>{{if .Healthy}}<span class="ok">healthy</span>{{else if not .Enabled}}<span class="bad">disabled</span>{{else}}<span class="bad">failing ({{.Failing}} in a row)</span>{{end}}</td></tr>
+<tr><th>Last success</th><td>{{since .Now .LastSuccess}}</td></tr>
+<tr><th>Retry queue</th><td>{{.Queued}} payloads, {{.QueuedBytes}} bytes</td></tr>
+</table>
+
+<h2>Pending ({{len .Pending}} lines)</h2>
+{{if .Pending}}<table>
+{{range .Pending}}<tr><td class="line">{{.}}</td></tr>
+{{end}}</table>{{else}}<p>Nothing waiting for the next flush.</p>{{end}}
+
+<h2>Recent flushes</h2>
+{{if .Flushes}}<table>
+<tr><th>Flush</th><th>When</th><th>Target</th><th>Lines</th><th>Bytes</th><th>Result</th></tr>
+{{range .Flushes}}<tr><td>{{.Seq}}</td><td>{{since $.Now .At}}</td><td><code>{{.Target}}</code></td><td>{{.Lines}}</td><td>{{.Bytes}}</td><td>{{if .Err}}<span class="bad">{{.Err}}</span>{{else}}<span class="ok">ok</span>{{end}}</td></tr>
+{{end}}</table>{{else}}<p>Nothing has been sent yet.</p>{{end}}
+</body>
+</html>
+`))
@@ -0,0 +1,78 @@
+package buckyclient
+
+import (
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDashboard_Client_DashboardHandler(t *testing.T) {
+	status := http.StatusOK
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	c := &Client{
+		hostURL:    srv.URL,
+		http:       &http.Client{},
+		logger:     log.New(ioutil.Discard, "", 0),
+		metrics:    make(map[Metric]Value),
+		bufferPool: newBufferPool(),
+	}
+
+	c.aggregate(MetricWithAmount{Metric{name: "sent", unit: UnitCount}, Amount{Value: 1}, ActionSum})
+	assert.NoError(t, c.flush())
+
+	status = http.StatusInternalServerError
+	c.aggregate(MetricWithAmount{Metric{name: "failed", unit: UnitCount}, Amount{Value: 1}, ActionSum})
+	assert.Error(t, c.flush())
+
+	c.aggregate(MetricWithAmount{Metric{name: "<waiting>", unit: UnitGauge, tags: "env:prod"}, Amount{Value: 7}, ActionLast})
+
+	rec := httptest.NewRecorder()
+	c.DashboardHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "text/html; charset=utf-8", rec.Header().Get("Content-Type"))
+
+	body := rec.Body.String()
+	assert.Contains(t, body, "&lt;waiting&gt;:7|g|#env:prod")
+	assert.Contains(t, body, "failing (1 in a row)")
+	assert.Contains(t, body, "Non-success HTTP Status Code (500)")
+	assert.NotContains(t, body, "<script")
+
+	// Viewing the page doesn't reset anything
+	assert.Equal(t, 1, c.PendingLines())
+
+	rec = httptest.NewRecorder()
+	c.DashboardHandler().ServeHTTP(rec, httptest.NewRequest("POST", "/", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
+
+func TestDashboard_flushHistory_add(t *testing.T) {
+	h := &flushHistory{}
+	now := time.Now()
+
+	for i := 1; i <= dashboardHistory+5; i++ {
+		h.add(FlushInfo{Seq: uint64(i)}, "t", []byte("a:1|c\nb:2|c\n"), nil, now)
+	}
+
+	assert.Len(t, h.records, dashboardHistory)
+	assert.Equal(t, uint64(6), h.records[0].Seq)
+	assert.Equal(t, 2, h.records[0].Lines)
+	assert.Equal(t, now, h.lastSuccess)
+
+	h.add(FlushInfo{Seq: 100}, "t", nil, errors.New("down"), now.Add(time.Second))
+	h.add(FlushInfo{Seq: 101}, "t", nil, errors.New("down"), now.Add(2*time.Second))
+	assert.Equal(t, 2, h.failing)
+	assert.Equal(t, now, h.lastSuccess)
+	assert.Equal(t, "down", h.records[len(h.records)-1].Err)
+}
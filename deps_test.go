@@ -0,0 +1,67 @@
+package buckyclient
+
+import (
+	"go/build"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const modulePath = "github.com/matzhouse/go-bucky-client"
+
+// coreDeps lists the packages that must build with the standard library
+// alone. Integrations with other dependencies belong in their own module,
+// or behind a build tag, so they can't end up in here.
+var coreDeps = []string{".", "buckytest", "relay", "statsd", "transport/udp", "contrib/buckyhttp", "contrib/buckysql", "cmd/bucky-demo"}
+
+func TestDeps_StandardLibraryOnly(t *testing.T) {
+	for _, dir := range coreDeps {
+		pkg, err := build.ImportDir(dir, 0)
+		if !assert.NoError(t, err, dir) {
+			continue
+		}
+
+		for _, path := range pkg.Imports {
+			assert.True(t, isStandard(path) || isModule(path), "%s imports %s", dir, path)
+		}
+	}
+}
+
+func TestDeps_EveryPackageListed(t *testing.T) {
+	listed := make(map[string]bool)
+	for _, dir := range coreDeps {
+		listed[dir] = true
+	}
+
+	filepath.Walk(".", func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.IsDir() {
+			return err
+		}
+
+		// Nested modules manage their own dependencies
+		if path != "." {
+			if _, err := os.Stat(filepath.Join(path, "go.mod")); err == nil {
+				return filepath.SkipDir
+			}
+		}
+
+		if pkg, err := build.ImportDir(path, 0); err == nil && len(pkg.GoFiles) > 0 {
+			assert.True(t, listed[filepath.ToSlash(path)], "%s isn't in coreDeps", path)
+		}
+
+		return nil
+	})
+}
+
+// isStandard reports whether an import path is in the standard library,
+// whose paths never have a dot in the first element
+func isStandard(path string) bool {
+	return !strings.Contains(strings.SplitN(path, "/", 2)[0], ".")
+}
+
+func isModule(path string) bool {
+	return path == modulePath || strings.HasPrefix(path, modulePath+"/")
+}
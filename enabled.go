@@ -0,0 +1,41 @@
+package buckyclient
+
+import (
+	"errors"
+	"sync/atomic"
+)
+
+var (
+	// ErrDisabled is returned when a flush is asked for while the client is disabled
+	ErrDisabled = errors.New("Client disabled")
+)
+
+// Enabled reports whether the client is recording and flushing metrics
+func (c *Client) Enabled() bool {
+	c = c.root()
+
+	return atomic.LoadInt32(&c.disabled) == 0
+}
+
+// SetEnabled turns the client on or off at runtime. While it is off every
+// recording call returns straight away and nothing is flushed, which makes
+// it usable as a kill switch. Metrics already aggregated are kept and sent
+// once the client is turned back on.
+func (c *Client) SetEnabled(enabled bool) {
+	c = c.root()
+
+	var disabled int32
+	if !enabled {
+		disabled = 1
+	}
+
+	atomic.StoreInt32(&c.disabled, disabled)
+}
+
+// WithEnabled sets whether the client starts out enabled
+func WithEnabled(enabled bool) Option {
+	return func(c *Client) error {
+		c.SetEnabled(enabled)
+		return nil
+	}
+}
@@ -0,0 +1,70 @@
+package buckyclient
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnabled_Client_SetEnabled(t *testing.T) {
+	cl := &Client{
+		input: make(chan MetricWithAmount, 10),
+	}
+
+	assert.True(t, cl.Enabled())
+
+	cl.SetEnabled(false)
+	assert.False(t, cl.Enabled())
+
+	cl.Count("myapp.facet", 1)
+	cl.Timer("myapp.timer", 1)
+	assert.NoError(t, cl.Record("myapp.facet", 1, UnitCount, ActionSum))
+
+	time.Sleep(time.Millisecond * 20) // Give any goroutines a chance to run
+	assert.Equal(t, 0, len(cl.input))
+	assert.Equal(t, ErrDisabled, cl.FlushContext(context.Background()))
+
+	cl.SetEnabled(true)
+	cl.Count("myapp.facet", 1)
+
+	time.Sleep(time.Millisecond * 20)
+	assert.Equal(t, 1, len(cl.input))
+}
+
+func TestEnabled_Client_sender_Disabled(t *testing.T) {
+	var posts int32
+
+	mockBucky := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&posts, 1)
+	}))
+	defer mockBucky.Close()
+
+	cl := &Client{
+		hostURL:    mockBucky.URL,
+		http:       &http.Client{},
+		logger:     log.New(ioutil.Discard, "", 0),
+		interval:   5 * time.Millisecond,
+		input:      make(chan MetricWithAmount, 10),
+		stop:       make(chan bool, 1),
+		stopped:    make(chan bool, 1),
+		metrics:    make(map[Metric]Value),
+		bufferPool: newBufferPool(),
+	}
+	assert.NoError(t, WithEnabled(false)(cl))
+
+	cl.handleMetricWithValue(MetricWithAmount{Metric{name: "myapp.facet", unit: UnitCount}, Amount{Value: 1}, ActionSum})
+
+	cl.sender()
+	time.Sleep(time.Millisecond * 50)
+	cl.Stop()
+
+	assert.Equal(t, int32(0), atomic.LoadInt32(&posts))
+	assert.Equal(t, 1, cl.PendingLines())
+}
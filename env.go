@@ -0,0 +1,143 @@
+package buckyclient
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNoURL is returned by NewClientFromEnv when BUCKY_URL isn't set
+var ErrNoURL = errors.New("BUCKY_URL is not set")
+
+// NewClientFromEnv creates a client configured by environment variables,
+// so a deployment can point it somewhere else without a code change:
+//
+//	BUCKY_URL             the server, as given to NewClient; required
+//	BUCKY_INTERVAL        how often to flush, e.g. 30s, or a number of seconds
+//	BUCKY_PREFIX          see WithPrefix
+//	BUCKY_TAGS            default tags as key:value pairs separated by commas, see WithTags
+//	BUCKY_ENABLED         false to start disabled, see WithEnabled
+//	BUCKY_HTTP_TIMEOUT    see WithHTTPTimeout
+//	BUCKY_BEARER_TOKEN    see WithBearerToken
+//	BUCKY_MAX_METRICS     see WithMaxMetrics
+//	BUCKY_RUNTIME_METRICS how often to sample the runtime, see WithRuntimeMetrics
+//
+// Variables that are unset or empty are left at their defaults. The
+// options given are applied after those from the environment, so code can
+// still override them. Every variable that can't be parsed is reported
+// together in a ConfigError.
+func NewClientFromEnv(opts ...Option) (*Client, error) {
+	host, envOpts, errs := optionsFromEnv(os.Getenv)
+	if len(errs) > 0 {
+		return nil, &ConfigError{Errors: errs}
+	}
+
+	return NewClient(host, 0, append(envOpts, opts...)...)
+}
+
+// optionsFromEnv returns the URL and options the environment asks for
+func optionsFromEnv(getenv func(string) string) (host string, opts []Option, errs []error) {
+	host = getenv("BUCKY_URL")
+	if host == "" {
+		errs = append(errs, ErrNoURL)
+	}
+
+	if v := getenv("BUCKY_INTERVAL"); v != "" {
+		if d, err := parseDuration(v); err != nil {
+			errs = append(errs, fmt.Errorf("BUCKY_INTERVAL: %w", err))
+		} else {
+			opts = append(opts, WithInterval(d))
+		}
+	}
+
+	if v := getenv("BUCKY_PREFIX"); v != "" {
+		opts = append(opts, WithPrefix(v))
+	}
+
+	if v := getenv("BUCKY_TAGS"); v != "" {
+		if tags, err := parseEnvTags(v); err != nil {
+			errs = append(errs, fmt.Errorf("BUCKY_TAGS: %w", err))
+		} else {
+			opts = append(opts, WithTags(tags...))
+		}
+	}
+
+	if v := getenv("BUCKY_ENABLED"); v != "" {
+		if enabled, err := strconv.ParseBool(v); err != nil {
+			errs = append(errs, fmt.Errorf("BUCKY_ENABLED: %q is not true or false", v))
+		} else {
+			opts = append(opts, WithEnabled(enabled))
+		}
+	}
+
+	if v := getenv("BUCKY_HTTP_TIMEOUT"); v != "" {
+		if d, err := parseDuration(v); err != nil {
+			errs = append(errs, fmt.Errorf("BUCKY_HTTP_TIMEOUT: %w", err))
+		} else {
+			opts = append(opts, WithHTTPTimeout(d))
+		}
+	}
+
+	if v := getenv("BUCKY_BEARER_TOKEN"); v != "" {
+		opts = append(opts, WithBearerToken(v))
+	}
+
+	if v := getenv("BUCKY_MAX_METRICS"); v != "" {
+		if n, err := strconv.Atoi(v); err != nil {
+			errs = append(errs, fmt.Errorf("BUCKY_MAX_METRICS: %q is not a number", v))
+		} else {
+			opts = append(opts, WithMaxMetrics(n))
+		}
+	}
+
+	if v := getenv("BUCKY_RUNTIME_METRICS"); v != "" {
+		if d, err := parseDuration(v); err != nil {
+			errs = append(errs, fmt.Errorf("BUCKY_RUNTIME_METRICS: %w", err))
+		} else {
+			opts = append(opts, WithRuntimeMetrics(d))
+		}
+	}
+
+	return host, opts, errs
+}
+
+// parseDuration parses a duration such as 30s, or a plain number of
+// seconds as NewClient takes
+func parseDuration(v string) (time.Duration, error) {
+	if seconds, err := strconv.Atoi(v); err == nil {
+		return time.Duration(seconds) * time.Second, nil
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a duration or a number of seconds", v)
+	}
+
+	return d, nil
+}
+
+// parseEnvTags parses key:value pairs separated by commas. A key without
+// a value is a tag with an empty value.
+func parseEnvTags(v string) ([]Tag, error) {
+	var tags []Tag
+
+	for _, pair := range strings.Split(v, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		key, value, _ := strings.Cut(pair, ":")
+		key = strings.TrimSpace(key)
+		if key == "" {
+			return nil, fmt.Errorf("%q has no key", pair)
+		}
+
+		tags = append(tags, Tag{Key: key, Value: strings.TrimSpace(value)})
+	}
+
+	return tags, nil
+}
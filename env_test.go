@@ -0,0 +1,61 @@
+package buckyclient
+
+import (
+	"errors"
+	"io/ioutil"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnv_NewClientFromEnv(t *testing.T) {
+	t.Setenv("BUCKY_URL", "http://localhost:8005/bucky/v1/send")
+	t.Setenv("BUCKY_INTERVAL", "15s")
+	t.Setenv("BUCKY_PREFIX", "myapp")
+	t.Setenv("BUCKY_TAGS", "env:prod, region:eu,canary")
+	t.Setenv("BUCKY_ENABLED", "false")
+	t.Setenv("BUCKY_MAX_METRICS", "100")
+
+	c, err := NewClientFromEnv(WithMaxMetrics(50))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer c.Close()
+	c.SetLogger(log.New(ioutil.Discard, "", 0))
+
+	assert.Equal(t, 15*time.Second, c.interval)
+	assert.Equal(t, "myapp.", c.prefix)
+	assert.Equal(t, []Tag{{"env", "prod"}, {"region", "eu"}, {"canary", ""}}, c.tags)
+	assert.False(t, c.Enabled())
+	assert.Equal(t, 50, c.maxMetrics, "options given in code win")
+}
+
+func TestEnv_NewClientFromEnv_Invalid(t *testing.T) {
+	t.Setenv("BUCKY_URL", "")
+	t.Setenv("BUCKY_INTERVAL", "soon")
+	t.Setenv("BUCKY_TAGS", ":prod")
+	t.Setenv("BUCKY_ENABLED", "maybe")
+
+	_, err := NewClientFromEnv()
+
+	var configErr *ConfigError
+	if assert.True(t, errors.As(err, &configErr)) {
+		assert.Len(t, configErr.Errors, 4)
+	}
+
+	assert.True(t, errors.Is(err, ErrNoURL))
+	assert.Contains(t, err.Error(), `BUCKY_INTERVAL: "soon" is not a duration or a number of seconds`)
+}
+
+func TestEnv_parseDuration(t *testing.T) {
+	for v, want := range map[string]time.Duration{"30": 30 * time.Second, "1m30s": 90 * time.Second, "250ms": 250 * time.Millisecond} {
+		d, err := parseDuration(v)
+		assert.NoError(t, err, v)
+		assert.Equal(t, want, d, v)
+	}
+
+	_, err := parseDuration("10 seconds")
+	assert.Error(t, err)
+}
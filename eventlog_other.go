@@ -0,0 +1,20 @@
+//go:build !windows
+
+package buckyclient
+
+import "errors"
+
+// eventLog is only implemented on Windows
+type eventLog struct{}
+
+func openEventLog(source string) (*eventLog, error) {
+	return nil, errors.New("the event log is only available on Windows")
+}
+
+func (l *eventLog) report(s severity, msg string) error {
+	return nil
+}
+
+func (l *eventLog) Close() error {
+	return nil
+}
@@ -0,0 +1,67 @@
+package buckyclient
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+var (
+	advapi32                  = syscall.NewLazyDLL("advapi32.dll")
+	procRegisterEventSourceW  = advapi32.NewProc("RegisterEventSourceW")
+	procDeregisterEventSource = advapi32.NewProc("DeregisterEventSource")
+	procReportEventW          = advapi32.NewProc("ReportEventW")
+)
+
+// eventLogTypes are the event types of each severity
+var eventLogTypes = map[severity]uintptr{
+	severityInfo:    0x0004, // EVENTLOG_INFORMATION_TYPE
+	severityWarning: 0x0002, // EVENTLOG_WARNING_TYPE
+	severityError:   0x0001, // EVENTLOG_ERROR_TYPE
+}
+
+// eventLogID is the event ID of every line
+const eventLogID = 1
+
+// eventLog is a handle to a registered event source
+type eventLog struct {
+	handle uintptr
+}
+
+func openEventLog(source string) (*eventLog, error) {
+	name, err := syscall.UTF16PtrFromString(source)
+	if err != nil {
+		return nil, err
+	}
+
+	handle, _, err := procRegisterEventSourceW.Call(0, uintptr(unsafe.Pointer(name)))
+	if handle == 0 {
+		return nil, err
+	}
+
+	return &eventLog{handle: handle}, nil
+}
+
+func (l *eventLog) report(s severity, msg string) error {
+	str, err := syscall.UTF16PtrFromString(msg)
+	if err != nil {
+		return err
+	}
+
+	strs := []*uint16{str}
+
+	ok, _, err := procReportEventW.Call(l.handle, eventLogTypes[s], 0, eventLogID, 0, 1, 0, uintptr(unsafe.Pointer(&strs[0])), 0)
+	if ok == 0 {
+		return err
+	}
+
+	return nil
+}
+
+func (l *eventLog) Close() error {
+	ok, _, err := procDeregisterEventSource.Call(l.handle)
+	if ok == 0 {
+		return err
+	}
+
+	return nil
+}
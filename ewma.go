@@ -0,0 +1,105 @@
+package buckyclient
+
+import (
+	"math"
+	"time"
+)
+
+// ewmaWindows are the decay periods of every EWMA, in the style of the
+// unix load average
+var ewmaWindows = []struct {
+	suffix string
+	tau    time.Duration
+}{
+	{".m1_rate", time.Minute},
+	{".m5_rate", 5 * time.Minute},
+	{".m15_rate", 15 * time.Minute},
+}
+
+// ewma is an exponentially weighted moving average of a per-second rate.
+// Unlike other metrics it is kept between flushes.
+type ewma struct {
+	pending int64     // Total recorded since the last update
+	rates   []float64 // One rate for each of ewmaWindows
+	last    time.Time // When the rates were last updated
+}
+
+// update folds the pending total into the rates. The first rate is only
+// measured once at least interval has passed, as one from a fraction of an
+// interval would take the longer averages a long time to decay.
+func (e *ewma) update(now time.Time, interval time.Duration) {
+	elapsed := now.Sub(e.last)
+	if elapsed <= 0 || (e.rates == nil && elapsed < interval) {
+		return
+	}
+
+	rate := float64(e.pending) / elapsed.Seconds()
+
+	e.pending = 0
+	e.last = now
+
+	// The first update starts every average at the current rate
+	if e.rates == nil {
+		e.rates = make([]float64, len(ewmaWindows))
+		for i := range e.rates {
+			e.rates[i] = rate
+		}
+
+		return
+	}
+
+	for i, w := range ewmaWindows {
+		alpha := 1 - math.Exp(-elapsed.Seconds()/w.tau.Seconds())
+		e.rates[i] += alpha * (rate - e.rates[i])
+	}
+}
+
+// EWMA returns nothing and adds a value to a smoothed per-second rate.
+// Every flush sends the 1, 5 and 15 minute moving averages of that rate as
+// the gauges name.m1_rate, name.m5_rate and name.m15_rate. The averages
+// keep going between flushes, so once used they are sent with every flush
+// from the first one a full interval after the first value.
+func (c *Client) EWMA(name string, value int, tags ...Tag) {
+	tags = c.withTags(tags)
+	c, name = c.scoped(name)
+	c.logCaller(name)
+
+	if !c.Enabled() {
+		return
+	}
+
+	m := Metric{name: c.normalizeName(name), unit: UnitGauge, tags: canonicalTags(tags)}
+
+	c.ewmaMu.Lock()
+	defer c.ewmaMu.Unlock()
+
+	if c.ewmas == nil {
+		c.ewmas = make(map[Metric]*ewma)
+	}
+
+	e, ok := c.ewmas[m]
+	if !ok {
+		e = &ewma{last: c.now()}
+		c.ewmas[m] = e
+	}
+
+	e.pending += int64(value)
+}
+
+// addEWMAs updates every EWMA and sets its gauges - c.m must be held
+func (c *Client) addEWMAs(now time.Time) {
+	c.ewmaMu.Lock()
+	defer c.ewmaMu.Unlock()
+
+	for m, e := range c.ewmas {
+		e.update(now, c.interval)
+
+		if e.rates == nil {
+			continue
+		}
+
+		for i, w := range ewmaWindows {
+			c.aggregate(MetricWithAmount{Metric{name: m.name + w.suffix, unit: UnitGauge, tags: m.tags}, Amount{Float: e.rates[i], IsFloat: true}, ActionLast})
+		}
+	}
+}
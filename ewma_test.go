@@ -0,0 +1,104 @@
+package buckyclient
+
+import (
+	"bytes"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEWMA_ewma_update(t *testing.T) {
+	start := time.Now()
+	e := &ewma{last: start}
+
+	e.pending = 600
+	e.update(start.Add(time.Minute), 10*time.Second) // 10 per second
+
+	assert.Equal(t, []float64{10, 10, 10}, e.rates)
+
+	e.update(start.Add(2*time.Minute), 10*time.Second) // nothing recorded, so decaying
+
+	assert.InDelta(t, 10*math.Exp(-1), e.rates[0], 0.0001)
+	assert.InDelta(t, 10*math.Exp(-1.0/5), e.rates[1], 0.0001)
+	assert.InDelta(t, 10*math.Exp(-1.0/15), e.rates[2], 0.0001)
+	assert.True(t, e.rates[0] < e.rates[1] && e.rates[1] < e.rates[2])
+}
+
+func TestEWMA_ewma_update_FirstInterval(t *testing.T) {
+	start := time.Now()
+	e := &ewma{last: start}
+
+	// A millisecond isn't enough to seed the rates from
+	e.pending = 10
+	e.update(start.Add(time.Millisecond), 10*time.Second)
+
+	assert.Nil(t, e.rates)
+	assert.Equal(t, int64(10), e.pending)
+
+	e.pending += 90
+	e.update(start.Add(10*time.Second), 10*time.Second)
+
+	assert.Equal(t, []float64{10, 10, 10}, e.rates)
+}
+
+func TestEWMA_Client_EWMA_Clock(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := &fakeClock{times: []time.Time{start, start.Add(6 * time.Second)}}
+	cl := &Client{metrics: make(map[Metric]Value), clock: clock}
+
+	// Started and updated by the client's clock, six seconds apart
+	cl.EWMA("myapp.requests", 60)
+	cl.addEWMAs(cl.now())
+
+	buf := &bytes.Buffer{}
+	cl.formatMetricsForFlush(buf)
+
+	assert.Contains(t, buf.String(), "myapp.requests.m1_rate:10|g\n")
+}
+
+func TestEWMA_Client_EWMA(t *testing.T) {
+	cl := &Client{metrics: make(map[Metric]Value)}
+
+	cl.EWMA("myapp.requests", 30)
+	cl.EWMA("myapp.requests", 30)
+
+	// Pretend the first value came in 6 seconds ago
+	now := time.Now()
+	cl.ewmas[Metric{name: "myapp.requests", unit: UnitGauge}].last = now.Add(-6 * time.Second)
+
+	cl.addEWMAs(now)
+
+	buf := &bytes.Buffer{}
+	cl.formatMetricsForFlush(buf)
+
+	assert.Contains(t, buf.String(), "myapp.requests.m1_rate:10|g\n")
+	assert.Contains(t, buf.String(), "myapp.requests.m5_rate:10|g\n")
+	assert.Contains(t, buf.String(), "myapp.requests.m15_rate:10|g\n")
+}
+
+func TestEWMA_Client_EWMA_Slow(t *testing.T) {
+	cl := &Client{metrics: make(map[Metric]Value)}
+
+	// One event every four seconds isn't rounded away
+	cl.EWMA("myapp.errors", 15)
+	now := time.Now()
+	cl.ewmas[Metric{name: "myapp.errors", unit: UnitGauge}].last = now.Add(-time.Minute)
+
+	cl.addEWMAs(now)
+
+	buf := &bytes.Buffer{}
+	cl.formatMetricsForFlush(buf)
+
+	assert.Contains(t, buf.String(), "myapp.errors.m1_rate:0.25|g\n")
+}
+
+func TestEWMA_Client_EWMA_Disabled(t *testing.T) {
+	cl := &Client{}
+	cl.SetEnabled(false)
+
+	cl.EWMA("myapp.requests", 1)
+
+	assert.Nil(t, cl.ewmas)
+}
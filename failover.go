@@ -0,0 +1,218 @@
+package buckyclient
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultPrimaryRecheck is how long WithFailover sends to a fallback before
+// it tries the primary again
+const DefaultPrimaryRecheck = time.Minute
+
+// FailoverMetric counts payloads delivered to a fallback of WithFailover
+const FailoverMetric = "buckyclient.failover_sends"
+
+// WithFailover adds fallback bucky servers, tried in order whenever a
+// payload can't be sent to the host given to NewClient, the primary. Once
+// a fallback has taken a payload the following flushes go straight to it,
+// and the primary is tried first again every DefaultPrimaryRecheck, or
+// WithPrimaryRecheck, until it is back. Payloads the status policy rejects
+// aren't offered to the other servers, because the payload is the problem.
+//
+// Only the default http transport fails over, and it can't be combined
+// with WithTargetSelector. Warm up and capability probe requests always go
+// to the primary. Client.Endpoints reports the health of each server.
+func WithFailover(fallbacks ...string) Option {
+	return func(c *Client) error {
+		if len(fallbacks) == 0 {
+			return invalidOption("WithFailover", "no fallback URLs given")
+		}
+
+		for _, fallback := range fallbacks {
+			if u, err := url.Parse(fallback); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return invalidOption("WithFailover", fmt.Sprintf("%q is not an http or https URL", fallback))
+			}
+		}
+
+		c.fallbacks = append(c.fallbacks, fallbacks...)
+		return nil
+	}
+}
+
+// WithPrimaryRecheck replaces DefaultPrimaryRecheck
+func WithPrimaryRecheck(d time.Duration) Option {
+	return func(c *Client) error {
+		if d <= 0 {
+			return invalidOption("WithPrimaryRecheck", "interval must be positive")
+		}
+
+		c.primaryRecheck = d
+		return nil
+	}
+}
+
+// EndpointHealth is what the client knows about one of its bucky servers
+type EndpointHealth struct {
+	URL         string
+	Primary     bool      // Whether it is the host given to NewClient
+	Active      bool      // Whether payloads are sent to it first
+	Failures    int       // Sends that failed since the last one that worked
+	LastError   error     // Why the last failed send failed
+	LastFailure time.Time // When the last failed send was
+}
+
+// failover tracks which of several servers payloads go to
+type failover struct {
+	sends uint64 // Payloads delivered to a fallback, for FailoverMetric, first for 64-bit alignment
+
+	mu        sync.Mutex
+	endpoints []EndpointHealth // The primary first
+	active    int              // Index of the endpoint tried first
+	checked   time.Time        // When the primary was last tried
+	recheck   time.Duration
+}
+
+// setUpFailover checks WithFailover can be used and starts tracking the
+// servers
+func (c *Client) setUpFailover() []error {
+	var errs []error
+
+	if c.transport != nil {
+		errs = append(errs, invalidOption("WithFailover", "only works with the default http transport"))
+	}
+
+	if c.targetSelector != nil {
+		errs = append(errs, invalidOption("WithFailover", "can't be combined with WithTargetSelector"))
+	}
+
+	f := &failover{recheck: c.primaryRecheck}
+	if f.recheck == 0 {
+		f.recheck = DefaultPrimaryRecheck
+	}
+
+	for i, u := range append([]string{c.hostURL}, c.fallbacks...) {
+		f.endpoints = append(f.endpoints, EndpointHealth{URL: u, Primary: i == 0, Active: i == 0})
+	}
+
+	c.failover = f
+
+	return errs
+}
+
+// order returns the endpoints to try, in order: the active one and those
+// after it, or the primary first when it is due to be tried again
+func (f *failover) order(now time.Time) []int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	start := f.active
+	if start != 0 && now.Sub(f.checked) >= f.recheck {
+		start, f.checked = 0, now
+	}
+
+	order := make([]int, len(f.endpoints))
+	for i := range order {
+		order[i] = (start + i) % len(f.endpoints)
+	}
+
+	return order
+}
+
+// result records how a send to endpoint i went, returning the endpoint
+// that was active before when i has taken over from it, or -1
+func (f *failover) result(i int, now time.Time, err error) (previous int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	e := &f.endpoints[i]
+
+	if err != nil {
+		e.Failures++
+		e.LastError, e.LastFailure = err, now
+
+		return -1
+	}
+
+	e.Failures = 0
+
+	if i != 0 {
+		atomic.AddUint64(&f.sends, 1)
+	}
+
+	if i == f.active {
+		return -1
+	}
+
+	previous = f.active
+	f.endpoints[previous].Active, e.Active = false, true
+	f.active = i
+
+	if i != 0 {
+		f.checked = now
+	}
+
+	return previous
+}
+
+// sendWithFailover sends a payload to the first endpoint that takes it
+func (c *Client) sendWithFailover(ctx context.Context, fc *flushContext, payload []byte) error {
+	f := c.failover
+
+	var err error
+	for _, i := range f.order(c.now()) {
+		target := f.endpoints[i].URL
+		fc.target = target
+
+		err = c.sendTo(ctx, fc.info, target, payload)
+		if c.rejected(err) {
+			return err
+		}
+
+		switch previous := f.result(i, c.now(), err); {
+		case previous < 0:
+		case i == 0:
+			c.logf(fc.info, "flushes to %s recovered, leaving %s", target, f.endpoints[previous].URL)
+		default:
+			c.warnf(fc.info, "flushes to %s failed, failing over to %s", f.endpoints[previous].URL, target)
+		}
+
+		if err == nil || ctx.Err() != nil {
+			return err
+		}
+	}
+
+	return err
+}
+
+// Endpoints returns the health of the primary and every fallback of
+// WithFailover, in that order, or nil without WithFailover
+func (c *Client) Endpoints() []EndpointHealth {
+	f := c.root().failover
+	if f == nil {
+		return nil
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]EndpointHealth(nil), f.endpoints...)
+}
+
+// addFailoverMetrics adds the count of payloads a fallback took - c.m
+// must be held
+func (c *Client) addFailoverMetrics() {
+	if c.failover == nil {
+		return
+	}
+
+	sends := atomic.SwapUint64(&c.failover.sends, 0)
+	if sends == 0 {
+		return
+	}
+
+	c.aggregate(MetricWithAmount{Metric{name: FailoverMetric, unit: UnitCount}, Amount{Value: int(sends)}, ActionSum})
+}
@@ -0,0 +1,146 @@
+package buckyclient
+
+import (
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// failoverServer answers with status, counting the requests it gets
+type failoverServer struct {
+	*httptest.Server
+	status int32
+	hits   int32
+
+	mu   sync.Mutex
+	body string // Of the last request
+}
+
+func newFailoverServer(t *testing.T, status int) *failoverServer {
+	s := &failoverServer{status: int32(status)}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&s.hits, 1)
+
+		body, _ := ioutil.ReadAll(r.Body)
+		s.mu.Lock()
+		s.body = string(body)
+		s.mu.Unlock()
+
+		w.WriteHeader(int(atomic.LoadInt32(&s.status)))
+	}))
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+func (s *failoverServer) takeHits() int {
+	return int(atomic.SwapInt32(&s.hits, 0))
+}
+
+func (s *failoverServer) lastBody() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.body
+}
+
+func TestFailover_Client_flush(t *testing.T) {
+	primary := newFailoverServer(t, http.StatusServiceUnavailable)
+	down := newFailoverServer(t, http.StatusBadGateway)
+	fallback := newFailoverServer(t, http.StatusOK)
+
+	c, errs := newClient(primary.URL, time.Minute, []Option{WithFailover(down.URL, fallback.URL), WithPrimaryRecheck(time.Hour)})
+	assert.Empty(t, errs)
+	c.SetLogger(log.New(ioutil.Discard, "", 0))
+
+	record := func() {
+		c.aggregate(MetricWithAmount{Metric{name: "a", unit: UnitCount}, Amount{Value: 1}, ActionSum})
+	}
+
+	// Every server is tried in order until one takes the payload
+	record()
+	assert.NoError(t, c.flush())
+	assert.Equal(t, []int{1, 1, 1}, []int{primary.takeHits(), down.takeHits(), fallback.takeHits()})
+
+	// The next flush goes straight to the fallback that worked
+	record()
+	assert.NoError(t, c.flush())
+	assert.Equal(t, []int{0, 0, 1}, []int{primary.takeHits(), down.takeHits(), fallback.takeHits()})
+
+	health := c.Endpoints()
+	if assert.Len(t, health, 3) {
+		assert.True(t, health[0].Primary)
+		assert.False(t, health[0].Active)
+		assert.Equal(t, 1, health[0].Failures)
+		assert.Equal(t, &StatusError{StatusCode: http.StatusServiceUnavailable}, health[0].LastError)
+		assert.Equal(t, 1, health[1].Failures)
+		assert.True(t, health[2].Active)
+		assert.Equal(t, 0, health[2].Failures)
+	}
+
+	// Once the recheck is due the primary is tried first again, and takes
+	// over as soon as it is back
+	c.failover.checked = time.Time{}
+	atomic.StoreInt32(&primary.status, http.StatusOK)
+
+	record()
+	assert.NoError(t, c.flush())
+	assert.Equal(t, []int{1, 0, 0}, []int{primary.takeHits(), down.takeHits(), fallback.takeHits()})
+	assert.True(t, c.Endpoints()[0].Active)
+	assert.Equal(t, 0, c.Endpoints()[0].Failures)
+
+	// Each flush reports the payloads a fallback took before it
+	assert.Contains(t, splitLines(primary.lastBody()), FailoverMetric+":1|c")
+}
+
+func TestFailover_Client_flush_AllDown(t *testing.T) {
+	primary := newFailoverServer(t, http.StatusServiceUnavailable)
+	fallback := newFailoverServer(t, http.StatusServiceUnavailable)
+
+	c, errs := newClient(primary.URL, time.Minute, []Option{WithFailover(fallback.URL)})
+	assert.Empty(t, errs)
+	c.SetLogger(log.New(ioutil.Discard, "", 0))
+
+	c.aggregate(MetricWithAmount{Metric{name: "a", unit: UnitCount}, Amount{Value: 1}, ActionSum})
+
+	var statusErr *StatusError
+	assert.True(t, errors.As(c.flush(), &statusErr))
+	assert.Equal(t, []int{1, 1}, []int{primary.takeHits(), fallback.takeHits()})
+	assert.True(t, c.Endpoints()[0].Active, "the primary stays active until another server works")
+}
+
+func TestFailover_Client_flush_Rejected(t *testing.T) {
+	primary := newFailoverServer(t, http.StatusBadRequest)
+	fallback := newFailoverServer(t, http.StatusOK)
+
+	c, errs := newClient(primary.URL, time.Minute, []Option{WithFailover(fallback.URL), WithStatusPolicy(StatusDropClientErrors)})
+	assert.Empty(t, errs)
+	c.SetLogger(log.New(ioutil.Discard, "", 0))
+
+	c.aggregate(MetricWithAmount{Metric{name: "a", unit: UnitCount}, Amount{Value: 1}, ActionSum})
+
+	assert.True(t, errors.Is(c.flush(), ErrPayloadRejected))
+	assert.Equal(t, 0, fallback.takeHits(), "a rejected payload isn't offered to the fallback")
+}
+
+func TestFailover_WithFailover_Invalid(t *testing.T) {
+	assert.True(t, errors.Is(WithFailover()(&Client{}), ErrInvalidOption))
+	assert.True(t, errors.Is(WithFailover("localhost:8005")(&Client{}), ErrInvalidOption))
+	assert.True(t, errors.Is(WithPrimaryRecheck(0)(&Client{}), ErrInvalidOption))
+
+	_, errs := newClient("http://localhost", time.Minute, []Option{WithFailover("http://fallback"), WithTransport(&recordingTransport{})})
+	assert.Len(t, errs, 1)
+
+	_, errs = newClient("http://localhost", time.Minute, []Option{WithFailover("http://fallback"), WithTargetSelector(func(Snapshot) string { return "" })})
+	assert.Len(t, errs, 1)
+
+	assert.Nil(t, (&Client{}).Endpoints())
+}
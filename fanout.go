@@ -0,0 +1,108 @@
+package buckyclient
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// FanOutError is returned when a payload couldn't be sent to every sink of
+// WithFanOut. There is an error for each sink that failed, saying which
+// one: sink 0 is the client's own transport and the others are numbered in
+// the order they were given.
+type FanOutError struct {
+	Errors []error
+}
+
+func (e *FanOutError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = err.Error()
+	}
+
+	return fmt.Sprintf("%d sinks failed: %s", len(e.Errors), strings.Join(msgs, "; "))
+}
+
+// Unwrap returns every error so errors.Is and errors.As can match any of them
+func (e *FanOutError) Unwrap() []error {
+	return e.Errors
+}
+
+// WithFanOut also sends every payload with each of sinks, alongside the
+// bucky server or the transport given with WithTransport, e.g. to feed a
+// local statsd daemon with transport/udp during a migration. Payloads go
+// to every sink at the same time and the flush waits for all of them,
+// returning a FanOutError if any failed.
+//
+// A payload is retried and queued as a whole, so with WithRetry or a retry
+// queue a sink that took it can get it again when another sink failed.
+// Sinks that are an io.Closer are closed by Close.
+func WithFanOut(sinks ...Transport) Option {
+	return func(c *Client) error {
+		if len(sinks) == 0 {
+			return invalidOption("WithFanOut", "no sinks given")
+		}
+
+		for _, sink := range sinks {
+			if sink == nil {
+				return invalidOption("WithFanOut", "sink must not be nil")
+			}
+		}
+
+		c.sinks = append(c.sinks, sinks...)
+		return nil
+	}
+}
+
+// fanOut sends each payload with several transports
+type fanOut []Transport
+
+// Send sends the payload with every transport, waiting for them all
+func (f fanOut) Send(ctx context.Context, payload []byte) error {
+	errs := make([]error, len(f))
+
+	var wg sync.WaitGroup
+	for i, t := range f {
+		wg.Add(1)
+		go func(i int, t Transport) {
+			defer wg.Done()
+
+			if err := t.Send(ctx, payload); err != nil {
+				errs[i] = fmt.Errorf("sink %d: %w", i, err)
+			}
+		}(i, t)
+	}
+
+	wg.Wait()
+
+	var failed []error
+	for _, err := range errs {
+		if err != nil {
+			failed = append(failed, err)
+		}
+	}
+
+	if len(failed) == 0 {
+		return nil
+	}
+
+	return &FanOutError{Errors: failed}
+}
+
+// closeSinks closes the sinks of WithFanOut that are an io.Closer,
+// returning the first error
+func (c *Client) closeSinks() error {
+	var first error
+
+	for _, sink := range c.sinks {
+		if closer, ok := sink.(io.Closer); ok {
+			if err := closer.Close(); err != nil && first == nil {
+				first = err
+			}
+		}
+	}
+
+	return first
+}
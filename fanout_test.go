@@ -0,0 +1,55 @@
+package buckyclient
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFanOut_Client_flush(t *testing.T) {
+	own := &recordingTransport{}
+	mirror := &recordingTransport{}
+	down := &recordingTransport{err: errors.New("unreachable")}
+
+	c := newRetryClient(own, WithFanOut(mirror, down))
+
+	c.aggregate(MetricWithAmount{Metric{name: "a", unit: UnitCount}, Amount{Value: 1}, ActionSum})
+	err := c.flush()
+
+	assert.Equal(t, []string{"a:1|c\n"}, own.payloads)
+	assert.Equal(t, []string{"a:1|c\n"}, mirror.payloads)
+	assert.Equal(t, []string{"a:1|c\n"}, down.payloads)
+	assert.Equal(t, own.infos, mirror.infos, "every sink is told which flush it is sending")
+
+	var fanOutErr *FanOutError
+	if assert.True(t, errors.As(err, &fanOutErr)) {
+		assert.Len(t, fanOutErr.Errors, 1)
+		assert.EqualError(t, fanOutErr.Errors[0], "sink 2: unreachable")
+	}
+
+	assert.ErrorIs(t, err, down.err)
+
+	down.err = nil
+
+	c.aggregate(MetricWithAmount{Metric{name: "b", unit: UnitCount}, Amount{Value: 1}, ActionSum})
+	assert.NoError(t, c.flush())
+}
+
+func TestFanOut_Client_Close(t *testing.T) {
+	own := &recordingTransport{}
+	mirror := &recordingTransport{}
+
+	c, errs := newClient("", DefaultInterval, []Option{WithTransport(own), WithFanOut(mirror)})
+	assert.Empty(t, errs)
+	c.start()
+
+	assert.NoError(t, c.Close())
+	assert.True(t, own.closed)
+	assert.True(t, mirror.closed)
+}
+
+func TestFanOut_WithFanOut_Invalid(t *testing.T) {
+	assert.True(t, errors.Is(WithFanOut()(&Client{}), ErrInvalidOption))
+	assert.True(t, errors.Is(WithFanOut(&recordingTransport{}, nil)(&Client{}), ErrInvalidOption))
+}
@@ -0,0 +1,75 @@
+package buckyclient
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFloat_Client_aggregate(t *testing.T) {
+	c := &Client{metrics: make(map[Metric]Value)}
+
+	for _, m := range []MetricWithAmount{
+		// a counter stays an integer until it gets a fractional sample
+		{Metric{name: "count", unit: UnitCount}, Amount{Value: 2}, ActionSum},
+		{Metric{name: "count", unit: UnitCount}, Amount{Float: 0.5, IsFloat: true}, ActionSum},
+		{Metric{name: "count", unit: UnitCount}, Amount{Value: 1}, ActionSum},
+		{Metric{name: "whole", unit: UnitCount}, Amount{Value: 3}, ActionSum},
+		{Metric{name: "timer", unit: UnitMillisecond}, Amount{Value: 1}, ActionAvg},
+		{Metric{name: "timer", unit: UnitMillisecond}, Amount{Float: 0.25, IsFloat: true}, ActionAvg},
+		{Metric{name: "gauge", unit: UnitGauge}, Amount{Float: 1.5, IsFloat: true}, ActionLast},
+		{Metric{name: "gauge2", unit: UnitGauge}, Amount{Float: 1.5, IsFloat: true}, ActionLast},
+		{Metric{name: "gauge2", unit: UnitGauge}, Amount{Value: 4}, ActionLast},
+	} {
+		assert.NoError(t, c.aggregate(m))
+	}
+
+	buf := &bytes.Buffer{}
+	c.formatMetricsForFlush(buf)
+
+	assert.ElementsMatch(t, []string{
+		"count:3.5|c",
+		"whole:3|c",
+		"timer:0.625|ms",
+		"gauge:1.5|g",
+		"gauge2:4|g",
+	}, splitLines(buf.String()))
+}
+
+func TestFloat_Client_aggregate_Invalid(t *testing.T) {
+	c := &Client{metrics: make(map[Metric]Value)}
+
+	for _, f := range []float64{math.NaN(), math.Inf(1), math.Inf(-1)} {
+		err := c.aggregate(MetricWithAmount{Metric{name: "a", unit: UnitCount}, Amount{Float: f, IsFloat: true}, ActionSum})
+
+		var metricErr *MetricError
+		assert.True(t, errors.As(err, &metricErr))
+		assert.Equal(t, "a", metricErr.Name)
+		assert.True(t, errors.Is(err, ErrInvalidValue))
+	}
+
+	assert.Empty(t, c.metrics)
+}
+
+func TestFloat_Client_RecordFloat(t *testing.T) {
+	c := &Client{}
+	c.SetEnabled(false)
+
+	assert.Equal(t, ErrInvalidUnit, c.RecordFloat("a", 1.5, Unit("x"), ActionSum))
+	assert.Equal(t, ErrInvalidAction, c.RecordFloat("a", 1.5, UnitGauge, ActionRatio))
+	assert.NoError(t, c.RecordFloat("a", 1.5, UnitGauge, ActionLast))
+}
+
+func splitLines(s string) []string {
+	var lines []string
+	for _, line := range bytes.Split([]byte(s), []byte("\n")) {
+		if len(line) > 0 {
+			lines = append(lines, string(line))
+		}
+	}
+
+	return lines
+}
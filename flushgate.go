@@ -0,0 +1,146 @@
+package buckyclient
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// FlushConcurrency controls what happens when a flush is asked for while
+// another one is still running
+type FlushConcurrency int
+
+const (
+	// FlushSerialize waits for the running flush, then flushes again.
+	// This is the default.
+	FlushSerialize FlushConcurrency = iota
+
+	// FlushCoalesce waits for the running flush and returns its result
+	// rather than starting another one
+	FlushCoalesce
+
+	// FlushReject returns ErrBusy straight away
+	FlushReject
+)
+
+var (
+	// ErrBusy is returned when a flush is rejected because one is already running
+	ErrBusy = errors.New("Flush already in progress")
+
+	// ErrStopped is returned when a flush is asked for after the client has stopped
+	ErrStopped = errors.New("Client stopped")
+)
+
+// flushGate makes sure only one flush runs at a time. The interval tick
+// and Stop always serialize, so only ad-hoc flushes use other policies.
+type flushGate struct {
+	state   sync.Mutex    // protects sem and current
+	sem     chan struct{} // holds a value for the whole of a flush
+	current *flushRun     // the flush that is running, if any
+}
+
+// flushRun is the result of a single flush, shared with coalesced callers
+type flushRun struct {
+	done chan struct{}
+	err  error
+}
+
+// run calls fn once no other flush is running, or as the policy says. It
+// gives up with ctx's error if ctx is done while waiting.
+func (g *flushGate) run(ctx context.Context, policy FlushConcurrency, fn func() error) error {
+	g.state.Lock()
+	if g.sem == nil {
+		g.sem = make(chan struct{}, 1)
+	}
+	sem := g.sem
+
+	if run := g.current; run != nil {
+		switch policy {
+		case FlushReject:
+			g.state.Unlock()
+			return ErrBusy
+
+		case FlushCoalesce:
+			g.state.Unlock()
+
+			select {
+			case <-run.done:
+				return run.err
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+	g.state.Unlock()
+
+	select {
+	case sem <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-sem }()
+
+	run := &flushRun{done: make(chan struct{})}
+
+	g.state.Lock()
+	g.current = run
+	g.state.Unlock()
+
+	run.err = fn()
+
+	g.state.Lock()
+	g.current = nil
+	g.state.Unlock()
+
+	close(run.done)
+
+	return run.err
+}
+
+// Flush sends everything recorded so far straight away rather than waiting
+// for the interval, for programs that exit before it comes round, such as
+// command line tools and serverless functions. It is FlushContext without
+// a deadline.
+func (c *Client) Flush() error {
+	return c.FlushContext(context.Background())
+}
+
+// FlushContext sends everything recorded so far straight away rather than
+// waiting for the interval, following the configured FlushConcurrency.
+// Samples recorded before the call are included. ctx bounds waiting for a
+// flush that is already running as well as the sends of this one, retries
+// included; metrics that weren't sent in time are kept as for any failed
+// flush. It returns ErrStopped once Stop was called and ErrDisabled while
+// the client is disabled.
+func (c *Client) FlushContext(ctx context.Context) error {
+	c = c.root()
+
+	if atomic.LoadInt32(&c.closed) == 1 {
+		return ErrStopped
+	}
+
+	if !c.Enabled() {
+		return ErrDisabled
+	}
+
+	return c.gate.run(ctx, c.flushConcurrency, func() error {
+		c.waitForInput(ctx)
+		return c.flushUngated(ctx)
+	})
+}
+
+// WithFlushConcurrency sets what an ad-hoc flush does when another flush
+// is already running
+func WithFlushConcurrency(policy FlushConcurrency) Option {
+	return func(c *Client) error {
+		switch policy {
+		case FlushSerialize, FlushCoalesce, FlushReject:
+		default:
+			return invalidOption("WithFlushConcurrency", "unknown policy")
+		}
+
+		c.flushConcurrency = policy
+		return nil
+	}
+}
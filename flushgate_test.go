@@ -0,0 +1,268 @@
+package buckyclient
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// startSlowFlush runs a flush through the gate that blocks until release
+// is closed, and returns once it is running
+func startSlowFlush(g *flushGate, release chan struct{}) chan error {
+	started := make(chan struct{})
+	result := make(chan error, 1)
+
+	go func() {
+		result <- g.run(context.Background(), FlushSerialize, func() error {
+			close(started)
+			<-release
+			return ErrNoMetrics
+		})
+	}()
+
+	<-started
+
+	return result
+}
+
+func TestFlushGate_run_Reject(t *testing.T) {
+	g := &flushGate{}
+	release := make(chan struct{})
+	result := startSlowFlush(g, release)
+
+	err := g.run(context.Background(), FlushReject, func() error {
+		t.Error("rejected flush should not run")
+		return nil
+	})
+	assert.Equal(t, ErrBusy, err)
+
+	close(release)
+	assert.Equal(t, ErrNoMetrics, <-result)
+}
+
+func TestFlushGate_run_Coalesce(t *testing.T) {
+	g := &flushGate{}
+	release := make(chan struct{})
+	result := startSlowFlush(g, release)
+
+	coalesced := make(chan error, 1)
+	go func() {
+		coalesced <- g.run(context.Background(), FlushCoalesce, func() error {
+			t.Error("coalesced flush should not run")
+			return nil
+		})
+	}()
+
+	time.Sleep(time.Millisecond * 20) // Let the second caller find the flush running
+	close(release)
+
+	assert.Equal(t, ErrNoMetrics, <-result)
+	assert.Equal(t, ErrNoMetrics, <-coalesced) // shares the running flush's result
+}
+
+func TestFlushGate_run_Serialize(t *testing.T) {
+	g := &flushGate{}
+
+	var running, overlaps int32
+	var wg sync.WaitGroup
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			g.run(context.Background(), FlushSerialize, func() error {
+				if atomic.AddInt32(&running, 1) > 1 {
+					atomic.AddInt32(&overlaps, 1)
+				}
+				time.Sleep(time.Millisecond)
+				atomic.AddInt32(&running, -1)
+				return nil
+			})
+		}()
+	}
+
+	wg.Wait()
+
+	assert.Equal(t, int32(0), overlaps)
+}
+
+func TestFlushGate_run_Context(t *testing.T) {
+	g := &flushGate{}
+	release := make(chan struct{})
+	result := startSlowFlush(g, release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	for _, policy := range []FlushConcurrency{FlushSerialize, FlushCoalesce} {
+		err := g.run(ctx, policy, func() error {
+			t.Error("flush should not run once ctx is done")
+			return nil
+		})
+		assert.Equal(t, context.DeadlineExceeded, err)
+	}
+
+	close(release)
+	assert.Equal(t, ErrNoMetrics, <-result)
+
+	// The gate is free again
+	assert.NoError(t, g.run(context.Background(), FlushSerialize, func() error { return nil }))
+}
+
+func TestFlushGate_Client_FlushContext(t *testing.T) {
+	unblock := make(chan struct{})
+
+	ts := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		<-unblock
+	}))
+	defer ts.Close()
+	defer close(unblock)
+
+	cl, errs := newClient(ts.URL, DefaultInterval, nil)
+	assert.Empty(t, errs)
+	cl.SetLogger(log.New(ioutil.Discard, "", 0))
+
+	cl.metrics[Metric{name: "hits", unit: UnitCount}] = Value{Sum: &Sum{Value: 1}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	assert.ErrorIs(t, cl.FlushContext(ctx), context.DeadlineExceeded)
+}
+
+func TestFlushGate_Client_Flush(t *testing.T) {
+	bodies := make(chan string, 10)
+	mockBucky := captureBuckyServer(bodies)
+	defer mockBucky.Close()
+
+	cl, err := NewClient(mockBucky.URL, 0)
+	assert.NoError(t, err)
+	cl.SetLogger(log.New(ioutil.Discard, "", 0))
+
+	// Flushed straight after recording, as a short-lived program would
+	for i := 0; i < 100; i++ {
+		cl.Count("hits", 1)
+	}
+
+	assert.NoError(t, cl.Flush())
+	assert.Equal(t, "hits:100|c\n", <-bodies)
+
+	assert.ErrorIs(t, cl.Flush(), ErrNoMetrics)
+
+	assert.NoError(t, cl.Close())
+	assert.Equal(t, ErrStopped, cl.Flush())
+}
+
+func TestFlushGate_Client_StopContext(t *testing.T) {
+	unblock := make(chan struct{})
+
+	ts := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		<-unblock
+	}))
+	defer ts.Close()
+	defer close(unblock)
+
+	cl, err := NewClient(ts.URL, 0)
+	assert.NoError(t, err)
+	cl.SetLogger(log.New(ioutil.Discard, "", 0))
+
+	cl.Count("hits", 1)
+	assert.Eventually(t, func() bool { return cl.PendingLines() == 1 }, time.Second, time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	assert.Equal(t, context.DeadlineExceeded, cl.StopContext(ctx))
+	assert.True(t, time.Since(start) < time.Second)
+
+	// The final flush gave up with the ctx, so the client still stops
+	assert.ErrorIs(t, cl.StopWithResult(), context.DeadlineExceeded)
+	assert.NoError(t, cl.Close())
+}
+
+func TestFlushGate_Client_Stop_Twice(t *testing.T) {
+	cl := &Client{
+		http:       &http.Client{},
+		logger:     log.New(ioutil.Discard, "", 0),
+		interval:   time.Hour,
+		input:      make(chan MetricWithAmount, 10),
+		stop:       make(chan bool, 1),
+		stopped:    make(chan bool, 1),
+		metrics:    make(map[Metric]Value),
+		bufferPool: newBufferPool(),
+	}
+	cl.sender()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cl.Stop()
+		}()
+	}
+	wg.Wait()
+
+	cl.Stop() // returns straight away once stopped
+
+	assert.Equal(t, ErrStopped, cl.FlushContext(context.Background()))
+}
+
+func TestFlushGate_Client_FlushContext_RacesSender(t *testing.T) {
+	bodies := make(chan string, 1000)
+	mockBucky := captureBuckyServer(bodies)
+	defer mockBucky.Close()
+
+	cl := &Client{
+		hostURL:    mockBucky.URL,
+		http:       &http.Client{},
+		logger:     log.New(ioutil.Discard, "", 0),
+		interval:   time.Millisecond,
+		input:      make(chan MetricWithAmount, 10),
+		stop:       make(chan bool, 1),
+		stopped:    make(chan bool, 1),
+		metrics:    make(map[Metric]Value),
+		bufferPool: newBufferPool(),
+	}
+	cl.sender()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cl.handleMetricWithValue(MetricWithAmount{Metric{name: "myapp.facet", unit: UnitCount}, Amount{Value: 1}, ActionSum})
+			cl.FlushContext(context.Background())
+		}()
+	}
+	wg.Wait()
+
+	cl.Stop()
+	close(bodies)
+
+	// However the flushes interleaved, every sample is sent exactly once
+	total := 0
+	for body := range bodies {
+		for _, line := range strings.Split(strings.TrimSpace(body), "\n") {
+			_, value, _, err := ParseLine(line)
+			assert.NoError(t, err)
+			total += value
+		}
+	}
+	assert.Equal(t, 20, total)
+}
+
+func TestFlushGate_WithFlushConcurrency_Invalid(t *testing.T) {
+	assert.True(t, errors.Is(WithFlushConcurrency(FlushConcurrency(9))(&Client{}), ErrInvalidOption))
+}
@@ -0,0 +1,60 @@
+package buckyclient
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync/atomic"
+)
+
+// FlushInfo identifies a single flush. It is included in every log line
+// and error for that flush so one payload can be followed through retries.
+type FlushInfo struct {
+	Seq    uint64 // Increases by one for every flush of a client
+	ID     string // Random correlation ID
+	Window Window // When the metrics in the flush were recorded
+}
+
+func (f FlushInfo) String() string {
+	return fmt.Sprintf("flush=%d id=%s", f.Seq, f.ID)
+}
+
+// FlushError is passed to the error handler when a flush fails
+type FlushError struct {
+	FlushInfo
+	Err error
+}
+
+func (e *FlushError) Error() string {
+	return e.FlushInfo.String() + ": " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error
+func (e *FlushError) Unwrap() error {
+	return e.Err
+}
+
+func (c *Client) nextFlushInfo() FlushInfo {
+	buf := make([]byte, 8)
+	rand.Read(buf)
+
+	return FlushInfo{
+		Seq: atomic.AddUint64(&c.flushSeq, 1),
+		ID:  hex.EncodeToString(buf),
+	}
+}
+
+// logf writes a log line tagged with the flush it belongs to
+func (c *Client) logf(info FlushInfo, format string, v ...interface{}) {
+	c.logAt(severityInfo, 2, info.String()+" "+fmt.Sprintf(format, v...))
+}
+
+// warnf is logf for a failure the client can recover from
+func (c *Client) warnf(info FlushInfo, format string, v ...interface{}) {
+	c.logAt(severityWarning, 2, info.String()+" "+fmt.Sprintf(format, v...))
+}
+
+// errorf is logf for a failure that loses metrics
+func (c *Client) errorf(info FlushInfo, format string, v ...interface{}) {
+	c.logAt(severityError, 2, info.String()+" "+fmt.Sprintf(format, v...))
+}
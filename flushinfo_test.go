@@ -0,0 +1,48 @@
+package buckyclient
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlushInfo_Client_nextFlushInfo(t *testing.T) {
+	cl := &Client{}
+
+	first := cl.nextFlushInfo()
+	second := cl.nextFlushInfo()
+
+	assert.Equal(t, uint64(1), first.Seq)
+	assert.Equal(t, uint64(2), second.Seq)
+	assert.Len(t, first.ID, 16)
+	assert.NotEqual(t, first.ID, second.ID)
+}
+
+func TestFlushInfo_Client_flush_TagsLogsAndErrors(t *testing.T) {
+	buf := &bytes.Buffer{}
+
+	mockBucky := mockBuckyServer(http.StatusInternalServerError)
+	defer mockBucky.Close()
+
+	cl := &Client{
+		hostURL:    mockBucky.URL,
+		metrics:    make(map[Metric]Value),
+		bufferPool: newBufferPool(),
+		http:       &http.Client{},
+		logger:     log.New(buf, "", 0),
+	}
+	cl.metrics[Metric{name: "myapp.facet", unit: UnitCount}] = Value{Sum: &Sum{Value: 1}}
+
+	err := cl.flush()
+
+	var flushErr *FlushError
+	assert.True(t, errors.As(err, &flushErr))
+	assert.Equal(t, uint64(1), flushErr.Seq)
+	assert.True(t, strings.HasPrefix(err.Error(), flushErr.FlushInfo.String()))
+	assert.Contains(t, buf.String(), flushErr.FlushInfo.String()+" status code above 200 received - 500")
+}
@@ -0,0 +1,176 @@
+package buckyclient
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrInvalidLine is returned when a line isn't in the name:value|unit format
+	ErrInvalidLine = errors.New("Invalid metric line")
+)
+
+// FormatLine returns a single metric in the wire format used by bucky
+// and statsd: name:value|unit
+func FormatLine(name string, value int, unit Unit) string {
+	buf := &bytes.Buffer{}
+
+	writeLine(buf, name, number{i: int64(value)}, unit)
+
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// FormatLineFloat is FormatLine for fractional values. Values are written
+// in the shortest form that reads back exactly, without an exponent.
+func FormatLineFloat(name string, value float64, unit Unit) string {
+	buf := &bytes.Buffer{}
+
+	writeLine(buf, name, number{f: value, isFloat: true}, unit)
+
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// FormatTaggedLine is FormatLineFloat with tags in the DogStatsD format:
+// name:value|unit|#k:v,k2:v2. Tags are sorted, and characters used as
+// separators are replaced with underscores.
+func FormatTaggedLine(name string, value float64, unit Unit, tags ...Tag) string {
+	buf := &bytes.Buffer{}
+
+	writeTaggedLine(buf, name, number{f: value, isFloat: true}, unit, canonicalTags(tags), TagFormatDogStatsD)
+
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// ParseLine reads a single metric in the name:value|unit format. A trailing
+// newline is allowed, and the unit must be one the client understands.
+func ParseLine(s string) (name string, value int, unit Unit, err error) {
+	name, raw, unit, err := splitLine(s)
+	if err != nil {
+		return "", 0, "", err
+	}
+
+	value, err = strconv.Atoi(raw)
+	if err != nil {
+		return "", 0, "", ErrInvalidLine
+	}
+
+	return name, value, unit, nil
+}
+
+// ParseLineFloat is ParseLine for lines that may have fractional values.
+// NaN and infinite values are rejected.
+func ParseLineFloat(s string) (name string, value float64, unit Unit, err error) {
+	name, raw, unit, err := splitLine(s)
+	if err != nil {
+		return "", 0, "", err
+	}
+
+	value, err = strconv.ParseFloat(raw, 64)
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		return "", 0, "", ErrInvalidLine
+	}
+
+	return name, value, unit, nil
+}
+
+// ParseTaggedLine is ParseLineFloat for lines that may have DogStatsD
+// tags after the unit, as written by FormatTaggedLine. Tags without a
+// value, such as #canary, are returned with an empty Value.
+func ParseTaggedLine(s string) (name string, value float64, unit Unit, tags []Tag, err error) {
+	s = strings.TrimSuffix(s, "\n")
+
+	if i := strings.Index(s, "|#"); i >= 0 {
+		for _, t := range strings.Split(s[i+2:], ",") {
+			if t == "" {
+				return "", 0, "", nil, ErrInvalidLine
+			}
+
+			key, v, _ := strings.Cut(t, ":")
+			tags = append(tags, Tag{key, v})
+		}
+
+		s = s[:i]
+	}
+
+	name, value, unit, err = ParseLineFloat(s)
+	if err != nil {
+		return "", 0, "", nil, err
+	}
+
+	return name, value, unit, tags, nil
+}
+
+// splitLine splits a line into its name, unparsed value and unit
+func splitLine(s string) (name, value string, unit Unit, err error) {
+	s = strings.TrimSuffix(s, "\n")
+
+	pipe := strings.IndexByte(s, '|')
+	if pipe < 0 {
+		return "", "", "", ErrInvalidLine
+	}
+
+	colon := strings.LastIndexByte(s[:pipe], ':')
+	if colon <= 0 {
+		return "", "", "", ErrInvalidLine
+	}
+
+	unit = Unit(s[pipe+1:])
+	if !unit.Valid() {
+		return "", "", "", ErrInvalidUnit
+	}
+
+	return s[:colon], s[colon+1 : pipe], unit, nil
+}
+
+// number is a flushed value, which is an integer unless isFloat is set
+type number struct {
+	i       int64
+	f       float64
+	isFloat bool
+	raw     string // Written as it is if set, e.g. a serialized sketch
+	signed  bool   // Written with a plus when it isn't negative, for gauge adjustments
+}
+
+// negative reports whether the number is below zero
+func (n number) negative() bool {
+	if n.isFloat {
+		return n.f < 0
+	}
+
+	return n.i < 0
+}
+
+// append adds the number to b in the wire format
+func (n number) append(b []byte) []byte {
+	if n.raw != "" {
+		return append(b, n.raw...)
+	}
+
+	if n.signed && !n.negative() {
+		b = append(b, '+')
+	}
+
+	if n.isFloat {
+		return strconv.AppendFloat(b, n.f, 'f', -1, 64)
+	}
+
+	return strconv.AppendInt(b, n.i, 10)
+}
+
+// writeLine writes a single newline terminated metric to the buffer
+func writeLine(buf *bytes.Buffer, name string, value number, unit Unit) {
+	var scratch [32]byte
+
+	buf.WriteString(name)
+	buf.WriteRune(':')
+
+	// I blame @bradfitz for this: http://yapcasia.org/2015/talk/show/6bde6c69-187a-11e5-aca1-525412004261
+	buf.Write(value.append(scratch[:0]))
+
+	buf.WriteRune('|')
+	buf.WriteString(string(unit))
+	buf.WriteRune('\n')
+}
@@ -0,0 +1,96 @@
+package buckyclient
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormat_FormatLine(t *testing.T) {
+	assert.Equal(t, "myapp.facet:42|c", FormatLine("myapp.facet", 42, UnitCount))
+	assert.Equal(t, "myapp.timer:-3|ms", FormatLine("myapp.timer", -3, UnitMillisecond))
+}
+
+func TestFormat_ParseLine(t *testing.T) {
+	name, value, unit, err := ParseLine("myapp.facet:42|c\n")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "myapp.facet", name)
+	assert.Equal(t, 42, value)
+	assert.Equal(t, UnitCount, unit)
+}
+
+func TestFormat_ParseLine_RoundTrip(t *testing.T) {
+	name, value, unit, err := ParseLine(FormatLine("a.b.c", 1234, UnitMillisecond))
+
+	assert.NoError(t, err)
+	assert.Equal(t, FormatLine(name, value, unit), "a.b.c:1234|ms")
+}
+
+func TestFormat_ParseLine_Invalid(t *testing.T) {
+	for _, line := range []string{"", "myapp", "myapp:1", ":1|c", "myapp:x|c", "myapp|c"} {
+		_, _, _, err := ParseLine(line)
+		assert.Equal(t, ErrInvalidLine, err, line)
+	}
+
+	_, _, _, err := ParseLine("myapp:1|h")
+	assert.Equal(t, ErrInvalidUnit, err)
+}
+
+func TestFormat_FormatLineFloat(t *testing.T) {
+	assert.Equal(t, "a:0.25|ms", FormatLineFloat("a", 0.25, UnitMillisecond))
+	assert.Equal(t, "a:3|c", FormatLineFloat("a", 3, UnitCount))
+	assert.Equal(t, "a:1000000000000000000000|c", FormatLineFloat("a", 1e21, UnitCount))
+}
+
+func TestFormat_ParseLineFloat(t *testing.T) {
+	name, value, unit, err := ParseLineFloat("a.b:0.125|ms\n")
+	assert.NoError(t, err)
+	assert.Equal(t, "a.b", name)
+	assert.Equal(t, 0.125, value)
+	assert.Equal(t, UnitMillisecond, unit)
+
+	_, value, _, err = ParseLineFloat("a:-3|c")
+	assert.NoError(t, err)
+	assert.Equal(t, -3.0, value)
+
+	for _, line := range []string{"a:NaN|c", "a:+Inf|c", "a:x|c", "a1|c"} {
+		_, _, _, err = ParseLineFloat(line)
+		assert.Equal(t, ErrInvalidLine, err, line)
+	}
+
+	_, _, _, err = ParseLineFloat("a:1.5|x")
+	assert.Equal(t, ErrInvalidUnit, err)
+
+	// ParseLine still only takes integers
+	_, _, _, err = ParseLine("a:1.5|c")
+	assert.Equal(t, ErrInvalidLine, err)
+}
+
+func TestFormat_FormatTaggedLine(t *testing.T) {
+	assert.Equal(t, "a:1|c|#env:prod,region:eu", FormatTaggedLine("a", 1, UnitCount, Tag{"region", "eu"}, Tag{"env", "prod"}))
+	assert.Equal(t, "a:0.5|ms", FormatTaggedLine("a", 0.5, UnitMillisecond))
+	assert.Equal(t, "a:1|c|#k_1:v_2", FormatTaggedLine("a", 1, UnitCount, Tag{"k,1", "v|2"}))
+}
+
+func TestFormat_ParseTaggedLine(t *testing.T) {
+	name, value, unit, tags, err := ParseTaggedLine("a.b:2|c|#env:prod,canary\n")
+	assert.NoError(t, err)
+	assert.Equal(t, "a.b", name)
+	assert.Equal(t, 2.0, value)
+	assert.Equal(t, UnitCount, unit)
+	assert.Equal(t, []Tag{{"env", "prod"}, {"canary", ""}}, tags)
+
+	_, _, _, tags, err = ParseTaggedLine("a:1|g")
+	assert.NoError(t, err)
+	assert.Empty(t, tags)
+
+	for _, line := range []string{"a:1|c|#", "a:1|c|#k:v,", "a:x|c|#k:v"} {
+		_, _, _, _, err = ParseTaggedLine(line)
+		assert.Equal(t, ErrInvalidLine, err, line)
+	}
+
+	// The untagged parsers don't accept tags
+	_, _, _, err = ParseLineFloat("a:1|c|#k:v")
+	assert.Equal(t, ErrInvalidUnit, err)
+}
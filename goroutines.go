@@ -0,0 +1,141 @@
+package buckyclient
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// closeTimeout is how long Close waits for goroutines to exit
+var closeTimeout = 2 * time.Second
+
+// goroutines counts the goroutines the client has running, by what they
+// were started for, so Close can check they have all exited. The zero
+// value is ready to use.
+type goroutines struct {
+	running sync.Map // name to *int64
+}
+
+// spawn runs fn on a new goroutine, counting it under name until it returns
+func (g *goroutines) spawn(name string, fn func()) {
+	n := g.counter(name)
+	atomic.AddInt64(n, 1)
+
+	go func() {
+		defer atomic.AddInt64(n, -1)
+		fn()
+	}()
+}
+
+func (g *goroutines) counter(name string) *int64 {
+	if n, ok := g.running.Load(name); ok {
+		return n.(*int64)
+	}
+
+	n, _ := g.running.LoadOrStore(name, new(int64))
+	return n.(*int64)
+}
+
+// stragglers returns the goroutines still running, e.g. "aggregateBatch (3)"
+func (g *goroutines) stragglers() []string {
+	var out []string
+
+	g.running.Range(func(name, n interface{}) bool {
+		if count := atomic.LoadInt64(n.(*int64)); count == 1 {
+			out = append(out, name.(string))
+		} else if count > 1 {
+			out = append(out, fmt.Sprintf("%s (%d)", name, count))
+		}
+
+		return true
+	})
+
+	sort.Strings(out)
+
+	return out
+}
+
+// wait polls until every goroutine has exited or the timeout passes, and
+// returns any that are still running
+func (g *goroutines) wait(timeout time.Duration) []string {
+	deadline := time.Now().Add(timeout)
+
+	for {
+		left := g.stragglers()
+		if len(left) == 0 || time.Now().After(deadline) {
+			return left
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// LeakError is returned by Close when goroutines started by the client
+// haven't exited
+type LeakError struct {
+	Stragglers []string // What each goroutine was started for, with a count if more than one
+}
+
+func (e *LeakError) Error() string {
+	return "Goroutines still running after close: " + strings.Join(e.Stragglers, ", ")
+}
+
+// Close stops the client, like Stop, then shuts down the goroutine that
+// aggregates samples, closes idle connections and checks that every
+// goroutine the client started has exited. If any are still running after
+// a couple of seconds a LeakError lists them. A transport or sink that is
+// an io.Closer is closed too, and its error returned. Samples recorded once Close
+// has been called are dropped. It is safe to call more than once.
+func (c *Client) Close() error {
+	c = c.root()
+
+	c.Stop()
+
+	err := c.release()
+
+	if left := c.goroutines.wait(closeTimeout); len(left) > 0 {
+		return &LeakError{Stragglers: left}
+	}
+
+	return err
+}
+
+// release shuts down the input processor and frees what the client holds
+// open, returning the error of closing the transport. It only does
+// anything the first time.
+func (c *Client) release() error {
+	var err error
+
+	c.closeOnce.Do(func() {
+		close(c.done)
+
+		// A client given with WithHTTPClient may still be used elsewhere
+		if c.http != nil && c.httpClient == nil {
+			c.http.CloseIdleConnections()
+		}
+
+		if c.control != nil {
+			c.control.Close()
+		}
+
+		if closer, ok := c.transport.(io.Closer); ok && (c.ownTransport || !c.pooled) {
+			err = closer.Close()
+		}
+
+		if !c.pooled {
+			if sinkErr := c.closeSinks(); err == nil {
+				err = sinkErr
+			}
+		}
+
+		if c.logCloser != nil {
+			c.logCloser.Close()
+		}
+	})
+
+	return err
+}
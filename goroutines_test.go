@@ -0,0 +1,123 @@
+package buckyclient
+
+import (
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/goleak"
+)
+
+// newLeakTestClient returns a running client posting to a test server
+func newLeakTestClient(t *testing.T, opts ...Option) (*Client, *httptest.Server) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ioutil.ReadAll(r.Body)
+	}))
+
+	opts = append([]Option{WithInterval(5 * time.Millisecond)}, opts...)
+
+	c, err := NewClient(srv.URL, 0, opts...)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	c.SetLogger(log.New(ioutil.Discard, "", 0))
+
+	return c, srv
+}
+
+func TestGoroutines_Client_Close(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	c, srv := newLeakTestClient(t)
+	defer srv.Close()
+
+	for i := 0; i < 100; i++ {
+		c.Count("hits", 1)
+		c.AverageTimer("latency", i)
+	}
+
+	time.Sleep(20 * time.Millisecond) // Let a few flushes go out
+
+	assert.NoError(t, c.Close())
+	assert.NoError(t, c.Close(), "closing twice is fine")
+}
+
+func TestGoroutines_Client_Close_RecordAfterClose(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	c, srv := newLeakTestClient(t)
+	defer srv.Close()
+
+	assert.NoError(t, c.Close())
+
+	// Nothing reads the input channel any more, so these must not block
+	for i := 0; i < 10; i++ {
+		c.Count("late", 1)
+	}
+
+	assert.NoError(t, c.Close())
+}
+
+func TestGoroutines_Client_Close_Options(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	c, srv := newLeakTestClient(t,
+		WithRecordBudget(time.Millisecond),
+		WithSampleBatching(8),
+		WithCPUCollector(),
+		WithRetryQueue(time.Minute, 1<<20),
+	)
+	defer srv.Close()
+
+	for i := 0; i < 1000; i++ {
+		c.Count("hits", 1)
+	}
+
+	assert.NoError(t, c.Close())
+}
+
+func TestGoroutines_Client_Close_Stragglers(t *testing.T) {
+	defer func(d time.Duration) { closeTimeout = d }(closeTimeout)
+	closeTimeout = 10 * time.Millisecond
+
+	c, srv := newLeakTestClient(t)
+	defer srv.Close()
+
+	block := make(chan struct{})
+	defer close(block)
+
+	for i := 0; i < 2; i++ {
+		c.goroutines.spawn("stuck", func() { <-block })
+	}
+
+	err := c.Close()
+
+	var leak *LeakError
+	assert.True(t, errors.As(err, &leak))
+	assert.Equal(t, []string{"stuck (2)"}, leak.Stragglers)
+	assert.Equal(t, "Goroutines still running after close: stuck (2)", err.Error())
+}
+
+func TestGoroutines_goroutines_stragglers(t *testing.T) {
+	g := &goroutines{}
+	assert.Empty(t, g.stragglers())
+
+	block := make(chan struct{})
+	done := make(chan struct{})
+
+	g.spawn("b", func() { <-block })
+	g.spawn("a", func() { <-block })
+	g.spawn("a", func() { <-block })
+	g.spawn("c", func() { close(done) })
+
+	<-done
+	assert.Equal(t, []string{"a (2)", "b"}, g.wait(10*time.Millisecond))
+
+	close(block)
+	assert.Empty(t, g.wait(time.Second))
+}
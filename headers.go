@@ -0,0 +1,108 @@
+package buckyclient
+
+import (
+	"net/http"
+	"strings"
+)
+
+// setHeaders adds the configured static headers, then lets the header
+// function add or override anything it needs for this request
+func (c *Client) setHeaders(h http.Header) {
+	for key, values := range c.headers {
+		for _, value := range values {
+			h.Add(key, value)
+		}
+	}
+
+	if c.headerFunc != nil {
+		c.headerFunc(h)
+	}
+}
+
+// WithHeaders adds static headers to every flush request. This is useful
+// inside service meshes that route on headers such as l5d-dst-override.
+// Like WithHeader it fails for an empty key or a line break.
+func WithHeaders(headers http.Header) Option {
+	return func(c *Client) error {
+		for key, values := range headers {
+			for _, value := range values {
+				if err := checkHeader("WithHeaders", key, value); err != nil {
+					return err
+				}
+			}
+		}
+
+		if c.headers == nil {
+			c.headers = make(http.Header)
+		}
+
+		for key, values := range headers {
+			for _, value := range values {
+				c.headers.Add(key, value)
+			}
+		}
+
+		return nil
+	}
+}
+
+// WithHeader adds one static header to every flush request, e.g. a key an
+// authenticating proxy in front of the bucky server expects
+func WithHeader(key, value string) Option {
+	return func(c *Client) error {
+		if err := checkHeader("WithHeader", key, value); err != nil {
+			return err
+		}
+
+		if c.headers == nil {
+			c.headers = make(http.Header)
+		}
+
+		c.headers.Add(key, value)
+		return nil
+	}
+}
+
+// checkHeader rejects a header that can't be sent as given to option
+func checkHeader(option, key, value string) error {
+	if key == "" {
+		return invalidOption(option, "key must not be empty")
+	}
+
+	if strings.ContainsAny(key+value, "\r\n") {
+		return invalidOption(option, "key and value must not contain line breaks")
+	}
+
+	return nil
+}
+
+// WithBearerToken sends "Authorization: Bearer <token>" with every flush
+// request, replacing any Authorization header set before it
+func WithBearerToken(token string) Option {
+	return func(c *Client) error {
+		if token == "" {
+			return invalidOption("WithBearerToken", "token must not be empty")
+		}
+
+		if strings.ContainsAny(token, "\r\n") {
+			return invalidOption("WithBearerToken", "token must not contain line breaks")
+		}
+
+		if c.headers == nil {
+			c.headers = make(http.Header)
+		}
+
+		c.headers.Set("Authorization", "Bearer "+token)
+		return nil
+	}
+}
+
+// WithHeaderFunc sets a function that is called with the headers of every
+// flush request, after the static headers have been added, so headers that
+// change over time can be set.
+func WithHeaderFunc(fn func(http.Header)) Option {
+	return func(c *Client) error {
+		c.headerFunc = fn
+		return nil
+	}
+}
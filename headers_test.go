@@ -0,0 +1,103 @@
+package buckyclient
+
+import (
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeaders_Client_flush_SetsHeaders(t *testing.T) {
+	requests := make(chan *http.Request, 1)
+
+	mockBucky := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests <- r
+	}))
+	defer mockBucky.Close()
+
+	cl := &Client{
+		hostURL:    mockBucky.URL,
+		http:       &http.Client{},
+		logger:     log.New(ioutil.Discard, "", 0),
+		metrics:    make(map[Metric]Value),
+		bufferPool: newBufferPool(),
+	}
+
+	assert.NoError(t, WithHeaders(http.Header{"L5d-Dst-Override": {"bucky.default.svc:8080"}})(cl))
+	assert.NoError(t, WithHeaderFunc(func(h http.Header) {
+		h.Set("X-Request-Id", "abc")
+	})(cl))
+
+	cl.metrics[Metric{name: "myapp.facet", unit: UnitCount}] = Value{Sum: &Sum{Value: 1}}
+
+	assert.NoError(t, cl.flush())
+
+	r := <-requests
+	assert.Equal(t, "POST", r.Method)
+	assert.Equal(t, "text/plain", r.Header.Get("Content-Type"))
+	assert.Equal(t, "bucky.default.svc:8080", r.Header.Get("L5d-Dst-Override"))
+	assert.Equal(t, "abc", r.Header.Get("X-Request-Id"))
+}
+
+func TestHeaders_WithHeaders_Merges(t *testing.T) {
+	cl := &Client{}
+
+	assert.NoError(t, WithHeaders(http.Header{"A": {"1"}})(cl))
+	assert.NoError(t, WithHeaders(http.Header{"A": {"2"}, "B": {"3"}})(cl))
+
+	assert.Equal(t, []string{"1", "2"}, cl.headers["A"])
+	assert.Equal(t, "3", cl.headers.Get("B"))
+}
+
+func TestHeaders_WithHeaders_Invalid(t *testing.T) {
+	cl := &Client{}
+
+	assert.ErrorIs(t, WithHeaders(http.Header{"": {"v"}})(cl), ErrInvalidOption)
+	assert.ErrorIs(t, WithHeaders(http.Header{"X-Evil\r\nHost": {"other"}})(cl), ErrInvalidOption)
+	assert.ErrorIs(t, WithHeaders(http.Header{"A": {"1"}, "X-Evil": {"v\r\nHost: other"}})(cl), ErrInvalidOption)
+
+	// Nothing is added from a set with a bad header
+	assert.Empty(t, cl.headers)
+}
+
+func TestHeaders_WithHeader(t *testing.T) {
+	cl := &Client{}
+
+	assert.NoError(t, WithHeader("X-Api-Key", "k1")(cl))
+	assert.NoError(t, WithHeader("X-Api-Key", "k2")(cl))
+	assert.Equal(t, []string{"k1", "k2"}, cl.headers["X-Api-Key"])
+
+	assert.ErrorIs(t, WithHeader("", "v")(cl), ErrInvalidOption)
+	assert.ErrorIs(t, WithHeader("X-Evil", "v\r\nHost: other")(cl), ErrInvalidOption)
+}
+
+func TestHeaders_WithBearerToken(t *testing.T) {
+	requests := make(chan *http.Request, 1)
+
+	mockBucky := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests <- r
+	}))
+	defer mockBucky.Close()
+
+	cl := &Client{
+		hostURL:    mockBucky.URL,
+		http:       &http.Client{},
+		logger:     log.New(ioutil.Discard, "", 0),
+		metrics:    make(map[Metric]Value),
+		bufferPool: newBufferPool(),
+	}
+
+	assert.NoError(t, WithHeader("Authorization", "Basic old")(cl))
+	assert.NoError(t, WithBearerToken("s3cret")(cl))
+
+	cl.metrics[Metric{name: "myapp.facet", unit: UnitCount}] = Value{Sum: &Sum{Value: 1}}
+	assert.NoError(t, cl.flush())
+
+	r := <-requests
+	assert.Equal(t, []string{"Bearer s3cret"}, r.Header.Values("Authorization"))
+
+	assert.ErrorIs(t, WithBearerToken("")(cl), ErrInvalidOption)
+}
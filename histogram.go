@@ -0,0 +1,107 @@
+package buckyclient
+
+import (
+	"math"
+	"math/rand/v2"
+	"sort"
+)
+
+// histogramSamples is how many samples a histogram keeps each interval.
+// Beyond that a uniform random sample is kept, so percentiles become
+// estimates while min, max and mean stay exact.
+const histogramSamples = 1028
+
+// histogramPercentiles are the percentiles sent for every histogram
+var histogramPercentiles = []struct {
+	suffix string
+	p      float64
+}{
+	{".p50", 0.50},
+	{".p90", 0.90},
+	{".p99", 0.99},
+}
+
+// Histogram holds the samples of a metric for an interval. Once a
+// fractional sample has been added IsFloat is set and the derived values
+// are sent with a decimal point.
+type Histogram struct {
+	Count   int64
+	Total   float64
+	Min     float64
+	Max     float64
+	Samples []float64
+	IsFloat bool
+}
+
+// add includes a sample, replacing a random kept sample once the
+// histogram is full (reservoir sampling)
+func (h *Histogram) add(amount Amount) {
+	value := amount.float()
+
+	if h.Count == 0 || value < h.Min {
+		h.Min = value
+	}
+
+	if h.Count == 0 || value > h.Max {
+		h.Max = value
+	}
+
+	h.Count++
+	h.Total += value
+	h.IsFloat = h.IsFloat || amount.IsFloat
+
+	if len(h.Samples) < histogramSamples {
+		h.Samples = append(h.Samples, value)
+		return
+	}
+
+	if i := rand.Int64N(h.Count); i < histogramSamples {
+		h.Samples[i] = value
+	}
+}
+
+// percentile returns the nearest-rank percentile of sorted samples
+func percentile(sorted []float64, p float64) float64 {
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+
+	return sorted[rank]
+}
+
+// number returns a derived value in the histogram's format
+func (h *Histogram) number(f float64) number {
+	if h.IsFloat {
+		return number{f: f, isFloat: true}
+	}
+
+	return number{i: int64(f)}
+}
+
+// mean is rounded down for integer histograms, like Average
+func (h *Histogram) mean() number {
+	if h.IsFloat {
+		return number{f: h.Total / float64(h.Count), isFloat: true}
+	}
+
+	return number{i: int64(h.Total) / h.Count}
+}
+
+// eachLine calls fn with every derived line
+func (h *Histogram) eachLine(name string, fn func(name string, value number)) {
+	if h.Count == 0 {
+		return
+	}
+
+	fn(name+".min", h.number(h.Min))
+	fn(name+".max", h.number(h.Max))
+	fn(name+".mean", h.mean())
+
+	sorted := append([]float64(nil), h.Samples...)
+	sort.Float64s(sorted)
+
+	for _, p := range histogramPercentiles {
+		fn(name+p.suffix, h.number(percentile(sorted, p.p)))
+	}
+}
@@ -0,0 +1,90 @@
+package buckyclient
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistogram_Client_aggregate(t *testing.T) {
+	c := &Client{metrics: make(map[Metric]Value)}
+
+	for i := 1; i <= 100; i++ {
+		assert.NoError(t, c.aggregate(MetricWithAmount{Metric{name: "latency", unit: UnitMillisecond}, Amount{Value: i}, ActionHistogram}))
+	}
+
+	buf := &bytes.Buffer{}
+	c.formatMetricsForFlush(buf)
+
+	assert.ElementsMatch(t, []string{
+		"latency.min:1|ms",
+		"latency.max:100|ms",
+		"latency.mean:50|ms",
+		"latency.p50:50|ms",
+		"latency.p90:90|ms",
+		"latency.p99:99|ms",
+	}, splitLines(buf.String()))
+
+	assert.Equal(t, 6, c.PendingLines())
+	assert.Equal(t, buf.Len(), c.PendingBytesEstimate())
+}
+
+func TestHistogram_Histogram_add_Float(t *testing.T) {
+	h := &Histogram{}
+	h.add(Amount{Value: 1})
+	h.add(Amount{Float: 0.5, IsFloat: true})
+
+	var lines []string
+	h.eachLine("a", func(name string, value number) {
+		lines = append(lines, name+":"+string(value.append(nil)))
+	})
+
+	assert.Equal(t, []string{"a.min:0.5", "a.max:1", "a.mean:0.75", "a.p50:0.5", "a.p90:1", "a.p99:1"}, lines)
+}
+
+func TestHistogram_Histogram_add_Reservoir(t *testing.T) {
+	h := &Histogram{}
+
+	for i := 0; i < 10*histogramSamples; i++ {
+		h.add(Amount{Value: i})
+	}
+
+	assert.Len(t, h.Samples, histogramSamples)
+	assert.Equal(t, int64(10*histogramSamples), h.Count)
+	assert.Equal(t, 0.0, h.Min)
+	assert.Equal(t, float64(10*histogramSamples-1), h.Max)
+
+	// the kept samples still spread over the whole range
+	over := 0
+	for _, s := range h.Samples {
+		if s >= 5*histogramSamples {
+			over++
+		}
+	}
+
+	assert.InDelta(t, histogramSamples/2, over, histogramSamples/5)
+}
+
+func TestHistogram_Client_WriteOpenMetrics(t *testing.T) {
+	c := &Client{metrics: make(map[Metric]Value)}
+
+	for _, v := range []int{10, 20, 30} {
+		c.aggregate(MetricWithAmount{Metric{name: "rpc.latency", unit: UnitMillisecond}, Amount{Value: v}, ActionHistogram})
+	}
+
+	buf := &bytes.Buffer{}
+	assert.NoError(t, c.WriteOpenMetrics(buf))
+
+	assert.Equal(t, strings.Join([]string{
+		"# TYPE rpc_latency summary",
+		`rpc_latency{quantile="0.5"} 20`,
+		`rpc_latency{quantile="0.9"} 30`,
+		`rpc_latency{quantile="0.99"} 30`,
+		"rpc_latency_sum 60",
+		"rpc_latency_count 3",
+		"# EOF",
+		"",
+	}, "\n"), buf.String())
+}
@@ -0,0 +1,109 @@
+package buckyclient
+
+import (
+	"hash/fnv"
+	"math"
+	"math/bits"
+)
+
+// hllPrecision gives 4096 one byte registers per metric, for a standard
+// error of about 1.6%
+const hllPrecision = 12
+
+// hyperLogLog estimates the number of distinct values added to it
+type hyperLogLog struct {
+	registers [1 << hllPrecision]uint8
+}
+
+func (h *hyperLogLog) add(value string) {
+	f := fnv.New64a()
+	f.Write([]byte(value))
+	x := mix64(f.Sum64())
+
+	idx := x >> (64 - hllPrecision)
+	rank := uint8(bits.LeadingZeros64(x<<hllPrecision|1<<(hllPrecision-1)) + 1)
+
+	if rank > h.registers[idx] {
+		h.registers[idx] = rank
+	}
+}
+
+// estimate returns the approximate cardinality, using linear counting
+// while the estimate is small and registers are still empty
+func (h *hyperLogLog) estimate() int64 {
+	m := float64(len(h.registers))
+
+	sum := 0.0
+	zeros := 0
+	for _, r := range h.registers {
+		sum += 1 / float64(uint64(1)<<r)
+		if r == 0 {
+			zeros++
+		}
+	}
+
+	alpha := 0.7213 / (1 + 1.079/m)
+	e := alpha * m * m / sum
+
+	if e <= 2.5*m && zeros > 0 {
+		e = m * math.Log(m/float64(zeros))
+	}
+
+	return int64(math.Round(e))
+}
+
+// mix64 spreads the bits of an FNV hash, whose low bits are weak, using
+// the splitmix64 finalizer
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+
+	return x
+}
+
+// Distinct returns nothing and adds a value to an approximate count of
+// distinct values, such as unique users or IPs. Every flush sends the
+// estimate for the interval as a gauge, using a fixed 4KB per metric
+// however many values are seen.
+func (c *Client) Distinct(name, value string, tags ...Tag) {
+	tags = c.withTags(tags)
+	c, name = c.scoped(name)
+	c.logCaller(name)
+
+	if !c.Enabled() {
+		return
+	}
+
+	m := Metric{name: c.normalizeName(name), unit: UnitGauge, tags: canonicalTags(tags)}
+
+	c.hllMu.Lock()
+	defer c.hllMu.Unlock()
+
+	if c.hlls == nil {
+		c.hlls = make(map[Metric]*hyperLogLog)
+	}
+
+	h, ok := c.hlls[m]
+	if !ok {
+		h = &hyperLogLog{}
+		c.hlls[m] = h
+	}
+
+	h.add(value)
+}
+
+// addDistincts sets a gauge for every estimate and starts a new
+// interval - c.m must be held
+func (c *Client) addDistincts() {
+	c.hllMu.Lock()
+	defer c.hllMu.Unlock()
+
+	for m, h := range c.hlls {
+		c.aggregate(MetricWithAmount{m, Amount{Value: int(h.estimate())}, ActionLast})
+	}
+
+	c.hlls = nil
+}
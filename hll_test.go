@@ -0,0 +1,38 @@
+package buckyclient
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHLL_hyperLogLog_estimate(t *testing.T) {
+	for _, n := range []int{0, 10, 1000, 100000} {
+		h := &hyperLogLog{}
+
+		for i := 0; i < n; i++ {
+			h.add("user-" + strconv.Itoa(i))
+			h.add("user-" + strconv.Itoa(i)) // duplicates don't count
+		}
+
+		assert.InDelta(t, float64(n), float64(h.estimate()), float64(n)*0.05+1, "n=%d", n)
+	}
+}
+
+func TestHLL_Client_Distinct(t *testing.T) {
+	cl := &Client{metrics: make(map[Metric]Value)}
+
+	for i := 0; i < 50; i++ {
+		cl.Distinct("myapp.users", "user-"+strconv.Itoa(i%5))
+	}
+
+	cl.addDistincts()
+
+	buf := &bytes.Buffer{}
+	cl.formatMetricsForFlush(buf)
+
+	assert.Equal(t, "myapp.users:5|g\n", buf.String())
+	assert.Nil(t, cl.hlls)
+}
@@ -0,0 +1,85 @@
+package buckyclient
+
+import (
+	"net/http"
+	"time"
+)
+
+// DefaultHTTPTimeout bounds every flush request, including reading the
+// response, so a server that stops answering can't hold up the sender
+const DefaultHTTPTimeout = 30 * time.Second
+
+// WithHTTPTimeout replaces DefaultHTTPTimeout. Each attempt of WithRetry
+// gets the whole timeout.
+func WithHTTPTimeout(d time.Duration) Option {
+	return func(c *Client) error {
+		if d <= 0 {
+			return invalidOption("WithHTTPTimeout", "timeout must be positive")
+		}
+
+		c.httpTimeout = d
+		return nil
+	}
+}
+
+// WithHTTPClient sends flushes with the given http client instead of one
+// the client builds, for sharing connection pools or instrumenting
+// requests. It is used as it is, so its own Timeout applies rather than
+// DefaultHTTPTimeout. It can't be combined with the options that
+// configure the default client: WithHTTPTimeout, WithRoundTripper,
+// WithDialContext and WithTLSConfig.
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *Client) error {
+		if client == nil {
+			return invalidOption("WithHTTPClient", "client must not be nil")
+		}
+
+		c.httpClient = client
+		return nil
+	}
+}
+
+// newHTTPClient returns the http client flushes are sent with, adding the
+// errors of options that conflict with the ones given
+func (c *Client) newHTTPClient() (*http.Client, []error) {
+	var errs []error
+
+	if c.httpClient != nil {
+		conflicts := []struct {
+			option string
+			set    bool
+		}{
+			{"WithHTTPTimeout", c.httpTimeout != 0},
+			{"WithRoundTripper", c.roundTripper != nil},
+			{"WithDialContext", c.dialContext != nil},
+			{"WithTLSConfig", c.tlsConfig != nil},
+		}
+
+		for _, conflict := range conflicts {
+			if conflict.set {
+				errs = append(errs, invalidOption(conflict.option, "can't be used with a custom http client"))
+			}
+		}
+
+		return c.httpClient, errs
+	}
+
+	timeout := c.httpTimeout
+	if timeout == 0 {
+		timeout = DefaultHTTPTimeout
+	}
+
+	if c.roundTripper != nil {
+		if c.dialContext != nil {
+			errs = append(errs, invalidOption("WithDialContext", "can't be used with a custom round tripper"))
+		}
+
+		if c.tlsConfig != nil {
+			errs = append(errs, invalidOption("WithTLSConfig", "can't be used with a custom round tripper"))
+		}
+
+		return &http.Client{Transport: c.roundTripper, Timeout: timeout}, errs
+	}
+
+	return &http.Client{Transport: c.newTransport(), Timeout: timeout}, errs
+}
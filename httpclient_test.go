@@ -0,0 +1,98 @@
+package buckyclient
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTTPClient_newClient_DefaultTimeout(t *testing.T) {
+	cl, errs := newClient("http://localhost", DefaultInterval, nil)
+	assert.Empty(t, errs)
+	assert.Equal(t, DefaultHTTPTimeout, cl.http.Timeout)
+
+	cl, errs = newClient("http://localhost", DefaultInterval, []Option{WithRoundTripper(http.DefaultTransport)})
+	assert.Empty(t, errs)
+	assert.Equal(t, DefaultHTTPTimeout, cl.http.Timeout)
+	assert.Equal(t, http.DefaultTransport, cl.http.Transport)
+}
+
+func TestHTTPClient_WithHTTPTimeout(t *testing.T) {
+	unblock := make(chan struct{})
+
+	ts := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		<-unblock
+	}))
+	defer ts.Close()
+	defer close(unblock)
+
+	cl, errs := newClient(ts.URL, DefaultInterval, []Option{WithHTTPTimeout(50 * time.Millisecond)})
+	assert.Empty(t, errs)
+	cl.SetLogger(log.New(ioutil.Discard, "", 0))
+
+	cl.metrics[Metric{name: "hits", unit: UnitCount}] = Value{Sum: &Sum{Value: 1}}
+
+	var netErr net.Error
+	err := cl.flush()
+	assert.True(t, errors.As(err, &netErr) && netErr.Timeout(), "%v", err)
+}
+
+func TestHTTPClient_WithHTTPClient(t *testing.T) {
+	var posts int32
+
+	ts := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		atomic.AddInt32(&posts, 1)
+	}))
+	defer ts.Close()
+
+	used := false
+	client := &http.Client{Transport: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		used = true
+		return http.DefaultTransport.RoundTrip(r)
+	})}
+
+	cl, errs := newClient(ts.URL, DefaultInterval, []Option{WithHTTPClient(client)})
+	assert.Empty(t, errs)
+	assert.Same(t, client, cl.http)
+
+	cl.metrics[Metric{name: "hits", unit: UnitCount}] = Value{Sum: &Sum{Value: 1}}
+	assert.NoError(t, cl.flush())
+	assert.True(t, used)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&posts))
+}
+
+func TestHTTPClient_WithHTTPClient_Conflicts(t *testing.T) {
+	dial := func(context.Context, string, string) (net.Conn, error) { return nil, nil }
+
+	_, errs := newClient("http://localhost", DefaultInterval, []Option{
+		WithHTTPClient(&http.Client{}),
+		WithHTTPTimeout(time.Second),
+		WithRoundTripper(http.DefaultTransport),
+		WithDialContext(dial),
+	})
+
+	assert.Len(t, errs, 3)
+	for _, err := range errs {
+		assert.ErrorIs(t, err, ErrInvalidOption)
+	}
+}
+
+func TestHTTPClient_Invalid(t *testing.T) {
+	assert.ErrorIs(t, WithHTTPTimeout(0)(&Client{}), ErrInvalidOption)
+	assert.ErrorIs(t, WithHTTPClient(nil)(&Client{}), ErrInvalidOption)
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
@@ -0,0 +1,141 @@
+package buckyclient
+
+import (
+	"bytes"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Format is how payloads are written
+type Format int
+
+const (
+	// FormatBucky writes a line per value, name:value|unit, with tags in
+	// the WithTagFormat format. This is the default.
+	FormatBucky Format = iota
+
+	// FormatInflux writes InfluxDB line protocol, so payloads can be posted
+	// straight to an InfluxDB or Telegraf http listener:
+	//
+	//	http.requests,metric_type=counter,status=200 value=12 1700000000000000000
+	//
+	// Every metric is a line of its own, named after the metric, with its
+	// tags, a metric_type tag as Telegraf's statsd input adds, and the end
+	// of the flush window as the timestamp in nanoseconds. Metrics with a
+	// single value have a value field, while histograms and digests have
+	// min, max, mean and a field for each percentile. Fields are always
+	// floats, so a metric recorded both as an int and a float doesn't
+	// cause a field type conflict. Gauges that were only adjusted with
+	// GaugeDelta are left out, as the client doesn't know their value, and
+	// so are the sketches of WithDigestSketches.
+	FormatInflux
+)
+
+// WithFormat sets how payloads are written. Point the client at the
+// listener's write URL to use FormatInflux, e.g.
+// http://localhost:8086/api/v2/write?bucket=metrics.
+func WithFormat(format Format) Option {
+	return func(c *Client) error {
+		switch format {
+		case FormatBucky, FormatInflux:
+		default:
+			return invalidOption("WithFormat", "unknown format")
+		}
+
+		c.format = format
+		return nil
+	}
+}
+
+// influxEscaper escapes measurements, which can't have unescaped commas or
+// spaces
+var influxEscaper = strings.NewReplacer(`\`, `\\`, ",", `\,`, " ", `\ `)
+
+// influxTagEscaper escapes tag keys and values, which can't have unescaped
+// equals signs either
+var influxTagEscaper = strings.NewReplacer(`\`, `\\`, ",", `\,`, " ", `\ `, "=", `\=`)
+
+// influxTypes maps units to the metric_type tag
+var influxTypes = map[Unit]string{
+	UnitCount:       "counter",
+	UnitMillisecond: "timing",
+	UnitGauge:       "gauge",
+	UnitSet:         "set",
+}
+
+// writeInfluxMetric writes a metric as a line of InfluxDB line protocol
+func (c *Client) writeInfluxMetric(buf *bytes.Buffer, k Metric, v Value, at time.Time) {
+	type field struct {
+		key   string
+		value number
+	}
+
+	var fields []field
+
+	if v.Last != nil {
+		// Unlike statsd, the line protocol has no trouble with negative
+		// gauges
+		if value, ok := v.flushValue(); ok && !v.Last.Delta {
+			fields = append(fields, field{"value", value})
+		}
+	} else {
+		v.eachLine(k.name, func(name string, value number) {
+			key := strings.TrimPrefix(strings.TrimPrefix(name, k.name), ".")
+			if key == "" {
+				key = "value"
+			}
+
+			fields = append(fields, field{key, value})
+		})
+	}
+
+	if len(fields) == 0 {
+		return
+	}
+
+	buf.WriteString(influxEscaper.Replace(c.prefix + k.name))
+
+	metricType, ok := influxTypes[k.unit]
+	if !ok {
+		metricType = string(k.unit)
+	}
+
+	tags := append(splitTags(k.tags), Tag{Key: "metric_type", Value: metricType})
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].Key < tags[j].Key })
+
+	for _, t := range tags {
+		if t.Value == "" {
+			// The line protocol doesn't allow empty tag values
+			continue
+		}
+
+		buf.WriteByte(',')
+		buf.WriteString(influxTagEscaper.Replace(t.Key))
+		buf.WriteByte('=')
+		buf.WriteString(influxTagEscaper.Replace(t.Value))
+	}
+
+	var scratch [32]byte
+
+	for i, f := range fields {
+		if i == 0 {
+			buf.WriteByte(' ')
+		} else {
+			buf.WriteByte(',')
+		}
+
+		buf.WriteString(influxTagEscaper.Replace(f.key))
+		buf.WriteByte('=')
+		f.value.signed = false
+		buf.Write(f.value.append(scratch[:0]))
+	}
+
+	if !at.IsZero() {
+		buf.WriteByte(' ')
+		buf.Write(strconv.AppendInt(scratch[:0], at.UnixNano(), 10))
+	}
+
+	buf.WriteByte('\n')
+}
@@ -0,0 +1,68 @@
+package buckyclient
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInflux_Client_writeMetric(t *testing.T) {
+	c := newBatchingClient(1)
+	assert.NoError(t, WithFormat(FormatInflux)(c))
+	assert.NoError(t, WithPrefix("app")(c))
+
+	at := time.Unix(1700000000, 5)
+
+	write := func(m MetricWithAmount) string {
+		c.metrics = make(map[Metric]Value)
+		c.aggregate(m)
+
+		buf := &bytes.Buffer{}
+		c.formatMap(buf, c.metrics, at)
+
+		return buf.String()
+	}
+
+	assert.Equal(t, "app.http.requests,metric_type=counter,status=200 value=12 1700000000000000005\n",
+		write(MetricWithAmount{Metric{name: "http.requests", unit: UnitCount, tags: canonicalTags([]Tag{{"status", "200"}})}, Amount{Value: 12}, ActionSum}))
+
+	assert.Equal(t, "app.temp,metric_type=gauge value=-2.5 1700000000000000005\n",
+		write(MetricWithAmount{Metric{name: "temp", unit: UnitGauge}, Amount{Float: -2.5, IsFloat: true}, ActionLast}), "negative gauges are written as they are")
+
+	assert.Equal(t, "", write(MetricWithAmount{Metric{name: "queue", unit: UnitGauge}, Amount{Value: 3, Delta: true}, ActionLast}), "adjustments are left out")
+
+	assert.Equal(t, `app.disk\ io,host=web\ 1,metric_type=timing value=7 1700000000000000005`+"\n",
+		write(MetricWithAmount{Metric{name: "disk io", unit: UnitMillisecond, tags: canonicalTags([]Tag{{"host", "web 1"}, {"canary", ""}})}, Amount{Value: 7}, ActionSum}), "spaces are escaped and empty tags dropped")
+
+	assert.Equal(t, "app.latency,metric_type=timing min=10,max=30,mean=20,p50=20,p90=30,p99=30 1700000000000000005\n",
+		func() string {
+			c.metrics = make(map[Metric]Value)
+			for _, v := range []int{10, 20, 30} {
+				c.aggregate(MetricWithAmount{Metric{name: "latency", unit: UnitMillisecond}, Amount{Value: v}, ActionHistogram})
+			}
+
+			buf := &bytes.Buffer{}
+			c.formatMap(buf, c.metrics, at)
+
+			return buf.String()
+		}(), "histograms are a line with a field for each value")
+}
+
+func TestInflux_Client_flush(t *testing.T) {
+	rt := &recordingTransport{}
+	c := newRetryClient(rt, WithFormat(FormatInflux))
+
+	c.aggregate(MetricWithAmount{Metric{name: "a", unit: UnitCount}, Amount{Value: 1}, ActionSum})
+	assert.NoError(t, c.flush())
+
+	if assert.Len(t, rt.payloads, 1) {
+		assert.Regexp(t, `^a,metric_type=counter value=1 \d{19}\n$`, rt.payloads[0])
+	}
+}
+
+func TestInflux_WithFormat_Invalid(t *testing.T) {
+	assert.True(t, errors.Is(WithFormat(Format(7))(&Client{}), ErrInvalidOption))
+}
@@ -0,0 +1,131 @@
+package buckyclient
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultInputBuffer is how many samples can wait to be aggregated before
+// the buffer is full
+const DefaultInputBuffer = 1024
+
+// DroppedMetric counts samples dropped because the input buffer was full
+const DroppedMetric = "buckyclient.dropped"
+
+// OverflowPolicy is what a recording call does when the input buffer is
+// full
+type OverflowPolicy int
+
+const (
+	// OverflowBlock waits for room, so nothing is lost but a caller can be
+	// held up for as long as aggregating falls behind. This is the default.
+	OverflowBlock OverflowPolicy = iota
+
+	// OverflowDropNewest drops the sample being recorded
+	OverflowDropNewest
+
+	// OverflowDropOldest drops the sample that has waited longest to make
+	// room, which keeps the most recent data
+	OverflowDropOldest
+)
+
+// WithInputBuffer sets how many samples can wait to be aggregated. Samples
+// are handed over in the order they were recorded, and what happens once
+// the buffer is full is up to WithOverflowPolicy. A bigger buffer absorbs
+// longer bursts at the cost of holding more samples in memory.
+func WithInputBuffer(n int) Option {
+	return func(c *Client) error {
+		if n <= 0 {
+			return invalidOption("WithInputBuffer", "size must be positive")
+		}
+
+		c.inputBuffer = n
+		return nil
+	}
+}
+
+// WithOverflowPolicy sets what a recording call does when the input
+// buffer is full. Dropped samples are counted in DroppedMetric. With
+// WithRecordBudget the budget decides instead: a call waits for room until
+// it runs out, then drops the sample being recorded.
+func WithOverflowPolicy(policy OverflowPolicy) Option {
+	return func(c *Client) error {
+		switch policy {
+		case OverflowBlock, OverflowDropNewest, OverflowDropOldest:
+		default:
+			return invalidOption("WithOverflowPolicy", "unknown policy")
+		}
+
+		c.overflow = policy
+		return nil
+	}
+}
+
+// send hands a sample over to be aggregated, following the overflow policy
+// if the input buffer is full
+func (c *Client) send(metric MetricWithAmount) {
+	switch c.overflow {
+	case OverflowDropNewest:
+		select {
+		case c.input <- metric:
+			atomic.AddUint64(&c.inputSent, 1)
+		default:
+			atomic.AddUint64(&c.inputDropped, 1)
+		}
+
+	case OverflowDropOldest:
+		for {
+			select {
+			case c.input <- metric:
+				atomic.AddUint64(&c.inputSent, 1)
+				return
+			default:
+			}
+
+			select {
+			case <-c.input:
+				// Taken out of the buffer, so it counts as handled
+				atomic.AddUint64(&c.inputHandled, 1)
+				atomic.AddUint64(&c.inputDropped, 1)
+			default:
+			}
+		}
+
+	default:
+		select {
+		case c.input <- metric:
+			atomic.AddUint64(&c.inputSent, 1)
+		case <-c.done:
+			// Closed, so nothing is left to aggregate it
+		}
+	}
+}
+
+// handleInput aggregates a sample taken from the input buffer
+func (c *Client) handleInput(metric MetricWithAmount) {
+	c.handleMetricWithValue(metric)
+	atomic.AddUint64(&c.inputHandled, 1)
+}
+
+// waitForInput waits until every sample handed over before it was called
+// has been aggregated, or ctx is done. Samples come out of the buffer in
+// order, so it only has to wait for the count to catch up.
+func (c *Client) waitForInput(ctx context.Context) {
+	sent := atomic.LoadUint64(&c.inputSent)
+
+	for atomic.LoadUint64(&c.inputHandled) < sent && ctx.Err() == nil {
+		time.Sleep(time.Millisecond / 10)
+	}
+}
+
+// addDroppedMetrics adds the count of samples dropped for a full input
+// buffer - c.m must be held
+func (c *Client) addDroppedMetrics() {
+	dropped := atomic.SwapUint64(&c.inputDropped, 0)
+	if dropped == 0 {
+		return
+	}
+
+	c.aggregate(MetricWithAmount{Metric{name: DroppedMetric, unit: UnitCount}, Amount{Value: int(dropped)}, ActionSum})
+}
@@ -0,0 +1,117 @@
+package buckyclient
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInput_WithInputBuffer(t *testing.T) {
+	cl, errs := newClient("http://localhost", DefaultInterval, nil)
+	assert.Empty(t, errs)
+	assert.Equal(t, DefaultInputBuffer, cap(cl.input))
+
+	cl, errs = newClient("http://localhost", DefaultInterval, []Option{WithInputBuffer(8)})
+	assert.Empty(t, errs)
+	assert.Equal(t, 8, cap(cl.input))
+
+	assert.ErrorIs(t, WithInputBuffer(0)(&Client{}), ErrInvalidOption)
+}
+
+func TestInput_Client_send_InOrder(t *testing.T) {
+	cl := &Client{input: make(chan MetricWithAmount, 100)}
+
+	for i := 0; i < 100; i++ {
+		cl.Count("hits", i)
+	}
+
+	// Nothing is left running to hand a sample over
+	assert.Empty(t, cl.goroutines.stragglers())
+
+	for i := 0; i < 100; i++ {
+		assert.Equal(t, i, (<-cl.input).Amount.Value)
+	}
+}
+
+func TestInput_Client_waitForInput(t *testing.T) {
+	cl := &Client{
+		metrics: make(map[Metric]Value),
+		input:   make(chan MetricWithAmount, 10),
+		done:    make(chan struct{}),
+	}
+
+	for i := 0; i < 5; i++ {
+		cl.Count("hits", 1)
+	}
+
+	// Without anything aggregating, it gives up with the ctx
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	cl.waitForInput(ctx)
+	assert.Empty(t, cl.metrics)
+
+	go cl.inputProcessor()
+	defer close(cl.done)
+
+	cl.waitForInput(context.Background())
+
+	cl.m.Lock()
+	assert.Equal(t, int64(5), cl.metrics[Metric{name: "hits", unit: UnitCount}].Sum.Value)
+	cl.m.Unlock()
+}
+
+func TestInput_WithOverflowPolicy(t *testing.T) {
+	for _, tc := range []struct {
+		policy OverflowPolicy
+		kept   []int
+	}{
+		{OverflowDropNewest, []int{0, 1}},
+		{OverflowDropOldest, []int{1, 2}},
+	} {
+		cl := &Client{
+			metrics: make(map[Metric]Value),
+			input:   make(chan MetricWithAmount, 2),
+		}
+		assert.NoError(t, WithOverflowPolicy(tc.policy)(cl))
+
+		for i := 0; i < 3; i++ {
+			cl.Count("hits", i)
+		}
+
+		assert.Equal(t, tc.kept[0], (<-cl.input).Amount.Value)
+		assert.Equal(t, tc.kept[1], (<-cl.input).Amount.Value)
+
+		cl.m.Lock()
+		cl.addDroppedMetrics()
+		assert.Equal(t, int64(1), cl.metrics[Metric{name: DroppedMetric, unit: UnitCount}].Sum.Value)
+		cl.m.Unlock()
+	}
+
+	assert.ErrorIs(t, WithOverflowPolicy(OverflowPolicy(7))(&Client{}), ErrInvalidOption)
+}
+
+func TestInput_WithOverflowPolicy_Block(t *testing.T) {
+	cl := &Client{input: make(chan MetricWithAmount, 1)}
+
+	cl.Count("hits", 0)
+
+	recorded := make(chan struct{})
+	go func() {
+		cl.Count("hits", 1)
+		close(recorded)
+	}()
+
+	select {
+	case <-recorded:
+		t.Fatal("recording should wait for room")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	assert.Equal(t, 0, (<-cl.input).Amount.Value)
+	<-recorded
+	assert.Equal(t, 1, (<-cl.input).Amount.Value)
+	assert.Equal(t, uint64(0), cl.inputDropped)
+}
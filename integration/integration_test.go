@@ -0,0 +1,175 @@
+// Package integration runs the client against a real BuckyServer. The
+// tests are skipped unless BUCKY_INTEGRATION_URL is set to the server's
+// send URL; docker-compose.yml starts one. If BUCKY_INTEGRATION_GRAPHITE
+// is also set to a graphite web URL, every metric is looked for there too.
+//
+// Add a case to variants for every new encoder or transport so its wire
+// format is checked against the real server.
+package integration
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matzhouse/go-bucky-client"
+	"github.com/stretchr/testify/assert"
+)
+
+// variant is one way of configuring the client
+type variant struct {
+	name string
+	opts []buckyclient.Option
+}
+
+var variants = []variant{
+	{"plain", nil},
+	{"capability_probe", []buckyclient.Option{buckyclient.WithCapabilityProbe()}},
+	{"batched", []buckyclient.Option{buckyclient.WithSampleBatching(16)}},
+	{"warmup", []buckyclient.Option{buckyclient.WithWarmup()}},
+	{"tags_graphite", []buckyclient.Option{buckyclient.WithTagFormat(buckyclient.TagFormatGraphite)}},
+	{"max_payload", []buckyclient.Option{buckyclient.WithMaxPayloadBytes(64)}},
+	{"digest_sketches", []buckyclient.Option{buckyclient.WithDigestSketches()}},
+}
+
+// sample records one kind of metric and names what graphite should show
+type sample struct {
+	name   string
+	record func(c *buckyclient.Client, name string)
+
+	// graphite is the series statsd writes for the metric. Tagged series
+	// are stored under graphite's own tag scheme, so they aren't looked for.
+	graphite string
+}
+
+var samples = []sample{
+	{"count", func(c *buckyclient.Client, name string) { c.Count(name, 3) }, "stats_counts.%s"},
+	{"timer", func(c *buckyclient.Client, name string) { c.AverageTimer(name, 12) }, "stats.timers.%s.mean"},
+	{"timer_float", func(c *buckyclient.Client, name string) { c.AverageTimerF(name, 0.5) }, "stats.timers.%s.mean"},
+	{"gauge", func(c *buckyclient.Client, name string) { c.Gauge(name, 7) }, "stats.gauges.%s"},
+	{"histogram", func(c *buckyclient.Client, name string) { c.Histogram(name, 12) }, "stats.timers.%s.p99.mean"},
+	{"digest", func(c *buckyclient.Client, name string) { c.Digest(name, 12) }, "stats.timers.%s.p99.mean"},
+	{"count_tagged", func(c *buckyclient.Client, name string) { c.Count(name, 3, region) }, ""},
+	{"timer_tagged", func(c *buckyclient.Client, name string) { c.AverageTimerF(name, 0.5, region) }, ""},
+	{"gauge_tagged", func(c *buckyclient.Client, name string) { c.Gauge(name, 7, region) }, ""},
+}
+
+var region = buckyclient.Tag{Key: "region", Value: "integration"}
+
+func sendURL(t *testing.T) string {
+	u := os.Getenv("BUCKY_INTEGRATION_URL")
+	if u == "" {
+		t.Skip("BUCKY_INTEGRATION_URL isn't set")
+	}
+
+	return u
+}
+
+func TestIntegration_BuckyServer(t *testing.T) {
+	host := sendURL(t)
+	run := strconv.FormatInt(time.Now().Unix(), 10)
+
+	for _, v := range variants {
+		t.Run(v.name, func(t *testing.T) {
+			// WithMaxPayloadBytes reports every post of a flush separately
+			results := make(chan buckyclient.FlushResult, 100)
+
+			opts := append([]buckyclient.Option{
+				buckyclient.WithFlushCallback(func(r buckyclient.FlushResult) { results <- r }),
+			}, v.opts...)
+
+			c, err := buckyclient.NewClient(host, 60, opts...)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			c.SetLogger(log.New(ioutil.Discard, "", 0))
+
+			var names []string
+			for _, s := range samples {
+				name := fmt.Sprintf("buckyclient_it.%s.%s.%s", run, v.name, s.name)
+				names = append(names, name)
+
+				s.record(c, name)
+			}
+
+			// Recording happens in the background, and Stop sends the rest
+			time.Sleep(100 * time.Millisecond)
+			c.Stop()
+
+			close(results)
+			sent := 0
+			for r := range results {
+				assert.NoError(t, r.Err, "payload %q", r.Payload)
+				sent++
+			}
+
+			assert.NotZero(t, sent)
+
+			for i, s := range samples {
+				if s.graphite == "" {
+					continue
+				}
+
+				assertInGraphite(t, fmt.Sprintf(s.graphite, names[i]))
+			}
+		})
+	}
+}
+
+// assertInGraphite waits for a series to have a value in graphite, if
+// BUCKY_INTEGRATION_GRAPHITE is set
+func assertInGraphite(t *testing.T, target string) {
+	graphite := os.Getenv("BUCKY_INTEGRATION_GRAPHITE")
+	if graphite == "" {
+		return
+	}
+
+	render := strings.TrimSuffix(graphite, "/") + "/render?format=json&from=-10min&target=" + url.QueryEscape(target)
+
+	// statsd flushes every 10 seconds, and graphite takes a moment to write
+	deadline := time.Now().Add(45 * time.Second)
+	for time.Now().Before(deadline) {
+		if hasDatapoint(render) {
+			return
+		}
+
+		time.Sleep(2 * time.Second)
+	}
+
+	t.Errorf("%s never showed up in graphite", target)
+}
+
+func hasDatapoint(render string) bool {
+	resp, err := http.Get(render)
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+
+	var series []struct {
+		Datapoints [][2]*float64 `json:"datapoints"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&series); err != nil {
+		return false
+	}
+
+	for _, s := range series {
+		for _, p := range s.Datapoints {
+			if p[0] != nil {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,282 @@
+package buckyclient
+
+import "math/rand/v2"
+
+// Batch aggregates samples locally, without any locks or channel sends,
+// until Submit folds them into the client in one go. It is meant for tight
+// loops that would otherwise record thousands of individual samples:
+//
+//	b := c.Batch()
+//	for _, item := range items {
+//		b.Count("items.processed", 1)
+//		b.Timer("items.latency", process(item))
+//	}
+//	b.Submit()
+//
+// A Batch must only be used from one goroutine. Samples recorded on it
+// are not traced one by one and don't count against a record budget.
+type Batch struct {
+	c       *Client
+	scope   string // Of the scope the batch came from
+	tags    []Tag  // Added to every sample, see WithTags
+	metrics map[Metric]Value
+	errs    []error
+}
+
+// Batch returns an empty batch that submits to the client
+func (c *Client) Batch() *Batch {
+	return &Batch{c: c.root(), scope: c.scope, tags: c.tags, metrics: make(map[Metric]Value)}
+}
+
+// Count is Client.Count on the batch
+func (b *Batch) Count(name string, value int, tags ...Tag) {
+	b.record(name, Amount{Value: value}, UnitCount, ActionSum, tags)
+}
+
+// Incr is Client.Incr on the batch
+func (b *Batch) Incr(name string, tags ...Tag) {
+	b.Count(name, 1, tags...)
+}
+
+// Decr is Client.Decr on the batch
+func (b *Batch) Decr(name string, tags ...Tag) {
+	b.Count(name, -1, tags...)
+}
+
+// Timer is Client.Timer on the batch
+func (b *Batch) Timer(name string, value int, tags ...Tag) {
+	b.record(name, Amount{Value: value}, UnitMillisecond, ActionSum, tags)
+}
+
+// Gauge is Client.Gauge on the batch
+func (b *Batch) Gauge(name string, value int, tags ...Tag) {
+	b.record(name, Amount{Value: value}, UnitGauge, ActionLast, tags)
+}
+
+// GaugeDelta is Client.GaugeDelta on the batch
+func (b *Batch) GaugeDelta(name string, delta int, tags ...Tag) {
+	b.record(name, Amount{Value: delta, Delta: true}, UnitGauge, ActionLast, tags)
+}
+
+// Ratio is Client.Ratio on the batch
+func (b *Batch) Ratio(name string, numerator, denominator int, tags ...Tag) {
+	b.record(name, Amount{Value: numerator, Denominator: denominator}, UnitGauge, ActionRatio, tags)
+}
+
+// AverageTimer is Client.AverageTimer on the batch
+func (b *Batch) AverageTimer(name string, value int, tags ...Tag) {
+	b.record(name, Amount{Value: value}, UnitMillisecond, ActionAvg, tags)
+}
+
+// CountF is Client.CountF on the batch
+func (b *Batch) CountF(name string, value float64, tags ...Tag) {
+	b.record(name, Amount{Float: value, IsFloat: true}, UnitCount, ActionSum, tags)
+}
+
+// TimerF is Client.TimerF on the batch
+func (b *Batch) TimerF(name string, value float64, tags ...Tag) {
+	b.record(name, Amount{Float: value, IsFloat: true}, UnitMillisecond, ActionSum, tags)
+}
+
+// AverageTimerF is Client.AverageTimerF on the batch
+func (b *Batch) AverageTimerF(name string, value float64, tags ...Tag) {
+	b.record(name, Amount{Float: value, IsFloat: true}, UnitMillisecond, ActionAvg, tags)
+}
+
+// GaugeF is Client.GaugeF on the batch
+func (b *Batch) GaugeF(name string, value float64, tags ...Tag) {
+	b.record(name, Amount{Float: value, IsFloat: true}, UnitGauge, ActionLast, tags)
+}
+
+// Histogram is Client.Histogram on the batch
+func (b *Batch) Histogram(name string, value int, tags ...Tag) {
+	b.record(name, Amount{Value: value}, UnitMillisecond, ActionHistogram, tags)
+}
+
+// Digest is Client.Digest on the batch
+func (b *Batch) Digest(name string, value int, tags ...Tag) {
+	b.record(name, Amount{Value: value}, UnitMillisecond, ActionDigest, tags)
+}
+
+// Unique is Client.Unique on the batch
+func (b *Batch) Unique(name string, value string, tags ...Tag) {
+	b.record(name, Amount{Member: value}, UnitSet, ActionUnique, tags)
+}
+
+// record aggregates a sample in the batch, keeping any error for Submit
+func (b *Batch) record(name string, amount Amount, unit Unit, action Action, tags []Tag) {
+	metric := Metric{name: b.c.normalizeName(b.scope + name), unit: unit, tags: canonicalTags(mergeTags(b.tags, tags))}
+	m := MetricWithAmount{metric, amount, b.c.aggregation(metric.name, action)}
+
+	if err := aggregateInto(b.metrics, m); err != nil {
+		b.errs = append(b.errs, err)
+	}
+}
+
+// Len returns how many metrics the batch holds
+func (b *Batch) Len() int {
+	return len(b.metrics)
+}
+
+// Submit merges the batch into the client, taking the client lock once,
+// and empties it so it can be used again. Errors from recording, such as
+// overflows, are passed to the error handler. Nothing is merged while the
+// client is disabled.
+func (b *Batch) Submit() {
+	metrics, errs := b.metrics, b.errs
+	b.metrics, b.errs = make(map[Metric]Value, len(metrics)), nil
+
+	if !b.c.Enabled() {
+		return
+	}
+
+	if len(metrics) > 0 {
+		b.c.m.Lock()
+		for k, v := range metrics {
+			if existing, ok := b.c.metrics[k]; ok {
+				if existing.action() != v.action() {
+					errs = append(errs, &MetricError{Name: k.name, Err: ErrActionConflict})
+				} else if existing.merge(v) {
+					errs = append(errs, &MetricError{Name: k.name, Err: ErrOverflow})
+				}
+			} else if b.c.admit(k) {
+				b.c.metrics[k] = v
+			}
+		}
+		b.c.m.Unlock()
+	}
+
+	// Report outside the lock in case the handler records metrics itself
+	for _, err := range errs {
+		b.c.handleError(err)
+	}
+}
+
+// merge folds another aggregate of the same metric into v, reporting
+// whether a total overflowed. Aggregates of different actions are left
+// alone, so callers check for ErrActionConflict first.
+func (v Value) merge(o Value) (overflow bool) {
+	switch {
+	case v.Sum != nil && o.Sum != nil:
+		return v.Sum.merge(*o.Sum)
+	case v.Avg != nil && o.Avg != nil:
+		return v.Avg.merge(*o.Avg)
+	case v.Last != nil && o.Last != nil:
+		return v.Last.apply(*o.Last)
+	case v.Ratio != nil && o.Ratio != nil:
+		var denOverflow bool
+
+		v.Ratio.Numerator, overflow = addInt64(v.Ratio.Numerator, o.Ratio.Numerator)
+		v.Ratio.Denominator, denOverflow = addInt64(v.Ratio.Denominator, o.Ratio.Denominator)
+
+		return overflow || denOverflow
+	case v.Set != nil && o.Set != nil:
+		for member := range o.Set.Members {
+			v.Set.add(member)
+		}
+	case v.Hist != nil && o.Hist != nil:
+		v.Hist.merge(o.Hist)
+	case v.Digest != nil && o.Digest != nil:
+		v.Digest.Merge(o.Digest)
+	}
+
+	return false
+}
+
+// merge adds another sum to s
+func (s *Sum) merge(o Sum) (overflow bool) {
+	if o.IsFloat || s.IsFloat {
+		total := o.Float
+		if !o.IsFloat {
+			total = float64(o.Value)
+		}
+
+		return s.add(Amount{Float: total, IsFloat: true})
+	}
+
+	s.Value, overflow = addInt64(s.Value, o.Value)
+	return overflow
+}
+
+// merge adds the samples of another average to a
+func (a *Average) merge(o Average) (overflow bool) {
+	a.Count += o.Count
+
+	if o.IsFloat && !a.IsFloat {
+		a.FloatTotal = float64(a.Total)
+		a.IsFloat = true
+	}
+
+	if a.IsFloat {
+		total := o.FloatTotal
+		if !o.IsFloat {
+			total = float64(o.Total)
+		}
+
+		a.FloatTotal, overflow = addFloat64(a.FloatTotal, total)
+		return overflow
+	}
+
+	a.Total, overflow = addInt64(a.Total, o.Total)
+	a.Avg = a.Total / a.Count
+
+	return overflow
+}
+
+// merge adds the samples of another histogram to h. Min, max and mean stay
+// exact. Once there are more samples than a histogram keeps, each kept
+// sample is drawn from h or o in proportion to how many samples each saw.
+func (h *Histogram) merge(o *Histogram) {
+	if o.Count == 0 {
+		return
+	}
+
+	if h.Count == 0 {
+		*h = *o
+		h.Samples = append([]float64(nil), o.Samples...)
+
+		return
+	}
+
+	if o.Min < h.Min {
+		h.Min = o.Min
+	}
+
+	if o.Max > h.Max {
+		h.Max = o.Max
+	}
+
+	h.IsFloat = h.IsFloat || o.IsFloat
+
+	if len(h.Samples)+len(o.Samples) <= histogramSamples {
+		h.Samples = append(h.Samples, o.Samples...)
+	} else {
+		h.Samples = mergeSamples(h.Samples, h.Count, o.Samples, o.Count)
+	}
+
+	h.Count += o.Count
+	h.Total += o.Total
+}
+
+// mergeSamples picks histogramSamples samples from a and b, which stand for
+// na and nb samples
+func mergeSamples(a []float64, na int64, b []float64, nb int64) []float64 {
+	a = append([]float64(nil), a...)
+	b = append([]float64(nil), b...)
+
+	rand.Shuffle(len(a), func(i, j int) { a[i], a[j] = a[j], a[i] })
+	rand.Shuffle(len(b), func(i, j int) { b[i], b[j] = b[j], b[i] })
+
+	out := make([]float64, 0, histogramSamples)
+	for len(out) < histogramSamples {
+		fromA := len(b) == 0 || (len(a) > 0 && rand.Int64N(na+nb) < na)
+
+		if fromA {
+			out, a = append(out, a[0]), a[1:]
+		} else {
+			out, b = append(out, b[0]), b[1:]
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,139 @@
+package buckyclient
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocalBatch_Batch_Submit(t *testing.T) {
+	c := &Client{metrics: make(map[Metric]Value)}
+
+	// Already aggregated on the client, so the batch is merged in
+	c.aggregate(MetricWithAmount{Metric{name: "hits", unit: UnitCount}, Amount{Value: 10}, ActionSum})
+	c.aggregate(MetricWithAmount{Metric{name: "lat", unit: UnitMillisecond}, Amount{Value: 10}, ActionAvg})
+	c.aggregate(MetricWithAmount{Metric{name: "users", unit: UnitSet}, Amount{Member: "alice"}, ActionUnique})
+
+	b := c.Batch()
+	for i := 0; i < 1000; i++ {
+		b.Count("hits", 1)
+		b.AverageTimer("lat", 20)
+		b.Gauge("depth", i)
+	}
+	b.Unique("users", "alice")
+	b.Unique("users", "bob")
+	b.Count("hits", 5, Tag{"env", "prod"})
+	b.Ratio("ratio", 1, 4)
+
+	assert.Equal(t, 6, b.Len())
+	assert.Equal(t, 3, c.PendingLines(), "nothing is recorded until Submit")
+
+	b.Submit()
+	assert.Equal(t, 0, b.Len())
+
+	buf := &bytes.Buffer{}
+	c.formatMetricsForFlush(buf)
+	assert.ElementsMatch(t, []string{
+		"hits:1010|c",
+		"hits:5|c|#env:prod",
+		"lat:19|ms",
+		"depth:999|g",
+		"users:2|s",
+		"ratio:25|g",
+	}, splitLines(buf.String()))
+
+	// The batch can be used again
+	b.Count("hits", 1)
+	b.Submit()
+	assert.Equal(t, int64(1011), c.metrics[Metric{name: "hits", unit: UnitCount}].Sum.Value)
+}
+
+func TestLocalBatch_Batch_Submit_Disabled(t *testing.T) {
+	c := &Client{metrics: make(map[Metric]Value)}
+	c.SetEnabled(false)
+
+	b := c.Batch()
+	b.Count("hits", 1)
+	b.Submit()
+
+	assert.Empty(t, c.metrics)
+	assert.Equal(t, 0, b.Len())
+}
+
+func TestLocalBatch_Batch_Submit_Errors(t *testing.T) {
+	var errs []error
+
+	c := &Client{metrics: make(map[Metric]Value)}
+	WithErrorHandler(func(err error) { errs = append(errs, err) })(c)
+
+	c.metrics[Metric{name: "big", unit: UnitCount}] = Value{Sum: &Sum{Value: math.MaxInt64}}
+
+	b := c.Batch()
+	b.Count("big", 1)
+	b.CountF("nan", math.NaN())
+	b.Submit()
+
+	assert.Len(t, errs, 2)
+	assert.ErrorIs(t, errs[0], ErrInvalidValue)
+	assert.ErrorIs(t, errs[1], ErrOverflow)
+}
+
+func TestLocalBatch_Batch_Submit_GaugeDelta(t *testing.T) {
+	c := newBatchingClient(1)
+	c.Gauge("queue", 10)
+	c.GaugeDelta("in_flight", 1)
+
+	b := c.Batch()
+	b.GaugeDelta("queue", -2)
+	b.GaugeDelta("in_flight", 1)
+	b.Submit()
+
+	buf := &bytes.Buffer{}
+	c.formatMetricsForFlush(buf)
+	assert.ElementsMatch(t, []string{"queue:8|g", "in_flight:+2|g"}, splitLines(buf.String()))
+}
+
+func TestLocalBatch_Value_merge_Float(t *testing.T) {
+	sum := Value{Sum: &Sum{Value: 2}}
+	sum.merge(Value{Sum: &Sum{Float: 0.5, IsFloat: true}})
+	value, _ := sum.flushValue()
+	assert.Equal(t, 2.5, value.f)
+
+	avg := Value{Avg: &Average{Count: 2, Total: 4, Avg: 2}}
+	avg.merge(Value{Avg: &Average{Count: 2, FloatTotal: 1, IsFloat: true}})
+	value, _ = avg.flushValue()
+	assert.Equal(t, number{f: 1.25, isFloat: true}, value)
+}
+
+func TestLocalBatch_Histogram_merge(t *testing.T) {
+	h := &Histogram{}
+	o := &Histogram{}
+
+	for i := 1; i <= histogramSamples; i++ {
+		h.add(Amount{Value: i})
+		o.add(Amount{Value: histogramSamples + i})
+	}
+
+	h.merge(o)
+
+	assert.Equal(t, int64(2*histogramSamples), h.Count)
+	assert.Equal(t, 1.0, h.Min)
+	assert.Equal(t, float64(2*histogramSamples), h.Max)
+	assert.Len(t, h.Samples, histogramSamples)
+
+	// Roughly half of the kept samples come from each side
+	fromO := 0
+	for _, s := range h.Samples {
+		if s > histogramSamples {
+			fromO++
+		}
+	}
+	assert.InDelta(t, histogramSamples/2, fromO, histogramSamples/10)
+
+	small := &Histogram{}
+	small.merge(&Histogram{Count: 1, Total: 3, Min: 3, Max: 3, Samples: []float64{3}})
+	small.merge(&Histogram{Count: 1, Total: 1, Min: 1, Max: 1, Samples: []float64{1}})
+	assert.Equal(t, &Histogram{Count: 2, Total: 4, Min: 1, Max: 3, Samples: []float64{3, 1}}, small)
+}
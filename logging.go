@@ -0,0 +1,133 @@
+package buckyclient
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"os"
+	"strconv"
+)
+
+// severity is how important a log line is, for log outputs that record it
+type severity int
+
+const (
+	severityInfo severity = iota
+	severityWarning
+	severityError
+)
+
+// severityWriter is a log output that records a severity with every line
+type severityWriter interface {
+	io.Writer
+	withSeverity(s severity) io.Writer
+}
+
+// logAt writes a log line with a severity. Outputs that don't record
+// severities get it like any other line.
+func (c *Client) logAt(s severity, calldepth int, msg string) {
+	logger := c.logger
+	if sw, ok := logger.Writer().(severityWriter); ok && s != severityInfo {
+		logger = log.New(sw.withSeverity(s), logger.Prefix(), logger.Flags())
+	}
+
+	logger.Output(calldepth+1, msg)
+}
+
+// WithJournald writes log lines to stderr with the priority prefixes
+// systemd-journald reads, so failed flushes show up as warnings in
+// journalctl -p. journald timestamps lines itself, so they have none.
+func WithJournald() Option {
+	return func(c *Client) error {
+		c.logger = newJournaldLogger(os.Stderr)
+		return nil
+	}
+}
+
+func newJournaldLogger(w io.Writer) *log.Logger {
+	return log.New(journaldWriter{w: w, priority: journaldPriorities[severityInfo]}, "", log.Lshortfile)
+}
+
+// journaldPriorities are the syslog priorities of each severity
+var journaldPriorities = map[severity]int{
+	severityInfo:    6,
+	severityWarning: 4,
+	severityError:   3,
+}
+
+// journaldWriter prefixes every line with a priority such as "<6>"
+type journaldWriter struct {
+	w        io.Writer
+	priority int
+}
+
+func (j journaldWriter) Write(p []byte) (int, error) {
+	prefix := "<" + strconv.Itoa(j.priority) + ">"
+
+	var buf bytes.Buffer
+	for _, line := range bytes.SplitAfter(p, []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+
+		buf.WriteString(prefix)
+		buf.Write(line)
+	}
+
+	if _, err := j.w.Write(buf.Bytes()); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+func (j journaldWriter) withSeverity(s severity) io.Writer {
+	j.priority = journaldPriorities[s]
+	return j
+}
+
+// WithEventLog writes log lines to the Windows Event Log under source, as
+// information, warning or error events. The source should be registered
+// first, e.g. with eventcreate, or Event Viewer shows the lines without a
+// proper description. Close deregisters it. It fails everywhere but
+// Windows.
+func WithEventLog(source string) Option {
+	return func(c *Client) error {
+		if source == "" {
+			return invalidOption("WithEventLog", "source must not be empty")
+		}
+
+		l, err := openEventLog(source)
+		if err != nil {
+			return invalidOption("WithEventLog", err.Error())
+		}
+
+		if c.logCloser != nil {
+			c.logCloser.Close()
+		}
+
+		c.logger = log.New(eventLogWriter{l: l, severity: severityInfo}, "", log.Lshortfile)
+		c.logCloser = l
+
+		return nil
+	}
+}
+
+// eventLogWriter reports every line as an event
+type eventLogWriter struct {
+	l        *eventLog
+	severity severity
+}
+
+func (e eventLogWriter) Write(p []byte) (int, error) {
+	if err := e.l.report(e.severity, string(bytes.TrimRight(p, "\n"))); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+func (e eventLogWriter) withSeverity(s severity) io.Writer {
+	e.severity = s
+	return e
+}
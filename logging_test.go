@@ -0,0 +1,65 @@
+package buckyclient
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogging_journaldWriter(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := journaldWriter{w: buf, priority: 6}
+
+	n, err := w.Write([]byte("one\ntwo\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, 8, n)
+	assert.Equal(t, "<6>one\n<6>two\n", buf.String())
+
+	buf.Reset()
+	w.withSeverity(severityError).Write([]byte("failed\n"))
+	assert.Equal(t, "<3>failed\n", buf.String())
+}
+
+func TestLogging_Client_logAt(t *testing.T) {
+	buf := &bytes.Buffer{}
+	c := &Client{logger: newJournaldLogger(buf)}
+
+	c.logf(FlushInfo{Seq: 1, ID: "ab"}, "probing")
+	c.warnf(FlushInfo{Seq: 1, ID: "ab"}, "http client - %v", errors.New("refused"))
+	c.errorf(FlushInfo{Seq: 1, ID: "ab"}, "dropping it")
+
+	assert.Equal(t, []string{
+		"<6>logging_test.go:31: flush=1 id=ab probing",
+		"<4>logging_test.go:32: flush=1 id=ab http client - refused",
+		"<3>logging_test.go:33: flush=1 id=ab dropping it",
+	}, splitLines(buf.String()))
+
+	// Other loggers are left as they are
+	buf.Reset()
+	c.logger = log.New(buf, "bucky ", 0)
+	c.warnf(FlushInfo{Seq: 2, ID: "cd"}, "retrying")
+
+	assert.Equal(t, "bucky flush=2 id=cd retrying\n", buf.String())
+}
+
+func TestLogging_WithJournald(t *testing.T) {
+	c := &Client{}
+	assert.NoError(t, WithJournald()(c))
+
+	_, ok := c.logger.Writer().(journaldWriter)
+	assert.True(t, ok)
+}
+
+func TestLogging_WithEventLog(t *testing.T) {
+	assert.ErrorIs(t, WithEventLog("")(&Client{}), ErrInvalidOption)
+
+	if runtime.GOOS == "windows" {
+		t.Skip("the event log is available")
+	}
+
+	assert.ErrorIs(t, WithEventLog("bucky")(&Client{}), ErrInvalidOption)
+}
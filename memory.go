@@ -0,0 +1,151 @@
+package buckyclient
+
+import (
+	"sync/atomic"
+	"unsafe"
+)
+
+const (
+	// MemoryMetricsMetric is how many aggregates were waiting for the flush
+	MemoryMetricsMetric = "buckyclient.memory.metrics"
+
+	// MemoryAggregatesMetric is Stats.AggregateBytes, in bytes
+	MemoryAggregatesMetric = "buckyclient.memory.aggregates_bytes"
+
+	// MemorySpoolMetric is Stats.SpoolBytes, in bytes
+	MemorySpoolMetric = "buckyclient.memory.spool_bytes"
+
+	// MemoryBufferMetric is Stats.BufferBytes, in bytes
+	MemoryBufferMetric = "buckyclient.memory.buffer_bytes"
+)
+
+// Stats is a snapshot of how much the client is holding on to. The sizes
+// are estimates from the lengths of names, tags and samples, which is
+// close enough to size WithMaxMetrics and WithRetryQueue from real data.
+type Stats struct {
+	Metrics        int // Aggregates waiting for the next flush
+	AggregateBytes int // Memory used by those aggregates and by Distinct and TopK
+	SpoolPayloads  int // Payloads held in the retry queue
+	SpoolBytes     int // Size of those payloads
+	BufferBytes    int // Capacity of the buffer the last payload was formatted in
+}
+
+// Stats returns how many aggregates, queued payloads and buffer bytes the
+// client holds right now
+func (c *Client) Stats() Stats {
+	c = c.root()
+
+	c.drainBatches()
+
+	c.m.Lock()
+	s := c.stats()
+	c.m.Unlock()
+
+	return s
+}
+
+// stats fills in Stats - c.m must be held
+func (c *Client) stats() Stats {
+	s := Stats{
+		Metrics:        len(c.metrics),
+		AggregateBytes: c.aggregateBytes(),
+		BufferBytes:    int(atomic.LoadInt64(&c.bufferBytes)),
+	}
+
+	if c.spool != nil {
+		c.spool.m.Lock()
+		s.SpoolPayloads, s.SpoolBytes = len(c.spool.entries), c.spool.bytes
+		c.spool.m.Unlock()
+	}
+
+	return s
+}
+
+// Rough per-entry costs of the maps aggregates are kept in: the key and
+// value plus what the map needs to find them
+const (
+	mapEntryOverhead = 16
+	metricEntrySize  = int(unsafe.Sizeof(Metric{})+unsafe.Sizeof(Value{})) + mapEntryOverhead
+	stringEntrySize  = int(unsafe.Sizeof("")) + mapEntryOverhead
+)
+
+// aggregateBytes estimates the memory used by everything aggregated for
+// the next flush - c.m must be held
+func (c *Client) aggregateBytes() int {
+	n := 0
+
+	for k, v := range c.metrics {
+		n += metricEntrySize + len(k.name) + len(k.tags) + v.size()
+	}
+
+	c.topkMu.Lock()
+	for k, s := range c.topks {
+		n += metricEntrySize + len(k.name) + len(k.tags) + int(unsafe.Sizeof(*s))
+		for key := range s.counts {
+			n += stringEntrySize + len(key) + 8
+		}
+	}
+	c.topkMu.Unlock()
+
+	c.hllMu.Lock()
+	for k, h := range c.hlls {
+		n += metricEntrySize + len(k.name) + len(k.tags) + int(unsafe.Sizeof(*h))
+	}
+	c.hllMu.Unlock()
+
+	return n
+}
+
+// size estimates the memory an aggregate points to
+func (v Value) size() int {
+	switch {
+	case v.Sum != nil:
+		return int(unsafe.Sizeof(*v.Sum))
+	case v.Avg != nil:
+		return int(unsafe.Sizeof(*v.Avg))
+	case v.Last != nil:
+		return int(unsafe.Sizeof(*v.Last))
+	case v.Ratio != nil:
+		return int(unsafe.Sizeof(*v.Ratio))
+	case v.Hist != nil:
+		return int(unsafe.Sizeof(*v.Hist)) + 8*cap(v.Hist.Samples)
+	case v.Digest != nil:
+		return int(unsafe.Sizeof(*v.Digest)) + int(unsafe.Sizeof(centroid{}))*(cap(v.Digest.centroids)+cap(v.Digest.unmerged))
+	case v.Set != nil:
+		n := int(unsafe.Sizeof(*v.Set))
+		for member := range v.Set.Members {
+			n += stringEntrySize + len(member)
+		}
+
+		return n
+	}
+
+	return 0
+}
+
+// WithMemoryMetrics reports Stats as gauges with every default flush,
+// measured before the flush takes the aggregates out
+func WithMemoryMetrics() Option {
+	return func(c *Client) error {
+		c.memoryMetrics = true
+		return nil
+	}
+}
+
+// addMemoryMetrics adds the gauges of WithMemoryMetrics - c.m must be held
+func (c *Client) addMemoryMetrics() {
+	if !c.memoryMetrics {
+		return
+	}
+
+	s := c.stats()
+
+	for name, value := range map[string]int{
+		MemoryMetricsMetric:    s.Metrics,
+		MemoryAggregatesMetric: s.AggregateBytes,
+		MemorySpoolMetric:      s.SpoolBytes,
+		MemoryBufferMetric:     s.BufferBytes,
+	} {
+		c.aggregate(MetricWithAmount{Metric{name: name, unit: UnitGauge}, Amount{Value: value}, ActionLast})
+	}
+}
@@ -0,0 +1,55 @@
+package buckyclient
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemory_Client_Stats(t *testing.T) {
+	rt := &recordingTransport{}
+	c := newRetryClient(rt, WithRetryQueue(time.Minute, 1<<20))
+
+	assert.Equal(t, Stats{}, c.Stats())
+
+	c.aggregate(MetricWithAmount{Metric{name: "hits", unit: UnitCount}, Amount{Value: 1}, ActionSum})
+	small := c.Stats()
+	assert.Equal(t, 1, small.Metrics)
+	assert.True(t, small.AggregateBytes > len("hits"), "%d", small.AggregateBytes)
+
+	for i := 0; i < 100; i++ {
+		c.aggregate(MetricWithAmount{Metric{name: "users", unit: UnitSet}, Amount{Member: "user-" + strconv.Itoa(i)}, ActionUnique})
+	}
+
+	large := c.Stats()
+	assert.Equal(t, 2, large.Metrics)
+	assert.True(t, large.AggregateBytes > small.AggregateBytes+100*len("user-00"), "%d", large.AggregateBytes)
+
+	// A failed flush leaves its payload in the retry queue, and the buffer
+	// it was formatted in behind
+	rt.err = errors.New("unreachable")
+	assert.Error(t, c.flush())
+
+	s := c.Stats()
+	assert.Equal(t, 0, s.Metrics)
+	assert.Equal(t, 0, s.AggregateBytes)
+	assert.Equal(t, 1, s.SpoolPayloads)
+	assert.Equal(t, len(rt.payloads[0]), s.SpoolBytes)
+	assert.True(t, s.BufferBytes >= s.SpoolBytes, "%d", s.BufferBytes)
+}
+
+func TestMemory_WithMemoryMetrics(t *testing.T) {
+	rt := &recordingTransport{}
+	c := newRetryClient(rt, WithMemoryMetrics())
+
+	c.aggregate(MetricWithAmount{Metric{name: "hits", unit: UnitCount}, Amount{Value: 1}, ActionSum})
+	assert.NoError(t, c.flush())
+
+	lines := splitLines(rt.payloads[0])
+	assert.Contains(t, lines, MemoryMetricsMetric+":1|g")
+	assert.Contains(t, lines, MemorySpoolMetric+":0|g")
+	assert.Len(t, lines, 5)
+}
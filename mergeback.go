@@ -0,0 +1,90 @@
+package buckyclient
+
+// MergeDroppedMetric counts aggregates WithMergeOnFailure couldn't keep
+const MergeDroppedMetric = "buckyclient.merge_dropped"
+
+// WithMergeOnFailure merges the metrics of a flush that fails back into
+// the client, so they are sent with the next flush along with anything
+// recorded since. Counters and timers add up, averages, histograms and
+// sets combine their samples, and a gauge keeps its newer value if it was
+// set again. Unlike WithRetryQueue, which it replaces, every interval's
+// data goes out as one payload once the server is back.
+//
+// At most maxMetrics metrics are held: a failed metric that hasn't been
+// recorded again since is dropped once the client holds that many, and
+// counted in MergeDroppedMetric, as is one recorded again with another
+// action. Payloads the status policy rejects are
+// never merged back.
+func WithMergeOnFailure(maxMetrics int) Option {
+	return func(c *Client) error {
+		if maxMetrics <= 0 {
+			return invalidOption("WithMergeOnFailure", "maxMetrics must be positive")
+		}
+
+		c.mergeBack = maxMetrics
+		return nil
+	}
+}
+
+// restoreMetrics returns the metrics of a failed flush to the client
+func (c *Client) restoreMetrics(snapshot map[Metric]Value) {
+	var errs []error
+
+	c.m.Lock()
+	for k, v := range snapshot {
+		if v.rollup {
+			continue
+		}
+
+		existing, ok := c.metrics[k]
+
+		switch {
+		case !ok:
+			if len(c.metrics) < c.mergeBack && c.admit(k) {
+				c.metrics[k] = v
+				c.unexpose(k, v)
+			} else {
+				c.mergeDropped++
+			}
+		case existing.action() != v.action():
+			c.mergeDropped++
+			errs = append(errs, &MetricError{Name: k.name, Err: ErrActionConflict})
+		case existing.Last != nil && !existing.Last.Delta:
+			// The gauge was set again, so its value is newer
+		case existing.Last != nil:
+			// It was only adjusted since, so the adjustments apply on top
+			c.unexpose(k, v)
+
+			older := *v.Last
+			if older.apply(*existing.Last) {
+				errs = append(errs, &MetricError{Name: k.name, Err: ErrOverflow})
+			}
+
+			*existing.Last = older
+		default:
+			c.unexpose(k, v)
+
+			if existing.merge(v) {
+				errs = append(errs, &MetricError{Name: k.name, Err: ErrOverflow})
+			}
+		}
+	}
+	c.m.Unlock()
+
+	for _, err := range errs {
+		c.handleError(err)
+	}
+}
+
+// addMergeMetrics adds the count of aggregates WithMergeOnFailure dropped -
+// c.m must be held
+func (c *Client) addMergeMetrics() {
+	if c.mergeDropped == 0 {
+		return
+	}
+
+	dropped := c.mergeDropped
+	c.mergeDropped = 0
+
+	c.aggregate(MetricWithAmount{Metric{name: MergeDroppedMetric, unit: UnitCount}, Amount{Value: dropped}, ActionSum})
+}
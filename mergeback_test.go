@@ -0,0 +1,87 @@
+package buckyclient
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeBack_Client_restoreMetrics(t *testing.T) {
+	rt := &recordingTransport{err: errors.New("unreachable")}
+	c := newRetryClient(rt, WithMergeOnFailure(10))
+
+	c.aggregate(MetricWithAmount{Metric{name: "hits", unit: UnitCount}, Amount{Value: 1}, ActionSum})
+	c.aggregate(MetricWithAmount{Metric{name: "temp", unit: UnitGauge}, Amount{Value: 20}, ActionLast})
+	c.aggregate(MetricWithAmount{Metric{name: "latency", unit: UnitMillisecond}, Amount{Value: 10}, ActionAvg})
+	assert.ErrorIs(t, c.flush(), rt.err)
+
+	// Recorded while the server was away
+	c.aggregate(MetricWithAmount{Metric{name: "hits", unit: UnitCount}, Amount{Value: 2}, ActionSum})
+	c.aggregate(MetricWithAmount{Metric{name: "temp", unit: UnitGauge}, Amount{Value: 25}, ActionLast})
+	c.aggregate(MetricWithAmount{Metric{name: "latency", unit: UnitMillisecond}, Amount{Value: 20}, ActionAvg})
+
+	rt.err = nil
+	assert.NoError(t, c.flush())
+
+	assert.ElementsMatch(t, []string{"hits:3|c", "temp:25|g", "latency:15|ms"}, splitLines(rt.payloads[1]))
+}
+
+func TestMergeBack_Client_restoreMetrics_GaugeDelta(t *testing.T) {
+	rt := &recordingTransport{err: errors.New("unreachable")}
+	c := newRetryClient(rt, WithMergeOnFailure(10))
+
+	c.aggregate(MetricWithAmount{Metric{name: "queue", unit: UnitGauge}, Amount{Value: 10}, ActionLast})
+	c.aggregate(MetricWithAmount{Metric{name: "in_flight", unit: UnitGauge}, Amount{Value: 3, Delta: true}, ActionLast})
+	assert.ErrorIs(t, c.flush(), rt.err)
+
+	// Only adjusted while the server was away, so both apply on top
+	c.aggregate(MetricWithAmount{Metric{name: "queue", unit: UnitGauge}, Amount{Value: -4, Delta: true}, ActionLast})
+	c.aggregate(MetricWithAmount{Metric{name: "in_flight", unit: UnitGauge}, Amount{Value: 2, Delta: true}, ActionLast})
+
+	rt.err = nil
+	assert.NoError(t, c.flush())
+
+	assert.ElementsMatch(t, []string{"queue:6|g", "in_flight:+5|g"}, splitLines(rt.payloads[1]))
+}
+
+func TestMergeBack_Client_restoreMetrics_Limit(t *testing.T) {
+	rt := &recordingTransport{err: errors.New("unreachable")}
+	c := newRetryClient(rt, WithMergeOnFailure(2))
+
+	c.aggregate(MetricWithAmount{Metric{name: "a", unit: UnitCount}, Amount{Value: 1}, ActionSum})
+	c.aggregate(MetricWithAmount{Metric{name: "b", unit: UnitCount}, Amount{Value: 1}, ActionSum})
+	c.aggregate(MetricWithAmount{Metric{name: "c", unit: UnitCount}, Amount{Value: 1}, ActionSum})
+	assert.ErrorIs(t, c.flush(), rt.err)
+
+	assert.Len(t, c.metrics, 2)
+	assert.Equal(t, 1, c.mergeDropped)
+
+	rt.err = nil
+	assert.NoError(t, c.flush())
+
+	assert.Contains(t, splitLines(rt.payloads[1]), MergeDroppedMetric+":1|c")
+	assert.Len(t, splitLines(rt.payloads[1]), 3)
+}
+
+func TestMergeBack_Client_restoreMetrics_ActionConflict(t *testing.T) {
+	var handled []error
+
+	c := newRetryClient(&recordingTransport{}, WithMergeOnFailure(10))
+	c.errorHandler = func(err error) { handled = append(handled, err) }
+
+	latency := Metric{name: "latency", unit: UnitMillisecond}
+	c.aggregate(MetricWithAmount{latency, Amount{Value: 10}, ActionAvg})
+
+	// The failed flush had it as a timer
+	c.restoreMetrics(map[Metric]Value{latency: {Sum: &Sum{Value: 5}}})
+
+	assert.Equal(t, ActionAvg, c.metrics[latency].action())
+	assert.Equal(t, 1, c.mergeDropped)
+	assert.Len(t, handled, 1)
+	assert.ErrorIs(t, handled[0], ErrActionConflict)
+}
+
+func TestMergeBack_WithMergeOnFailure_Invalid(t *testing.T) {
+	assert.ErrorIs(t, WithMergeOnFailure(0)(&Client{}), ErrInvalidOption)
+}
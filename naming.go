@@ -0,0 +1,84 @@
+package buckyclient
+
+import (
+	"strings"
+	"unicode"
+)
+
+// NameCase is how metric names are normalized before they are aggregated,
+// so the same metric recorded as "HTTPRequests" and "httpRequests" doesn't
+// end up as two series
+type NameCase int
+
+const (
+	// NameCaseNone leaves names as they are recorded
+	NameCaseNone NameCase = iota
+	// NameCaseLower lowercases names: "API.Requests" becomes "api.requests"
+	NameCaseLower
+	// NameCaseSnake splits camelCase words with underscores:
+	// "apiRequests.HTTPErrors" becomes "api_requests.http_errors"
+	NameCaseSnake
+	// NameCaseDots splits camelCase words with dots, so each word is a
+	// graphite node: "apiRequests.HTTPErrors" becomes "api.requests.http.errors"
+	NameCaseDots
+)
+
+// WithNameCase normalizes every recorded metric name. It only changes the
+// case of names and where camelCase words are split; dots, underscores and
+// dashes already in a name are kept.
+func WithNameCase(nc NameCase) Option {
+	return func(c *Client) error {
+		if nc < NameCaseNone || nc > NameCaseDots {
+			return invalidOption("WithNameCase", "unknown name case")
+		}
+
+		c.nameCase = nc
+		return nil
+	}
+}
+
+// normalizeName applies WithNameCase to a metric name
+func (c *Client) normalizeName(name string) string {
+	switch c.nameCase {
+	case NameCaseLower:
+		return strings.ToLower(name)
+	case NameCaseSnake:
+		return splitWords(name, '_')
+	case NameCaseDots:
+		return splitWords(name, '.')
+	}
+
+	return name
+}
+
+// splitWords lowercases name, putting sep between camelCase words. A run
+// of capitals is one word, so "HTTPServer" is "http" and "server".
+func splitWords(name string, sep rune) string {
+	if strings.IndexFunc(name, unicode.IsUpper) < 0 {
+		return name
+	}
+
+	runes := []rune(name)
+
+	var b strings.Builder
+	b.Grow(len(name) + 4)
+
+	for i, r := range runes {
+		if unicode.IsUpper(r) && i > 0 && !isNameSeparator(runes[i-1]) {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				b.WriteRune(sep)
+			}
+		}
+
+		b.WriteRune(unicode.ToLower(r))
+	}
+
+	return b.String()
+}
+
+func isNameSeparator(r rune) bool {
+	return r == '.' || r == '_' || r == '-'
+}
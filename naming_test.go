@@ -0,0 +1,50 @@
+package buckyclient
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNaming_Client_normalizeName(t *testing.T) {
+	tests := []struct {
+		name               string
+		lower, snake, dots string
+	}{
+		{"requests", "requests", "requests", "requests"},
+		{"API.Requests", "api.requests", "api.requests", "api.requests"},
+		{"apiRequests.HTTPErrors", "apirequests.httperrors", "api_requests.http_errors", "api.requests.http.errors"},
+		{"HTTPServer", "httpserver", "http_server", "http.server"},
+		{"cache_Hits", "cache_hits", "cache_hits", "cache_hits"},
+		{"s3Uploads", "s3uploads", "s3_uploads", "s3.uploads"},
+		{"queue2Depth", "queue2depth", "queue2_depth", "queue2.depth"},
+		{"ÜberCount", "übercount", "über_count", "über.count"},
+	}
+
+	for _, test := range tests {
+		for nc, want := range map[NameCase]string{NameCaseNone: test.name, NameCaseLower: test.lower, NameCaseSnake: test.snake, NameCaseDots: test.dots} {
+			c := &Client{nameCase: nc}
+			assert.Equal(t, want, c.normalizeName(test.name), "%s with %d", test.name, nc)
+		}
+	}
+}
+
+func TestNaming_WithNameCase(t *testing.T) {
+	c := newBatchingClient(1)
+	assert.NoError(t, WithNameCase(NameCaseSnake)(c))
+
+	c.Count("httpRequests", 1)
+	c.Count("HTTPRequests", 2)
+	c.Count("http_requests", 3)
+
+	b := c.Batch()
+	b.Count("HttpRequests", 4)
+	b.Submit()
+
+	buf := &bytes.Buffer{}
+	c.formatMetricsForFlush(buf)
+	assert.Equal(t, []string{"http_requests:10|c"}, splitLines(buf.String()))
+
+	assert.ErrorIs(t, WithNameCase(NameCase(9))(&Client{}), ErrInvalidOption)
+}
@@ -0,0 +1,65 @@
+package buckyclient
+
+import "math"
+
+// RegisterGauge has every flush of the default window call fn and send
+// what it returns as a gauge, so values such as pool sizes or goroutine
+// counts can be sampled without a ticker of their own:
+//
+//	c.RegisterGauge("goroutines", func() float64 { return float64(runtime.NumGoroutine()) })
+//
+// fn is called from the sender, outside the client's locks, so it must be
+// quick and safe to call from another goroutine. Nothing is sent for a
+// flush where it returns NaN or an infinity. Registering the same name and
+// tags again replaces the callback; UnregisterGauge removes it.
+func (c *Client) RegisterGauge(name string, fn func() float64, tags ...Tag) {
+	tags = c.withTags(tags)
+	c, name = c.scoped(name)
+
+	m := Metric{name: c.normalizeName(name), unit: UnitGauge, tags: canonicalTags(tags)}
+
+	c.observedMu.Lock()
+	defer c.observedMu.Unlock()
+
+	if c.observed == nil {
+		c.observed = make(map[Metric]func() float64)
+	}
+
+	c.observed[m] = fn
+}
+
+// UnregisterGauge stops sending a gauge registered with RegisterGauge
+func (c *Client) UnregisterGauge(name string, tags ...Tag) {
+	tags = c.withTags(tags)
+	c, name = c.scoped(name)
+
+	m := Metric{name: c.normalizeName(name), unit: UnitGauge, tags: canonicalTags(tags)}
+
+	c.observedMu.Lock()
+	delete(c.observed, m)
+	c.observedMu.Unlock()
+}
+
+// observeGauges calls every registered gauge callback, returning what they
+// reported
+func (c *Client) observeGauges() []MetricWithAmount {
+	c.observedMu.Lock()
+	observed := make(map[Metric]func() float64, len(c.observed))
+	for m, fn := range c.observed {
+		observed[m] = fn
+	}
+	c.observedMu.Unlock()
+
+	// Called without the lock, so a callback may register gauges itself
+	var metrics []MetricWithAmount
+	for m, fn := range observed {
+		value := fn()
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			continue
+		}
+
+		metrics = append(metrics, MetricWithAmount{m, Amount{Float: value, IsFloat: true}, ActionLast})
+	}
+
+	return metrics
+}
@@ -0,0 +1,55 @@
+package buckyclient
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestObserved_Client_RegisterGauge(t *testing.T) {
+	rt := &recordingTransport{}
+	c := newRetryClient(rt)
+
+	depth := 3.0
+	c.RegisterGauge("queue.depth", func() float64 { return depth })
+	c.RegisterGauge("load", func() float64 { return 0.25 }, Tag{Key: "cpu", Value: "0"})
+	c.RegisterGauge("broken", func() float64 { return math.NaN() })
+
+	assert.NoError(t, c.flush())
+
+	// Evaluated again on every flush
+	depth = 5
+	assert.NoError(t, c.flush())
+
+	assert.ElementsMatch(t, []string{"queue.depth:3|g", "load:0.25|g|#cpu:0"}, splitLines(rt.payloads[0]))
+	assert.ElementsMatch(t, []string{"queue.depth:5|g", "load:0.25|g|#cpu:0"}, splitLines(rt.payloads[1]))
+}
+
+func TestObserved_Client_RegisterGauge_Replace(t *testing.T) {
+	rt := &recordingTransport{}
+	c := newRetryClient(rt)
+
+	c.RegisterGauge("workers", func() float64 { return 1 })
+	c.RegisterGauge("workers", func() float64 { return 2 })
+	c.RegisterGauge("idle", func() float64 { return 4 })
+	c.UnregisterGauge("idle")
+
+	assert.NoError(t, c.flush())
+
+	assert.Equal(t, []string{"workers:2|g"}, splitLines(rt.payloads[0]))
+}
+
+func TestObserved_Client_RegisterGauge_Scope(t *testing.T) {
+	rt := &recordingTransport{}
+	c := newRetryClient(rt)
+
+	db := c.Scope("db").WithAdditionalTags(Tag{Key: "pool", Value: "main"})
+	db.RegisterGauge("connections", func() float64 { return 7 })
+
+	assert.NoError(t, c.flush())
+	assert.Equal(t, []string{"db.connections:7|g|#pool:main"}, splitLines(rt.payloads[0]))
+
+	db.UnregisterGauge("connections")
+	assert.Empty(t, c.observed)
+}
@@ -0,0 +1,365 @@
+package buckyclient
+
+import (
+	"bytes"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// OpenMetricsContentType is the content type of WriteOpenMetrics output,
+// for serving it to a scraper
+const OpenMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+
+// WriteOpenMetrics writes the aggregates waiting for the next flush in the
+// OpenMetrics text format, so agents that only scrape can pick them up.
+// Nothing is reset. Counters become counters with a _total sample, timers
+// recorded as averages become summaries with _sum and _count, and
+// everything else becomes a gauge. Characters OpenMetrics doesn't allow in
+// names, such as dots, are replaced with underscores, and tags become
+// labels. A name recorded with several units, such as a counter and a
+// timer, is exposed as one family for each with the unit as a suffix, e.g.
+// db_query_c and db_query_ms. Gauges that were only adjusted with
+// GaugeDelta are left out, as the client doesn't know their value, and so
+// is a metric whose type conflicts with another of the same name, with
+// ErrActionConflict reported to the error handler.
+func (c *Client) WriteOpenMetrics(w io.Writer) error {
+	c = c.root()
+
+	c.drainBatches()
+
+	c.m.Lock()
+
+	metrics := make(map[Metric]exposed, len(c.metrics))
+	for k, v := range c.metrics {
+		metrics[k] = newExposed(v)
+	}
+
+	families, conflicts := c.families(metrics)
+
+	c.m.Unlock()
+
+	for _, err := range conflicts {
+		c.handleError(err)
+	}
+
+	buf := &bytes.Buffer{}
+	writeFamilies(buf, families, true)
+	buf.WriteString("# EOF\n")
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// family is a metric as it is exposed to a scraper
+type family struct {
+	name   string
+	unit   Unit
+	labels string
+	value  exposed
+	typ    string // The OpenMetrics type, empty for one that isn't exposed
+}
+
+// families returns the metrics with their exposed names, labels and types,
+// in order, along with the metrics left out because their type conflicts
+// with another under the same name. A name that bucky has with several
+// units, such as a counter and a timer, gets the unit as a suffix, as a
+// family can only have one type. A counter that went below zero is
+// exposed as a gauge, since a counter can't go down.
+func (c *Client) families(metrics map[Metric]exposed) ([]family, []error) {
+	families := make([]family, 0, len(metrics))
+	units := make(map[string]map[Unit]bool)
+
+	for k, v := range metrics {
+		f := family{openMetricsName(c.prefix + k.name), k.unit, openMetricsLabels(k.tags), v, exposedType(k.unit, v)}
+		if f.typ == "" {
+			continue
+		}
+
+		if units[f.name] == nil {
+			units[f.name] = make(map[Unit]bool)
+		}
+
+		units[f.name][f.unit] = true
+		families = append(families, f)
+	}
+
+	for i, f := range families {
+		if len(units[f.name]) > 1 {
+			families[i].name = openMetricsName(f.name + "_" + string(f.unit))
+		}
+	}
+
+	sort.Slice(families, func(i, j int) bool {
+		if families[i].name != families[j].name {
+			return families[i].name < families[j].name
+		}
+
+		if families[i].unit != families[j].unit {
+			return families[i].unit < families[j].unit
+		}
+
+		return families[i].labels < families[j].labels
+	})
+
+	negative := make(map[string]bool)
+	for _, f := range families {
+		if value, _ := f.value.flushValue(); f.typ == "counter" && value.negative() {
+			negative[f.name] = true
+		}
+	}
+
+	// The first type seen for a name is the family's, and anything else
+	// under the same name is left out
+	var conflicts []error
+
+	kept := families[:0]
+	typ := make(map[string]string)
+
+	for _, f := range families {
+		if f.typ == "counter" && negative[f.name] {
+			f.typ = "gauge"
+		}
+
+		if t, ok := typ[f.name]; ok && t != f.typ {
+			conflicts = append(conflicts, &MetricError{Name: f.name, Err: ErrActionConflict})
+			continue
+		}
+
+		typ[f.name] = f.typ
+		kept = append(kept, f)
+	}
+
+	return kept, conflicts
+}
+
+// exposedType returns the OpenMetrics type a metric is exposed as, or
+// nothing for one with no value to expose
+func exposedType(unit Unit, v exposed) string {
+	switch {
+	case unit == UnitCount && v.Sum != nil:
+		return "counter"
+	case v.Hist != nil, v.Digest != nil:
+		if v.count > 0 {
+			return "summary"
+		}
+	case v.Last != nil && v.Last.Delta:
+		// Only adjusted, so there is no value to expose
+	case v.Avg != nil:
+		return "summary"
+	default:
+		if _, ok := v.flushValue(); ok {
+			return "gauge"
+		}
+	}
+
+	return ""
+}
+
+// writeFamilies writes the samples of every family, in the OpenMetrics
+// format or the Prometheus text format, which only differ in how counter
+// families are named
+func writeFamilies(buf *bytes.Buffer, families []family, openMetrics bool) {
+	// Differently tagged metrics are samples in one family, so the TYPE
+	// line is only written for the first of them
+	last := ""
+	typ := func(name, t string) {
+		if name != last {
+			writeFamily(buf, name, t)
+			last = name
+		}
+	}
+
+	for _, f := range families {
+		switch {
+		case f.typ == "counter":
+			value, _ := f.value.flushValue()
+
+			if openMetrics {
+				typ(f.name, "counter")
+			} else {
+				typ(f.name+"_total", "counter")
+			}
+
+			writeSample(buf, sampleName(f.name+"_total", f.labels), value)
+		case f.value.Hist != nil:
+			typ(f.name, "summary")
+			writeHistogram(buf, f.name, f.labels, f.value)
+		case f.value.Digest != nil:
+			typ(f.name, "summary")
+			writeDigest(buf, f.name, f.labels, f.value)
+		case f.value.Avg != nil:
+			sum := number{i: f.value.Avg.Total}
+			if f.value.Avg.IsFloat {
+				sum = number{f: f.value.Avg.FloatTotal, isFloat: true}
+			}
+
+			typ(f.name, "summary")
+			writeSample(buf, sampleName(f.name+"_sum", f.labels), sum)
+			writeSample(buf, sampleName(f.name+"_count", f.labels), number{i: f.value.Avg.Count})
+		default:
+			value, _ := f.value.flushValue()
+
+			typ(f.name, "gauge")
+			writeSample(buf, sampleName(f.name, f.labels), value)
+		}
+	}
+}
+
+// writeHistogram writes a histogram as a summary with quantiles of its
+// samples and the count and sum of e - the TYPE line is written by the
+// caller
+func writeHistogram(buf *bytes.Buffer, name, labels string, e exposed) {
+	h := e.Hist
+
+	sorted := append([]float64(nil), h.Samples...)
+	sort.Float64s(sorted)
+
+	for _, p := range histogramPercentiles {
+		quantile := `quantile="` + strconv.FormatFloat(p.p, 'f', -1, 64) + `"`
+		if labels != "" {
+			quantile += "," + labels
+		}
+
+		writeSample(buf, sampleName(name, quantile), h.number(percentile(sorted, p.p)))
+	}
+
+	writeSample(buf, sampleName(name+"_sum", labels), h.number(e.sum))
+	writeSample(buf, sampleName(name+"_count", labels), number{i: e.count})
+}
+
+// writeDigest writes a t-digest as a summary with quantiles and the count
+// and sum of e - the TYPE line is written by the caller
+func writeDigest(buf *bytes.Buffer, name, labels string, e exposed) {
+	t := e.Digest
+
+	for _, p := range histogramPercentiles {
+		quantile := `quantile="` + strconv.FormatFloat(p.p, 'f', -1, 64) + `"`
+		if labels != "" {
+			quantile += "," + labels
+		}
+
+		writeSample(buf, sampleName(name, quantile), t.number(t.Quantile(p.p)))
+	}
+
+	writeSample(buf, sampleName(name+"_sum", labels), t.number(e.sum))
+	writeSample(buf, sampleName(name+"_count", labels), number{i: e.count})
+}
+
+// openMetricsLabels turns canonical tags into k="v",k2="v2"
+func openMetricsLabels(tags string) string {
+	var b strings.Builder
+
+	for i, t := range splitTags(tags) {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+
+		b.WriteString(openMetricsName(t.Key))
+		b.WriteString(`="`)
+		b.WriteString(labelEscaper.Replace(t.Value))
+		b.WriteByte('"')
+	}
+
+	return b.String()
+}
+
+// labelEscaper escapes label values. Newlines never get this far, as
+// canonicalTags replaces them.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// sampleName adds labels, if there are any, to a sample name
+func sampleName(name, labels string) string {
+	if labels == "" {
+		return name
+	}
+
+	return name + "{" + labels + "}"
+}
+
+// copy returns a Value that doesn't share anything with v
+func (v Value) copy() Value {
+	var out Value
+
+	if v.Avg != nil {
+		avg := *v.Avg
+		out.Avg = &avg
+	}
+
+	if v.Sum != nil {
+		sum := *v.Sum
+		out.Sum = &sum
+	}
+
+	if v.Last != nil {
+		last := *v.Last
+		out.Last = &last
+	}
+
+	if v.Ratio != nil {
+		ratio := *v.Ratio
+		out.Ratio = &ratio
+	}
+
+	if v.Set != nil {
+		set := Set{Members: make(map[string]struct{}, len(v.Set.Members))}
+		for member := range v.Set.Members {
+			set.Members[member] = struct{}{}
+		}
+
+		out.Set = &set
+	}
+
+	if v.Hist != nil {
+		hist := *v.Hist
+		hist.Samples = append([]float64(nil), v.Hist.Samples...)
+		out.Hist = &hist
+	}
+
+	if v.Digest != nil {
+		digest := *v.Digest
+		digest.centroids = append([]centroid(nil), v.Digest.centroids...)
+		digest.unmerged = append([]centroid(nil), v.Digest.unmerged...)
+		out.Digest = &digest
+	}
+
+	return out
+}
+
+func writeFamily(buf *bytes.Buffer, name, typ string) {
+	buf.WriteString("# TYPE ")
+	buf.WriteString(name)
+	buf.WriteByte(' ')
+	buf.WriteString(typ)
+	buf.WriteByte('\n')
+}
+
+func writeSample(buf *bytes.Buffer, name string, value number) {
+	var scratch [32]byte
+
+	buf.WriteString(name)
+	buf.WriteByte(' ')
+	buf.Write(value.append(scratch[:0]))
+	buf.WriteByte('\n')
+}
+
+// openMetricsName replaces anything outside [a-zA-Z0-9_:] with an
+// underscore, and prefixes names that start with a digit
+func openMetricsName(name string) string {
+	out := []byte(name)
+
+	for i, b := range out {
+		switch {
+		case b >= 'a' && b <= 'z', b >= 'A' && b <= 'Z', b == '_', b == ':':
+		case b >= '0' && b <= '9':
+			if i == 0 {
+				return openMetricsName("_" + name)
+			}
+		default:
+			out[i] = '_'
+		}
+	}
+
+	return string(out)
+}
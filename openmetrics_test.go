@@ -0,0 +1,91 @@
+package buckyclient
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenMetrics_Client_WriteOpenMetrics(t *testing.T) {
+	c := &Client{metrics: make(map[Metric]Value)}
+
+	for _, m := range []MetricWithAmount{
+		{Metric{name: "app.requests", unit: UnitCount}, Amount{Value: 3}, ActionSum},
+		{Metric{name: "app.requests", unit: UnitCount}, Amount{Value: 2}, ActionSum},
+		{Metric{name: "app.latency", unit: UnitMillisecond}, Amount{Value: 10}, ActionAvg},
+		{Metric{name: "app.latency", unit: UnitMillisecond}, Amount{Value: 30}, ActionAvg},
+		{Metric{name: "app.queue-depth", unit: UnitGauge}, Amount{Value: 7}, ActionLast},
+		{Metric{name: "5xx", unit: UnitGauge}, Amount{Value: 1, Denominator: 4}, ActionRatio},
+		{Metric{name: "app.in_flight", unit: UnitGauge}, Amount{Value: 2, Delta: true}, ActionLast},
+	} {
+		assert.NoError(t, c.aggregate(m))
+	}
+
+	buf := &bytes.Buffer{}
+	assert.NoError(t, c.WriteOpenMetrics(buf))
+
+	assert.Equal(t, `# TYPE _5xx gauge
+_5xx 25
+# TYPE app_latency summary
+app_latency_sum 40
+app_latency_count 2
+# TYPE app_queue_depth gauge
+app_queue_depth 7
+# TYPE app_requests counter
+app_requests_total 5
+# EOF
+`, buf.String())
+
+	// nothing is reset, including the adjusted gauge that was left out
+	assert.Equal(t, 5, c.PendingLines())
+}
+
+func TestOpenMetrics_Client_WriteOpenMetrics_Conflicts(t *testing.T) {
+	var errs []error
+	c := &Client{metrics: make(map[Metric]Value), errorHandler: func(err error) { errs = append(errs, err) }}
+
+	eu := canonicalTags([]Tag{{"region", "eu"}})
+	us := canonicalTags([]Tag{{"region", "us"}})
+
+	for _, m := range []MetricWithAmount{
+		{Metric{name: "db.query", unit: UnitCount}, Amount{Value: 3}, ActionSum},
+		{Metric{name: "db.query", unit: UnitMillisecond}, Amount{Value: 10}, ActionAvg},
+		{Metric{name: "jobs", unit: UnitCount, tags: eu}, Amount{Value: 2}, ActionSum},
+		{Metric{name: "jobs", unit: UnitCount, tags: us}, Amount{Value: 4}, ActionAvg},
+		{Metric{name: "balance", unit: UnitCount, tags: eu}, Amount{Value: 5}, ActionSum},
+		{Metric{name: "balance", unit: UnitCount, tags: us}, Amount{Value: -2}, ActionSum},
+	} {
+		assert.NoError(t, c.aggregate(m))
+	}
+
+	buf := &bytes.Buffer{}
+	assert.NoError(t, c.WriteOpenMetrics(buf))
+
+	// Each unit is its own family, a counter that went negative is a gauge
+	// and a conflict within one unit only keeps the first type
+	assert.Equal(t, `# TYPE balance gauge
+balance{region="eu"} 5
+balance{region="us"} -2
+# TYPE db_query_c counter
+db_query_c_total 3
+# TYPE db_query_ms summary
+db_query_ms_sum 10
+db_query_ms_count 1
+# TYPE jobs counter
+jobs_total{region="eu"} 2
+# EOF
+`, buf.String())
+
+	if assert.Len(t, errs, 1) {
+		assert.ErrorIs(t, errs[0], ErrActionConflict)
+	}
+}
+
+func TestOpenMetrics_Client_WriteOpenMetrics_Empty(t *testing.T) {
+	c := &Client{metrics: make(map[Metric]Value)}
+
+	buf := &bytes.Buffer{}
+	assert.NoError(t, c.WriteOpenMetrics(buf))
+	assert.Equal(t, "# EOF\n", buf.String())
+}
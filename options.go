@@ -0,0 +1,151 @@
+package buckyclient
+
+import (
+	"errors"
+	"time"
+)
+
+// Option configures a client when it is created with NewClient
+type Option func(*Client) error
+
+// EmptyFlushPolicy controls what the client does when an interval
+// ends without any metrics having been recorded
+type EmptyFlushPolicy int
+
+const (
+	// EmptyFlushSkip silently skips the flush. This is the default.
+	EmptyFlushSkip EmptyFlushPolicy = iota
+
+	// EmptyFlushError reports ErrNoMetrics to the error handler
+	EmptyFlushError
+
+	// EmptyFlushHeartbeat sends a single heartbeat counter instead
+	EmptyFlushHeartbeat
+
+	// EmptyFlushKeepAlive sends a POST with an empty body
+	EmptyFlushKeepAlive
+)
+
+// DefaultHeartbeatName is the metric sent by EmptyFlushHeartbeat when
+// no heartbeat has been configured with WithHeartbeat
+const DefaultHeartbeatName = "buckyclient.heartbeat"
+
+var (
+	// ErrInvalidOption is matched by every error from an option given a
+	// value it can't use
+	ErrInvalidOption = errors.New("Invalid client option")
+)
+
+// OptionError says which option was misconfigured and why
+type OptionError struct {
+	Option string
+	Reason string
+}
+
+func (e *OptionError) Error() string {
+	return e.Option + ": " + e.Reason
+}
+
+// Is lets errors.Is(err, ErrInvalidOption) match any OptionError
+func (e *OptionError) Is(target error) bool {
+	return target == ErrInvalidOption
+}
+
+func invalidOption(option, reason string) error {
+	return &OptionError{Option: option, Reason: reason}
+}
+
+// WithErrorHandler sets a function that is called with every error
+// returned by a flush in the background sender
+func WithErrorHandler(handler func(error)) Option {
+	return func(c *Client) error {
+		c.errorHandler = handler
+		return nil
+	}
+}
+
+// WithEmptyFlushPolicy sets what happens when an interval has no metrics
+func WithEmptyFlushPolicy(policy EmptyFlushPolicy) Option {
+	return func(c *Client) error {
+		switch policy {
+		case EmptyFlushSkip, EmptyFlushError, EmptyFlushHeartbeat, EmptyFlushKeepAlive:
+		default:
+			return invalidOption("WithEmptyFlushPolicy", "unknown policy")
+		}
+
+		c.emptyFlush = policy
+		return nil
+	}
+}
+
+// WithHeartbeat adds a counter with the given name and a value of 1 to
+// every flush, even when nothing else was recorded. This lets alerts tell
+// a dead process apart from an idle one.
+func WithHeartbeat(name string) Option {
+	return func(c *Client) error {
+		if name == "" {
+			return invalidOption("WithHeartbeat", "name must not be empty")
+		}
+
+		c.heartbeat = name
+		return nil
+	}
+}
+
+// WithRetryQueue keeps payloads that fail to send and retries them, oldest
+// first, on the next flush. Payloads older than maxAge are dropped, and once
+// more than maxBytes are queued the oldest are dropped until it fits. Dropped
+// volume is reported with the SpoolEvictedPayloadsMetric and
+// SpoolEvictedBytesMetric counters.
+func WithRetryQueue(maxAge time.Duration, maxBytes int) Option {
+	return func(c *Client) error {
+		if maxAge <= 0 || maxBytes <= 0 {
+			return invalidOption("WithRetryQueue", "maxAge and maxBytes must be positive")
+		}
+
+		c.spool = newSpool(maxAge, maxBytes)
+		return nil
+	}
+}
+
+// WithCallerDebug logs the file, line and function of the first call to
+// record each metric name from each call site. It is meant for tracking down
+// where unexpected metric names come from and adds a cost to every call.
+func WithCallerDebug() Option {
+	return func(c *Client) error {
+		c.callers = &callerLog{seen: make(map[string]bool)}
+		return nil
+	}
+}
+
+// WithInterval sets how often metrics are sent, replacing the interval
+// given to NewClient. Any positive duration is allowed, so tests and local
+// development can flush every few milliseconds.
+func WithInterval(interval time.Duration) Option {
+	return func(c *Client) error {
+		if interval <= 0 {
+			return invalidOption("WithInterval", "interval must be positive")
+		}
+
+		c.interval = interval
+		return nil
+	}
+}
+
+// WithUnitInterval flushes metrics with the given unit on their own interval
+// instead of the client's, e.g. timers every 10 seconds while counters stay
+// on the default. Units given the same interval are sent together.
+func WithUnitInterval(unit Unit, interval time.Duration) Option {
+	return func(c *Client) error {
+		if !unit.Valid() || interval <= 0 {
+			return invalidOption("WithUnitInterval", "unit must be valid and interval positive")
+		}
+
+		if c.unitIntervals == nil {
+			c.unitIntervals = make(map[Unit]time.Duration)
+		}
+
+		c.unitIntervals[unit] = interval
+		return nil
+	}
+}
@@ -0,0 +1,136 @@
+package buckyclient
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// captureBuckyServer records every request body it receives
+func captureBuckyServer(bodies chan<- string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		bodies <- string(body)
+	}))
+}
+
+func TestOptions_NewClient_OptionError(t *testing.T) {
+	cl, err := NewClient("", 60, WithEmptyFlushPolicy(EmptyFlushPolicy(42)))
+
+	assert.True(t, errors.Is(err, ErrInvalidOption))
+	assert.Nil(t, cl)
+}
+
+func TestOptions_WithErrorHandler(t *testing.T) {
+	cl := &Client{}
+
+	var got error
+	err := WithErrorHandler(func(err error) { got = err })(cl)
+	assert.NoError(t, err)
+
+	flushErr := errors.New("flush failed")
+	cl.handleError(flushErr)
+
+	assert.Equal(t, flushErr, got)
+}
+
+func TestOptions_WithEmptyFlushPolicy_Skip(t *testing.T) {
+	called := false
+
+	cl := &Client{
+		metrics:      make(map[Metric]Value),
+		errorHandler: func(error) { called = true },
+	}
+
+	cl.handleError(cl.flush())
+
+	assert.False(t, called)
+}
+
+func TestOptions_WithEmptyFlushPolicy_Error(t *testing.T) {
+	var got error
+
+	cl := &Client{
+		metrics:      make(map[Metric]Value),
+		errorHandler: func(err error) { got = err },
+	}
+	assert.NoError(t, WithEmptyFlushPolicy(EmptyFlushError)(cl))
+
+	cl.handleError(cl.flush())
+
+	assert.True(t, errors.Is(got, ErrNoMetrics))
+}
+
+func TestOptions_WithEmptyFlushPolicy_Heartbeat(t *testing.T) {
+	bodies := make(chan string, 1)
+	mockBucky := captureBuckyServer(bodies)
+	defer mockBucky.Close()
+
+	cl := &Client{
+		hostURL:    mockBucky.URL,
+		http:       &http.Client{},
+		logger:     log.New(ioutil.Discard, "", 0),
+		metrics:    make(map[Metric]Value),
+		bufferPool: newBufferPool(),
+		emptyFlush: EmptyFlushHeartbeat,
+	}
+
+	assert.NoError(t, cl.flush())
+	assert.Equal(t, DefaultHeartbeatName+":1|c\n", <-bodies)
+}
+
+func TestOptions_WithEmptyFlushPolicy_KeepAlive(t *testing.T) {
+	bodies := make(chan string, 1)
+	mockBucky := captureBuckyServer(bodies)
+	defer mockBucky.Close()
+
+	cl := &Client{
+		hostURL:    mockBucky.URL,
+		http:       &http.Client{},
+		logger:     log.New(&bytes.Buffer{}, "", 0),
+		metrics:    make(map[Metric]Value),
+		bufferPool: newBufferPool(),
+		emptyFlush: EmptyFlushKeepAlive,
+	}
+
+	assert.NoError(t, cl.flush())
+	assert.Equal(t, "", <-bodies)
+}
+
+func TestOptions_WithHeartbeat(t *testing.T) {
+	bodies := make(chan string, 2)
+	mockBucky := captureBuckyServer(bodies)
+	defer mockBucky.Close()
+
+	cl := &Client{
+		hostURL:    mockBucky.URL,
+		http:       &http.Client{},
+		logger:     log.New(ioutil.Discard, "", 0),
+		metrics:    make(map[Metric]Value),
+		bufferPool: newBufferPool(),
+	}
+	assert.NoError(t, WithHeartbeat("myapp.alive")(cl))
+
+	// Nothing recorded, the heartbeat still goes out
+	assert.NoError(t, cl.flush())
+	assert.Equal(t, "myapp.alive:1|c\n", <-bodies)
+
+	// Sent alongside other metrics too
+	cl.handleMetricWithValue(MetricWithAmount{Metric{name: "myapp.facet", unit: UnitCount}, Amount{Value: 3}, ActionSum})
+
+	assert.NoError(t, cl.flush())
+
+	body := <-bodies
+	assert.Contains(t, body, "myapp.alive:1|c\n")
+	assert.Contains(t, body, "myapp.facet:3|c\n")
+}
+
+func TestOptions_WithHeartbeat_Empty(t *testing.T) {
+	assert.True(t, errors.Is(WithHeartbeat("")(&Client{}), ErrInvalidOption))
+}
@@ -0,0 +1,61 @@
+package buckyclient
+
+import (
+	"bytes"
+	"time"
+)
+
+// WithMaxPayloadBytes splits a flush into several posts of at most n bytes
+// each, for proxies and load balancers that reject large requests. A
+// payload is only split between metrics, so the lines of a histogram are
+// always sent together, and a single metric bigger than n is sent on its
+// own. Each post is retried, queued or merged back on its own, and is
+// reported to flush callbacks with the same FlushInfo. If one post fails
+// the rest of the flush isn't posted but kept the same way.
+func WithMaxPayloadBytes(n int) Option {
+	return func(c *Client) error {
+		if n <= 0 {
+			return invalidOption("WithMaxPayloadBytes", "n must be positive")
+		}
+
+		c.maxPayload = n
+		return nil
+	}
+}
+
+// splitPayload groups metrics into payloads of at most maxPayload bytes,
+// as they are written at at
+func (c *Client) splitPayload(metrics map[Metric]Value, at time.Time) []map[Metric]Value {
+	if c.maxPayload == 0 {
+		return []map[Metric]Value{metrics}
+	}
+
+	var (
+		chunks  []map[Metric]Value
+		current map[Metric]Value
+		size    int
+		scratch bytes.Buffer
+	)
+
+	for k, v := range metrics {
+		scratch.Reset()
+		c.writeMetric(&scratch, k, v, at)
+
+		// Too big for any payload, so it goes on its own and the current
+		// one can still be filled
+		if scratch.Len() > c.maxPayload {
+			chunks = append(chunks, map[Metric]Value{k: v})
+			continue
+		}
+
+		if current == nil || size+scratch.Len() > c.maxPayload {
+			current, size = make(map[Metric]Value), 0
+			chunks = append(chunks, current)
+		}
+
+		current[k] = v
+		size += scratch.Len()
+	}
+
+	return chunks
+}
@@ -0,0 +1,79 @@
+package buckyclient
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPayload_Client_splitPayload(t *testing.T) {
+	c := &Client{maxPayload: 20}
+
+	metrics := make(map[Metric]Value)
+	for i := 0; i < 5; i++ {
+		aggregateInto(metrics, MetricWithAmount{Metric{name: fmt.Sprintf("m%d", i), unit: UnitCount}, Amount{Value: 1}, ActionSum})
+	}
+
+	// Each line is 7 bytes, so two fit in a payload
+	chunks := c.splitPayload(metrics, time.Time{})
+	assert.Len(t, chunks, 3)
+
+	total := 0
+	for _, chunk := range chunks {
+		assert.True(t, len(chunk) <= 2)
+		total += len(chunk)
+	}
+	assert.Equal(t, 5, total)
+
+	// A metric bigger than the limit goes on its own
+	c.maxPayload = 1
+	assert.Len(t, c.splitPayload(metrics, time.Time{}), 5)
+
+	c.maxPayload = 0
+	assert.Len(t, c.splitPayload(metrics, time.Time{}), 1)
+}
+
+func TestPayload_WithMaxPayloadBytes(t *testing.T) {
+	rt := &recordingTransport{}
+	c := newRetryClient(rt, WithMaxPayloadBytes(16))
+
+	c.aggregate(MetricWithAmount{Metric{name: "a", unit: UnitCount}, Amount{Value: 1}, ActionSum})
+	c.aggregate(MetricWithAmount{Metric{name: "b", unit: UnitCount}, Amount{Value: 2}, ActionSum})
+	c.aggregate(MetricWithAmount{Metric{name: "latency", unit: UnitMillisecond}, Amount{Value: 5}, ActionHistogram})
+	assert.NoError(t, c.flush())
+
+	var lines []string
+	for _, payload := range rt.payloads {
+		assert.True(t, len(payload) <= 16 || len(splitLines(payload)) == 6, payload)
+		lines = append(lines, splitLines(payload)...)
+	}
+
+	assert.Len(t, rt.payloads, 2)
+	assert.Len(t, lines, 8)
+	assert.Contains(t, lines, "a:1|c")
+	assert.Contains(t, lines, "b:2|c")
+}
+
+func TestPayload_WithMaxPayloadBytes_Failure(t *testing.T) {
+	rt := &recordingTransport{err: errors.New("unreachable")}
+	c := newRetryClient(rt, WithMaxPayloadBytes(8), WithRetryQueue(time.Minute, 1<<20))
+
+	c.aggregate(MetricWithAmount{Metric{name: "a", unit: UnitCount}, Amount{Value: 1}, ActionSum})
+	c.aggregate(MetricWithAmount{Metric{name: "b", unit: UnitCount}, Amount{Value: 2}, ActionSum})
+	assert.ErrorIs(t, c.flush(), rt.err)
+
+	// Only the first post is tried, but both are queued
+	assert.Len(t, rt.payloads, 1)
+
+	// The queue goes out even though nothing new was recorded
+	rt.err = nil
+	assert.ErrorIs(t, c.flush(), ErrNoMetrics)
+	assert.ElementsMatch(t, []string{"a:1|c\n", "b:2|c\n"}, rt.payloads[1:])
+}
+
+func TestPayload_WithMaxPayloadBytes_Invalid(t *testing.T) {
+	assert.ErrorIs(t, WithMaxPayloadBytes(0)(&Client{}), ErrInvalidOption)
+}
@@ -0,0 +1,92 @@
+package buckyclient
+
+import "bytes"
+
+// PendingLines returns how many lines the next flush would send for what
+// has been recorded. Like PendingBytesEstimate it leaves out the client's
+// own metrics and anything else only added when the flush runs, such as
+// rollups, registered gauges and the EWMA, TopK and Distinct aggregates.
+func (c *Client) PendingLines() int {
+	c = c.root()
+
+	c.drainBatches()
+
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	lines, _ := c.pendingSize()
+	return lines
+}
+
+// PendingBytesEstimate returns roughly how many bytes are waiting to be
+// sent, counting both the next flush and any payloads held for retry
+func (c *Client) PendingBytesEstimate() int {
+	c = c.root()
+
+	c.drainBatches()
+
+	c.m.Lock()
+	_, n := c.pendingSize()
+	c.m.Unlock()
+
+	if c.spool != nil {
+		c.spool.m.Lock()
+		n += c.spool.bytes
+		c.spool.m.Unlock()
+	}
+
+	return n
+}
+
+// pendingSize is how many lines and bytes the next flush would write for
+// the metrics recorded so far - c.m must be held
+func (c *Client) pendingSize() (lines, size int) {
+	if c.format == FormatInflux {
+		// Influx lines are only measured by writing them
+		buf, at := &bytes.Buffer{}, c.now()
+		for k, v := range c.metrics {
+			buf.Reset()
+			c.writeMetric(buf, k, v, at)
+			lines += bytes.Count(buf.Bytes(), []byte{'\n'})
+			size += buf.Len()
+		}
+
+		return lines, size
+	}
+
+	for k, v := range c.metrics {
+		unit, extra := k.unit, len(c.prefix)+tagsLength(k.tags, c.tagFormat)
+		v.eachLine(k.name, func(name string, value number) {
+			lines++
+			size += lineLength(name, value, unit) + extra
+		})
+
+		if c.digestSketches && v.Digest != nil && v.Digest.count > 0 {
+			lines++
+			size += lineLength(k.name, number{raw: v.Digest.sketch()}, UnitDigest) + extra
+		}
+	}
+
+	return lines, size
+}
+
+// lineLength is the size of a line written by writeLine
+func lineLength(name string, value number, unit Unit) int {
+	digits := 1
+
+	if value.raw != "" || value.isFloat {
+		var scratch [32]byte
+		digits = len(value.append(scratch[:0]))
+	} else {
+		if value.i < 0 {
+			digits++ // for the sign
+		}
+
+		for v := value.i / 10; v != 0; v /= 10 {
+			digits++
+		}
+	}
+
+	// name:value|unit\n
+	return len(name) + 1 + digits + 1 + len(unit) + 1
+}
@@ -0,0 +1,97 @@
+package buckyclient
+
+import (
+	"bytes"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPending_Client_PendingLines(t *testing.T) {
+	cl := &Client{metrics: make(map[Metric]Value)}
+
+	assert.Equal(t, 0, cl.PendingLines())
+
+	cl.handleMetricWithValue(MetricWithAmount{Metric{name: "a", unit: UnitCount}, Amount{Value: 1}, ActionSum})
+	cl.handleMetricWithValue(MetricWithAmount{Metric{name: "a", unit: UnitCount}, Amount{Value: 1}, ActionSum})
+	cl.handleMetricWithValue(MetricWithAmount{Metric{name: "b", unit: UnitMillisecond}, Amount{Value: 1}, ActionAvg})
+
+	assert.Equal(t, 2, cl.PendingLines())
+}
+
+func TestPending_Client_PendingLines_Written(t *testing.T) {
+	for _, opts := range [][]Option{nil, {WithFormat(FormatInflux)}} {
+		cl, errs := newClient("", DefaultInterval, opts)
+		assert.Empty(t, errs)
+
+		for _, metric := range []MetricWithAmount{
+			{Metric{name: "hit_rate", unit: UnitGauge}, Amount{}, ActionRatio},
+			{Metric{name: "temperature", unit: UnitGauge}, Amount{Value: -4}, ActionLast},
+			{Metric{name: "latency", unit: UnitMillisecond}, Amount{Value: 12}, ActionHistogram},
+			{Metric{name: "requests", unit: UnitCount}, Amount{Value: 1}, ActionSum},
+		} {
+			cl.handleMetricWithValue(metric)
+		}
+
+		buf := &bytes.Buffer{}
+		cl.formatMetricsForFlush(buf)
+
+		assert.Equal(t, bytes.Count(buf.Bytes(), []byte{'\n'}), cl.PendingLines())
+	}
+}
+
+func TestPending_Client_PendingBytesEstimate(t *testing.T) {
+	cl := &Client{
+		metrics:    make(map[Metric]Value),
+		bufferPool: newBufferPool(),
+	}
+
+	cl.metrics[Metric{name: "myapp.count", unit: UnitCount}] = Value{Sum: &Sum{Value: -1234}}
+	cl.metrics[Metric{name: "myapp.timer", unit: UnitMillisecond}] = Value{Avg: &Average{Count: 1, Total: 7, Avg: 7}}
+
+	buf := &bytes.Buffer{}
+	cl.formatMetricsForFlush(buf)
+
+	assert.Equal(t, buf.Len(), cl.PendingBytesEstimate())
+
+	cl.spool = newSpool(time.Minute, 100)
+	cl.spool.push([]byte("queued:1|c\n"), Window{}, time.Now())
+
+	assert.Equal(t, buf.Len()+11, cl.PendingBytesEstimate())
+}
+
+func TestPending_Client_PendingBytesEstimate_Formats(t *testing.T) {
+	for _, opts := range [][]Option{
+		{WithPrefix("myapp.")},
+		{WithPrefix("myapp."), WithFormat(FormatInflux)},
+	} {
+		cl, errs := newClient("", DefaultInterval, opts)
+		assert.Empty(t, errs)
+
+		cl.metrics[Metric{name: "count", unit: UnitCount, tags: canonicalTags([]Tag{{"region", "eu"}})}] = Value{Sum: &Sum{Value: 3}}
+		cl.metrics[Metric{name: "timer", unit: UnitMillisecond}] = Value{Avg: &Average{Count: 1, Total: 7, Avg: 7}}
+
+		buf := &bytes.Buffer{}
+		cl.formatMetricsForFlush(buf)
+
+		assert.Equal(t, buf.Len(), cl.PendingBytesEstimate())
+	}
+}
+
+func TestPending_lineLength(t *testing.T) {
+	for _, v := range []int64{0, 9, 10, -1, -10, math.MaxInt64, math.MinInt64} {
+		buf := &bytes.Buffer{}
+		writeLine(buf, "a.b", number{i: v}, UnitMillisecond)
+
+		assert.Equal(t, buf.Len(), lineLength("a.b", number{i: v}, UnitMillisecond), v)
+	}
+
+	for _, v := range []float64{0, 0.25, -1.5, 1e21, 1e-9} {
+		buf := &bytes.Buffer{}
+		writeLine(buf, "a.b", number{f: v, isFloat: true}, UnitMillisecond)
+
+		assert.Equal(t, buf.Len(), lineLength("a.b", number{f: v, isFloat: true}, UnitMillisecond), v)
+	}
+}
@@ -0,0 +1,371 @@
+package buckyclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrInvalidPoolSize is returned by NewClientPool for a pool without
+	// any clients
+	ErrInvalidPoolSize = errors.New("Invalid pool size")
+)
+
+// ClientPool spreads recording over several clients, each with its own
+// aggregation, input channel and flushes, for processes that record more
+// than one client can keep up with. Samples are sent to a client by a hash
+// of the metric name, so every sample of a metric is aggregated by the
+// same client and each metric is still sent once per interval.
+type ClientPool struct {
+	clients     []*Client
+	closeShared sync.Once
+}
+
+// PoolClientTag is the key of the tag telling apart the WithTelemetry
+// metrics of the clients in a pool
+const PoolClientTag = "pool_client"
+
+// NewClientPool starts n clients as NewClient would, applying the options
+// to each of them. A Transport given with WithTransport or WithFanOut is
+// shared by all of them, so it must be safe for concurrent use, and is
+// closed once by Close after every client has been. The heartbeat, build
+// info and process metrics are only sent by the first client, and each
+// client's telemetry is tagged with PoolClientTag and its index.
+func NewClientPool(n int, host string, interval int, opts ...Option) (*ClientPool, error) {
+	if n <= 0 {
+		return nil, ErrInvalidPoolSize
+	}
+
+	p := &ClientPool{clients: make([]*Client, 0, n)}
+
+	for i := 0; i < n; i++ {
+		c, err := NewClient(host, interval, append(opts[:len(opts):len(opts)], poolMember(i))...)
+		if err != nil {
+			p.Close()
+			return nil, err
+		}
+
+		p.clients = append(p.clients, c)
+	}
+
+	return p, nil
+}
+
+// poolMember makes a client the i'th of a pool. It comes after the
+// caller's options, so it sees everything they set.
+func poolMember(i int) Option {
+	return func(c *Client) error {
+		c.pooled = true
+		c.telemetryTags = canonicalTags([]Tag{{PoolClientTag, strconv.Itoa(i)}})
+
+		if i > 0 {
+			// Only one client reports on the process
+			c.heartbeat = ""
+			c.buildInfo = nil
+			c.collectors = nil
+		}
+
+		return nil
+	}
+}
+
+// Clients returns the clients of the pool, e.g. to serve their dashboards
+func (p *ClientPool) Clients() []*Client {
+	return append([]*Client(nil), p.clients...)
+}
+
+// client returns the client that records name
+func (p *ClientPool) client(name string) *Client {
+	// FNV-1a, inline so recording doesn't allocate a hasher
+	h := uint32(2166136261)
+	for i := 0; i < len(name); i++ {
+		h ^= uint32(name[i])
+		h *= 16777619
+	}
+
+	return p.clients[h%uint32(len(p.clients))]
+}
+
+// Count is Client.Count on the pool
+func (p *ClientPool) Count(name string, value int, tags ...Tag) {
+	p.client(name).Count(name, value, tags...)
+}
+
+// Incr is Client.Incr on the pool
+func (p *ClientPool) Incr(name string, tags ...Tag) {
+	p.client(name).Incr(name, tags...)
+}
+
+// Decr is Client.Decr on the pool
+func (p *ClientPool) Decr(name string, tags ...Tag) {
+	p.client(name).Decr(name, tags...)
+}
+
+// Timer is Client.Timer on the pool
+func (p *ClientPool) Timer(name string, value int, tags ...Tag) {
+	p.client(name).Timer(name, value, tags...)
+}
+
+// TimerDuration is Client.TimerDuration on the pool
+func (p *ClientPool) TimerDuration(name string, d time.Duration, tags ...Tag) {
+	p.client(name).TimerDuration(name, d, tags...)
+}
+
+// StartTimer is Client.StartTimer on the pool
+func (p *ClientPool) StartTimer(name string, tags ...Tag) *Stopwatch {
+	return p.client(name).StartTimer(name, tags...)
+}
+
+// Time is Client.Time on the pool
+func (p *ClientPool) Time(name string, fn func(), tags ...Tag) time.Duration {
+	return p.client(name).Time(name, fn, tags...)
+}
+
+// Gauge is Client.Gauge on the pool
+func (p *ClientPool) Gauge(name string, value int, tags ...Tag) {
+	p.client(name).Gauge(name, value, tags...)
+}
+
+// GaugeDelta is Client.GaugeDelta on the pool
+func (p *ClientPool) GaugeDelta(name string, delta int, tags ...Tag) {
+	p.client(name).GaugeDelta(name, delta, tags...)
+}
+
+// Ratio is Client.Ratio on the pool
+func (p *ClientPool) Ratio(name string, numerator, denominator int, tags ...Tag) {
+	p.client(name).Ratio(name, numerator, denominator, tags...)
+}
+
+// AverageTimer is Client.AverageTimer on the pool
+func (p *ClientPool) AverageTimer(name string, value int, tags ...Tag) {
+	p.client(name).AverageTimer(name, value, tags...)
+}
+
+// AverageTimerDuration is Client.AverageTimerDuration on the pool
+func (p *ClientPool) AverageTimerDuration(name string, d time.Duration, tags ...Tag) {
+	p.client(name).AverageTimerDuration(name, d, tags...)
+}
+
+// CountF is Client.CountF on the pool
+func (p *ClientPool) CountF(name string, value float64, tags ...Tag) {
+	p.client(name).CountF(name, value, tags...)
+}
+
+// TimerF is Client.TimerF on the pool
+func (p *ClientPool) TimerF(name string, value float64, tags ...Tag) {
+	p.client(name).TimerF(name, value, tags...)
+}
+
+// AverageTimerF is Client.AverageTimerF on the pool
+func (p *ClientPool) AverageTimerF(name string, value float64, tags ...Tag) {
+	p.client(name).AverageTimerF(name, value, tags...)
+}
+
+// GaugeF is Client.GaugeF on the pool
+func (p *ClientPool) GaugeF(name string, value float64, tags ...Tag) {
+	p.client(name).GaugeF(name, value, tags...)
+}
+
+// GaugeDeltaF is Client.GaugeDeltaF on the pool
+func (p *ClientPool) GaugeDeltaF(name string, delta float64, tags ...Tag) {
+	p.client(name).GaugeDeltaF(name, delta, tags...)
+}
+
+// RegisterGauge is Client.RegisterGauge on the pool
+func (p *ClientPool) RegisterGauge(name string, fn func() float64, tags ...Tag) {
+	p.client(name).RegisterGauge(name, fn, tags...)
+}
+
+// UnregisterGauge is Client.UnregisterGauge on the pool
+func (p *ClientPool) UnregisterGauge(name string, tags ...Tag) {
+	p.client(name).UnregisterGauge(name, tags...)
+}
+
+// Histogram is Client.Histogram on the pool
+func (p *ClientPool) Histogram(name string, value int, tags ...Tag) {
+	p.client(name).Histogram(name, value, tags...)
+}
+
+// HistogramDuration is Client.HistogramDuration on the pool
+func (p *ClientPool) HistogramDuration(name string, d time.Duration, tags ...Tag) {
+	p.client(name).HistogramDuration(name, d, tags...)
+}
+
+// Digest is Client.Digest on the pool
+func (p *ClientPool) Digest(name string, value int, tags ...Tag) {
+	p.client(name).Digest(name, value, tags...)
+}
+
+// DigestDuration is Client.DigestDuration on the pool
+func (p *ClientPool) DigestDuration(name string, d time.Duration, tags ...Tag) {
+	p.client(name).DigestDuration(name, d, tags...)
+}
+
+// Unique is Client.Unique on the pool
+func (p *ClientPool) Unique(name string, value string, tags ...Tag) {
+	p.client(name).Unique(name, value, tags...)
+}
+
+// Record is Client.Record on the pool
+func (p *ClientPool) Record(name string, value int, unit Unit, action Action, tags ...Tag) error {
+	return p.client(name).Record(name, value, unit, action, tags...)
+}
+
+// RecordFloat is Client.RecordFloat on the pool
+func (p *ClientPool) RecordFloat(name string, value float64, unit Unit, action Action, tags ...Tag) error {
+	return p.client(name).RecordFloat(name, value, unit, action, tags...)
+}
+
+// LatencyBuckets is Client.LatencyBuckets on the pool
+func (p *ClientPool) LatencyBuckets(name string, d time.Duration, bounds []time.Duration, tags ...Tag) {
+	p.client(name).LatencyBuckets(name, d, bounds, tags...)
+}
+
+// Distinct is Client.Distinct on the pool
+func (p *ClientPool) Distinct(name, value string, tags ...Tag) {
+	p.client(name).Distinct(name, value, tags...)
+}
+
+// TopK is Client.TopK on the pool
+func (p *ClientPool) TopK(name, key string, tags ...Tag) {
+	p.client(name).TopK(name, key, tags...)
+}
+
+// EWMA is Client.EWMA on the pool
+func (p *ClientPool) EWMA(name string, value int, tags ...Tag) {
+	p.client(name).EWMA(name, value, tags...)
+}
+
+// SetLogger sets the logger of every client
+func (p *ClientPool) SetLogger(logger *log.Logger) {
+	for _, c := range p.clients {
+		c.SetLogger(logger)
+	}
+}
+
+// SetEnabled turns every client on or off
+func (p *ClientPool) SetEnabled(enabled bool) {
+	for _, c := range p.clients {
+		c.SetEnabled(enabled)
+	}
+}
+
+// PendingLines returns how many lines the next flushes would send
+func (p *ClientPool) PendingLines() int {
+	n := 0
+	for _, c := range p.clients {
+		n += c.PendingLines()
+	}
+
+	return n
+}
+
+// Stats adds up the Stats of every client. BufferBytes is the total of
+// their last buffers.
+func (p *ClientPool) Stats() Stats {
+	var total Stats
+
+	for _, c := range p.clients {
+		s := c.Stats()
+
+		total.Metrics += s.Metrics
+		total.AggregateBytes += s.AggregateBytes
+		total.SpoolPayloads += s.SpoolPayloads
+		total.SpoolBytes += s.SpoolBytes
+		total.BufferBytes += s.BufferBytes
+	}
+
+	return total
+}
+
+// Stop stops every client, flushing what they hold
+func (p *ClientPool) Stop() {
+	for _, c := range p.clients {
+		c.Stop()
+	}
+}
+
+// StopWithResult stops every client like Stop, returning the errors of
+// all whose final flush failed
+func (p *ClientPool) StopWithResult() error {
+	var errs []error
+
+	for _, c := range p.clients {
+		if err := c.StopWithResult(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// StopContext stops every client like StopWithResult, waiting for all of
+// them until ctx is done
+func (p *ClientPool) StopContext(ctx context.Context) error {
+	var errs []error
+
+	for _, c := range p.clients {
+		if err := c.StopContext(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Flush is FlushContext without a deadline
+func (p *ClientPool) Flush() error {
+	return p.FlushContext(context.Background())
+}
+
+// FlushContext flushes every client, returning the errors of all that
+// failed. Clients with nothing to send aren't counted as failing.
+func (p *ClientPool) FlushContext(ctx context.Context) error {
+	var errs []error
+
+	for _, c := range p.clients {
+		if err := c.FlushContext(ctx); err != nil && !errors.Is(err, ErrNoMetrics) {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Close closes every client, then the transports they share, returning
+// the errors of all that failed
+func (p *ClientPool) Close() error {
+	var errs []error
+
+	for _, c := range p.clients {
+		if err := c.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	p.closeShared.Do(func() {
+		if len(p.clients) == 0 {
+			return
+		}
+
+		// Every client was given the same transports
+		c := p.clients[0]
+
+		if closer, ok := c.transport.(io.Closer); ok && !c.ownTransport {
+			if err := closer.Close(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+
+		if err := c.closeSinks(); err != nil {
+			errs = append(errs, err)
+		}
+	})
+
+	return errors.Join(errs...)
+}
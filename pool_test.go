@@ -0,0 +1,125 @@
+package buckyclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// lockedTransport is a recordingTransport several clients can share. Like
+// a socket it can only be closed once.
+type lockedTransport struct {
+	m sync.Mutex
+	recordingTransport
+	closes int
+}
+
+func (l *lockedTransport) Close() error {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	l.closes++
+	if l.closed {
+		return errors.New("use of closed network connection")
+	}
+
+	return l.recordingTransport.Close()
+}
+
+func (l *lockedTransport) Send(ctx context.Context, payload []byte) error {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	return l.recordingTransport.Send(ctx, payload)
+}
+
+func TestPool_ClientPool_client(t *testing.T) {
+	p := &ClientPool{clients: []*Client{{}, {}, {}, {}}}
+
+	used := make(map[*Client]bool)
+	for i := 0; i < 100; i++ {
+		name := fmt.Sprintf("metric.%d", i)
+
+		assert.Same(t, p.client(name), p.client(name))
+		used[p.client(name)] = true
+	}
+
+	assert.Len(t, used, 4)
+}
+
+func TestPool_NewClientPool(t *testing.T) {
+	tr := &lockedTransport{}
+
+	p, err := NewClientPool(3, "", 0, WithTransport(tr))
+	assert.NoError(t, err)
+	p.SetLogger(log.New(ioutil.Discard, "", 0))
+	assert.Len(t, p.Clients(), 3)
+
+	for i := 0; i < 10; i++ {
+		p.Count(fmt.Sprintf("hits.%d", i), 1)
+		p.Count(fmt.Sprintf("hits.%d", i), 2)
+	}
+
+	time.Sleep(20 * time.Millisecond) // Give the recording goroutines a chance to run
+	assert.NoError(t, p.Close())
+
+	var lines []string
+	for _, payload := range tr.payloads {
+		lines = append(lines, splitLines(payload)...)
+	}
+	sort.Strings(lines)
+
+	assert.Equal(t, "hits.0:3|c hits.1:3|c hits.2:3|c hits.3:3|c hits.4:3|c hits.5:3|c hits.6:3|c hits.7:3|c hits.8:3|c hits.9:3|c", strings.Join(lines, " "))
+	assert.True(t, tr.closed)
+}
+
+func TestPool_NewClientPool_Shared(t *testing.T) {
+	tr, sink := &lockedTransport{}, &lockedTransport{}
+
+	p, err := NewClientPool(3, "", 0, WithTransport(tr), WithFanOut(sink), WithHeartbeat("alive"), WithTelemetry("bucky"))
+	assert.NoError(t, err)
+	p.SetLogger(log.New(ioutil.Discard, "", 0))
+
+	assert.NoError(t, p.Close())
+	assert.NoError(t, p.Close())
+
+	// The shared transports are closed once, after every client
+	assert.Equal(t, 1, tr.closes)
+	assert.Equal(t, 1, sink.closes)
+
+	var heartbeats, flushes []string
+	for _, payload := range tr.payloads {
+		for _, line := range splitLines(payload) {
+			switch {
+			case strings.HasPrefix(line, "alive:"):
+				heartbeats = append(heartbeats, line)
+			case strings.HasPrefix(line, "bucky.flushes:"):
+				flushes = append(flushes, line)
+			}
+		}
+	}
+
+	assert.Equal(t, []string{"alive:1|c"}, heartbeats)
+	assert.Len(t, flushes, 3)
+	for i := range flushes {
+		assert.Contains(t, flushes, "bucky.flushes:0|c|#pool_client:"+strconv.Itoa(i))
+	}
+}
+
+func TestPool_NewClientPool_Invalid(t *testing.T) {
+	_, err := NewClientPool(0, "", 0)
+	assert.ErrorIs(t, err, ErrInvalidPoolSize)
+
+	_, err = NewClientPool(2, "", 0, WithInterval(-time.Second))
+	assert.ErrorIs(t, err, ErrInvalidOption)
+}
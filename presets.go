@@ -0,0 +1,60 @@
+package buckyclient
+
+import "time"
+
+// The presets bundle options for common deployment shapes. They are plain
+// options, so anything given after a preset overrides it:
+//
+//	cl, err := buckyclient.NewClient(host, 60,
+//		buckyclient.PresetHighThroughput(),
+//		buckyclient.WithTopK(100),
+//	)
+
+// PresetHighThroughput suits busy long-running services. Failed payloads
+// are kept for up to ten minutes in an 8MB retry queue, TopK tracks more
+// keys, and ad-hoc flushes that overlap a running one join it instead of
+// queueing up behind it.
+func PresetHighThroughput() Option {
+	return withOptions(
+		WithRetryQueue(10*time.Minute, 8<<20),
+		WithFlushConcurrency(FlushCoalesce),
+		WithTopK(50),
+	)
+}
+
+// PresetLowMemory suits small containers and sidecars. The retry queue is
+// capped at 256KB and two minutes, TopK tracks few keys, and overlapping
+// ad-hoc flushes are rejected with ErrBusy rather than held.
+func PresetLowMemory() Option {
+	return withOptions(
+		WithRetryQueue(2*time.Minute, 256<<10),
+		WithFlushConcurrency(FlushReject),
+		WithTopK(3),
+	)
+}
+
+// PresetLambda suits short-lived functions that may be frozen between
+// invocations. Everything is sent every ten seconds, replacing the
+// interval given to NewClient, so little is lost when the process goes
+// away, there is no retry queue to outlive it, and the connection is
+// warmed up when the client is created, during cold start.
+func PresetLambda() Option {
+	return withOptions(
+		WithInterval(10*time.Second),
+		WithFlushConcurrency(FlushSerialize),
+		WithWarmup(),
+	)
+}
+
+// withOptions applies several options in order as one
+func withOptions(opts ...Option) Option {
+	return func(c *Client) error {
+		for _, opt := range opts {
+			if err := opt(c); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
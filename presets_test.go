@@ -0,0 +1,63 @@
+package buckyclient
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPresets_PresetHighThroughput(t *testing.T) {
+	c := &Client{}
+
+	assert.NoError(t, PresetHighThroughput()(c))
+	assert.Equal(t, 8<<20, c.spool.maxBytes)
+	assert.Equal(t, FlushCoalesce, c.flushConcurrency)
+	assert.Equal(t, 50, c.topK)
+}
+
+func TestPresets_PresetLowMemory(t *testing.T) {
+	c := &Client{}
+
+	assert.NoError(t, PresetLowMemory()(c))
+	assert.Equal(t, 256<<10, c.spool.maxBytes)
+	assert.Equal(t, FlushReject, c.flushConcurrency)
+	assert.Equal(t, 3, c.topK)
+}
+
+func TestPresets_PresetLambda(t *testing.T) {
+	c := &Client{}
+
+	assert.NoError(t, PresetLambda()(c))
+	assert.Nil(t, c.spool)
+	assert.True(t, c.warmup)
+	assert.Equal(t, 10*time.Second, c.interval)
+	assert.Nil(t, c.unitIntervals)
+}
+
+func TestPresets_PresetLambda_Sets(t *testing.T) {
+	rt := &recordingTransport{}
+	c := newRetryClient(rt, WithInterval(time.Minute), PresetLambda())
+
+	c.handleMetricWithValue(MetricWithAmount{Metric{name: "myapp.users", unit: UnitSet}, Amount{Member: "alice"}, ActionUnique})
+
+	// Sets are on the ten second tick along with everything else
+	now := time.Now()
+	windows := c.windows(now)
+	assert.Len(t, windows, 1)
+	assert.Equal(t, now.Add(10*time.Second), nextDue(windows))
+	assert.True(t, windows[0].owns(c, UnitSet))
+
+	assert.NoError(t, c.flushWindow(windows[0]))
+	assert.Equal(t, []string{"myapp.users:1|s"}, splitLines(string(rt.payloads[0])))
+}
+
+func TestPresets_Override(t *testing.T) {
+	c := &Client{}
+
+	for _, opt := range []Option{PresetHighThroughput(), WithTopK(7)} {
+		assert.NoError(t, opt(c))
+	}
+
+	assert.Equal(t, 7, c.topK)
+}
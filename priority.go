@@ -0,0 +1,158 @@
+package buckyclient
+
+import (
+	"strings"
+	"sync/atomic"
+)
+
+// CardinalityDroppedMetric counts samples and aggregates dropped by
+// WithMaxMetrics
+const CardinalityDroppedMetric = "buckyclient.cardinality_dropped"
+
+// Priority decides which metrics are dropped first when the client has to
+// shed load. Metrics are PriorityNormal unless WithPriority says otherwise.
+type Priority int
+
+const (
+	// PriorityLow metrics are the first to go, e.g. debugging detail
+	PriorityLow Priority = iota - 1
+	// PriorityNormal is every metric without a priority
+	PriorityNormal
+	// PriorityHigh metrics are kept for as long as possible, e.g. error
+	// counters
+	PriorityHigh
+)
+
+// priorities maps metric names to their priority
+type priorities struct {
+	exact    map[string]Priority
+	prefixes []prefixPriority
+}
+
+type prefixPriority struct {
+	prefix   string
+	priority Priority
+}
+
+// WithPriority gives metrics a priority. A name ending in * matches every
+// metric starting with the rest of it, e.g. "errors.*"; any other name
+// has to match exactly, whatever the metric's tags. When several match,
+// an exact name wins over a prefix and a longer prefix over a shorter
+// one.
+//
+// High priority samples ignore WithRecordBudget and wait to be handed over
+// as they would without one, while low priority samples are dropped
+// straight away rather than waiting for the budget. WithMaxMetrics makes
+// room for a new metric by dropping a lower priority one.
+func WithPriority(p Priority, names ...string) Option {
+	return func(c *Client) error {
+		if p < PriorityLow || p > PriorityHigh {
+			return invalidOption("WithPriority", "unknown priority")
+		}
+
+		if len(names) == 0 {
+			return invalidOption("WithPriority", "no metric names given")
+		}
+
+		for _, name := range names {
+			if name == "" || name == "*" {
+				return invalidOption("WithPriority", "name must not be empty")
+			}
+
+			if strings.HasSuffix(name, "*") {
+				c.priorities.prefixes = append(c.priorities.prefixes, prefixPriority{strings.TrimSuffix(name, "*"), p})
+				continue
+			}
+
+			if c.priorities.exact == nil {
+				c.priorities.exact = make(map[string]Priority)
+			}
+
+			c.priorities.exact[name] = p
+		}
+
+		return nil
+	}
+}
+
+// priority returns the priority of a metric name
+func (c *Client) priority(name string) Priority {
+	if p, ok := c.priorities.exact[name]; ok {
+		return p
+	}
+
+	p, longest := PriorityNormal, -1
+	for _, pp := range c.priorities.prefixes {
+		if len(pp.prefix) > longest && strings.HasPrefix(name, pp.prefix) {
+			p, longest = pp.priority, len(pp.prefix)
+		}
+	}
+
+	return p
+}
+
+// WithMaxMetrics limits how many metrics are aggregated between flushes,
+// counting each combination of name, unit and tags once, which bounds the
+// memory and payload size a runaway tag or name can cause. A sample for a
+// new metric once the limit is reached replaces the aggregate of a lower
+// priority metric if there is one, and is dropped otherwise. Both are
+// counted in CardinalityDroppedMetric. The client's own metrics don't
+// count towards the limit.
+func WithMaxMetrics(n int) Option {
+	return func(c *Client) error {
+		if n <= 0 {
+			return invalidOption("WithMaxMetrics", "limit must be positive")
+		}
+
+		c.maxMetrics = n
+		return nil
+	}
+}
+
+// admit reports whether a recorded sample for m may be aggregated, making
+// room for it if that is allowed - c.m must be held
+func (c *Client) admit(m Metric) bool {
+	if c.maxMetrics == 0 || len(c.metrics) < c.maxMetrics {
+		return true
+	}
+
+	if _, ok := c.metrics[m]; ok {
+		return true
+	}
+
+	lowest := c.priority(m.name)
+
+	var victim Metric
+	found := false
+
+	for k := range c.metrics {
+		if p := c.priority(k.name); p < lowest {
+			victim, lowest, found = k, p, true
+
+			if p == PriorityLow {
+				break
+			}
+		}
+	}
+
+	atomic.AddUint64(&c.cardinalityDropped, 1)
+
+	if !found {
+		return false
+	}
+
+	delete(c.metrics, victim)
+
+	return true
+}
+
+// addCardinalityMetrics adds the count of metrics dropped by
+// WithMaxMetrics - c.m must be held
+func (c *Client) addCardinalityMetrics() {
+	dropped := atomic.SwapUint64(&c.cardinalityDropped, 0)
+	if dropped == 0 {
+		return
+	}
+
+	c.aggregate(MetricWithAmount{Metric{name: CardinalityDroppedMetric, unit: UnitCount}, Amount{Value: int(dropped)}, ActionSum})
+}
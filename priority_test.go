@@ -0,0 +1,108 @@
+package buckyclient
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPriority_Client_priority(t *testing.T) {
+	c := &Client{}
+
+	assert.Equal(t, PriorityNormal, c.priority("anything"))
+
+	assert.NoError(t, WithPriority(PriorityHigh, "errors.*", "panics")(c))
+	assert.NoError(t, WithPriority(PriorityLow, "errors.debug.*", "debug.*")(c))
+
+	assert.Equal(t, PriorityHigh, c.priority("errors.http"))
+	assert.Equal(t, PriorityHigh, c.priority("panics"))
+	assert.Equal(t, PriorityNormal, c.priority("panics.total"))
+	assert.Equal(t, PriorityLow, c.priority("errors.debug.retries"), "the longer prefix wins")
+	assert.Equal(t, PriorityLow, c.priority("debug.x"))
+
+	assert.NoError(t, WithPriority(PriorityNormal, "errors.debug.kept")(c))
+	assert.Equal(t, PriorityNormal, c.priority("errors.debug.kept"), "an exact name wins")
+}
+
+func TestPriority_WithPriority_Invalid(t *testing.T) {
+	assert.True(t, errors.Is(WithPriority(Priority(5), "a")(&Client{}), ErrInvalidOption))
+	assert.True(t, errors.Is(WithPriority(PriorityHigh)(&Client{}), ErrInvalidOption))
+	assert.True(t, errors.Is(WithPriority(PriorityHigh, "*")(&Client{}), ErrInvalidOption))
+	assert.True(t, errors.Is(WithMaxMetrics(0)(&Client{}), ErrInvalidOption))
+}
+
+func TestPriority_WithMaxMetrics(t *testing.T) {
+	c := newBatchingClient(1)
+	assert.NoError(t, WithMaxMetrics(2)(c))
+	assert.NoError(t, WithPriority(PriorityHigh, "errors")(c))
+	assert.NoError(t, WithPriority(PriorityLow, "debug")(c))
+
+	c.Count("debug", 1)
+	c.Count("requests", 1)
+
+	// The limit is reached, so a high priority metric replaces the lowest
+	// priority one
+	c.Count("errors", 1)
+
+	// Nothing left is lower than normal, so a new normal metric is
+	// dropped...
+	c.Count("other", 1)
+	// ...but an existing one is still aggregated
+	c.Count("requests", 1)
+
+	// And a low priority metric can't replace anything
+	c.Count("debug", 1)
+
+	buf := &bytes.Buffer{}
+	c.formatMetricsForFlush(buf)
+	assert.ElementsMatch(t, []string{"requests:2|c", "errors:1|c"}, splitLines(buf.String()))
+
+	c.m.Lock()
+	c.addCardinalityMetrics()
+	c.m.Unlock()
+
+	assert.Equal(t, int64(3), c.metrics[Metric{name: CardinalityDroppedMetric, unit: UnitCount}].Sum.Value)
+}
+
+func TestPriority_WithMaxMetrics_Batch(t *testing.T) {
+	c := &Client{metrics: make(map[Metric]Value)}
+	WithMaxMetrics(1)(c)
+
+	b := c.Batch()
+	b.Count("a", 1)
+	b.Count("b", 1)
+	b.Submit()
+
+	assert.Len(t, c.metrics, 1)
+	assert.Equal(t, uint64(1), c.cardinalityDropped)
+}
+
+func TestPriority_recordWithBudget(t *testing.T) {
+	c := &Client{
+		metrics: make(map[Metric]Value),
+		input:   make(chan MetricWithAmount),
+		budget:  time.Millisecond,
+	}
+	WithPriority(PriorityHigh, "errors")(c)
+	WithPriority(PriorityLow, "debug")(c)
+
+	// Nothing reads the input channel, so the others are dropped while
+	// the high priority sample waits to be handed over
+	c.Count("debug", 1)
+	c.Count("normal", 1)
+
+	recorded := make(chan struct{})
+	go func() {
+		c.Count("errors", 1)
+		close(recorded)
+	}()
+
+	metric := <-c.input
+	assert.Equal(t, "errors", metric.name)
+
+	<-recorded
+	assert.Equal(t, uint64(2), c.budgetDropped)
+}
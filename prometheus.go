@@ -0,0 +1,159 @@
+package buckyclient
+
+import (
+	"bytes"
+	"net/http"
+)
+
+// PrometheusContentType is the content type of PrometheusHandler responses
+const PrometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// exposed is a metric as a scraper sees it. Counters, averages and gauges
+// carry on across flushes, as a scraper expects, while histograms and
+// digests keep the samples of the latest interval for their quantiles and
+// the count and sum of every interval.
+type exposed struct {
+	Value
+	count int64
+	sum   float64
+}
+
+// newExposed returns an exposed metric of one interval, sharing nothing
+// with v
+func newExposed(v Value) exposed {
+	e := exposed{Value: v.copy()}
+
+	switch {
+	case v.Hist != nil:
+		e.count, e.sum = v.Hist.Count, v.Hist.Total
+	case v.Digest != nil:
+		e.count, e.sum = v.Digest.Count(), v.Digest.Sum()
+	}
+
+	return e
+}
+
+// PrometheusHandler returns a handler serving the client's metrics in the
+// Prometheus text format, so the counters and timers pushed to bucky can
+// be scraped as well. Unlike WriteOpenMetrics, which only has what is
+// waiting for the next flush, counters and averages keep adding up from
+// when the handler is first asked for, so call it when the client is set
+// up. Summaries have quantiles of the latest interval and the count and
+// sum of every one. Metrics are exposed the same way as WriteOpenMetrics
+// exposes them, and are kept for as long as the client is, whether or not
+// they are recorded again.
+func (c *Client) PrometheusHandler() http.Handler {
+	c = c.root()
+
+	c.m.Lock()
+	if c.exposed == nil {
+		c.exposed = make(map[Metric]exposed)
+	}
+	c.m.Unlock()
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		buf := &bytes.Buffer{}
+		c.writePrometheus(buf)
+
+		w.Header().Set("Content-Type", PrometheusContentType)
+		w.Write(buf.Bytes())
+	})
+}
+
+// writePrometheus writes everything flushed since PrometheusHandler was
+// first called along with what is waiting for the next flush
+func (c *Client) writePrometheus(buf *bytes.Buffer) {
+	c.drainBatches()
+
+	c.m.Lock()
+
+	metrics := make(map[Metric]exposed, len(c.exposed)+len(c.metrics))
+	for k, e := range c.exposed {
+		e.Value = e.Value.copy()
+		metrics[k] = e
+	}
+
+	foldExposed(metrics, c.metrics)
+	families, conflicts := c.families(metrics)
+
+	c.m.Unlock()
+
+	for _, err := range conflicts {
+		c.handleError(err)
+	}
+
+	writeFamilies(buf, families, false)
+}
+
+// exposeFlushed adds the metrics taken for a flush to what PrometheusHandler
+// serves - c.m must be held
+func (c *Client) exposeFlushed(metrics map[Metric]Value) {
+	if c.exposed != nil {
+		foldExposed(c.exposed, metrics)
+	}
+}
+
+// foldExposed adds an interval's metrics to exposed ones. A metric that is
+// recorded with another action than before starts over.
+func foldExposed(into map[Metric]exposed, metrics map[Metric]Value) {
+	for k, v := range metrics {
+		if v.rollup {
+			continue
+		}
+
+		e, ok := into[k]
+		if !ok || e.action() != v.action() {
+			into[k] = newExposed(v)
+			continue
+		}
+
+		switch {
+		case v.Sum != nil:
+			e.Sum.merge(*v.Sum)
+		case v.Avg != nil:
+			e.Avg.merge(*v.Avg)
+		case v.Last != nil:
+			e.Last.apply(*v.Last)
+		default:
+			latest := newExposed(v)
+			latest.count += e.count
+			latest.sum += e.sum
+			into[k] = latest
+		}
+	}
+}
+
+// unexpose takes a metric merged back into the client after a failed flush
+// out of what PrometheusHandler serves, as it will be added again with the
+// next flush - c.m must be held
+func (c *Client) unexpose(k Metric, v Value) {
+	e, ok := c.exposed[k]
+	if !ok || e.action() != v.action() {
+		return
+	}
+
+	switch {
+	case v.Sum != nil:
+		e.Sum.merge(Sum{Value: -v.Sum.Value, Float: -v.Sum.Float, IsFloat: v.Sum.IsFloat})
+	case v.Avg != nil:
+		e.Avg.Count -= v.Avg.Count
+
+		switch {
+		case v.Avg.IsFloat:
+			e.Avg.FloatTotal -= v.Avg.FloatTotal
+		case e.Avg.IsFloat:
+			e.Avg.FloatTotal -= float64(v.Avg.Total)
+		default:
+			e.Avg.Total -= v.Avg.Total
+		}
+	case v.Last != nil && v.Last.Delta:
+		// A gauge that was set is set again, but an adjustment would be
+		// applied twice
+		e.Last.apply(Last{Value: -v.Last.Value, Float: -v.Last.Float, IsFloat: v.Last.IsFloat, Delta: true})
+	case v.Hist != nil, v.Digest != nil:
+		interval := newExposed(v)
+		e.count -= interval.count
+		e.sum -= interval.sum
+		c.exposed[k] = e
+	}
+}
@@ -0,0 +1,144 @@
+package buckyclient
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// scrape returns what the client's Prometheus handler serves
+func scrape(t *testing.T, h http.Handler) string {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	assert.Equal(t, PrometheusContentType, rec.Header().Get("Content-Type"))
+
+	return rec.Body.String()
+}
+
+func TestPrometheus_Client_PrometheusHandler(t *testing.T) {
+	c := newRetryClient(&recordingTransport{})
+	h := c.PrometheusHandler()
+
+	record := func(ms ...MetricWithAmount) {
+		for _, m := range ms {
+			assert.NoError(t, c.aggregate(m))
+		}
+	}
+
+	requests := Metric{name: "app.requests", unit: UnitCount, tags: canonicalTags([]Tag{{"status", "200"}})}
+	latency := Metric{name: "app.latency", unit: UnitMillisecond}
+	queue := Metric{name: "app.queue", unit: UnitGauge}
+
+	record(
+		MetricWithAmount{requests, Amount{Value: 3}, ActionSum},
+		MetricWithAmount{latency, Amount{Value: 10}, ActionHistogram},
+		MetricWithAmount{queue, Amount{Value: 7}, ActionLast},
+	)
+
+	assert.NoError(t, c.flush())
+
+	// Flushed metrics are still served, and keep adding up with what is
+	// waiting for the next flush
+	record(
+		MetricWithAmount{requests, Amount{Value: 2}, ActionSum},
+		MetricWithAmount{latency, Amount{Value: 30}, ActionHistogram},
+		MetricWithAmount{queue, Amount{Value: -2, Delta: true}, ActionLast},
+	)
+
+	assert.Equal(t, `# TYPE app_latency summary
+app_latency{quantile="0.5"} 30
+app_latency{quantile="0.9"} 30
+app_latency{quantile="0.99"} 30
+app_latency_sum 40
+app_latency_count 2
+# TYPE app_queue gauge
+app_queue 5
+# TYPE app_requests_total counter
+app_requests_total{status="200"} 5
+`, scrape(t, h))
+
+	// Nothing is counted twice once the second interval is flushed too
+	assert.NoError(t, c.flush())
+	second := scrape(t, h)
+	assert.Contains(t, second, `app_requests_total{status="200"} 5`)
+	assert.Contains(t, second, "app_latency_count 2")
+	assert.Contains(t, second, "app_queue 5")
+}
+
+func TestPrometheus_Client_PrometheusHandler_MergeOnFailure(t *testing.T) {
+	rt := &recordingTransport{err: errors.New("unreachable")}
+	c := newRetryClient(rt, WithMergeOnFailure(10))
+	h := c.PrometheusHandler()
+
+	requests := Metric{name: "requests", unit: UnitCount}
+	latency := Metric{name: "latency", unit: UnitMillisecond}
+	queue := Metric{name: "queue", unit: UnitGauge}
+	depth := Metric{name: "depth", unit: UnitGauge}
+
+	rt.err = nil
+	c.aggregate(MetricWithAmount{queue, Amount{Value: 7}, ActionLast})
+	c.aggregate(MetricWithAmount{depth, Amount{Value: 7}, ActionLast})
+	assert.NoError(t, c.flush())
+
+	rt.err = errors.New("unreachable")
+	c.aggregate(MetricWithAmount{requests, Amount{Value: 3}, ActionSum})
+	c.aggregate(MetricWithAmount{latency, Amount{Value: 10}, ActionAvg})
+	c.aggregate(MetricWithAmount{queue, Amount{Value: -2, Delta: true}, ActionLast})
+	c.aggregate(MetricWithAmount{depth, Amount{Value: -2, Delta: true}, ActionLast})
+	assert.Error(t, c.flush())
+
+	c.aggregate(MetricWithAmount{requests, Amount{Value: 1}, ActionSum})
+	c.aggregate(MetricWithAmount{depth, Amount{Value: -1, Delta: true}, ActionLast})
+
+	// The failed flush was merged back, so it is only counted once
+	body := scrape(t, h)
+	assert.Contains(t, body, "requests_total 4\n")
+	assert.Contains(t, body, "latency_count 1\n")
+	assert.Contains(t, body, "queue 5\n")
+	assert.Contains(t, body, "depth 4\n")
+
+	rt.err = nil
+	assert.NoError(t, c.flush())
+
+	// The adjustments are only applied once, including one merged into a
+	// later adjustment
+	body = scrape(t, h)
+	assert.Contains(t, body, "requests_total 4\n")
+	assert.Contains(t, body, "latency_count 1\n")
+	assert.Contains(t, body, "queue 5\n")
+	assert.Contains(t, body, "depth 4\n")
+}
+
+func TestPrometheus_Client_PrometheusHandler_Units(t *testing.T) {
+	c := newRetryClient(&recordingTransport{})
+	h := c.PrometheusHandler()
+
+	c.aggregate(MetricWithAmount{Metric{name: "db.query", unit: UnitCount}, Amount{Value: 3}, ActionSum})
+	c.aggregate(MetricWithAmount{Metric{name: "db.query", unit: UnitMillisecond}, Amount{Value: 12}, ActionAvg})
+	assert.NoError(t, c.flush())
+
+	assert.Equal(t, `# TYPE db_query_c_total counter
+db_query_c_total 3
+# TYPE db_query_ms summary
+db_query_ms_sum 12
+db_query_ms_count 1
+`, scrape(t, h))
+}
+
+func TestPrometheus_Client_WriteOpenMetrics_Unchanged(t *testing.T) {
+	c := newRetryClient(&recordingTransport{})
+	c.PrometheusHandler()
+
+	c.aggregate(MetricWithAmount{Metric{name: "requests", unit: UnitCount}, Amount{Value: 3}, ActionSum})
+	assert.NoError(t, c.flush())
+
+	// WriteOpenMetrics still only has what is waiting for the next flush
+	buf := &bytes.Buffer{}
+	assert.NoError(t, c.WriteOpenMetrics(buf))
+	assert.Equal(t, "# EOF\n", buf.String())
+}
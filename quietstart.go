@@ -0,0 +1,33 @@
+package buckyclient
+
+import "time"
+
+// WithQuietStart holds back the client's scheduled flushes for d after it
+// starts, so a service can warm up and a sidecar or the network become
+// ready before the first post. Metrics recorded meanwhile are aggregated
+// as usual and go out with the first flush once d has passed, and the
+// connection of WithWarmup is only opened then. Flush and Stop still send
+// straight away. It can't be combined with WithStartupVerification, which
+// has to reach the server as the client is created.
+func WithQuietStart(d time.Duration) Option {
+	return func(c *Client) error {
+		if d <= 0 {
+			return invalidOption("WithQuietStart", "duration must be positive")
+		}
+
+		c.quietStart = d
+		return nil
+	}
+}
+
+// quiet delays every window that would be due during the quiet start
+// until it ends
+func (c *Client) quiet(windows []*window, now time.Time) {
+	end := now.Add(c.quietStart)
+
+	for _, w := range windows {
+		if w.next.Before(end) {
+			w.next = end
+		}
+	}
+}
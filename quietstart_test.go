@@ -0,0 +1,65 @@
+package buckyclient
+
+import (
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuietStart_WithQuietStart(t *testing.T) {
+	assert.ErrorIs(t, WithQuietStart(0)(&Client{}), ErrInvalidOption)
+
+	_, err := NewClient("http://localhost", 0, WithQuietStart(time.Second), WithStartupVerification())
+	assert.ErrorIs(t, err, ErrInvalidOption)
+}
+
+func TestQuietStart_NewClient(t *testing.T) {
+	type request struct {
+		method string
+		body   string
+		at     time.Time
+	}
+
+	requests := make(chan request, 10)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		requests <- request{r.Method, string(body), time.Now()}
+	}))
+	defer ts.Close()
+
+	started := time.Now()
+	quiet := 200 * time.Millisecond
+
+	c, err := NewClient(ts.URL, 0, WithInterval(10*time.Millisecond), WithQuietStart(quiet), WithWarmup())
+	assert.NoError(t, err)
+	c.SetLogger(log.New(ioutil.Discard, "", 0))
+	defer c.Stop()
+
+	c.Count("hits", 1)
+	c.Count("hits", 2)
+
+	// Nothing is sent, not even the warm-up, until the quiet start is over
+	warmup := <-requests
+	assert.Equal(t, "HEAD", warmup.method)
+	assert.False(t, warmup.at.Before(started.Add(quiet)))
+
+	first := <-requests
+	assert.Equal(t, "POST", first.method)
+	assert.Equal(t, "hits:3|c\n", first.body)
+}
+
+func TestQuietStart_Client_quiet(t *testing.T) {
+	now := time.Now()
+	c := &Client{interval: time.Second, quietStart: 5 * time.Second, unitIntervals: map[Unit]time.Duration{UnitGauge: 10 * time.Second}}
+
+	windows := c.windows(now)
+	c.quiet(windows, now)
+
+	assert.Equal(t, now.Add(5*time.Second), windows[0].next)
+	assert.Equal(t, now.Add(10*time.Second), windows[1].next, "a window due after the quiet start keeps its time")
+}
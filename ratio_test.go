@@ -0,0 +1,51 @@
+package buckyclient
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRatio_Client_Gauge(t *testing.T) {
+	cl := &Client{
+		metrics: make(map[Metric]Value),
+		input:   make(chan MetricWithAmount, 10),
+	}
+
+	cl.Gauge("myapp.queue", 5)
+	cl.Gauge("myapp.queue", 3)
+
+	cl.flushInputChannel()
+
+	assert.Equal(t, &Last{Value: 3}, cl.metrics[Metric{name: "myapp.queue", unit: UnitGauge}].Last)
+}
+
+func TestRatio_Client_Ratio(t *testing.T) {
+	cl := &Client{
+		metrics: make(map[Metric]Value),
+		input:   make(chan MetricWithAmount, 10),
+	}
+
+	cl.Ratio("myapp.cache.hit_rate", 3, 4)
+	cl.Ratio("myapp.cache.hit_rate", 0, 4)
+
+	cl.flushInputChannel()
+
+	buf := &bytes.Buffer{}
+	cl.formatMetricsForFlush(buf)
+
+	assert.Equal(t, "myapp.cache.hit_rate:37|g\n", buf.String())
+}
+
+func TestRatio_Client_Ratio_ZeroDenominator(t *testing.T) {
+	cl := &Client{metrics: make(map[Metric]Value)}
+
+	cl.handleMetricWithValue(MetricWithAmount{Metric{name: "myapp.rate", unit: UnitGauge}, Amount{Value: 0, Denominator: 0}, ActionRatio})
+
+	buf := &bytes.Buffer{}
+	cl.formatMetricsForFlush(buf)
+
+	assert.Equal(t, "", buf.String())
+	assert.Equal(t, 0, cl.PendingBytesEstimate())
+}
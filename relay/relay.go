@@ -0,0 +1,152 @@
+// Package relay implements a small per-host aggregator for the bucky
+// plaintext protocol. Local clients POST their payloads to the relay, which
+// re-aggregates them and forwards the result upstream on its own interval.
+package relay
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+
+	"github.com/matzhouse/go-bucky-client"
+)
+
+// Handler accepts bucky payloads and records them on a client
+type Handler struct {
+	client *buckyclient.Client
+}
+
+// NewHandler returns a handler that re-aggregates every payload it
+// receives into the given client. Counters are summed, gauges keep the
+// last value and timers are averaged, since a timer line doesn't say how
+// it was aggregated. A gauge value with a sign in front, such as +5 or -3,
+// adjusts the gauge as in statsd.
+func NewHandler(client *buckyclient.Client) *Handler {
+	return &Handler{client: client}
+}
+
+// record keeps whole numbers as integers so they are forwarded as sent
+func record(c *buckyclient.Client, name string, value float64, unit buckyclient.Unit, action buckyclient.Action, tags []buckyclient.Tag) error {
+	if value == math.Trunc(value) && math.Abs(value) <= math.MaxInt32 {
+		return c.Record(name, int(value), unit, action, tags...)
+	}
+
+	return c.RecordFloat(name, value, unit, action, tags...)
+}
+
+// adjusts reports whether a gauge line adjusts the gauge rather than
+// setting it, which is when its value starts with a sign
+func adjusts(line string) bool {
+	value, _, _ := strings.Cut(line, "|")
+	value = value[strings.LastIndexByte(value, ':')+1:]
+
+	return strings.HasPrefix(value, "+") || strings.HasPrefix(value, "-")
+}
+
+// adjust is record for a gauge adjustment
+func adjust(c *buckyclient.Client, name string, value float64, tags []buckyclient.Tag) {
+	if value == math.Trunc(value) && math.Abs(value) <= math.MaxInt32 {
+		c.GaugeDelta(name, int(value), tags...)
+		return
+	}
+
+	c.GaugeDeltaF(name, value, tags...)
+}
+
+// ServeHTTP records every valid line in the request body. If any line is
+// invalid a 400 is returned, but the valid lines are still recorded.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	invalid := 0
+
+	scanner := bufio.NewScanner(r.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+
+		name, value, unit, tags, err := buckyclient.ParseTaggedLine(line)
+		if err != nil {
+			invalid++
+			continue
+		}
+
+		if unit == buckyclient.UnitGauge && adjusts(line) {
+			adjust(h.client, name, value, tags)
+			continue
+		}
+
+		action := buckyclient.ActionSum
+		switch unit {
+		case buckyclient.UnitMillisecond:
+			action = buckyclient.ActionAvg
+		case buckyclient.UnitGauge:
+			action = buckyclient.ActionLast
+		case buckyclient.UnitSet:
+			// The members aren't sent, so sets from different clients can
+			// only be added up, which may count a member more than once
+			action = buckyclient.ActionSum
+		}
+
+		if err := record(h.client, name, value, unit, action, tags); err != nil {
+			invalid++
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if invalid > 0 {
+		http.Error(w, fmt.Sprintf("%d invalid lines", invalid), http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// Server listens for local clients and forwards to an upstream bucky server
+type Server struct {
+	http   *http.Server
+	client *buckyclient.Client
+}
+
+// NewServer returns a relay listening on addr that forwards to the upstream
+// bucky server every interval seconds. The options are passed on to the
+// client used for forwarding.
+func NewServer(addr string, upstream string, interval int, opts ...buckyclient.Option) (*Server, error) {
+	client, err := buckyclient.NewClient(upstream, interval, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Server{
+		http:   &http.Server{Addr: addr, Handler: NewHandler(client)},
+		client: client,
+	}, nil
+}
+
+// ListenAndServe accepts payloads until the server is closed
+func (s *Server) ListenAndServe() error {
+	return s.http.ListenAndServe()
+}
+
+// Close stops accepting payloads, flushes what has been aggregated and
+// closes the forwarding client
+func (s *Server) Close() error {
+	err := s.http.Close()
+
+	if closeErr := s.client.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
@@ -0,0 +1,173 @@
+package relay
+
+import (
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matzhouse/go-bucky-client"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/goleak"
+)
+
+// upstreamServer records every payload forwarded by the relay
+func upstreamServer(bodies chan<- string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		bodies <- string(body)
+	}))
+}
+
+func TestRelay_Handler_ServeHTTP(t *testing.T) {
+	bodies := make(chan string, 1)
+	upstream := upstreamServer(bodies)
+	defer upstream.Close()
+
+	client, err := buckyclient.NewClient(upstream.URL, 60)
+	assert.NoError(t, err)
+	client.SetLogger(log.New(ioutil.Discard, "", 0))
+
+	relay := httptest.NewServer(NewHandler(client))
+	defer relay.Close()
+
+	for _, payload := range []string{"myapp.count:1|c\nmyapp.timer:2|ms\nmyapp.queue:9|g\n", "myapp.count:2|c\nmyapp.timer:6|ms\n"} {
+		resp, err := http.Post(relay.URL, "text/plain", strings.NewReader(payload))
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		resp.Body.Close()
+	}
+
+	time.Sleep(time.Millisecond * 20) // Give the recording goroutines a chance to run
+
+	client.Stop()
+
+	body := <-bodies
+	assert.Contains(t, body, "myapp.count:3|c\n")
+	assert.Contains(t, body, "myapp.timer:4|ms\n")
+	assert.Contains(t, body, "myapp.queue:9|g\n")
+}
+
+func TestRelay_Handler_ServeHTTP_Float(t *testing.T) {
+	bodies := make(chan string, 1)
+	upstream := upstreamServer(bodies)
+	defer upstream.Close()
+
+	client, err := buckyclient.NewClient(upstream.URL, 60)
+	assert.NoError(t, err)
+	client.SetLogger(log.New(ioutil.Discard, "", 0))
+
+	relay := httptest.NewServer(NewHandler(client))
+	defer relay.Close()
+
+	resp, err := http.Post(relay.URL, "text/plain", strings.NewReader("myapp.timer:0.5|ms\nmyapp.timer:0.25|ms\nmyapp.count:2.0|c\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+
+	time.Sleep(time.Millisecond * 20) // Give the recording goroutines a chance to run
+
+	client.Stop()
+
+	body := <-bodies
+	assert.Contains(t, body, "myapp.timer:0.375|ms\n")
+	assert.Contains(t, body, "myapp.count:2|c\n")
+}
+
+func TestRelay_Handler_ServeHTTP_Tags(t *testing.T) {
+	bodies := make(chan string, 1)
+	upstream := upstreamServer(bodies)
+	defer upstream.Close()
+
+	client, err := buckyclient.NewClient(upstream.URL, 60)
+	assert.NoError(t, err)
+	client.SetLogger(log.New(ioutil.Discard, "", 0))
+
+	relay := httptest.NewServer(NewHandler(client))
+	defer relay.Close()
+
+	resp, err := http.Post(relay.URL, "text/plain", strings.NewReader("myapp.hits:1|c|#env:prod\nmyapp.hits:2|c|#env:prod\nmyapp.hits:4|c\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+
+	time.Sleep(time.Millisecond * 20) // Give the recording goroutines a chance to run
+
+	client.Stop()
+
+	body := <-bodies
+	assert.Contains(t, body, "myapp.hits:3|c|#env:prod\n")
+	assert.Contains(t, body, "myapp.hits:4|c\n")
+}
+
+func TestRelay_Handler_ServeHTTP_GaugeDelta(t *testing.T) {
+	bodies := make(chan string, 1)
+	upstream := upstreamServer(bodies)
+	defer upstream.Close()
+
+	client, err := buckyclient.NewClient(upstream.URL, 60)
+	assert.NoError(t, err)
+	client.SetLogger(log.New(ioutil.Discard, "", 0))
+
+	relay := httptest.NewServer(NewHandler(client))
+	defer relay.Close()
+
+	resp, err := http.Post(relay.URL, "text/plain", strings.NewReader("myapp.in_flight:+3|g\nmyapp.in_flight:-1|g|#env:prod\nmyapp.in_flight:-1|g\nmyapp.queue:0|g\nmyapp.queue:-4|g\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+
+	time.Sleep(time.Millisecond * 20) // Give the recording goroutines a chance to run
+
+	client.Stop()
+
+	body := <-bodies
+	assert.Contains(t, body, "myapp.in_flight:+2|g\n")
+	assert.Contains(t, body, "myapp.in_flight:-1|g|#env:prod\n")
+	assert.Contains(t, body, "myapp.queue:0|g\nmyapp.queue:-4|g\n")
+}
+
+func TestRelay_Handler_ServeHTTP_InvalidLines(t *testing.T) {
+	client, err := buckyclient.NewClient("", 60)
+	assert.NoError(t, err)
+	client.SetLogger(log.New(ioutil.Discard, "", 0))
+	defer client.Stop()
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/", strings.NewReader("myapp.count:1|c\nnonsense\nmyapp.x:1|h\n"))
+
+	NewHandler(client).ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "2 invalid lines")
+}
+
+func TestRelay_Handler_ServeHTTP_MethodNotAllowed(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/", nil)
+
+	NewHandler(nil).ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
+
+func TestRelay_Server_Close(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	bodies := make(chan string, 1)
+	upstream := upstreamServer(bodies)
+	defer upstream.Close()
+
+	s, err := NewServer("127.0.0.1:0", upstream.URL, 60)
+	assert.NoError(t, err)
+	s.client.SetLogger(log.New(ioutil.Discard, "", 0))
+
+	s.client.Count("myapp.count", 1)
+	time.Sleep(time.Millisecond * 20) // Give the recording goroutine a chance to run
+
+	assert.NoError(t, s.Close())
+	assert.Equal(t, "myapp.count:1|c\n", <-bodies)
+}
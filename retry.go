@@ -0,0 +1,96 @@
+package buckyclient
+
+import (
+	"bytes"
+	"context"
+	"math/rand/v2"
+	"time"
+)
+
+const (
+	// DefaultMaxRetryBackoff caps the wait between two attempts of WithRetry
+	DefaultMaxRetryBackoff = 30 * time.Second
+
+	// DefaultRetryQueueAge and DefaultRetryQueueBytes size the retry queue
+	// WithRetry sets up when WithRetryQueue isn't used
+	DefaultRetryQueueAge   = 10 * time.Minute
+	DefaultRetryQueueBytes = 1 << 20
+)
+
+// retryPolicy is how often and how patiently a failed post is retried
+type retryPolicy struct {
+	max  int
+	base time.Duration
+}
+
+// WithRetry retries a payload that fails to send up to max more times
+// before the flush gives up on it. The first retry waits around base, and
+// every one after that twice as long as the one before, up to
+// DefaultMaxRetryBackoff, with jitter so clients that failed together
+// don't retry together. Payloads the status policy rejects aren't retried.
+//
+// A payload that still fails goes in the retry queue to be sent with a
+// later flush, or back into the client with WithMergeOnFailure. Without
+// either a queue of DefaultRetryQueueAge and DefaultRetryQueueBytes is
+// used. Retries stop as soon as Stop is called,
+// so shutting down is never held up by a server that is down.
+func WithRetry(max int, base time.Duration) Option {
+	return func(c *Client) error {
+		if max <= 0 || base <= 0 {
+			return invalidOption("WithRetry", "max and base must be positive")
+		}
+
+		c.retry = retryPolicy{max: max, base: base}
+		return nil
+	}
+}
+
+// postWithRetry posts a payload, retrying it as WithRetry allows
+func (c *Client) postWithRetry(ctx context.Context, info FlushInfo, buf *bytes.Buffer) error {
+	err := c.post(ctx, info, buf)
+
+	for attempt := 1; err != nil && attempt <= c.retry.max && !c.rejected(err); attempt++ {
+		delay := backoff(c.retry.base, attempt)
+		c.warnf(info, "retrying in %s, attempt %d of %d - %v", delay, attempt, c.retry.max, err)
+
+		if !c.sleepUnlessStopping(ctx, delay) {
+			break
+		}
+
+		err = c.post(ctx, info, buf)
+	}
+
+	return err
+}
+
+// backoff returns how long to wait before a retry: base doubled for every
+// attempt after the first, capped, then jittered to between half and all
+// of that
+func backoff(base time.Duration, attempt int) time.Duration {
+	d := base
+	for i := 1; i < attempt && d < DefaultMaxRetryBackoff; i++ {
+		d *= 2
+	}
+
+	if d > DefaultMaxRetryBackoff {
+		d = DefaultMaxRetryBackoff
+	}
+
+	return d/2 + rand.N(d/2+1)
+}
+
+// sleepUnlessStopping waits for d, returning false straight away if Stop
+// is called or ctx is done
+func (c *Client) sleepUnlessStopping(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-c.stopping:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
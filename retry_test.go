@@ -0,0 +1,111 @@
+package buckyclient
+
+import (
+	"errors"
+	"io/ioutil"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newRetryClient(tr Transport, opts ...Option) *Client {
+	c := &Client{
+		logger:     log.New(ioutil.Discard, "", 0),
+		metrics:    make(map[Metric]Value),
+		bufferPool: newBufferPool(),
+		stopping:   make(chan struct{}),
+		transport:  tr,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+func TestRetry_Client_postWithRetry(t *testing.T) {
+	rt := &recordingTransport{err: errors.New("unreachable")}
+	c := newRetryClient(rt, WithRetry(2, time.Millisecond), WithRetryQueue(time.Minute, 1<<20))
+
+	c.aggregate(MetricWithAmount{Metric{name: "a", unit: UnitCount}, Amount{Value: 1}, ActionSum})
+	assert.ErrorIs(t, c.flush(), rt.err)
+
+	// The first attempt and both retries, then the payload is queued...
+	assert.Equal(t, []string{"a:1|c\n", "a:1|c\n", "a:1|c\n"}, rt.payloads)
+
+	// ...and sent with the next flush
+	rt.err = nil
+	c.aggregate(MetricWithAmount{Metric{name: "b", unit: UnitCount}, Amount{Value: 2}, ActionSum})
+	assert.NoError(t, c.flush())
+
+	assert.Equal(t, []string{"a:1|c\n", "b:2|c\n"}, rt.payloads[3:])
+}
+
+func TestRetry_Client_postWithRetry_Recovers(t *testing.T) {
+	rt := &recordingTransport{err: errors.New("unreachable")}
+	c := newRetryClient(rt, WithRetry(3, time.Millisecond))
+
+	var results []FlushResult
+	WithFlushCallback(func(r FlushResult) {
+		results = append(results, r)
+
+		// The server comes back after the first retry
+		if len(results) == 2 {
+			rt.err = nil
+		}
+	})(c)
+
+	c.aggregate(MetricWithAmount{Metric{name: "a", unit: UnitCount}, Amount{Value: 1}, ActionSum})
+	assert.NoError(t, c.flush())
+
+	assert.Len(t, rt.payloads, 3)
+	assert.Len(t, results, 3)
+	assert.Equal(t, results[0].Seq, results[2].Seq, "retries report the same flush")
+}
+
+func TestRetry_Client_postWithRetry_Stopping(t *testing.T) {
+	rt := &recordingTransport{err: errors.New("unreachable")}
+	c := newRetryClient(rt, WithRetry(5, time.Hour))
+	close(c.stopping)
+
+	c.aggregate(MetricWithAmount{Metric{name: "a", unit: UnitCount}, Amount{Value: 1}, ActionSum})
+
+	done := make(chan error)
+	go func() { done <- c.flush() }()
+
+	select {
+	case err := <-done:
+		assert.ErrorIs(t, err, rt.err)
+	case <-time.After(time.Second):
+		t.Fatal("flush waited for a retry while stopping")
+	}
+
+	assert.Len(t, rt.payloads, 1)
+}
+
+func TestRetry_NewClient_DefaultQueue(t *testing.T) {
+	c, err := NewClient("", 0, WithRetry(1, time.Millisecond))
+	assert.NoError(t, err)
+	c.SetLogger(log.New(ioutil.Discard, "", 0))
+	defer c.Close()
+
+	assert.NotNil(t, c.spool)
+}
+
+func TestRetry_backoff(t *testing.T) {
+	for attempt, want := range []time.Duration{100, 200, 400, 800} {
+		d := backoff(100*time.Millisecond, attempt+1)
+
+		assert.True(t, d >= want*time.Millisecond/2 && d <= want*time.Millisecond, "attempt %d waited %s", attempt+1, d)
+	}
+
+	assert.True(t, backoff(time.Second, 40) <= DefaultMaxRetryBackoff)
+}
+
+func TestRetry_WithRetry_Invalid(t *testing.T) {
+	assert.ErrorIs(t, WithRetry(0, time.Second)(&Client{}), ErrInvalidOption)
+	assert.ErrorIs(t, WithRetry(1, 0)(&Client{}), ErrInvalidOption)
+}
@@ -0,0 +1,75 @@
+package buckyclient
+
+// WithTagRollups also sends the untagged total of every metric recorded
+// with tags, so a dashboard can show both the series of each tag value and
+// the sum of them without recording everything twice. The total merges the
+// aggregates of every tag combination: counters and timers add up,
+// averages, histograms, digests and sets combine their samples, ratios add
+// their numerators and denominators, and gauges add up their last values.
+//
+// A metric that is also recorded without tags keeps that aggregate and
+// gets no rollup, as is one whose tag combinations were recorded with
+// different actions or where some gauges were set and others only
+// adjusted. Rollups are never merged back by WithMergeOnFailure,
+// since the tagged metrics they come from are.
+func WithTagRollups() Option {
+	return func(c *Client) error {
+		c.tagRollups = true
+		return nil
+	}
+}
+
+// addRollups adds the untagged totals of WithTagRollups to metrics taken
+// for a flush
+func (c *Client) addRollups(metrics map[Metric]Value) {
+	if !c.tagRollups {
+		return
+	}
+
+	rollups := make(map[Metric]Value)
+	conflicts := make(map[Metric]bool)
+
+	for k, v := range metrics {
+		total := Metric{name: k.name, unit: k.unit}
+		if k.tags == "" || conflicts[total] {
+			continue
+		}
+
+		if _, ok := metrics[total]; ok {
+			continue
+		}
+
+		existing, ok := rollups[total]
+
+		switch {
+		case !ok:
+			rollup := v.copy()
+			rollup.rollup = true
+			rollups[total] = rollup
+		case existing.action() != v.action(), existing.Last != nil && existing.Last.Delta != v.Last.Delta:
+			delete(rollups, total)
+			conflicts[total] = true
+		default:
+			existing.rollUp(v)
+		}
+	}
+
+	for k, v := range rollups {
+		metrics[k] = v
+	}
+}
+
+// rollUp adds another tag combination's aggregate to a rollup, which is
+// merge apart from gauges adding up. Totals saturate as they do when
+// recording.
+func (v Value) rollUp(o Value) {
+	if v.Last != nil && o.Last != nil {
+		total := Sum{Value: v.Last.Value, Float: v.Last.Float, IsFloat: v.Last.IsFloat}
+		total.merge(Sum{Value: o.Last.Value, Float: o.Last.Float, IsFloat: o.Last.IsFloat})
+		v.Last.Value, v.Last.Float, v.Last.IsFloat = total.Value, total.Float, total.IsFloat
+
+		return
+	}
+
+	v.merge(o)
+}
@@ -0,0 +1,66 @@
+package buckyclient
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRollup_WithTagRollups(t *testing.T) {
+	rt := &recordingTransport{}
+	c := newRetryClient(rt, WithTagRollups())
+	c.input = make(chan MetricWithAmount, 20)
+
+	record := func(name string, amount Amount, unit Unit, action Action, tags ...Tag) {
+		c.aggregate(MetricWithAmount{Metric{name: name, unit: unit, tags: canonicalTags(tags)}, amount, action})
+	}
+
+	record("hits", Amount{Value: 1}, UnitCount, ActionSum, Tag{"env", "prod"})
+	record("hits", Amount{Value: 2}, UnitCount, ActionSum, Tag{"env", "dev"})
+	record("temp", Amount{Value: 20}, UnitGauge, ActionLast, Tag{"room", "a"})
+	record("temp", Amount{Value: 25}, UnitGauge, ActionLast, Tag{"room", "b"})
+	record("latency", Amount{Value: 10}, UnitMillisecond, ActionAvg, Tag{"route", "a"})
+	record("latency", Amount{Value: 30}, UnitMillisecond, ActionAvg, Tag{"route", "b"})
+	record("latency", Amount{Value: 20}, UnitMillisecond, ActionAvg, Tag{"route", "b"})
+
+	// Recorded untagged as well, so it keeps its own aggregate
+	record("jobs", Amount{Value: 1}, UnitCount, ActionSum, Tag{"queue", "a"})
+	record("jobs", Amount{Value: 5}, UnitCount, ActionSum)
+
+	// Recorded with another action for each tag value
+	record("mixed", Amount{Value: 1}, UnitMillisecond, ActionSum, Tag{"a", "1"})
+	record("mixed", Amount{Value: 1}, UnitMillisecond, ActionAvg, Tag{"a", "2"})
+
+	assert.NoError(t, c.flush())
+
+	lines := splitLines(rt.payloads[0])
+	assert.Contains(t, lines, "hits:1|c|#env:prod")
+	assert.Contains(t, lines, "hits:3|c")
+	assert.Contains(t, lines, "temp:45|g")
+	assert.Contains(t, lines, "latency:20|ms")
+	assert.Contains(t, lines, "jobs:5|c")
+	assert.NotContains(t, lines, "mixed:1|ms")
+	assert.Len(t, lines, 13)
+
+	// Nothing is rolled up without the option
+	c.tagRollups = false
+	record("hits", Amount{Value: 1}, UnitCount, ActionSum, Tag{"env", "prod"})
+	assert.NoError(t, c.flush())
+	assert.Equal(t, []string{"hits:1|c|#env:prod"}, splitLines(rt.payloads[1]))
+}
+
+func TestRollup_WithMergeOnFailure(t *testing.T) {
+	rt := &recordingTransport{err: errors.New("unreachable")}
+	c := newRetryClient(rt, WithTagRollups(), WithMergeOnFailure(10))
+
+	c.aggregate(MetricWithAmount{Metric{name: "hits", unit: UnitCount, tags: canonicalTags([]Tag{{"env", "prod"}})}, Amount{Value: 1}, ActionSum})
+	assert.ErrorIs(t, c.flush(), rt.err)
+
+	// Only the tagged metric came back, so the total isn't counted twice
+	assert.Len(t, c.metrics, 1)
+
+	rt.err = nil
+	assert.NoError(t, c.flush())
+	assert.ElementsMatch(t, []string{"hits:1|c|#env:prod", "hits:1|c"}, splitLines(rt.payloads[1]))
+}
@@ -0,0 +1,146 @@
+package buckyclient
+
+import (
+	"runtime"
+	"time"
+)
+
+const (
+	// RuntimeGoroutinesMetric is runtime.NumGoroutine
+	RuntimeGoroutinesMetric = "go.runtime.goroutines"
+
+	// RuntimeCgoCallsMetric counts calls into C
+	RuntimeCgoCallsMetric = "go.runtime.cgo_calls"
+
+	// RuntimeHeapAllocMetric is the bytes of live and not yet freed heap
+	// objects
+	RuntimeHeapAllocMetric = "go.runtime.mem.heap_alloc_bytes"
+
+	// RuntimeHeapInuseMetric is the bytes of heap spans in use
+	RuntimeHeapInuseMetric = "go.runtime.mem.heap_inuse_bytes"
+
+	// RuntimeHeapIdleMetric is the bytes of heap spans that aren't in use
+	RuntimeHeapIdleMetric = "go.runtime.mem.heap_idle_bytes"
+
+	// RuntimeHeapObjectsMetric is how many heap objects are allocated
+	RuntimeHeapObjectsMetric = "go.runtime.mem.heap_objects"
+
+	// RuntimeStackInuseMetric is the bytes of goroutine stacks
+	RuntimeStackInuseMetric = "go.runtime.mem.stack_inuse_bytes"
+
+	// RuntimeSysMetric is the bytes obtained from the OS
+	RuntimeSysMetric = "go.runtime.mem.sys_bytes"
+
+	// RuntimeMallocsMetric counts heap allocations
+	RuntimeMallocsMetric = "go.runtime.mem.mallocs"
+
+	// RuntimeFreesMetric counts heap objects freed
+	RuntimeFreesMetric = "go.runtime.mem.frees"
+
+	// RuntimeGCMetric counts completed GC cycles
+	RuntimeGCMetric = "go.runtime.gc.count"
+
+	// RuntimeGCPauseMetric is a histogram of stop-the-world GC pauses, in
+	// milliseconds
+	RuntimeGCPauseMetric = "go.runtime.gc.pause"
+
+	// RuntimeGCNextMetric is the heap size the next GC cycle aims for
+	RuntimeGCNextMetric = "go.runtime.gc.next_bytes"
+)
+
+// WithRuntimeMetrics samples the Go runtime every interval and records
+// what it finds like any other metric: the goroutine count and memory
+// statistics as gauges, calls into C, allocations, frees and GC cycles as
+// counters of how many happened since the last sample, and every GC pause
+// in the RuntimeGCPauseMetric histogram. Reading the memory statistics
+// stops the world briefly, so an interval much shorter than the flush
+// interval gains little.
+func WithRuntimeMetrics(interval time.Duration) Option {
+	return func(c *Client) error {
+		if interval <= 0 {
+			return invalidOption("WithRuntimeMetrics", "interval must be positive")
+		}
+
+		c.runtimeInterval = interval
+		return nil
+	}
+}
+
+// sampleRuntime records runtime metrics every interval until the client
+// stops
+func (c *Client) sampleRuntime() {
+	ticker := time.NewTicker(c.runtimeInterval)
+	defer ticker.Stop()
+
+	s := &runtimeSampler{}
+	s.sample(c)
+
+	for {
+		select {
+		case <-ticker.C:
+			s.sample(c)
+		case <-c.stopping:
+			return
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// runtimeSampler keeps the totals of the previous sample, so counters are
+// recorded as what happened since. The first sample only records gauges,
+// as the totals include everything from before the client started.
+type runtimeSampler struct {
+	mem      runtime.MemStats
+	cgoCalls int64
+	started  bool
+}
+
+// sample records one sample of runtime metrics
+func (s *runtimeSampler) sample(c *Client) {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	cgoCalls := runtime.NumCgoCall()
+
+	c.record(RuntimeGoroutinesMetric, runtime.NumGoroutine(), UnitGauge, ActionLast, nil)
+
+	for _, g := range []struct {
+		name  string
+		value uint64
+	}{
+		{RuntimeHeapAllocMetric, mem.HeapAlloc},
+		{RuntimeHeapInuseMetric, mem.HeapInuse},
+		{RuntimeHeapIdleMetric, mem.HeapIdle},
+		{RuntimeHeapObjectsMetric, mem.HeapObjects},
+		{RuntimeStackInuseMetric, mem.StackInuse},
+		{RuntimeSysMetric, mem.Sys},
+		{RuntimeGCNextMetric, mem.NextGC},
+	} {
+		c.record(g.name, int(g.value), UnitGauge, ActionLast, nil)
+	}
+
+	if !s.started {
+		s.mem, s.cgoCalls, s.started = mem, cgoCalls, true
+		return
+	}
+
+	c.record(RuntimeCgoCallsMetric, int(cgoCalls-s.cgoCalls), UnitCount, ActionSum, nil)
+	c.record(RuntimeMallocsMetric, int(mem.Mallocs-s.mem.Mallocs), UnitCount, ActionSum, nil)
+	c.record(RuntimeFreesMetric, int(mem.Frees-s.mem.Frees), UnitCount, ActionSum, nil)
+	c.record(RuntimeGCMetric, int(mem.NumGC-s.mem.NumGC), UnitCount, ActionSum, nil)
+
+	// PauseNs is a ring of the most recent pauses, so older ones may have
+	// been overwritten if there were many cycles since the last sample
+	cycles := mem.NumGC - s.mem.NumGC
+	if cycles > uint32(len(mem.PauseNs)) {
+		cycles = uint32(len(mem.PauseNs))
+	}
+
+	for i := uint32(0); i < cycles; i++ {
+		pause := mem.PauseNs[(mem.NumGC-i+uint32(len(mem.PauseNs))-1)%uint32(len(mem.PauseNs))]
+		c.recordDuration(RuntimeGCPauseMetric, time.Duration(pause), ActionHistogram, nil)
+	}
+
+	s.mem, s.cgoCalls = mem, cgoCalls
+}
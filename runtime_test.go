@@ -0,0 +1,71 @@
+package buckyclient
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"log"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// lineNames returns the names of every line in a payload
+func lineNames(payload string) []string {
+	var names []string
+	for _, line := range splitLines(payload) {
+		names = append(names, line[:strings.LastIndexByte(line[:strings.IndexByte(line, '|')], ':')])
+	}
+
+	return names
+}
+
+func TestRuntime_runtimeSampler_sample(t *testing.T) {
+	c := newBatchingClient(1)
+	s := &runtimeSampler{}
+
+	// The first sample only has gauges
+	s.sample(c)
+
+	buf := &bytes.Buffer{}
+	c.formatMetricsForFlush(buf)
+
+	names := lineNames(buf.String())
+	assert.Contains(t, names, RuntimeGoroutinesMetric)
+	assert.Contains(t, names, RuntimeHeapAllocMetric)
+	assert.Contains(t, names, RuntimeGCNextMetric)
+	assert.NotContains(t, names, RuntimeGCMetric)
+	assert.NotContains(t, names, RuntimeMallocsMetric)
+
+	runtime.GC()
+	runtime.GC()
+	s.sample(c)
+
+	buf.Reset()
+	c.formatMetricsForFlush(buf)
+
+	lines := splitLines(buf.String())
+	assert.Contains(t, lines, RuntimeGCMetric+":2|c")
+	assert.Contains(t, lineNames(buf.String()), RuntimeGCPauseMetric+".max")
+	assert.Contains(t, lineNames(buf.String()), RuntimeCgoCallsMetric)
+}
+
+func TestRuntime_WithRuntimeMetrics(t *testing.T) {
+	assert.True(t, errors.Is(WithRuntimeMetrics(0)(&Client{}), ErrInvalidOption))
+
+	rt := &recordingTransport{}
+	c, err := NewClient("http://localhost:8005/bucky/v1/send", 60, WithTransport(rt), WithRuntimeMetrics(time.Millisecond))
+	assert.NoError(t, err)
+	c.SetLogger(log.New(ioutil.Discard, "", 0))
+
+	time.Sleep(20 * time.Millisecond)
+
+	// The sampler stops with the client
+	assert.NoError(t, c.Close())
+
+	assert.NotEmpty(t, rt.payloads)
+	assert.Contains(t, lineNames(strings.Join(rt.payloads, "")), RuntimeGoroutinesMetric)
+}
@@ -0,0 +1,72 @@
+package buckyclient
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+	"sync"
+)
+
+// MetricDescription documents one metric an application can emit
+type MetricDescription struct {
+	Name        string `json:"name"`
+	Unit        Unit   `json:"unit"`
+	Description string `json:"description,omitempty"`
+}
+
+// Schema lists every described metric, sorted by name and unit
+type Schema struct {
+	Metrics []MetricDescription `json:"metrics"`
+}
+
+var schema = struct {
+	sync.Mutex
+	metrics map[Metric]string
+}{metrics: make(map[Metric]string)}
+
+// Describe registers a metric so it appears in the schema. It is meant to
+// be called once per metric, typically from a package level var or init
+// function, so the schema can be written without running the code that
+// records the metric. Describing a metric again replaces its description.
+func Describe(name string, unit Unit, description string) {
+	schema.Lock()
+	schema.metrics[Metric{name: name, unit: unit}] = description
+	schema.Unlock()
+}
+
+// DescribedSchema returns every metric registered with Describe
+func DescribedSchema() Schema {
+	schema.Lock()
+
+	s := Schema{Metrics: make([]MetricDescription, 0, len(schema.metrics))}
+	for k, description := range schema.metrics {
+		s.Metrics = append(s.Metrics, MetricDescription{k.name, k.unit, description})
+	}
+
+	schema.Unlock()
+
+	sort.Slice(s.Metrics, func(i, j int) bool {
+		if s.Metrics[i].Name != s.Metrics[j].Name {
+			return s.Metrics[i].Name < s.Metrics[j].Name
+		}
+
+		return s.Metrics[i].Unit < s.Metrics[j].Unit
+	})
+
+	return s
+}
+
+// WriteSchema writes every metric registered with Describe as indented
+// JSON, for generating documentation and dashboards. A program can offer
+// it behind a flag:
+//
+//	if *schemaFlag {
+//		buckyclient.WriteSchema(os.Stdout)
+//		return
+//	}
+func WriteSchema(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(DescribedSchema())
+}
@@ -0,0 +1,49 @@
+package buckyclient
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSchema_WriteSchema(t *testing.T) {
+	schema.Lock()
+	saved := schema.metrics
+	schema.metrics = make(map[Metric]string)
+	schema.Unlock()
+
+	defer func() {
+		schema.Lock()
+		schema.metrics = saved
+		schema.Unlock()
+	}()
+
+	Describe("http.requests", UnitCount, "Requests served")
+	Describe("http.latency", UnitMillisecond, "old")
+	Describe("http.latency", UnitMillisecond, "Time to first byte")
+	Describe("http.latency", UnitGauge, "")
+
+	buf := &bytes.Buffer{}
+	assert.NoError(t, WriteSchema(buf))
+
+	assert.Equal(t, `{
+  "metrics": [
+    {
+      "name": "http.latency",
+      "unit": "g"
+    },
+    {
+      "name": "http.latency",
+      "unit": "ms",
+      "description": "Time to first byte"
+    },
+    {
+      "name": "http.requests",
+      "unit": "c",
+      "description": "Requests served"
+    }
+  ]
+}
+`, buf.String())
+}
@@ -0,0 +1,59 @@
+package buckyclient
+
+import "strings"
+
+// WithPrefix puts prefix in front of every metric name as it is sent, e.g.
+// "myservice.prod." to namespace a service's metrics, and the client's own
+// metrics, without every call site adding it. A dot is added if prefix
+// doesn't end with one. Other options that match metric names, such as
+// WithPriority and WithAggregation, match them without the prefix.
+func WithPrefix(prefix string) Option {
+	return func(c *Client) error {
+		if strings.Trim(prefix, ".") == "" {
+			return invalidOption("WithPrefix", "prefix must not be empty")
+		}
+
+		if !strings.HasSuffix(prefix, ".") {
+			prefix += "."
+		}
+
+		c.prefix = prefix
+		return nil
+	}
+}
+
+// Scope returns a client that records on c with sub and a dot in front of
+// every name, so a package can be handed c.Scope("db") and record "query"
+// as db.query, and a scope of that c.Scope("db").Scope("pool") record
+// "open" as db.pool.open. It is added to the name before WithPrefix. A
+// scope shares everything else with c: it aggregates into the same
+// metrics, and flushing, stopping or configuring it acts on c. It keeps
+// the tags c adds to every sample.
+func (c *Client) Scope(sub string) *Client {
+	parent, scope := c.root(), c.scope
+
+	if sub = strings.Trim(sub, "."); sub != "" {
+		scope += sub + "."
+	}
+
+	return &Client{parent: parent, scope: scope, tags: c.tags}
+}
+
+// root returns the client a scope was made from, or c itself
+func (c *Client) root() *Client {
+	if c.parent != nil {
+		return c.parent
+	}
+
+	return c
+}
+
+// scoped returns the client a sample recorded on c is aggregated by, and
+// its name there
+func (c *Client) scoped(name string) (*Client, string) {
+	if c.parent == nil {
+		return c, name
+	}
+
+	return c.parent, c.scope + name
+}
@@ -0,0 +1,73 @@
+package buckyclient
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScope_WithPrefix(t *testing.T) {
+	c := &Client{}
+
+	assert.NoError(t, WithPrefix("myservice.prod")(c))
+	assert.Equal(t, "myservice.prod.", c.prefix)
+
+	assert.NoError(t, WithPrefix("myservice.prod.")(c))
+	assert.Equal(t, "myservice.prod.", c.prefix)
+
+	assert.ErrorIs(t, WithPrefix("")(c), ErrInvalidOption)
+	assert.ErrorIs(t, WithPrefix(".")(c), ErrInvalidOption)
+}
+
+func TestScope_WithPrefix_Flush(t *testing.T) {
+	rt := &recordingTransport{}
+	c := newRetryClient(rt, WithPrefix("svc."), WithHeartbeat("alive"))
+
+	c.aggregate(MetricWithAmount{Metric{name: "hits", unit: UnitCount, tags: canonicalTags([]Tag{{"env", "prod"}})}, Amount{Value: 1}, ActionSum})
+	c.aggregate(MetricWithAmount{Metric{name: "latency", unit: UnitMillisecond}, Amount{Value: 5}, ActionHistogram})
+	assert.NoError(t, c.flush())
+
+	lines := splitLines(rt.payloads[0])
+	assert.Contains(t, lines, "svc.hits:1|c|#env:prod")
+	assert.Contains(t, lines, "svc.latency.p99:5|ms")
+	assert.Contains(t, lines, "svc.alive:1|c")
+
+	// Names are kept without the prefix until they are sent
+	assert.NoError(t, WithPriority(PriorityHigh, "hits")(c))
+	assert.Equal(t, PriorityHigh, c.priority("hits"))
+
+	buf := &bytes.Buffer{}
+	c.aggregate(MetricWithAmount{Metric{name: "hits", unit: UnitCount}, Amount{Value: 1}, ActionSum})
+	assert.NoError(t, c.WriteOpenMetrics(buf))
+	assert.Contains(t, buf.String(), "svc_hits_total 1")
+}
+
+func TestScope_Client_Scope(t *testing.T) {
+	c := newBatchingClient(1)
+	assert.NoError(t, WithPrefix("svc")(c))
+
+	db := c.Scope("db")
+	pool := db.Scope(".pool.")
+
+	db.Count("queries", 1)
+	db.Count("queries", 2)
+	pool.Gauge("open", 4)
+	c.Scope("").Count("top", 1)
+
+	b := pool.Batch()
+	b.Count("waits", 3)
+	b.Submit()
+
+	buf := &bytes.Buffer{}
+	c.formatMetricsForFlush(buf)
+	assert.ElementsMatch(t, []string{"svc.db.queries:3|c", "svc.db.pool.open:4|g", "svc.top:1|c", "svc.db.pool.waits:3|c"}, splitLines(buf.String()))
+
+	// Everything else acts on the client the scope came from
+	assert.Equal(t, 4, pool.PendingLines())
+
+	pool.SetEnabled(false)
+	assert.False(t, c.Enabled())
+	db.Count("queries", 1)
+	assert.Equal(t, 4, c.PendingLines())
+}
@@ -0,0 +1,164 @@
+package buckyclient
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	// SpoolEvictedPayloadsMetric counts payloads dropped from the retry queue
+	SpoolEvictedPayloadsMetric = "buckyclient.spool.evicted_payloads"
+
+	// SpoolEvictedBytesMetric counts bytes dropped from the retry queue
+	SpoolEvictedBytesMetric = "buckyclient.spool.evicted_bytes"
+)
+
+// spool holds payloads that failed to send so they can be retried on a
+// later flush. It is bounded by the age of each payload and the total
+// number of bytes held, and always evicts the oldest payload first.
+type spool struct {
+	m sync.Mutex
+
+	maxAge   time.Duration
+	maxBytes int
+
+	entries []spoolEntry
+	bytes   int
+
+	evictedPayloads int
+	evictedBytes    int
+}
+
+type spoolEntry struct {
+	payload []byte
+	window  Window // The window the payload was recorded in
+	queued  time.Time
+}
+
+func newSpool(maxAge time.Duration, maxBytes int) *spool {
+	return &spool{
+		maxAge:   maxAge,
+		maxBytes: maxBytes,
+	}
+}
+
+// push adds a copy of the payload to the back of the queue
+func (s *spool) push(payload []byte, window Window, now time.Time) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	p := make([]byte, len(payload))
+	copy(p, payload)
+
+	s.entries = append(s.entries, spoolEntry{payload: p, window: window, queued: now})
+	s.bytes += len(p)
+
+	s.evict(now)
+}
+
+// pushFront puts a payload that failed again back at the head of the queue
+func (s *spool) pushFront(e spoolEntry, now time.Time) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.entries = append([]spoolEntry{e}, s.entries...)
+	s.bytes += len(e.payload)
+
+	s.evict(now)
+}
+
+// pop removes the oldest payload that is still within the age limit
+func (s *spool) pop(now time.Time) (spoolEntry, bool) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.evict(now)
+
+	if len(s.entries) == 0 {
+		return spoolEntry{}, false
+	}
+
+	e := s.entries[0]
+	s.entries = s.entries[1:]
+	s.bytes -= len(e.payload)
+
+	return e, true
+}
+
+// evict drops stale payloads, then the oldest ones until the queue fits
+// in maxBytes - s.m must be held
+func (s *spool) evict(now time.Time) {
+	for len(s.entries) > 0 {
+		e := s.entries[0]
+
+		if now.Sub(e.queued) <= s.maxAge && s.bytes <= s.maxBytes {
+			return
+		}
+
+		s.entries = s.entries[1:]
+		s.bytes -= len(e.payload)
+
+		s.evictedPayloads++
+		s.evictedBytes += len(e.payload)
+	}
+}
+
+// takeEvicted returns and resets the eviction counters
+func (s *spool) takeEvicted() (payloads int, size int) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	payloads, size = s.evictedPayloads, s.evictedBytes
+	s.evictedPayloads, s.evictedBytes = 0, 0
+
+	return payloads, size
+}
+
+// retrySpool resends queued payloads oldest first, stopping at the first failure
+func (c *Client) retrySpool(ctx context.Context, info FlushInfo) error {
+	if c.spool == nil {
+		return nil
+	}
+
+	for {
+		e, ok := c.spool.pop(time.Now())
+		if !ok {
+			return nil
+		}
+
+		// Keep the flush info for the logs, but send the original window
+		retry := info
+		retry.Window = e.window
+
+		err := c.post(ctx, retry, bytes.NewBuffer(e.payload))
+
+		if c.rejected(err) {
+			// Drop it and carry on with the rest of the queue
+			c.handleError(c.reject(retry, e.payload, err))
+			continue
+		}
+
+		if err != nil {
+			c.spool.pushFront(e, time.Now())
+			return err
+		}
+	}
+}
+
+// addSpoolMetrics reports evicted volume as counters - c.m must be held
+func (c *Client) addSpoolMetrics() {
+	if c.spool == nil {
+		return
+	}
+
+	payloads, size := c.spool.takeEvicted()
+	if payloads == 0 {
+		return
+	}
+
+	// Counters of our own won't overflow in practice, so errors are ignored
+	c.aggregate(MetricWithAmount{Metric{name: SpoolEvictedPayloadsMetric, unit: UnitCount}, Amount{Value: payloads}, ActionSum})
+	c.aggregate(MetricWithAmount{Metric{name: SpoolEvictedBytesMetric, unit: UnitCount}, Amount{Value: size}, ActionSum})
+}
@@ -0,0 +1,129 @@
+package buckyclient
+
+import (
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpool_push_EvictsOldestOverSize(t *testing.T) {
+	s := newSpool(time.Hour, 10)
+	now := time.Now()
+
+	s.push([]byte("aaaa"), Window{}, now)
+	s.push([]byte("bbbb"), Window{}, now)
+	s.push([]byte("cccc"), Window{}, now) // 12 bytes, so "aaaa" has to go
+
+	payloads, size := s.takeEvicted()
+	assert.Equal(t, 1, payloads)
+	assert.Equal(t, 4, size)
+
+	e, ok := s.pop(now)
+	assert.True(t, ok)
+	assert.Equal(t, "bbbb", string(e.payload))
+}
+
+func TestSpool_pop_EvictsStale(t *testing.T) {
+	s := newSpool(time.Minute, 100)
+	now := time.Now()
+
+	s.push([]byte("old"), Window{}, now.Add(-2*time.Minute))
+	s.push([]byte("new"), Window{}, now)
+
+	e, ok := s.pop(now)
+	assert.True(t, ok)
+	assert.Equal(t, "new", string(e.payload))
+
+	_, ok = s.pop(now)
+	assert.False(t, ok)
+
+	payloads, size := s.takeEvicted()
+	assert.Equal(t, 1, payloads)
+	assert.Equal(t, 3, size)
+
+	// Counters are reset once taken
+	payloads, _ = s.takeEvicted()
+	assert.Equal(t, 0, payloads)
+}
+
+func TestSpool_push_CopiesPayload(t *testing.T) {
+	s := newSpool(time.Minute, 100)
+
+	p := []byte("abc")
+	s.push(p, Window{}, time.Now())
+	p[0] = 'z'
+
+	e, _ := s.pop(time.Now())
+	assert.Equal(t, "abc", string(e.payload))
+}
+
+func TestSpool_Client_flush_RetriesQueuedPayload(t *testing.T) {
+	var failing int32 = 1
+	bodies := make(chan string, 10)
+
+	mockBucky := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&failing) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		body, _ := ioutil.ReadAll(r.Body)
+		bodies <- string(body)
+	}))
+	defer mockBucky.Close()
+
+	cl := &Client{
+		hostURL:    mockBucky.URL,
+		http:       &http.Client{},
+		logger:     log.New(ioutil.Discard, "", 0),
+		metrics:    make(map[Metric]Value),
+		bufferPool: newBufferPool(),
+	}
+	assert.NoError(t, WithRetryQueue(time.Minute, 1024)(cl))
+
+	cl.handleMetricWithValue(MetricWithAmount{Metric{name: "first", unit: UnitCount}, Amount{Value: 1}, ActionSum})
+	assert.Error(t, cl.flush())
+
+	atomic.StoreInt32(&failing, 0)
+
+	cl.handleMetricWithValue(MetricWithAmount{Metric{name: "second", unit: UnitCount}, Amount{Value: 2}, ActionSum})
+	assert.NoError(t, cl.flush())
+
+	assert.Equal(t, "first:1|c\n", <-bodies)
+	assert.Equal(t, "second:2|c\n", <-bodies)
+}
+
+func TestSpool_Client_flush_ReportsEvictions(t *testing.T) {
+	bodies := make(chan string, 1)
+	mockBucky := captureBuckyServer(bodies)
+	defer mockBucky.Close()
+
+	cl := &Client{
+		hostURL:    mockBucky.URL,
+		http:       &http.Client{},
+		logger:     log.New(ioutil.Discard, "", 0),
+		metrics:    make(map[Metric]Value),
+		bufferPool: newBufferPool(),
+		spool:      newSpool(time.Minute, 5),
+	}
+
+	cl.spool.push([]byte("abcdefgh"), Window{}, time.Now()) // too big, evicted straight away
+
+	assert.NoError(t, cl.flush())
+
+	body := <-bodies
+	assert.Contains(t, body, SpoolEvictedPayloadsMetric+":1|c\n")
+	assert.Contains(t, body, SpoolEvictedBytesMetric+":8|c\n")
+}
+
+func TestSpool_WithRetryQueue_Invalid(t *testing.T) {
+	assert.True(t, errors.Is(WithRetryQueue(0, 10)(&Client{}), ErrInvalidOption))
+	assert.True(t, errors.Is(WithRetryQueue(time.Minute, 0)(&Client{}), ErrInvalidOption))
+}
@@ -0,0 +1,137 @@
+// Package statsd wraps a bucky client in the method set common to statsd
+// client libraries, so code written against one of them can switch to
+// bucky by changing an import and the constructor.
+//
+// Bucky aggregates on the client, so sampling works differently from
+// statsd: a sampled count is scaled up by 1/rate before it is added rather
+// than being sent with the rate. Tags are given in the statsd "key:value"
+// form; a tag without a colon becomes a key with an empty value.
+package statsd
+
+import (
+	"math"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"github.com/matzhouse/go-bucky-client"
+)
+
+// Client records statsd style calls on a bucky client
+type Client struct {
+	bucky *buckyclient.Client
+}
+
+// New creates a bucky client for the given server and wraps it
+func New(host string, interval int, opts ...buckyclient.Option) (*Client, error) {
+	cl, err := buckyclient.NewClient(host, interval, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return Wrap(cl), nil
+}
+
+// Wrap returns a Client that records on an existing bucky client
+func Wrap(cl *buckyclient.Client) *Client {
+	return &Client{bucky: cl}
+}
+
+// Bucky returns the underlying bucky client
+func (c *Client) Bucky() *buckyclient.Client {
+	return c.bucky
+}
+
+// Count adds value to a counter
+func (c *Client) Count(name string, value int64, tags []string, rate float64) error {
+	if !sampled(rate) {
+		return nil
+	}
+
+	c.bucky.Count(name, scale(float64(value), rate), toTags(tags)...)
+	return nil
+}
+
+// Incr adds one to a counter
+func (c *Client) Incr(name string, tags []string, rate float64) error {
+	return c.Count(name, 1, tags, rate)
+}
+
+// Decr takes one from a counter
+func (c *Client) Decr(name string, tags []string, rate float64) error {
+	return c.Count(name, -1, tags, rate)
+}
+
+// Gauge sets a gauge to value
+func (c *Client) Gauge(name string, value float64, tags []string, rate float64) error {
+	if !sampled(rate) {
+		return nil
+	}
+
+	c.bucky.GaugeF(name, value, toTags(tags)...)
+	return nil
+}
+
+// Timing records a duration on a timer, which is averaged over the interval
+func (c *Client) Timing(name string, value time.Duration, tags []string, rate float64) error {
+	return c.TimingDuration(name, value, tags, rate)
+}
+
+// TimingDuration is the same as Timing
+func (c *Client) TimingDuration(name string, value time.Duration, tags []string, rate float64) error {
+	return c.TimeInMilliseconds(name, float64(value)/float64(time.Millisecond), tags, rate)
+}
+
+// TimeInMilliseconds records a number of milliseconds on a timer
+func (c *Client) TimeInMilliseconds(name string, value float64, tags []string, rate float64) error {
+	if !sampled(rate) {
+		return nil
+	}
+
+	c.bucky.AverageTimerF(name, value, toTags(tags)...)
+	return nil
+}
+
+// Set counts the unique values seen, which is sent as a set
+func (c *Client) Set(name string, value string, tags []string, rate float64) error {
+	if !sampled(rate) {
+		return nil
+	}
+
+	c.bucky.Unique(name, value, toTags(tags)...)
+	return nil
+}
+
+// Close flushes anything left and closes the client
+func (c *Client) Close() error {
+	return c.bucky.Close()
+}
+
+// toTags turns statsd "key:value" tags into bucky tags
+func toTags(tags []string) []buckyclient.Tag {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	out := make([]buckyclient.Tag, len(tags))
+	for i, t := range tags {
+		key, value, _ := strings.Cut(t, ":")
+		out[i] = buckyclient.Tag{Key: key, Value: value}
+	}
+
+	return out
+}
+
+// sampled decides whether to keep a call made with the given sample rate
+func sampled(rate float64) bool {
+	return rate >= 1 || rand.Float64() < rate
+}
+
+// scale makes up for the calls a sample rate dropped
+func scale(value, rate float64) int {
+	if rate >= 1 || rate <= 0 {
+		return int(value)
+	}
+
+	return int(math.Round(value / rate))
+}
@@ -0,0 +1,100 @@
+package statsd
+
+import (
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/matzhouse/go-bucky-client/buckytest"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/goleak"
+)
+
+// newTestClient returns a client posting to a test server, and a function
+// that closes it and returns the payload it sent
+func newTestClient(t *testing.T) (*Client, func() []byte) {
+	bodies := make(chan []byte, 1)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		bodies <- b
+	}))
+
+	c, err := New(srv.URL, 60)
+	assert.NoError(t, err)
+	c.Bucky().SetLogger(log.New(ioutil.Discard, "", 0))
+
+	return c, func() []byte {
+		defer srv.Close()
+
+		c.Close()
+		return <-bodies
+	}
+}
+
+func TestStatsd_Client(t *testing.T) {
+	c, closeClient := newTestClient(t)
+
+	c.Incr("hits", nil, 1)
+	c.Incr("hits", []string{"env:prod"}, 1)
+	c.Decr("hits", nil, 1)
+	c.Count("bytes", 100, nil, 1)
+	c.Gauge("temp", 20.6, nil, 1)
+	c.Timing("latency", 10*time.Millisecond, nil, 1)
+	c.TimingDuration("latency", 20*time.Millisecond, nil, 1)
+	c.TimeInMilliseconds("latency", 30.5, nil, 1)
+	c.Set("users", "alice", nil, 1)
+	c.Set("users", "bob", nil, 1)
+	c.Set("users", "alice", nil, 1)
+
+	// Recording happens in the background
+	time.Sleep(50 * time.Millisecond)
+
+	buckytest.AssertPayload(t, `bytes:100|c
+hits:0|c
+hits:1|c|#env:prod
+latency:20.166666666666668|ms
+temp:20.6|g
+users:2|s
+`, closeClient())
+}
+
+func TestStatsd_Client_Rate(t *testing.T) {
+	c, closeClient := newTestClient(t)
+
+	c.Count("never", 1, nil, 0)
+	for i := 0; i < 1000; i++ {
+		c.Incr("sampled", nil, 0.5)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+
+	payload := buckytest.MustParsePayload(string(closeClient()))
+	assert.Len(t, payload, 1)
+	assert.Equal(t, "sampled", payload[0].Name)
+
+	// each kept call counts for two
+	assert.Equal(t, 0, int(payload[0].Value)%2)
+	assert.InDelta(t, 1000, payload[0].Value, 200)
+}
+
+func TestStatsd_scale(t *testing.T) {
+	assert.Equal(t, 4, scale(1, 0.25))
+	assert.Equal(t, 3, scale(3, 1))
+	assert.Equal(t, 3, scale(3, 0))
+	assert.Equal(t, -10, scale(-1, 0.1))
+}
+
+func TestStatsd_Client_Close(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	c, closeClient := newTestClient(t)
+
+	c.Incr("hits", nil, 1)
+	time.Sleep(20 * time.Millisecond)
+
+	buckytest.AssertPayload(t, "hits:1|c\n", closeClient())
+}
@@ -0,0 +1,83 @@
+package buckyclient
+
+import "time"
+
+// Statter is the part of Client that records samples and sends them, for
+// libraries that want to be given somewhere to record metrics rather than
+// a client of their own. *Client and NoopClient both implement it.
+type Statter interface {
+	Count(name string, value int, tags ...Tag)
+	Incr(name string, tags ...Tag)
+	Decr(name string, tags ...Tag)
+	Timer(name string, value int, tags ...Tag)
+	Gauge(name string, value int, tags ...Tag)
+	Ratio(name string, numerator, denominator int, tags ...Tag)
+	AverageTimer(name string, value int, tags ...Tag)
+	CountF(name string, value float64, tags ...Tag)
+	TimerF(name string, value float64, tags ...Tag)
+	AverageTimerF(name string, value float64, tags ...Tag)
+	GaugeF(name string, value float64, tags ...Tag)
+	TimerDuration(name string, d time.Duration, tags ...Tag)
+	Histogram(name string, value int, tags ...Tag)
+	Digest(name string, value int, tags ...Tag)
+	Unique(name string, value string, tags ...Tag)
+
+	Flush() error
+	Stop()
+}
+
+// NoopClient is a Statter that drops everything, for tests and command
+// line tools that don't want metrics. It starts no goroutines or timers,
+// and its zero value is ready to use.
+type NoopClient struct{}
+
+// Count does nothing
+func (NoopClient) Count(name string, value int, tags ...Tag) {}
+
+// Incr does nothing
+func (NoopClient) Incr(name string, tags ...Tag) {}
+
+// Decr does nothing
+func (NoopClient) Decr(name string, tags ...Tag) {}
+
+// Timer does nothing
+func (NoopClient) Timer(name string, value int, tags ...Tag) {}
+
+// Gauge does nothing
+func (NoopClient) Gauge(name string, value int, tags ...Tag) {}
+
+// Ratio does nothing
+func (NoopClient) Ratio(name string, numerator, denominator int, tags ...Tag) {}
+
+// AverageTimer does nothing
+func (NoopClient) AverageTimer(name string, value int, tags ...Tag) {}
+
+// CountF does nothing
+func (NoopClient) CountF(name string, value float64, tags ...Tag) {}
+
+// TimerF does nothing
+func (NoopClient) TimerF(name string, value float64, tags ...Tag) {}
+
+// AverageTimerF does nothing
+func (NoopClient) AverageTimerF(name string, value float64, tags ...Tag) {}
+
+// GaugeF does nothing
+func (NoopClient) GaugeF(name string, value float64, tags ...Tag) {}
+
+// TimerDuration does nothing
+func (NoopClient) TimerDuration(name string, d time.Duration, tags ...Tag) {}
+
+// Histogram does nothing
+func (NoopClient) Histogram(name string, value int, tags ...Tag) {}
+
+// Digest does nothing
+func (NoopClient) Digest(name string, value int, tags ...Tag) {}
+
+// Unique does nothing
+func (NoopClient) Unique(name string, value string, tags ...Tag) {}
+
+// Flush sends nothing and never fails
+func (NoopClient) Flush() error { return nil }
+
+// Stop does nothing
+func (NoopClient) Stop() {}
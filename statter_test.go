@@ -0,0 +1,48 @@
+package buckyclient
+
+import (
+	"io/ioutil"
+	"log"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/goleak"
+)
+
+var (
+	_ Statter = (*Client)(nil)
+	_ Statter = NoopClient{}
+	_ Statter = (*ClientPool)(nil)
+)
+
+// instrumented stands in for a library that takes a Statter
+func instrumented(s Statter) error {
+	s.Count("calls", 1, Tag{"lib", "x"})
+	s.TimerF("latency", 1.5)
+	s.Unique("callers", "a")
+
+	return s.Flush()
+}
+
+func TestStatter_NoopClient(t *testing.T) {
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	var s Statter = NoopClient{}
+
+	assert.NoError(t, instrumented(s))
+	s.Stop()
+}
+
+func TestStatter_Client(t *testing.T) {
+	bodies := make(chan string, 1)
+	ts := captureBuckyServer(bodies)
+	defer ts.Close()
+
+	c, err := NewClient(ts.URL, 0)
+	assert.NoError(t, err)
+	c.SetLogger(log.New(ioutil.Discard, "", 0))
+	defer c.Stop()
+
+	assert.NoError(t, instrumented(c))
+	assert.ElementsMatch(t, []string{"calls:1|c|#lib:x", "latency:1.5|ms", "callers:1|s"}, splitLines(<-bodies))
+}
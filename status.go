@@ -0,0 +1,82 @@
+package buckyclient
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// ErrPayloadRejected is matched by the error from a flush whose payload was
+// dropped because the server rejected it with StatusDropClientErrors
+var ErrPayloadRejected = errors.New("Payload rejected by server")
+
+// StatusError is returned when the server answers a flush with a status
+// code above 299
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("Non-success HTTP Status Code (%d)", e.StatusCode)
+}
+
+// ClientError reports whether the server blamed the request itself. Too
+// many requests and request timeouts are 4xx codes but are worth retrying,
+// so they don't count.
+func (e *StatusError) ClientError() bool {
+	switch e.StatusCode {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return false
+	}
+
+	return e.StatusCode >= 400 && e.StatusCode <= 499
+}
+
+// StatusPolicy controls what happens to a payload the server didn't accept
+type StatusPolicy int
+
+const (
+	// StatusRetryAll treats every failure the same: the payload goes in the
+	// retry queue if there is one and is dropped otherwise. This is the
+	// default.
+	StatusRetryAll StatusPolicy = iota
+
+	// StatusDropClientErrors drops payloads rejected with a 4xx status
+	// straight away, logs them and reports an error matching
+	// ErrPayloadRejected, as a 4xx usually means the payload is malformed
+	// and retrying it can't help. Server errors and transport failures
+	// still go in the retry queue.
+	StatusDropClientErrors
+)
+
+// WithStatusPolicy sets what happens to payloads the server rejects
+func WithStatusPolicy(policy StatusPolicy) Option {
+	return func(c *Client) error {
+		switch policy {
+		case StatusRetryAll, StatusDropClientErrors:
+		default:
+			return invalidOption("WithStatusPolicy", "unknown policy")
+		}
+
+		c.statusPolicy = policy
+		return nil
+	}
+}
+
+// rejected reports whether a failed payload should be dropped rather
+// than retried
+func (c *Client) rejected(err error) bool {
+	if err == nil || c.statusPolicy != StatusDropClientErrors {
+		return false
+	}
+
+	var statusErr *StatusError
+	return errors.As(err, &statusErr) && statusErr.ClientError()
+}
+
+// reject logs a dropped payload and returns the error to report for it
+func (c *Client) reject(info FlushInfo, payload []byte, err error) error {
+	c.errorf(info, "payload of %d bytes rejected by server, dropping it - %v", len(payload), err)
+
+	return fmt.Errorf("%w: %w", ErrPayloadRejected, err)
+}
@@ -0,0 +1,105 @@
+package buckyclient
+
+import (
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newStatusClient(url string, policy StatusPolicy) *Client {
+	c := &Client{
+		hostURL:    url,
+		http:       &http.Client{},
+		logger:     log.New(ioutil.Discard, "", 0),
+		metrics:    make(map[Metric]Value),
+		bufferPool: newBufferPool(),
+		spool:      newSpool(time.Minute, 1<<20),
+	}
+
+	WithStatusPolicy(policy)(c)
+	return c
+}
+
+func TestStatus_Client_flush_StatusDropClientErrors(t *testing.T) {
+	for code, spooled := range map[int]bool{
+		http.StatusBadRequest:          false,
+		http.StatusUnprocessableEntity: false,
+		http.StatusTooManyRequests:     true,
+		http.StatusRequestTimeout:      true,
+		http.StatusInternalServerError: true,
+		http.StatusServiceUnavailable:  true,
+	} {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(code)
+		}))
+
+		c := newStatusClient(srv.URL, StatusDropClientErrors)
+		c.aggregate(MetricWithAmount{Metric{name: "a", unit: UnitCount}, Amount{Value: 1}, ActionSum})
+
+		err := c.flush()
+
+		var statusErr *StatusError
+		assert.True(t, errors.As(err, &statusErr), code)
+		assert.Equal(t, code, statusErr.StatusCode)
+		assert.Equal(t, !spooled, errors.Is(err, ErrPayloadRejected), code)
+		assert.Equal(t, spooled, len(c.spool.entries) == 1, code)
+
+		srv.Close()
+	}
+}
+
+func TestStatus_Client_flush_StatusRetryAll(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	c := newStatusClient(srv.URL, StatusRetryAll)
+	c.aggregate(MetricWithAmount{Metric{name: "a", unit: UnitCount}, Amount{Value: 1}, ActionSum})
+
+	err := c.flush()
+	assert.EqualError(t, errors.Unwrap(err), "Non-success HTTP Status Code (400)")
+	assert.False(t, errors.Is(err, ErrPayloadRejected))
+	assert.Len(t, c.spool.entries, 1)
+}
+
+func TestStatus_Client_retrySpool_Rejected(t *testing.T) {
+	var bodies []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		if string(b) == "bad\n" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		bodies = append(bodies, string(b))
+	}))
+	defer srv.Close()
+
+	var reported []error
+
+	c := newStatusClient(srv.URL, StatusDropClientErrors)
+	c.errorHandler = func(err error) { reported = append(reported, err) }
+
+	// queued before the server started rejecting anything
+	c.spool.push([]byte("bad\n"), Window{}, time.Now())
+	c.spool.push([]byte("good:1|c\n"), Window{}, time.Now())
+
+	c.aggregate(MetricWithAmount{Metric{name: "a", unit: UnitCount}, Amount{Value: 1}, ActionSum})
+	assert.NoError(t, c.flush())
+
+	assert.Equal(t, []string{"good:1|c\n", "a:1|c\n"}, bodies)
+	assert.Len(t, reported, 1)
+	assert.True(t, errors.Is(reported[0], ErrPayloadRejected))
+	assert.Empty(t, c.spool.entries)
+}
+
+func TestStatus_WithStatusPolicy_Invalid(t *testing.T) {
+	assert.True(t, errors.Is(WithStatusPolicy(StatusPolicy(9))(&Client{}), ErrInvalidOption))
+}
@@ -0,0 +1,166 @@
+package buckyclient
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+// ErrInvariant is matched by every error WithStrict reports
+var ErrInvariant = errors.New("Internal invariant violated")
+
+// InvariantError says which metric broke an invariant and how. Name is
+// empty for an invariant of the client rather than of one metric.
+type InvariantError struct {
+	Name   string
+	Reason string
+}
+
+func (e *InvariantError) Error() string {
+	if e.Name == "" {
+		return ErrInvariant.Error() + ": " + e.Reason
+	}
+
+	return ErrInvariant.Error() + ": " + e.Name + ": " + e.Reason
+}
+
+// Is lets errors.Is(err, ErrInvariant) match any InvariantError
+func (e *InvariantError) Is(target error) bool {
+	return target == ErrInvariant
+}
+
+// WithStrict checks the client's internal invariants before every flush,
+// such as each aggregate holding exactly one kind of value, averages
+// matching their totals, timers not going negative and a metric not being
+// recorded with two units. Every broken invariant is an InvariantError
+// passed to the error handler, or a panic without one. The checks go over
+// every metric, so this is meant for tests and CI rather than production.
+func WithStrict() Option {
+	return func(c *Client) error {
+		c.strict = true
+		return nil
+	}
+}
+
+// checkStrict reports the invariants the client breaks, if WithStrict
+// asked for it
+func (c *Client) checkStrict() {
+	if !c.strict {
+		return
+	}
+
+	c.m.Lock()
+	errs := c.invariants()
+	c.m.Unlock()
+
+	// Reported outside the lock in case the handler records metrics itself
+	for _, err := range errs {
+		if c.errorHandler == nil {
+			panic(err)
+		}
+
+		c.handleError(err)
+	}
+}
+
+// invariants returns every invariant the client breaks - c.m must be held
+func (c *Client) invariants() []error {
+	var errs []error
+
+	units := make(map[Metric]Unit)
+
+	for k, v := range c.metrics {
+		if reason := v.invariant(k.unit); reason != "" {
+			errs = append(errs, &InvariantError{Name: k.name, Reason: reason})
+		}
+
+		series := Metric{name: k.name, tags: k.tags}
+		if u, ok := units[series]; ok && u != k.unit {
+			first, second := u, k.unit
+			if second < first {
+				first, second = second, first
+			}
+
+			errs = append(errs, &InvariantError{Name: k.name, Reason: fmt.Sprintf("recorded with units %s and %s", first, second)})
+		}
+
+		units[series] = k.unit
+	}
+
+	if handled, sent := atomic.LoadUint64(&c.inputHandled), atomic.LoadUint64(&c.inputSent); handled > sent {
+		errs = append(errs, &InvariantError{Reason: fmt.Sprintf("%d samples handled of %d sent", handled, sent)})
+	}
+
+	if c.spool != nil {
+		c.spool.m.Lock()
+		total := 0
+		for _, e := range c.spool.entries {
+			total += len(e.payload)
+		}
+
+		if total != c.spool.bytes {
+			errs = append(errs, &InvariantError{Reason: fmt.Sprintf("retry queue counts %d bytes but holds %d", c.spool.bytes, total)})
+		}
+		c.spool.m.Unlock()
+	}
+
+	return errs
+}
+
+// invariant returns how v breaks an invariant of an aggregate with the
+// unit, or nothing if it doesn't
+func (v Value) invariant(unit Unit) string {
+	set := 0
+	for _, ok := range []bool{v.Avg != nil, v.Sum != nil, v.Last != nil, v.Ratio != nil, v.Hist != nil, v.Set != nil, v.Digest != nil} {
+		if ok {
+			set++
+		}
+	}
+
+	if set != 1 {
+		return fmt.Sprintf("holds %d aggregates", set)
+	}
+
+	timer := unit == UnitMillisecond
+
+	switch {
+	case v.Sum != nil:
+		if timer && (v.Sum.Value < 0 || v.Sum.Float < 0) {
+			return "negative timer total"
+		}
+	case v.Avg != nil:
+		if v.Avg.Count <= 0 {
+			return fmt.Sprintf("average of %d samples", v.Avg.Count)
+		}
+
+		if !v.Avg.IsFloat && v.Avg.Avg != v.Avg.Total/v.Avg.Count {
+			return fmt.Sprintf("average %d doesn't match total %d of %d samples", v.Avg.Avg, v.Avg.Total, v.Avg.Count)
+		}
+
+		if timer && (v.Avg.Total < 0 || v.Avg.FloatTotal < 0) {
+			return "negative average timer"
+		}
+	case v.Ratio != nil:
+		if v.Ratio.Denominator < 0 {
+			return "negative ratio denominator"
+		}
+	case v.Hist != nil:
+		if len(v.Hist.Samples) > histogramSamples || int64(len(v.Hist.Samples)) > v.Hist.Count {
+			return fmt.Sprintf("histogram keeps %d samples of %d", len(v.Hist.Samples), v.Hist.Count)
+		}
+
+		if v.Hist.Count > 0 && v.Hist.Min > v.Hist.Max {
+			return "histogram minimum above its maximum"
+		}
+	case v.Digest != nil:
+		if v.Digest.count > 0 && v.Digest.min > v.Digest.max {
+			return "digest minimum above its maximum"
+		}
+	case v.Set != nil:
+		if unit != UnitSet {
+			return fmt.Sprintf("set sent with unit %s", unit)
+		}
+	}
+
+	return ""
+}
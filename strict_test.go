@@ -0,0 +1,65 @@
+package buckyclient
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStrict_WithStrict_Valid(t *testing.T) {
+	var errs []error
+	rt := &recordingTransport{}
+	c := newRetryClient(rt, WithStrict(), WithErrorHandler(func(err error) { errs = append(errs, err) }), WithRetryQueue(0, 1<<20))
+
+	for _, m := range []MetricWithAmount{
+		{Metric{name: "hits", unit: UnitCount}, Amount{Value: -1}, ActionSum},
+		{Metric{name: "latency", unit: UnitMillisecond}, Amount{Value: 10}, ActionAvg},
+		{Metric{name: "latency", unit: UnitMillisecond}, Amount{Value: 5}, ActionAvg},
+		{Metric{name: "p", unit: UnitMillisecond}, Amount{Value: 5}, ActionHistogram},
+		{Metric{name: "hit_rate", unit: UnitGauge}, Amount{Value: 1, Denominator: 2}, ActionRatio},
+		{Metric{name: "users", unit: UnitSet}, Amount{Member: "a"}, ActionUnique},
+	} {
+		assert.NoError(t, c.aggregate(m))
+	}
+
+	assert.NoError(t, c.flush())
+	assert.Empty(t, errs)
+}
+
+func TestStrict_WithStrict_Broken(t *testing.T) {
+	var errs []error
+	rt := &recordingTransport{}
+	c := newRetryClient(rt, WithStrict(), WithErrorHandler(func(err error) { errs = append(errs, err) }))
+
+	c.metrics[Metric{name: "empty", unit: UnitCount}] = Value{}
+	c.metrics[Metric{name: "avg", unit: UnitMillisecond}] = Value{Avg: &Average{Count: 2, Total: 10, Avg: 7}}
+	c.metrics[Metric{name: "wrapped", unit: UnitMillisecond}] = Value{Sum: &Sum{Value: -5}}
+	c.metrics[Metric{name: "both", unit: UnitCount}] = Value{Sum: &Sum{Value: 1}}
+	c.metrics[Metric{name: "both", unit: UnitGauge}] = Value{Last: &Last{Value: 1}}
+	c.inputHandled = 1
+
+	c.flush()
+
+	var reasons []string
+	for _, err := range errs {
+		if errors.Is(err, ErrInvariant) {
+			reasons = append(reasons, err.Error())
+		}
+	}
+
+	assert.ElementsMatch(t, []string{
+		"Internal invariant violated: empty: holds 0 aggregates",
+		"Internal invariant violated: avg: average 7 doesn't match total 10 of 2 samples",
+		"Internal invariant violated: wrapped: negative timer total",
+		"Internal invariant violated: both: recorded with units c and g",
+		"Internal invariant violated: 1 samples handled of 0 sent",
+	}, reasons)
+}
+
+func TestStrict_WithStrict_Panics(t *testing.T) {
+	c := newRetryClient(&recordingTransport{}, WithStrict())
+	c.metrics[Metric{name: "users", unit: UnitCount}] = Value{Set: &Set{}}
+
+	assert.PanicsWithError(t, "Internal invariant violated: users: set sent with unit c", func() { c.flush() })
+}
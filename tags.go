@@ -0,0 +1,227 @@
+package buckyclient
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+)
+
+// Tag is a dimension attached to a metric, e.g. Tag{"region", "eu-west-1"}.
+// Samples with the same name and unit but different tags are aggregated
+// separately. The order tags are given in doesn't matter.
+type Tag struct {
+	Key   string
+	Value string
+}
+
+// TagFormat is how tags are written on the wire
+type TagFormat int
+
+const (
+	// TagFormatDogStatsD appends tags after the unit: name:1|c|#k:v,k2:v2
+	TagFormatDogStatsD TagFormat = iota
+	// TagFormatGraphite appends tags to the name: name;k=v;k2=v2:1|c
+	TagFormatGraphite
+	// TagFormatNone drops tags when writing, for servers that don't
+	// understand them. Differently tagged samples are still sent as
+	// separate lines.
+	TagFormatNone
+)
+
+// WithTagFormat sets how tags are written in payloads. The default is
+// TagFormatDogStatsD.
+func WithTagFormat(format TagFormat) Option {
+	return func(c *Client) error {
+		switch format {
+		case TagFormatDogStatsD, TagFormatGraphite, TagFormatNone:
+		default:
+			return invalidOption("WithTagFormat", "unknown tag format")
+		}
+
+		c.tagFormat = format
+
+		return nil
+	}
+}
+
+// WithTags adds tags to every sample recorded on the client, e.g. the
+// service and region, as if every call had been given them. A tag given
+// to a call replaces a default tag with the same key. The client's own
+// metrics aren't tagged.
+func WithTags(tags ...Tag) Option {
+	return func(c *Client) error {
+		for _, t := range tags {
+			if t.Key == "" {
+				return invalidOption("WithTags", "tag key must not be empty")
+			}
+		}
+
+		c.tags = mergeTags(c.tags, tags)
+		return nil
+	}
+}
+
+// WithAdditionalTags returns a client that adds tags to every sample
+// recorded on it, on top of those c adds, e.g. to tag everything a request
+// handler records with its route. Like a Scope it shares everything else
+// with c, and is cheap enough to make for every request.
+func (c *Client) WithAdditionalTags(tags ...Tag) *Client {
+	return &Client{parent: c.root(), scope: c.scope, tags: mergeTags(c.tags, tags)}
+}
+
+// withTags returns the tags of a sample recorded on c with tags
+func (c *Client) withTags(tags []Tag) []Tag {
+	return mergeTags(c.tags, tags)
+}
+
+// mergeTags returns tags along with every one of defaults whose key isn't
+// among them
+func mergeTags(defaults, tags []Tag) []Tag {
+	if len(defaults) == 0 {
+		return tags
+	}
+
+	if len(tags) == 0 {
+		return defaults
+	}
+
+	out := make([]Tag, 0, len(defaults)+len(tags))
+	out = append(out, tags...)
+
+	for _, d := range defaults {
+		found := false
+
+		for _, t := range tags {
+			if t.Key == d.Key {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			out = append(out, d)
+		}
+	}
+
+	return out
+}
+
+// tagReplacer replaces the characters used to separate tags in any of the
+// formats, so a canonical tag string can always be split again
+var tagReplacer = strings.NewReplacer(
+	",", "_",
+	"|", "_",
+	"#", "_",
+	":", "_",
+	";", "_",
+	"=", "_",
+	"\n", "_",
+)
+
+// canonicalTags returns tags as a string that is usable in a map key:
+// sorted by key then value, separators replaced, joined as k:v,k2:v2.
+// Tags without a key are dropped.
+func canonicalTags(tags []Tag) string {
+	if len(tags) == 0 {
+		return ""
+	}
+
+	clean := make([]Tag, 0, len(tags))
+	for _, t := range tags {
+		if t.Key != "" {
+			clean = append(clean, Tag{tagReplacer.Replace(t.Key), tagReplacer.Replace(t.Value)})
+		}
+	}
+
+	sort.Slice(clean, func(i, j int) bool {
+		if clean[i].Key != clean[j].Key {
+			return clean[i].Key < clean[j].Key
+		}
+
+		return clean[i].Value < clean[j].Value
+	})
+
+	var b strings.Builder
+	for i, t := range clean {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+
+		b.WriteString(t.Key)
+		b.WriteByte(':')
+		b.WriteString(t.Value)
+	}
+
+	return b.String()
+}
+
+// splitTags turns a canonical tag string back into tags
+func splitTags(tags string) []Tag {
+	if tags == "" {
+		return nil
+	}
+
+	parts := strings.Split(tags, ",")
+	out := make([]Tag, len(parts))
+
+	for i, p := range parts {
+		key, value, _ := strings.Cut(p, ":")
+		out[i] = Tag{key, value}
+	}
+
+	return out
+}
+
+// writeTaggedLine is writeLine with canonical tags written in format
+func writeTaggedLine(buf *bytes.Buffer, name string, value number, unit Unit, tags string, format TagFormat) {
+	if tags == "" || format == TagFormatNone {
+		writeLine(buf, name, value, unit)
+		return
+	}
+
+	if format == TagFormatGraphite {
+		buf.WriteString(name)
+		buf.WriteByte(';')
+		writeGraphiteTags(buf, tags)
+		writeLine(buf, "", value, unit)
+
+		return
+	}
+
+	writeLine(buf, name, value, unit)
+	buf.Truncate(buf.Len() - 1) // the newline
+
+	buf.WriteString("|#")
+	buf.WriteString(tags)
+	buf.WriteByte('\n')
+}
+
+// writeGraphiteTags writes k:v,k2:v2 as k=v;k2=v2
+func writeGraphiteTags(buf *bytes.Buffer, tags string) {
+	for i := 0; i < len(tags); i++ {
+		switch tags[i] {
+		case ':':
+			buf.WriteByte('=')
+		case ',':
+			buf.WriteByte(';')
+		default:
+			buf.WriteByte(tags[i])
+		}
+	}
+}
+
+// tagsLength is how many bytes writeTaggedLine adds for the tags
+func tagsLength(tags string, format TagFormat) int {
+	if tags == "" {
+		return 0
+	}
+
+	switch format {
+	case TagFormatDogStatsD:
+		return len(tags) + 2 // for |#
+	case TagFormatGraphite:
+		return len(tags) + 1 // for ;
+	}
+
+	return 0
+}
@@ -0,0 +1,166 @@
+package buckyclient
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTags_canonicalTags(t *testing.T) {
+	assert.Equal(t, "", canonicalTags(nil))
+	assert.Equal(t, "a:2,b:1", canonicalTags([]Tag{{"b", "1"}, {"a", "2"}}))
+	assert.Equal(t, "a:1,a:2", canonicalTags([]Tag{{"a", "2"}, {"a", "1"}}))
+	assert.Equal(t, "a_b:c_d_e", canonicalTags([]Tag{{"a:b", "c,d|e"}, {"", "dropped"}}))
+
+	assert.Equal(t, []Tag{{"a", "2"}, {"b", "1"}}, splitTags("a:2,b:1"))
+	assert.Nil(t, splitTags(""))
+}
+
+func TestTags_Client_Count(t *testing.T) {
+	c := newBatchingClient(10)
+
+	c.Count("hits", 1)
+	c.Count("hits", 2, Tag{"env", "prod"}, Tag{"region", "eu"})
+	c.Count("hits", 3, Tag{"region", "eu"}, Tag{"env", "prod"})
+	c.Gauge("temp", 20, Tag{"room", "kitchen"})
+
+	c.drainBatches()
+	assert.Equal(t, 3, c.PendingLines())
+
+	buf := &bytes.Buffer{}
+	c.formatMap(buf, c.metrics, time.Time{})
+	assert.ElementsMatch(t, []string{
+		"hits:1|c",
+		"hits:5|c|#env:prod,region:eu",
+		"temp:20|g|#room:kitchen",
+	}, splitLines(buf.String()))
+
+	assert.Equal(t, buf.Len(), c.PendingBytesEstimate())
+}
+
+func TestTags_writeTaggedLine(t *testing.T) {
+	tests := []struct {
+		format TagFormat
+		want   string
+	}{
+		{TagFormatDogStatsD, "a.b:1|c|#env:prod,region:eu\n"},
+		{TagFormatGraphite, "a.b;env=prod;region=eu:1|c\n"},
+		{TagFormatNone, "a.b:1|c\n"},
+	}
+
+	for _, test := range tests {
+		buf := &bytes.Buffer{}
+		writeTaggedLine(buf, "a.b", number{i: 1}, UnitCount, "env:prod,region:eu", test.format)
+		assert.Equal(t, test.want, buf.String())
+		assert.Equal(t, buf.Len(), lineLength("a.b", number{i: 1}, UnitCount)+tagsLength("env:prod,region:eu", test.format))
+	}
+}
+
+func TestTags_WithTagFormat(t *testing.T) {
+	c := &Client{}
+
+	assert.NoError(t, WithTagFormat(TagFormatGraphite)(c))
+	assert.Equal(t, TagFormatGraphite, c.tagFormat)
+
+	assert.Error(t, WithTagFormat(TagFormat(42))(c))
+}
+
+func TestTags_Client_WriteOpenMetrics(t *testing.T) {
+	c := &Client{metrics: make(map[Metric]Value)}
+	c.aggregate(MetricWithAmount{Metric{name: "hits", unit: UnitCount}, Amount{Value: 1}, ActionSum})
+	c.aggregate(MetricWithAmount{Metric{name: "hits", unit: UnitCount, tags: "env:prod"}, Amount{Value: 2}, ActionSum})
+	c.aggregate(MetricWithAmount{Metric{name: "lat", unit: UnitMillisecond, tags: "path:/\"x\""}, Amount{Value: 4}, ActionHistogram})
+
+	buf := &bytes.Buffer{}
+	assert.NoError(t, c.WriteOpenMetrics(buf))
+	assert.Equal(t, `# TYPE hits counter
+hits_total 1
+hits_total{env="prod"} 2
+# TYPE lat summary
+lat{quantile="0.5",path="/\"x\""} 4
+lat{quantile="0.9",path="/\"x\""} 4
+lat{quantile="0.99",path="/\"x\""} 4
+lat_sum{path="/\"x\""} 4
+lat_count{path="/\"x\""} 1
+# EOF
+`, buf.String())
+}
+
+func TestTags_WithTags(t *testing.T) {
+	c := newBatchingClient(1)
+	assert.NoError(t, WithTags(Tag{"service", "api"}, Tag{"region", "eu"})(c))
+	assert.ErrorIs(t, WithTags(Tag{"", "x"})(c), ErrInvalidOption)
+
+	c.Count("hits", 1)
+	c.Count("hits", 1, Tag{"region", "us"})
+
+	// Scopes and batches keep the default tags
+	c.Scope("db").Gauge("open", 2)
+
+	b := c.Batch()
+	b.Count("batched", 1)
+	b.Submit()
+
+	buf := &bytes.Buffer{}
+	c.formatMetricsForFlush(buf)
+	assert.ElementsMatch(t, []string{
+		"hits:1|c|#region:eu,service:api",
+		"hits:1|c|#region:us,service:api",
+		"db.open:2|g|#region:eu,service:api",
+		"batched:1|c|#region:eu,service:api",
+	}, splitLines(buf.String()))
+}
+
+func TestTags_WithTags_Aggregates(t *testing.T) {
+	c := newBatchingClient(1)
+	assert.NoError(t, WithTags(Tag{"service", "api"})(c))
+
+	c.TopK("endpoints", "/users")
+	c.Distinct("users", "alice", Tag{"region", "eu"})
+	c.EWMA("requests", 60)
+
+	now := time.Now()
+	for _, e := range c.ewmas {
+		e.last = now.Add(-time.Minute)
+	}
+
+	c.addTopKs()
+	c.addDistincts()
+	c.addEWMAs(now)
+
+	buf := &bytes.Buffer{}
+	c.formatMetricsForFlush(buf)
+	assert.ElementsMatch(t, []string{
+		"endpoints./users:1|c|#service:api",
+		"users:1|g|#region:eu,service:api",
+		"requests.m1_rate:1|g|#service:api",
+		"requests.m5_rate:1|g|#service:api",
+		"requests.m15_rate:1|g|#service:api",
+	}, splitLines(buf.String()))
+}
+
+func TestTags_Client_WithAdditionalTags(t *testing.T) {
+	c := newBatchingClient(1)
+	assert.NoError(t, WithTags(Tag{"service", "api"})(c))
+
+	handler := c.WithAdditionalTags(Tag{"route", "/users"})
+	handler.Count("requests", 1)
+	handler.Count("requests", 1, Tag{"route", "/override"})
+	handler.WithAdditionalTags(Tag{"method", "GET"}).Scope("http").Timer("latency", 5)
+
+	c.Count("requests", 1)
+
+	buf := &bytes.Buffer{}
+	c.formatMetricsForFlush(buf)
+	assert.ElementsMatch(t, []string{
+		"requests:1|c|#route:/users,service:api",
+		"requests:1|c|#route:/override,service:api",
+		"http.latency:5|ms|#method:GET,route:/users,service:api",
+		"requests:1|c|#service:api",
+	}, splitLines(buf.String()))
+
+	// It shares everything else with the client it came from
+	assert.Equal(t, 4, handler.PendingLines())
+}
@@ -0,0 +1,56 @@
+package buckyclient
+
+import (
+	"bytes"
+	"time"
+)
+
+// Snapshot describes a payload that is about to be sent
+type Snapshot struct {
+	FlushInfo
+
+	// Payload is the uncompressed body. It must not be modified or kept.
+	Payload []byte
+
+	// Lines is how many metrics are in the payload
+	Lines int
+
+	// Time is when the payload is being sent
+	Time time.Time
+}
+
+// WithTargetSelector sets a function that picks the URL each payload is
+// sent to, e.g. by payload size, tenant or time of day. Returning an empty
+// string sends it to the client's host. Payloads retried from the retry
+// queue are offered to the selector again. Warm up and capability probe
+// requests always go to the client's host.
+func WithTargetSelector(selector func(Snapshot) string) Option {
+	return func(c *Client) error {
+		if selector == nil {
+			return invalidOption("WithTargetSelector", "selector must not be nil")
+		}
+
+		c.targetSelector = selector
+		return nil
+	}
+}
+
+// target returns the URL a payload should be sent to
+func (c *Client) target(info FlushInfo, payload []byte) string {
+	if c.targetSelector == nil {
+		return c.hostURL
+	}
+
+	target := c.targetSelector(Snapshot{
+		FlushInfo: info,
+		Payload:   payload,
+		Lines:     bytes.Count(payload, []byte("\n")),
+		Time:      c.now(),
+	})
+
+	if target == "" {
+		return c.hostURL
+	}
+
+	return target
+}
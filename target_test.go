@@ -0,0 +1,59 @@
+package buckyclient
+
+import (
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTarget_Client_flush_TargetSelector(t *testing.T) {
+	paths := make(chan string, 2)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths <- r.URL.Path
+	}))
+	defer srv.Close()
+
+	var snapshots []Snapshot
+
+	c := &Client{
+		hostURL:    srv.URL + "/default",
+		http:       &http.Client{},
+		logger:     log.New(ioutil.Discard, "", 0),
+		metrics:    make(map[Metric]Value),
+		bufferPool: newBufferPool(),
+	}
+
+	WithTargetSelector(func(s Snapshot) string {
+		// The payload is only valid during the call
+		s.Payload = append([]byte(nil), s.Payload...)
+		snapshots = append(snapshots, s)
+		if s.Lines > 1 {
+			return srv.URL + "/bulk"
+		}
+
+		return ""
+	})(c)
+
+	c.aggregate(MetricWithAmount{Metric{name: "a", unit: UnitCount}, Amount{Value: 1}, ActionSum})
+	assert.NoError(t, c.flush())
+	assert.Equal(t, "/default", <-paths)
+
+	c.aggregate(MetricWithAmount{Metric{name: "a", unit: UnitCount}, Amount{Value: 1}, ActionSum})
+	c.aggregate(MetricWithAmount{Metric{name: "b", unit: UnitCount}, Amount{Value: 1}, ActionSum})
+	assert.NoError(t, c.flush())
+	assert.Equal(t, "/bulk", <-paths)
+
+	assert.Len(t, snapshots, 2)
+	assert.Equal(t, "a:1|c\n", string(snapshots[0].Payload))
+	assert.Equal(t, uint64(2), snapshots[1].Seq)
+	assert.False(t, snapshots[1].Time.IsZero())
+}
+
+func TestTarget_WithTargetSelector_Nil(t *testing.T) {
+	assert.Error(t, WithTargetSelector(nil)(&Client{}))
+}
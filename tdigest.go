@@ -0,0 +1,382 @@
+package buckyclient
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"math"
+	"sort"
+)
+
+// DefaultDigestCompression is the compression of the t-digest behind
+// Client.Digest. Higher values keep more centroids and give more accurate
+// quantiles: 100 keeps at most a few hundred, whatever the sample count.
+const DefaultDigestCompression = 100
+
+// UnitDigest is the unit of the serialized sketch lines sent with
+// WithDigestSketches
+const UnitDigest Unit = "td"
+
+// ErrInvalidDigest is returned when a serialized t-digest can't be decoded
+var ErrInvalidDigest = errors.New("Invalid t-digest")
+
+// digestVersion is the first byte of a serialized t-digest
+const digestVersion = 2
+
+// digestFloat is set in the flags byte of a digest with fractional samples
+const digestFloat = 1 << 0
+
+// centroid is the mean of a cluster of samples and how many there were
+type centroid struct {
+	mean   float64
+	weight float64
+}
+
+// TDigest estimates quantiles from a fixed amount of memory, keeping
+// samples near the extremes more accurately than those in the middle, so
+// p99 and p999 stay useful for latencies with any number of samples. Two
+// digests can be merged without losing accuracy, which lets a backend
+// combine the sketches of many clients; see WithDigestSketches.
+//
+// This is the merging t-digest of Dunning and Ertl with the k1 scale
+// function. A TDigest isn't safe for concurrent use.
+type TDigest struct {
+	compression float64
+	centroids   []centroid // merged, sorted by mean
+	unmerged    []centroid
+	count       float64
+	sum         float64
+	min, max    float64
+	isFloat     bool // Whether a fractional sample was added, for the wire format
+}
+
+// NewTDigest returns an empty digest. A compression of zero or less is
+// DefaultDigestCompression.
+func NewTDigest(compression float64) *TDigest {
+	if compression <= 0 {
+		compression = DefaultDigestCompression
+	}
+
+	return &TDigest{compression: compression}
+}
+
+// Add includes a sample
+func (t *TDigest) Add(value float64) {
+	t.addWeighted(value, 1)
+}
+
+// addWeighted includes a centroid of weight samples
+func (t *TDigest) addWeighted(mean, weight float64) {
+	if t.count == 0 || mean < t.min {
+		t.min = mean
+	}
+
+	if t.count == 0 || mean > t.max {
+		t.max = mean
+	}
+
+	t.count += weight
+	t.sum += mean * weight
+	t.unmerged = append(t.unmerged, centroid{mean, weight})
+
+	if len(t.unmerged) >= t.bufferSize() {
+		t.compress()
+	}
+}
+
+// add includes a recorded sample
+func (t *TDigest) add(amount Amount) {
+	t.isFloat = t.isFloat || amount.IsFloat
+	t.Add(amount.float())
+}
+
+// bufferSize is how many samples are collected before they are merged
+func (t *TDigest) bufferSize() int {
+	return int(5 * t.compression)
+}
+
+// Count returns how many samples were added
+func (t *TDigest) Count() int64 {
+	return int64(t.count)
+}
+
+// Sum returns the total of the samples
+func (t *TDigest) Sum() float64 {
+	return t.sum
+}
+
+// Min returns the smallest sample, or zero for an empty digest
+func (t *TDigest) Min() float64 {
+	return t.min
+}
+
+// Max returns the largest sample, or zero for an empty digest
+func (t *TDigest) Max() float64 {
+	return t.max
+}
+
+// Merge adds every sample of another digest to t
+func (t *TDigest) Merge(o *TDigest) {
+	if o.count == 0 {
+		return
+	}
+
+	min, max := o.min, o.max
+	if t.count > 0 {
+		min, max = math.Min(t.min, min), math.Max(t.max, max)
+	}
+
+	t.unmerged = append(t.unmerged, o.centroids...)
+	t.unmerged = append(t.unmerged, o.unmerged...)
+	t.count += o.count
+	t.sum += o.sum
+	t.min, t.max = min, max
+	t.isFloat = t.isFloat || o.isFloat
+
+	t.compress()
+}
+
+// compress merges the buffered samples into the centroids, joining
+// neighbours for as long as the scale function allows
+func (t *TDigest) compress() {
+	if len(t.unmerged) == 0 {
+		return
+	}
+
+	all := append(t.centroids, t.unmerged...)
+	sort.Slice(all, func(i, j int) bool { return all[i].mean < all[j].mean })
+
+	merged := make([]centroid, 0, len(t.centroids)+1)
+	merged = append(merged, all[0])
+
+	total := t.count
+	before := 0.0 // weight of every merged centroid but the last
+	limit := total * t.kInverse(t.k(0)+1)
+
+	for _, c := range all[1:] {
+		last := &merged[len(merged)-1]
+
+		if before+last.weight+c.weight <= limit {
+			last.mean += (c.mean - last.mean) * c.weight / (last.weight + c.weight)
+			last.weight += c.weight
+
+			continue
+		}
+
+		before += last.weight
+		limit = total * t.kInverse(t.k(before/total)+1)
+		merged = append(merged, c)
+	}
+
+	t.centroids = merged
+	t.unmerged = t.unmerged[:0]
+}
+
+// k is the k1 scale function, which keeps centroids small near q=0 and
+// q=1
+func (t *TDigest) k(q float64) float64 {
+	return t.compression / (2 * math.Pi) * math.Asin(2*q-1)
+}
+
+// kInverse is the inverse of k, clamped to q=1
+func (t *TDigest) kInverse(k float64) float64 {
+	if k >= t.compression/4 {
+		return 1
+	}
+
+	return (math.Sin(k*2*math.Pi/t.compression) + 1) / 2
+}
+
+// Quantile estimates the value below which a fraction q of the samples
+// fall, interpolating between centroids. It is NaN for an empty digest.
+func (t *TDigest) Quantile(q float64) float64 {
+	t.compress()
+
+	if t.count == 0 {
+		return math.NaN()
+	}
+
+	if q <= 0 {
+		return t.min
+	}
+
+	if q >= 1 {
+		return t.max
+	}
+
+	c := t.centroids
+	if len(c) == 1 {
+		return c[0].mean
+	}
+
+	index := q * t.count
+
+	// Before the middle of the first centroid, between the minimum and it
+	soFar := c[0].weight / 2
+	if index < soFar {
+		return t.clamp(t.min + (c[0].mean-t.min)*index/soFar)
+	}
+
+	for i := 0; i < len(c)-1; i++ {
+		dw := (c[i].weight + c[i+1].weight) / 2
+		if soFar+dw > index {
+			return t.clamp(c[i].mean + (c[i+1].mean-c[i].mean)*(index-soFar)/dw)
+		}
+
+		soFar += dw
+	}
+
+	// After the middle of the last centroid, between it and the maximum
+	last := c[len(c)-1]
+	return t.clamp(last.mean + (t.max-last.mean)*(index-soFar)/(last.weight/2))
+}
+
+func (t *TDigest) clamp(v float64) float64 {
+	return math.Max(t.min, math.Min(t.max, v))
+}
+
+// number returns a derived value in the digest's format
+func (t *TDigest) number(f float64) number {
+	if t.isFloat {
+		return number{f: f, isFloat: true}
+	}
+
+	return number{i: int64(math.Round(f))}
+}
+
+// eachLine sends the same lines as a histogram
+func (t *TDigest) eachLine(name string, fn func(name string, value number)) {
+	if t.count == 0 {
+		return
+	}
+
+	fn(name+".min", t.number(t.min))
+	fn(name+".max", t.number(t.max))
+
+	if t.isFloat {
+		fn(name+".mean", number{f: t.sum / t.count, isFloat: true})
+	} else {
+		fn(name+".mean", number{i: int64(t.sum) / int64(t.count)})
+	}
+
+	for _, p := range histogramPercentiles {
+		fn(name+p.suffix, t.number(t.Quantile(p.p)))
+	}
+}
+
+// MarshalBinary serializes the digest:
+//
+//	version byte, flags byte, then compression, count, sum, min and max
+//	as little-endian float64s, then a uvarint centroid count and the
+//	mean and weight of each centroid as float64s
+func (t *TDigest) MarshalBinary() ([]byte, error) {
+	t.compress()
+
+	var flags byte
+	if t.isFloat {
+		flags |= digestFloat
+	}
+
+	buf := make([]byte, 0, 2+5*8+binary.MaxVarintLen64+len(t.centroids)*16)
+	buf = append(buf, digestVersion, flags)
+
+	for _, f := range []float64{t.compression, t.count, t.sum, t.min, t.max} {
+		buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(f))
+	}
+
+	buf = binary.AppendUvarint(buf, uint64(len(t.centroids)))
+
+	for _, c := range t.centroids {
+		buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(c.mean))
+		buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(c.weight))
+	}
+
+	return buf, nil
+}
+
+// UnmarshalBinary replaces the digest with one serialized by
+// MarshalBinary
+func (t *TDigest) UnmarshalBinary(data []byte) error {
+	if len(data) < 2+5*8 || data[0] != digestVersion || data[1]&^digestFloat != 0 {
+		return ErrInvalidDigest
+	}
+
+	var header [5]float64
+	for i := range header {
+		header[i] = math.Float64frombits(binary.LittleEndian.Uint64(data[2+8*i:]))
+	}
+
+	rest := data[2+5*8:]
+
+	n, size := binary.Uvarint(rest)
+	if size <= 0 || n > uint64(len(rest[size:])/16) || uint64(len(rest[size:])) != n*16 {
+		return ErrInvalidDigest
+	}
+
+	rest = rest[size:]
+
+	weights := 0.0
+
+	centroids := make([]centroid, n)
+	for i := range centroids {
+		centroids[i].mean = math.Float64frombits(binary.LittleEndian.Uint64(rest[16*i:]))
+		centroids[i].weight = math.Float64frombits(binary.LittleEndian.Uint64(rest[16*i+8:]))
+
+		if math.IsNaN(centroids[i].mean) || !(centroids[i].weight > 0) {
+			return ErrInvalidDigest
+		}
+
+		weights += centroids[i].weight
+	}
+
+	// The header has to agree with the centroids it describes
+	if !(header[0] > 0) || header[1] != weights || math.IsNaN(header[3]) || math.IsNaN(header[4]) {
+		return ErrInvalidDigest
+	}
+
+	*t = TDigest{
+		compression: header[0],
+		count:       header[1],
+		sum:         header[2],
+		min:         header[3],
+		max:         header[4],
+		centroids:   centroids,
+		isFloat:     data[1]&digestFloat != 0,
+	}
+
+	return nil
+}
+
+// sketch returns the digest serialized for a sketch line
+func (t *TDigest) sketch() string {
+	b, _ := t.MarshalBinary()
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// ParseTDigest decodes the value of a sketch line sent with
+// WithDigestSketches
+func ParseTDigest(sketch string) (*TDigest, error) {
+	b, err := base64.RawURLEncoding.DecodeString(sketch)
+	if err != nil {
+		return nil, ErrInvalidDigest
+	}
+
+	t := &TDigest{}
+	if err := t.UnmarshalBinary(b); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// WithDigestSketches also sends the t-digest of every metric recorded with
+// Digest, serialized as name:<sketch>|td, for backends that merge sketches
+// from many clients instead of averaging their percentiles. A backend
+// decodes the value with ParseTDigest. Servers that don't know the td unit
+// are likely to reject these lines, so only use it with one that does.
+func WithDigestSketches() Option {
+	return func(c *Client) error {
+		c.digestSketches = true
+		return nil
+	}
+}
@@ -0,0 +1,190 @@
+package buckyclient
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"math/rand/v2"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// uniformDigest adds 1 to n in a shuffled but repeatable order
+func uniformDigest(n int) *TDigest {
+	t := NewTDigest(0)
+	for _, i := range rand.New(rand.NewPCG(1, 2)).Perm(n) {
+		t.Add(float64(i + 1))
+	}
+
+	return t
+}
+
+func TestTDigest_TDigest_Quantile(t *testing.T) {
+	d := uniformDigest(100000)
+
+	for _, q := range []float64{0.01, 0.1, 0.5, 0.9, 0.99, 0.999} {
+		assert.InDelta(t, q*100000, d.Quantile(q), 0.005*100000, "q=%v", q)
+	}
+
+	assert.Equal(t, 1.0, d.Quantile(0))
+	assert.Equal(t, 100000.0, d.Quantile(1))
+	assert.Equal(t, int64(100000), d.Count())
+	assert.True(t, len(d.centroids) < 2*DefaultDigestCompression, "%d centroids", len(d.centroids))
+
+	// The tails are kept more accurately than the middle
+	assert.InDelta(t, 99900, d.Quantile(0.999), 20)
+}
+
+func TestTDigest_TDigest_Quantile_Small(t *testing.T) {
+	d := NewTDigest(0)
+	assert.True(t, math.IsNaN(d.Quantile(0.5)))
+
+	d.Add(7)
+	assert.Equal(t, 7.0, d.Quantile(0.5))
+
+	d.Add(9)
+	assert.Equal(t, 7.0, d.Quantile(0.1))
+	assert.Equal(t, 9.0, d.Quantile(0.9))
+	assert.Equal(t, 8.0, d.Quantile(0.5))
+}
+
+func TestTDigest_TDigest_Merge(t *testing.T) {
+	a, b := NewTDigest(0), NewTDigest(0)
+	for i := 1; i <= 50000; i++ {
+		a.Add(float64(i))
+		b.Add(float64(i + 50000))
+	}
+
+	a.Merge(b)
+	a.Merge(NewTDigest(0))
+
+	assert.Equal(t, int64(100000), a.Count())
+	assert.Equal(t, 1.0, a.Min())
+	assert.Equal(t, 100000.0, a.Max())
+	assert.InDelta(t, 50000, a.Quantile(0.5), 500)
+	assert.InDelta(t, 99000, a.Quantile(0.99), 500)
+}
+
+func TestTDigest_TDigest_MarshalBinary(t *testing.T) {
+	d := uniformDigest(5000)
+
+	data, err := d.MarshalBinary()
+	assert.NoError(t, err)
+
+	out := &TDigest{}
+	assert.NoError(t, out.UnmarshalBinary(data))
+
+	assert.Equal(t, d.Count(), out.Count())
+	assert.Equal(t, d.Sum(), out.Sum())
+	assert.Equal(t, d.Quantile(0.99), out.Quantile(0.99))
+
+	// Decoded digests keep working
+	out.Add(1e6)
+	assert.Equal(t, 1e6, out.Max())
+
+	for _, bad := range [][]byte{nil, {digestVersion + 1}, data[:len(data)-1], append(data, 0)} {
+		assert.ErrorIs(t, (&TDigest{}).UnmarshalBinary(bad), ErrInvalidDigest)
+	}
+
+	// A header that doesn't match the centroids
+	wrongCount := append([]byte(nil), data...)
+	binary.LittleEndian.PutUint64(wrongCount[2+8:], math.Float64bits(4999))
+	assert.ErrorIs(t, (&TDigest{}).UnmarshalBinary(wrongCount), ErrInvalidDigest)
+
+	nanMin := append([]byte(nil), data...)
+	binary.LittleEndian.PutUint64(nanMin[2+3*8:], math.Float64bits(math.NaN()))
+	assert.ErrorIs(t, (&TDigest{}).UnmarshalBinary(nanMin), ErrInvalidDigest)
+
+	unknownFlags := append([]byte(nil), data...)
+	unknownFlags[1] = 0x80
+	assert.ErrorIs(t, (&TDigest{}).UnmarshalBinary(unknownFlags), ErrInvalidDigest)
+
+	_, err = ParseTDigest("not base64!")
+	assert.ErrorIs(t, err, ErrInvalidDigest)
+}
+
+func TestTDigest_TDigest_MarshalBinary_Float(t *testing.T) {
+	d := NewTDigest(0)
+	d.add(Amount{Float: 1.5, IsFloat: true})
+	d.add(Amount{Float: 2.25, IsFloat: true})
+
+	data, err := d.MarshalBinary()
+	assert.NoError(t, err)
+
+	out := &TDigest{}
+	assert.NoError(t, out.UnmarshalBinary(data))
+
+	// The lines of a decoded digest aren't rounded
+	var lines []string
+	out.eachLine("latency", func(name string, value number) {
+		var scratch [32]byte
+		lines = append(lines, name+":"+string(value.append(scratch[:0])))
+	})
+
+	assert.Contains(t, lines, "latency.min:1.5")
+	assert.Contains(t, lines, "latency.max:2.25")
+}
+
+func TestTDigest_Client_Digest(t *testing.T) {
+	c := newBatchingClient(1)
+
+	for i := 1; i <= 100; i++ {
+		c.Digest("db.query", i)
+	}
+
+	buf := &bytes.Buffer{}
+	c.formatMetricsForFlush(buf)
+	assert.ElementsMatch(t, []string{
+		"db.query.min:1|ms",
+		"db.query.max:100|ms",
+		"db.query.mean:50|ms",
+		"db.query.p50:51|ms",
+		"db.query.p90:91|ms",
+		"db.query.p99:100|ms",
+	}, splitLines(buf.String()))
+	assert.Equal(t, 6, c.PendingLines())
+}
+
+func TestTDigest_WithDigestSketches(t *testing.T) {
+	c := newBatchingClient(1)
+	assert.NoError(t, WithDigestSketches()(c))
+	assert.NoError(t, WithAggregation(ActionDigest, "latency")(c))
+
+	for i := 1; i <= 1000; i++ {
+		c.Timer("latency", i, Tag{"env", "prod"})
+	}
+
+	buf := &bytes.Buffer{}
+	c.formatMetricsForFlush(buf)
+
+	var sketch string
+	for _, line := range splitLines(buf.String()) {
+		if strings.HasPrefix(line, "latency:") {
+			sketch = line
+		}
+	}
+
+	assert.True(t, strings.HasSuffix(sketch, "|td|#env:prod"), sketch)
+	assert.Equal(t, 7, c.PendingLines())
+	assert.Equal(t, buf.Len(), c.PendingBytesEstimate())
+
+	d, err := ParseTDigest(strings.TrimSuffix(strings.TrimPrefix(sketch, "latency:"), "|td|#env:prod"))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1000), d.Count())
+	assert.InDelta(t, 990, d.Quantile(0.99), 5)
+}
+
+func TestTDigest_Client_WriteOpenMetrics(t *testing.T) {
+	c := newBatchingClient(1)
+	c.Digest("rpc", 10)
+	c.Digest("rpc", 20)
+
+	buf := &bytes.Buffer{}
+	assert.NoError(t, c.WriteOpenMetrics(buf))
+
+	assert.Contains(t, buf.String(), "# TYPE rpc summary\n")
+	assert.Contains(t, buf.String(), "rpc_sum 30\n")
+	assert.Contains(t, buf.String(), "rpc_count 2\n")
+}
@@ -0,0 +1,108 @@
+package buckyclient
+
+import (
+	"bytes"
+	"strings"
+	"sync/atomic"
+)
+
+// DefaultTelemetryPrefix is where WithTelemetry reports unless it is given
+// a prefix
+const DefaultTelemetryPrefix = "buckyclient.internal"
+
+// The metrics WithTelemetry reports, after its prefix and a dot
+const (
+	// TelemetryFlushes counts the posts attempted, retries included
+	TelemetryFlushes = "flushes"
+
+	// TelemetryFlushFailures counts the posts that failed
+	TelemetryFlushFailures = "flush_failures"
+
+	// TelemetryMetricsSent counts the lines of the posts that succeeded
+	TelemetryMetricsSent = "metrics_sent"
+
+	// TelemetryBytesSent counts the bytes of the posts that succeeded
+	TelemetryBytesSent = "bytes_sent"
+
+	// TelemetryQueueDepth is how many samples were waiting to be aggregated
+	TelemetryQueueDepth = "queue_depth"
+
+	// TelemetryRetryQueue is how many payloads were waiting to be retried
+	TelemetryRetryQueue = "retry_queue"
+
+	// TelemetryDropped counts the samples and aggregates dropped for any
+	// reason: a full input buffer, WithRecordBudget, WithMaxMetrics or
+	// WithMergeOnFailure
+	TelemetryDropped = "dropped"
+)
+
+// WithTelemetry has the client report its own health with every default
+// flush, so the pipeline itself can be alerted on: the counters cover the
+// posts since the last report, and the queue depths are gauges taken as
+// the flush starts. Use an empty prefix for DefaultTelemetryPrefix.
+func WithTelemetry(prefix string) Option {
+	return func(c *Client) error {
+		prefix = strings.TrimSuffix(prefix, ".")
+		if prefix == "" {
+			prefix = DefaultTelemetryPrefix
+		}
+
+		c.telemetry = prefix
+		return nil
+	}
+}
+
+// countPost tracks a post attempted for WithTelemetry
+func (c *Client) countPost(payload []byte, err error) {
+	if c.telemetry == "" {
+		return
+	}
+
+	atomic.AddUint64(&c.postsAttempted, 1)
+
+	if err != nil {
+		atomic.AddUint64(&c.postsFailed, 1)
+		return
+	}
+
+	atomic.AddUint64(&c.linesSent, uint64(bytes.Count(payload, []byte("\n"))))
+	atomic.AddUint64(&c.bytesSent, uint64(len(payload)))
+}
+
+// addTelemetry adds the metrics of WithTelemetry. It runs before the other
+// metrics counting drops are added, as they reset their counts - c.m must
+// be held.
+func (c *Client) addTelemetry() {
+	if c.telemetry == "" {
+		return
+	}
+
+	count := func(name string, value uint64) {
+		c.aggregate(MetricWithAmount{Metric{name: c.telemetry + "." + name, unit: UnitCount, tags: c.telemetryTags}, Amount{Value: int(value)}, ActionSum})
+	}
+
+	gauge := func(name string, value int) {
+		c.aggregate(MetricWithAmount{Metric{name: c.telemetry + "." + name, unit: UnitGauge, tags: c.telemetryTags}, Amount{Value: value}, ActionLast})
+	}
+
+	count(TelemetryFlushes, atomic.SwapUint64(&c.postsAttempted, 0))
+	count(TelemetryFlushFailures, atomic.SwapUint64(&c.postsFailed, 0))
+	count(TelemetryMetricsSent, atomic.SwapUint64(&c.linesSent, 0))
+	count(TelemetryBytesSent, atomic.SwapUint64(&c.bytesSent, 0))
+
+	count(TelemetryDropped, atomic.LoadUint64(&c.inputDropped)+
+		atomic.LoadUint64(&c.budgetDropped)+
+		atomic.LoadUint64(&c.cardinalityDropped)+
+		uint64(c.mergeDropped))
+
+	gauge(TelemetryQueueDepth, len(c.input))
+
+	retryQueue := 0
+	if c.spool != nil {
+		c.spool.m.Lock()
+		retryQueue = len(c.spool.entries)
+		c.spool.m.Unlock()
+	}
+
+	gauge(TelemetryRetryQueue, retryQueue)
+}
@@ -0,0 +1,61 @@
+package buckyclient
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTelemetry_WithTelemetry(t *testing.T) {
+	c := &Client{}
+
+	assert.NoError(t, WithTelemetry("")(c))
+	assert.Equal(t, DefaultTelemetryPrefix, c.telemetry)
+
+	assert.NoError(t, WithTelemetry("app.bucky.")(c))
+	assert.Equal(t, "app.bucky", c.telemetry)
+}
+
+func TestTelemetry_Client_addTelemetry(t *testing.T) {
+	rt := &recordingTransport{}
+	c := newRetryClient(rt, WithTelemetry("self"), WithRetryQueue(time.Minute, 1<<20))
+	c.input = make(chan MetricWithAmount, 10)
+
+	c.aggregate(MetricWithAmount{Metric{name: "hits", unit: UnitCount}, Amount{Value: 1}, ActionSum})
+	assert.NoError(t, c.flush())
+
+	// Nothing was posted before the first flush
+	first := splitLines(rt.payloads[0])
+	assert.Contains(t, first, "self.flushes:0|c")
+	assert.Contains(t, first, "self.queue_depth:0|g")
+	assert.Len(t, first, 8)
+
+	// The next flush reports the first post, although this one fails
+	rt.err = errors.New("unreachable")
+	c.aggregate(MetricWithAmount{Metric{name: "hits", unit: UnitCount}, Amount{Value: 1}, ActionSum})
+	assert.ErrorIs(t, c.flush(), rt.err)
+
+	second := splitLines(rt.payloads[1])
+	assert.Contains(t, second, "self.flushes:1|c")
+	assert.Contains(t, second, "self.flush_failures:0|c")
+	assert.Contains(t, second, "self.metrics_sent:8|c")
+	assert.Contains(t, second, "self.bytes_sent:"+strconv.Itoa(len(rt.payloads[0]))+"|c")
+
+	c.Count("waiting", 1)
+	c.inputDropped = 2
+	c.cardinalityDropped = 1
+
+	rt.err = nil
+	assert.NoError(t, c.flush())
+
+	lines := splitLines(rt.payloads[len(rt.payloads)-1])
+	assert.Contains(t, lines, "self.flushes:1|c")
+	assert.Contains(t, lines, "self.flush_failures:1|c")
+	assert.Contains(t, lines, "self.metrics_sent:0|c")
+	assert.Contains(t, lines, "self.dropped:3|c")
+	assert.Contains(t, lines, "self.queue_depth:1|g")
+	assert.Contains(t, lines, "self.retry_queue:1|g")
+}
@@ -0,0 +1,71 @@
+package buckyclient
+
+import (
+	"net/http"
+	"time"
+)
+
+// WindowTimeFormat is used for window boundaries. Times are always in UTC
+// and always carry an explicit +00:00 offset, so receivers never have to
+// guess the time zone of the sending container.
+const WindowTimeFormat = "2006-01-02T15:04:05.000-07:00"
+
+const (
+	// WindowStartHeader carries the start of the window in a flush request
+	WindowStartHeader = "X-Bucky-Window-Start"
+
+	// WindowEndHeader carries the end of the window in a flush request
+	WindowEndHeader = "X-Bucky-Window-End"
+)
+
+// Window is the period of time the metrics in a flush were recorded over
+type Window struct {
+	Start time.Time
+	End   time.Time
+}
+
+// newWindow returns a window with both boundaries in UTC
+func newWindow(start, end time.Time) Window {
+	return Window{Start: start.UTC(), End: end.UTC()}
+}
+
+// Duration is the real time elapsed in the window, which is unaffected
+// by daylight saving changes in the local time zone
+func (w Window) Duration() time.Duration {
+	return w.End.Sub(w.Start)
+}
+
+func (w Window) String() string {
+	return w.Start.UTC().Format(WindowTimeFormat) + "/" + w.End.UTC().Format(WindowTimeFormat)
+}
+
+// setHeaders adds the window boundaries to a flush request
+func (w Window) setHeaders(h http.Header) {
+	if w.Start.IsZero() {
+		return
+	}
+
+	h.Set(WindowStartHeader, w.Start.UTC().Format(WindowTimeFormat))
+	h.Set(WindowEndHeader, w.End.UTC().Format(WindowTimeFormat))
+}
+
+// closeWindow ends the current window for w, or for every metric when w
+// is nil, and starts the next one
+func (c *Client) closeWindow(w *window, now time.Time) Window {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	start := &c.windowStart
+	if w != nil && w.units != nil {
+		start = &w.start
+	}
+
+	if start.IsZero() {
+		*start = now
+	}
+
+	window := newWindow(*start, now)
+	*start = now
+
+	return window
+}
@@ -0,0 +1,86 @@
+package buckyclient
+
+import (
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	_ "time/tzdata" // so the DST tests don't depend on the host's zoneinfo
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimeWindow_Window_SpringForward(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	assert.NoError(t, err)
+
+	// Clocks jump from 02:00 EST to 03:00 EDT, so only an hour passes
+	start := time.Date(2026, 3, 8, 1, 30, 0, 0, ny)
+	end := time.Date(2026, 3, 8, 3, 30, 0, 0, ny)
+
+	w := newWindow(start, end)
+
+	assert.Equal(t, time.Hour, w.Duration())
+	assert.Equal(t, "2026-03-08T06:30:00.000+00:00/2026-03-08T07:30:00.000+00:00", w.String())
+}
+
+func TestTimeWindow_Window_FallBack(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	assert.NoError(t, err)
+
+	// 01:30 happens twice, an hour apart, when clocks go back
+	first := time.Date(2026, 11, 1, 5, 30, 0, 0, time.UTC).In(ny)
+	second := first.Add(time.Hour)
+	assert.Equal(t, first.Hour(), second.Hour())
+
+	w := newWindow(first, second)
+
+	assert.Equal(t, time.Hour, w.Duration())
+	assert.Equal(t, "2026-11-01T05:30:00.000+00:00/2026-11-01T06:30:00.000+00:00", w.String())
+}
+
+func TestTimeWindow_Client_closeWindow(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.FixedZone("CET", 3600))
+	cl := &Client{windowStart: start}
+
+	first := cl.closeWindow(nil, start.Add(time.Minute))
+	second := cl.closeWindow(nil, start.Add(2*time.Minute))
+
+	assert.Equal(t, time.UTC, first.Start.Location())
+	assert.True(t, first.Start.Equal(start))
+	assert.Equal(t, first.End, second.Start)
+	assert.Equal(t, time.Minute, second.Duration())
+}
+
+func TestTimeWindow_Client_flush_SetsHeaders(t *testing.T) {
+	requests := make(chan *http.Request, 1)
+
+	mockBucky := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests <- r
+	}))
+	defer mockBucky.Close()
+
+	start := time.Now().Add(-time.Minute)
+
+	cl := &Client{
+		hostURL:     mockBucky.URL,
+		http:        &http.Client{},
+		logger:      log.New(ioutil.Discard, "", 0),
+		metrics:     make(map[Metric]Value),
+		bufferPool:  newBufferPool(),
+		windowStart: start,
+	}
+	cl.metrics[Metric{name: "myapp.facet", unit: UnitCount}] = Value{Sum: &Sum{Value: 1}}
+
+	assert.NoError(t, cl.flush())
+
+	r := <-requests
+	assert.Equal(t, start.UTC().Format(WindowTimeFormat), r.Header.Get(WindowStartHeader))
+	assert.Contains(t, r.Header.Get(WindowEndHeader), "+00:00")
+
+	end, err := time.Parse(WindowTimeFormat, r.Header.Get(WindowEndHeader))
+	assert.NoError(t, err)
+	assert.True(t, end.After(start))
+}
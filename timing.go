@@ -0,0 +1,132 @@
+package buckyclient
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// TimerResolution is how precisely durations given to the timer methods
+// that take a time.Duration are sent
+type TimerResolution int
+
+const (
+	// TimerResolutionMicrosecond sends fractional milliseconds to the
+	// microsecond, e.g. 1.234|ms
+	TimerResolutionMicrosecond TimerResolution = iota
+	// TimerResolutionMillisecond rounds to whole milliseconds, as Timer
+	// sends them
+	TimerResolutionMillisecond
+)
+
+// WithTimerResolution sets how precisely durations are sent by
+// TimerDuration and the other methods taking a time.Duration, Time and
+// StartTimer. The default is TimerResolutionMicrosecond.
+func WithTimerResolution(r TimerResolution) Option {
+	return func(c *Client) error {
+		if r != TimerResolutionMicrosecond && r != TimerResolutionMillisecond {
+			return invalidOption("WithTimerResolution", "unknown resolution")
+		}
+
+		c.timerResolution = r
+		return nil
+	}
+}
+
+// TimerDuration is Timer for a time.Duration, e.g. time.Since(start)
+func (c *Client) TimerDuration(name string, d time.Duration, tags ...Tag) {
+	tags = c.withTags(tags)
+	c, name = c.scoped(name)
+	c.logCaller(name)
+	c.recordDuration(name, d, ActionSum, tags)
+}
+
+// AverageTimerDuration is AverageTimer for a time.Duration
+func (c *Client) AverageTimerDuration(name string, d time.Duration, tags ...Tag) {
+	tags = c.withTags(tags)
+	c, name = c.scoped(name)
+	c.logCaller(name)
+	c.recordDuration(name, d, ActionAvg, tags)
+}
+
+// HistogramDuration is Histogram for a time.Duration
+func (c *Client) HistogramDuration(name string, d time.Duration, tags ...Tag) {
+	tags = c.withTags(tags)
+	c, name = c.scoped(name)
+	c.logCaller(name)
+	c.recordDuration(name, d, ActionHistogram, tags)
+}
+
+// DigestDuration is Digest for a time.Duration
+func (c *Client) DigestDuration(name string, d time.Duration, tags ...Tag) {
+	tags = c.withTags(tags)
+	c, name = c.scoped(name)
+	c.logCaller(name)
+	c.recordDuration(name, d, ActionDigest, tags)
+}
+
+// recordDuration records a timer sample at the configured resolution
+func (c *Client) recordDuration(name string, d time.Duration, action Action, tags []Tag) {
+	if c.timerResolution == TimerResolutionMillisecond {
+		c.record(name, int(d.Round(time.Millisecond)/time.Millisecond), UnitMillisecond, action, tags)
+		return
+	}
+
+	c.recordFloat(name, math.Round(durationMillis(d)*1000)/1000, UnitMillisecond, action, tags)
+}
+
+// Time runs fn and records how long it took with AverageTimerDuration,
+// and returns the time too. The time is taken even if fn panics.
+func (c *Client) Time(name string, fn func(), tags ...Tag) time.Duration {
+	tags = c.withTags(tags)
+	c, name = c.scoped(name)
+	c.logCaller(name)
+
+	sw := &Stopwatch{c: c, name: name, tags: tags, start: time.Now()}
+	defer sw.Stop()
+
+	fn()
+
+	return sw.Stop()
+}
+
+// StartTimer starts a Stopwatch that records the time until its Stop is
+// called, as Time does:
+//
+//	sw := c.StartTimer("db.query")
+//	defer sw.Stop()
+func (c *Client) StartTimer(name string, tags ...Tag) *Stopwatch {
+	tags = c.withTags(tags)
+	c, name = c.scoped(name)
+	c.logCaller(name)
+
+	return &Stopwatch{c: c, name: name, tags: tags, start: time.Now()}
+}
+
+// Stopwatch measures one timing for StartTimer. It is safe to stop from
+// another goroutine than the one that started it.
+type Stopwatch struct {
+	c     *Client
+	name  string
+	tags  []Tag
+	start time.Time
+
+	once    sync.Once
+	elapsed time.Duration
+}
+
+// Stop records the time since the stopwatch started and returns it. Only
+// the first call records anything; later ones return the same time.
+func (sw *Stopwatch) Stop() time.Duration {
+	sw.once.Do(func() {
+		sw.elapsed = time.Since(sw.start)
+		sw.c.recordDuration(sw.name, sw.elapsed, ActionAvg, sw.tags)
+	})
+
+	return sw.elapsed
+}
+
+// durationMillis returns d in fractional milliseconds
+func durationMillis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,98 @@
+package buckyclient
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTiming_Client_Time(t *testing.T) {
+	c := newBatchingClient(1)
+
+	elapsed := c.Scope("jobs").Time("run", func() { time.Sleep(5 * time.Millisecond) }, Tag{"queue", "a"})
+	assert.True(t, elapsed >= 5*time.Millisecond, "%s", elapsed)
+
+	c.drainBatches()
+
+	avg := c.metrics[Metric{name: "jobs.run", unit: UnitMillisecond, tags: "queue:a"}].Avg
+	if assert.NotNil(t, avg) {
+		assert.Equal(t, int64(1), avg.Count)
+		assert.True(t, avg.IsFloat)
+		assert.InDelta(t, durationMillis(elapsed), avg.FloatTotal, 0.001)
+	}
+
+	// A panicking function is still timed
+	assert.Panics(t, func() { c.Time("panics", func() { panic("boom") }) })
+	assert.Equal(t, 2, c.PendingLines())
+}
+
+func TestTiming_Client_StartTimer(t *testing.T) {
+	c := newBatchingClient(1)
+
+	sw := c.StartTimer("query")
+	time.Sleep(2 * time.Millisecond)
+
+	var wg sync.WaitGroup
+	elapsed := make([]time.Duration, 4)
+	for i := range elapsed {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			elapsed[i] = sw.Stop()
+		}(i)
+	}
+	wg.Wait()
+
+	// Only the first Stop records, and every one returns its time
+	for _, e := range elapsed {
+		assert.Equal(t, elapsed[0], e)
+	}
+
+	assert.True(t, elapsed[0] >= 2*time.Millisecond)
+
+	c.drainBatches()
+	assert.Equal(t, int64(1), c.metrics[Metric{name: "query", unit: UnitMillisecond}].Avg.Count)
+}
+
+func TestTiming_Client_TimerDuration(t *testing.T) {
+	c := newBatchingClient(1)
+
+	c.TimerDuration("rounded", 1234567*time.Nanosecond)
+	c.TimerDuration("t", 1500*time.Microsecond)
+	c.TimerDuration("t", 1*time.Millisecond)
+	c.AverageTimerDuration("avg", 2500*time.Microsecond)
+	c.HistogramDuration("hist", 3*time.Millisecond)
+	c.DigestDuration("digest", 3*time.Millisecond)
+
+	buf := &bytes.Buffer{}
+	c.formatMetricsForFlush(buf)
+
+	lines := splitLines(buf.String())
+	assert.Contains(t, lines, "rounded:1.235|ms")
+	assert.Contains(t, lines, "t:2.5|ms")
+	assert.Contains(t, lines, "avg:2.5|ms")
+	assert.Contains(t, lines, "hist.p99:3|ms")
+	assert.Contains(t, lines, "digest.max:3|ms")
+}
+
+func TestTiming_WithTimerResolution(t *testing.T) {
+	c := newBatchingClient(1)
+	assert.NoError(t, WithTimerResolution(TimerResolutionMillisecond)(c))
+	assert.ErrorIs(t, WithTimerResolution(TimerResolution(9))(c), ErrInvalidOption)
+
+	c.TimerDuration("t", 1600*time.Microsecond)
+	c.TimerDuration("t", 1400*time.Microsecond)
+	c.StartTimer("sw").Stop()
+
+	buf := &bytes.Buffer{}
+	c.formatMetricsForFlush(buf)
+	assert.ElementsMatch(t, []string{"t:3|ms", "sw:0|ms"}, splitLines(buf.String()))
+}
+
+func TestTiming_durationMillis(t *testing.T) {
+	assert.Equal(t, 1.5, durationMillis(1500*time.Microsecond))
+	assert.Equal(t, 2000.0, durationMillis(2*time.Second))
+}
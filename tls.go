@@ -0,0 +1,19 @@
+package buckyclient
+
+import "crypto/tls"
+
+// WithTLSConfig sets the TLS configuration flushes to an https bucky server
+// use: Certificates for a server that requires mutual TLS, RootCAs for
+// one with a certificate from a private CA, or InsecureSkipVerify for a
+// self-signed development server. The config is copied, so changing it
+// afterwards has no effect. It can't be combined with WithRoundTripper.
+func WithTLSConfig(config *tls.Config) Option {
+	return func(c *Client) error {
+		if config == nil {
+			return invalidOption("WithTLSConfig", "config must not be nil")
+		}
+
+		c.tlsConfig = config.Clone()
+		return nil
+	}
+}
@@ -0,0 +1,69 @@
+package buckyclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTLS_WithTLSConfig_MutualTLS(t *testing.T) {
+	requests := make(chan *http.Request, 1)
+
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests <- r
+	}))
+	ts.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	ts.StartTLS()
+	defer ts.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ts.Certificate())
+
+	// The test server's own certificate doubles as the client's
+	config := &tls.Config{
+		RootCAs:      roots,
+		Certificates: ts.TLS.Certificates,
+	}
+
+	cl, errs := newClient(ts.URL, DefaultInterval, []Option{WithTLSConfig(config)})
+	assert.Empty(t, errs)
+	cl.SetLogger(log.New(ioutil.Discard, "", 0))
+
+	// Later changes don't reach the client
+	config.RootCAs = nil
+
+	cl.metrics[Metric{name: "hits", unit: UnitCount}] = Value{Sum: &Sum{Value: 1}}
+	assert.NoError(t, cl.flush())
+
+	r := <-requests
+	assert.Len(t, r.TLS.PeerCertificates, 1)
+}
+
+func TestTLS_WithTLSConfig_UnknownAuthority(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer ts.Close()
+
+	cl, errs := newClient(ts.URL, DefaultInterval, []Option{WithTLSConfig(&tls.Config{})})
+	assert.Empty(t, errs)
+	cl.SetLogger(log.New(ioutil.Discard, "", 0))
+
+	cl.metrics[Metric{name: "hits", unit: UnitCount}] = Value{Sum: &Sum{Value: 1}}
+
+	var unknown x509.UnknownAuthorityError
+	assert.True(t, errors.As(cl.flush(), &unknown))
+}
+
+func TestTLS_WithTLSConfig_Invalid(t *testing.T) {
+	assert.ErrorIs(t, WithTLSConfig(nil)(&Client{}), ErrInvalidOption)
+
+	_, errs := newClient("https://localhost", DefaultInterval, []Option{WithTLSConfig(&tls.Config{}), WithRoundTripper(http.DefaultTransport)})
+	assert.Len(t, errs, 1)
+	assert.ErrorIs(t, errs[0], ErrInvalidOption)
+}
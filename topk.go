@@ -0,0 +1,103 @@
+package buckyclient
+
+import (
+	"strings"
+)
+
+// DefaultTopK is how many keys TopK tracks per metric unless WithTopK is used
+const DefaultTopK = 10
+
+// spaceSaving tracks the most frequent keys in bounded memory using the
+// space-saving algorithm. When a new key arrives and every slot is taken
+// the least frequent key is replaced, and the newcomer inherits its count.
+type spaceSaving struct {
+	k      int
+	counts map[string]int64
+}
+
+func newSpaceSaving(k int) *spaceSaving {
+	return &spaceSaving{k: k, counts: make(map[string]int64, k)}
+}
+
+func (s *spaceSaving) add(key string) {
+	if _, ok := s.counts[key]; ok || len(s.counts) < s.k {
+		s.counts[key]++
+		return
+	}
+
+	minKey, minCount := "", int64(-1)
+	for k, count := range s.counts {
+		if minCount < 0 || count < minCount {
+			minKey, minCount = k, count
+		}
+	}
+
+	delete(s.counts, minKey)
+	s.counts[key] = minCount + 1
+}
+
+// keyReplacer makes keys safe to use as a single part of a metric name
+var keyReplacer = strings.NewReplacer(".", "_", ":", "_", "|", "_", " ", "_", "\n", "_")
+
+// TopK returns nothing and counts a key against name, keeping only the K
+// most frequent keys each interval. Every flush sends a name.<key> counter
+// for each tracked key, so hot endpoints or customers can be reported
+// without an unbounded number of series.
+func (c *Client) TopK(name, key string, tags ...Tag) {
+	tags = c.withTags(tags)
+	c, name = c.scoped(name)
+	c.logCaller(name)
+
+	if !c.Enabled() {
+		return
+	}
+
+	m := Metric{name: c.normalizeName(name), unit: UnitCount, tags: canonicalTags(tags)}
+
+	c.topkMu.Lock()
+	defer c.topkMu.Unlock()
+
+	if c.topks == nil {
+		c.topks = make(map[Metric]*spaceSaving)
+	}
+
+	s, ok := c.topks[m]
+	if !ok {
+		k := c.topK
+		if k == 0 {
+			k = DefaultTopK
+		}
+
+		s = newSpaceSaving(k)
+		c.topks[m] = s
+	}
+
+	s.add(key)
+}
+
+// addTopKs turns the tracked keys into counters and starts a new
+// interval - c.m must be held
+func (c *Client) addTopKs() {
+	c.topkMu.Lock()
+	defer c.topkMu.Unlock()
+
+	for m, s := range c.topks {
+		for key, count := range s.counts {
+			c.aggregate(MetricWithAmount{Metric{name: m.name + "." + keyReplacer.Replace(key), unit: UnitCount, tags: m.tags}, Amount{Value: int(count)}, ActionSum})
+		}
+	}
+
+	c.topks = nil
+}
+
+// WithTopK sets how many keys TopK tracks for each metric
+func WithTopK(k int) Option {
+	return func(c *Client) error {
+		if k <= 0 {
+			return invalidOption("WithTopK", "k must be positive")
+		}
+
+		c.topK = k
+		return nil
+	}
+}
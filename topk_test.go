@@ -0,0 +1,50 @@
+package buckyclient
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTopK_spaceSaving_add(t *testing.T) {
+	s := newSpaceSaving(2)
+
+	for i := 0; i < 5; i++ {
+		s.add("hot")
+	}
+	s.add("warm")
+	s.add("warm")
+	s.add("cold") // replaces warm, inheriting its count
+
+	assert.Len(t, s.counts, 2)
+	assert.Equal(t, int64(5), s.counts["hot"])
+	assert.Equal(t, int64(3), s.counts["cold"])
+}
+
+func TestTopK_Client_TopK(t *testing.T) {
+	cl := &Client{metrics: make(map[Metric]Value)}
+	assert.NoError(t, WithTopK(2)(cl))
+
+	for i := 0; i < 3; i++ {
+		cl.TopK("myapp.endpoints", "/users")
+	}
+	cl.TopK("myapp.endpoints", "/orders.json")
+	cl.TopK("myapp.endpoints", "/orders.json")
+
+	cl.addTopKs()
+
+	buf := &bytes.Buffer{}
+	cl.formatMetricsForFlush(buf)
+
+	assert.Contains(t, buf.String(), "myapp.endpoints./users:3|c\n")
+	assert.Contains(t, buf.String(), "myapp.endpoints./orders_json:2|c\n")
+
+	// Each interval starts again
+	assert.Nil(t, cl.topks)
+}
+
+func TestTopK_WithTopK_Invalid(t *testing.T) {
+	assert.True(t, errors.Is(WithTopK(0)(&Client{}), ErrInvalidOption))
+}
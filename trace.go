@@ -0,0 +1,131 @@
+package buckyclient
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// TraceEvent describes one recorded sample and what it did to its metric
+type TraceEvent struct {
+	Name   string
+	Unit   Unit
+	Action Action
+	Amount Amount
+
+	// Lines is what the metric would send if it was flushed now, after the
+	// sample was aggregated
+	Lines []string
+
+	// Err is set if the sample was rejected or overflowed
+	Err error
+}
+
+func (e TraceEvent) String() string {
+	sample := number{i: int64(e.Amount.Value), f: e.Amount.Float, isFloat: e.Amount.IsFloat, signed: e.Amount.Delta}.append(nil)
+	switch e.Action {
+	case ActionRatio:
+		sample = append(append(sample, '/'), fmt.Sprint(e.Amount.Denominator)...)
+	case ActionUnique:
+		sample = []byte(strconv.Quote(e.Amount.Member))
+	}
+
+	s := fmt.Sprintf("trace %s unit=%s action=%s sample=%s aggregate=[%s]", e.Name, e.Unit, e.Action, sample, strings.Join(e.Lines, " "))
+	if e.Err != nil {
+		s += " error=" + e.Err.Error()
+	}
+
+	return s
+}
+
+// tracer holds where trace events go
+type tracer struct {
+	m   sync.Mutex
+	log bool
+	ch  chan<- TraceEvent
+}
+
+// SetTraceLog turns logging every recorded sample on or off at runtime.
+// Each sample is logged with its name, unit, action and the lines its
+// metric would now send, which helps with finding out why a value isn't
+// what was expected. It is slow and noisy, so only for debugging.
+func (c *Client) SetTraceLog(enabled bool) {
+	c = c.root()
+
+	c.tracer.m.Lock()
+	c.tracer.log = enabled
+	c.updateTracing()
+	c.tracer.m.Unlock()
+}
+
+// SetTraceChan streams every recorded sample to ch, or stops streaming if
+// ch is nil. Events are dropped rather than waited for when ch is full, so
+// give it a buffer.
+func (c *Client) SetTraceChan(ch chan<- TraceEvent) {
+	c = c.root()
+
+	c.tracer.m.Lock()
+	c.tracer.ch = ch
+	c.updateTracing()
+	c.tracer.m.Unlock()
+}
+
+// updateTracing sets the fast path flag - c.tracer.m must be held
+func (c *Client) updateTracing() {
+	var on int32
+	if c.tracer.log || c.tracer.ch != nil {
+		on = 1
+	}
+
+	atomic.StoreInt32(&c.tracing, on)
+}
+
+func (c *Client) tracingEnabled() bool {
+	return atomic.LoadInt32(&c.tracing) == 1
+}
+
+// traceEvent describes an aggregated sample - c.m must be held
+func (c *Client) traceEvent(metric MetricWithAmount, err error) TraceEvent {
+	e := TraceEvent{
+		Name:   metric.name,
+		Unit:   metric.unit,
+		Action: metric.Action,
+		Amount: metric.Amount,
+		Err:    err,
+	}
+
+	if v, ok := c.metrics[metric.Metric]; ok {
+		buf := &bytes.Buffer{}
+		v.eachLine(metric.name, func(name string, value number) {
+			buf.Reset()
+			writeTaggedLine(buf, name, value, metric.unit, metric.tags, c.tagFormat)
+			e.Lines = append(e.Lines, strings.TrimSuffix(buf.String(), "\n"))
+		})
+	}
+
+	return e
+}
+
+// emitTrace logs and streams trace events. It must be called without c.m
+// held, as the logger and channel may be slow.
+func (c *Client) emitTrace(events []TraceEvent) {
+	c.tracer.m.Lock()
+	log, ch := c.tracer.log, c.tracer.ch
+	c.tracer.m.Unlock()
+
+	for _, e := range events {
+		if log {
+			c.logger.Println(e)
+		}
+
+		if ch != nil {
+			select {
+			case ch <- e:
+			default:
+			}
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package buckyclient
+
+import (
+	"bytes"
+	"log"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrace_Client_SetTraceChan(t *testing.T) {
+	c := &Client{metrics: make(map[Metric]Value)}
+
+	events := make(chan TraceEvent, 10)
+	c.SetTraceChan(events)
+
+	c.handleMetricWithValue(MetricWithAmount{Metric{name: "a", unit: UnitCount}, Amount{Value: 2}, ActionSum})
+	c.handleMetricWithValue(MetricWithAmount{Metric{name: "a", unit: UnitCount}, Amount{Value: 3}, ActionSum})
+	c.handleMetricWithValue(MetricWithAmount{Metric{name: "r", unit: UnitGauge}, Amount{Value: 1, Denominator: 4}, ActionRatio})
+
+	e := <-events
+	assert.Equal(t, []string{"a:2|c"}, e.Lines)
+
+	e = <-events
+	assert.Equal(t, "a", e.Name)
+	assert.Equal(t, UnitCount, e.Unit)
+	assert.Equal(t, ActionSum, e.Action)
+	assert.Equal(t, 3, e.Amount.Value)
+	assert.Equal(t, []string{"a:5|c"}, e.Lines)
+	assert.Equal(t, "trace a unit=c action=sum sample=3 aggregate=[a:5|c]", e.String())
+
+	e = <-events
+	assert.Equal(t, "trace r unit=g action=ratio sample=1/4 aggregate=[r:25|g]", e.String())
+
+	c.handleMetricWithValue(MetricWithAmount{Metric{name: "u", unit: UnitSet}, Amount{Member: "bob"}, ActionUnique})
+	e = <-events
+	assert.Equal(t, `trace u unit=s action=unique sample="bob" aggregate=[u:1|s]`, e.String())
+
+	// Turned off at runtime
+	c.SetTraceChan(nil)
+	c.handleMetricWithValue(MetricWithAmount{Metric{name: "a", unit: UnitCount}, Amount{Value: 1}, ActionSum})
+	assert.Len(t, events, 0)
+}
+
+func TestTrace_Client_SetTraceChan_Full(t *testing.T) {
+	c := &Client{metrics: make(map[Metric]Value)}
+
+	events := make(chan TraceEvent)
+	c.SetTraceChan(events)
+
+	// Nothing is reading, so the event is dropped instead of blocking
+	c.handleMetricWithValue(MetricWithAmount{Metric{name: "a", unit: UnitCount}, Amount{Value: 1}, ActionSum})
+}
+
+func TestTrace_Client_SetTraceLog(t *testing.T) {
+	buf := &bytes.Buffer{}
+	c := &Client{metrics: make(map[Metric]Value), logger: log.New(buf, "", 0)}
+
+	c.SetTraceLog(true)
+	c.handleMetricWithValue(MetricWithAmount{Metric{name: "a", unit: UnitCount}, Amount{Float: math.NaN(), IsFloat: true}, ActionSum})
+	c.SetTraceLog(false)
+	c.handleMetricWithValue(MetricWithAmount{Metric{name: "a", unit: UnitCount}, Amount{Value: 1}, ActionSum})
+
+	assert.Equal(t, "trace a unit=c action=sum sample=NaN aggregate=[] error=a: Invalid metric value\n", buf.String())
+}
+
+func TestTrace_Client_SetTraceChan_Batched(t *testing.T) {
+	c := newBatchingClient(2)
+
+	events := make(chan TraceEvent, 10)
+	c.SetTraceChan(events)
+
+	c.Count("a", 1)
+	c.PendingLines()
+
+	e := <-events
+	assert.Equal(t, []string{"a:1|c"}, e.Lines)
+	assert.NoError(t, e.Err)
+}
@@ -0,0 +1,225 @@
+package buckyclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// DefaultFallbackDelay is how long the default dialer waits for an IPv6
+// connection before racing an IPv4 one, as described in RFC 6555
+const DefaultFallbackDelay = 300 * time.Millisecond
+
+// newTransport returns the http transport used for flushes, dialling with
+// the configured DialContext or a dual-stack dialer by default
+func (c *Client) newTransport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+
+	dial := c.dialContext
+	if dial == nil {
+		dialer := &net.Dialer{
+			Timeout:       30 * time.Second,
+			KeepAlive:     30 * time.Second,
+			FallbackDelay: DefaultFallbackDelay,
+		}
+
+		dial = dialer.DialContext
+	}
+
+	t.DialContext = dial
+
+	if c.tlsConfig != nil {
+		t.TLSClientConfig = c.tlsConfig.Clone()
+	}
+
+	return t
+}
+
+// WithDialContext sets the function used to open flush connections, for
+// IPv6-only or split-horizon networks that need control over how the bucky
+// server is reached
+func WithDialContext(dial func(ctx context.Context, network, addr string) (net.Conn, error)) Option {
+	return func(c *Client) error {
+		if dial == nil {
+			return invalidOption("WithDialContext", "dial must not be nil")
+		}
+
+		c.dialContext = dial
+		return nil
+	}
+}
+
+// Transport sends a flushed payload to wherever metrics are collected.
+// The client posts to its bucky server over http unless WithTransport
+// gives it another, e.g. to write statsd over UDP with transport/udp or graphite over TCP.
+// Send is called with one payload at a time, and FlushInfoFromContext
+// says which flush it belongs to. A returned error is handled like a
+// failed post, so the payload goes in the retry queue if there is one.
+type Transport interface {
+	Send(ctx context.Context, payload []byte) error
+}
+
+// schemes maps the URL schemes registered with RegisterScheme to the
+// function opening their transport
+var schemes = struct {
+	sync.RWMutex
+	open map[string]func(u *url.URL) (Transport, error)
+}{}
+
+// RegisterScheme lets NewClient take a host URL with another scheme than
+// http or https, sending payloads with the transport open returns for it.
+// Transport packages register their scheme when they are imported, as
+// transport/udp does for udp://. It panics if the scheme is http, https or
+// already registered.
+func RegisterScheme(scheme string, open func(u *url.URL) (Transport, error)) {
+	schemes.Lock()
+	defer schemes.Unlock()
+
+	if scheme == "http" || scheme == "https" || schemes.open[scheme] != nil {
+		panic("buckyclient: scheme " + scheme + " registered twice")
+	}
+
+	if open == nil {
+		panic("buckyclient: nil transport for scheme " + scheme)
+	}
+
+	if schemes.open == nil {
+		schemes.open = make(map[string]func(u *url.URL) (Transport, error))
+	}
+
+	schemes.open[scheme] = open
+}
+
+// schemeOpener returns the function registered to open a transport for
+// the scheme, if there is one
+func schemeOpener(scheme string) func(u *url.URL) (Transport, error) {
+	schemes.RLock()
+	defer schemes.RUnlock()
+
+	return schemes.open[scheme]
+}
+
+// schemeTransport opens the registered transport for host, returning nil
+// if its scheme isn't registered
+func schemeTransport(host string) (Transport, error) {
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, nil
+	}
+
+	open := schemeOpener(u.Scheme)
+	if open == nil {
+		return nil, nil
+	}
+
+	return open(u)
+}
+
+// WithTransport sends every payload with t instead of posting it to the
+// host given to NewClient. Everything that is specific to http, such as
+// headers, gzip and the target selector, only applies to the default
+// transport. If t is an io.Closer it is closed by Close.
+func WithTransport(t Transport) Option {
+	return func(c *Client) error {
+		if t == nil {
+			return invalidOption("WithTransport", "transport must not be nil")
+		}
+
+		c.transport = t
+		return nil
+	}
+}
+
+// flushContextKey finds the flushContext of a Send
+type flushContextKey struct{}
+
+// flushContext is what a transport is told about the flush it is sending,
+// and where the default transport reports the URL it chose
+type flushContext struct {
+	info   FlushInfo
+	target string
+}
+
+// FlushInfoFromContext returns the flush a payload given to Transport.Send
+// belongs to
+func FlushInfoFromContext(ctx context.Context) (FlushInfo, bool) {
+	fc, ok := ctx.Value(flushContextKey{}).(*flushContext)
+	if !ok {
+		return FlushInfo{}, false
+	}
+
+	return fc.info, true
+}
+
+// httpTransport is the default transport, which posts to the bucky server
+type httpTransport struct {
+	c *Client
+}
+
+// Send posts the payload to the target for the flush, or to the first
+// server that takes it with WithFailover
+func (t httpTransport) Send(ctx context.Context, payload []byte) error {
+	c := t.c
+
+	fc, _ := ctx.Value(flushContextKey{}).(*flushContext)
+	if fc == nil {
+		fc = &flushContext{}
+	}
+
+	if c.failover != nil {
+		return c.sendWithFailover(ctx, fc, payload)
+	}
+
+	target := c.target(fc.info, payload)
+	fc.target = target
+
+	return c.sendTo(ctx, fc.info, target, payload)
+}
+
+// sendTo posts the payload to target, gzipped if the server accepts it
+func (c *Client) sendTo(ctx context.Context, info FlushInfo, target string, payload []byte) error {
+	if c.useGzip(info) {
+		err := c.postBody(ctx, info, target, gzipPayload(payload), "gzip")
+		if !errors.Is(err, errUnsupportedEncoding) {
+			return err
+		}
+
+		// The server changed its mind, so send it as it is from now on
+		c.disableGzip(info)
+	}
+
+	return c.postBody(ctx, info, target, bytes.NewBuffer(payload), "")
+}
+
+// flushTransport returns the transport payloads are sent with
+func (c *Client) flushTransport() Transport {
+	var own Transport = httpTransport{c}
+	if c.transport != nil {
+		own = c.transport
+	}
+
+	if len(c.sinks) > 0 {
+		return append(fanOut{own}, c.sinks...)
+	}
+
+	return own
+}
+
+// WithRoundTripper replaces the http transport used for flushes. It can't
+// be combined with WithDialContext or WithTLSConfig, which configure the
+// default transport, or with WithHTTPClient.
+func WithRoundTripper(rt http.RoundTripper) Option {
+	return func(c *Client) error {
+		if rt == nil {
+			return invalidOption("WithRoundTripper", "round tripper must not be nil")
+		}
+
+		c.roundTripper = rt
+		return nil
+	}
+}
@@ -0,0 +1,110 @@
+// Package udp sends bucky payloads to a statsd compatible daemon, such as
+// statsd itself or telegraf, as UDP datagrams. Importing it also lets
+// NewClient take a udp://host:port URL:
+//
+//	import _ "github.com/matzhouse/go-bucky-client/transport/udp"
+//
+//	bc, err := buckyclient.NewClient("udp://localhost:8125", 10)
+package udp
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/url"
+
+	"github.com/matzhouse/go-bucky-client"
+)
+
+// DefaultMaxDatagramSize keeps UDP datagrams inside a 1500 byte Ethernet
+// frame with room for IP options and tunnel headers, the same limit the
+// statsd daemon suggests
+const DefaultMaxDatagramSize = 1432
+
+func init() {
+	buckyclient.RegisterScheme("udp", func(u *url.URL) (buckyclient.Transport, error) {
+		return New(u.Host, 0)
+	})
+}
+
+// Transport writes payloads as UDP datagrams. Payloads are split at line
+// boundaries so no datagram is bigger than the limit, except for a single
+// line that is longer than it, which is sent on its own.
+type Transport struct {
+	conn    net.Conn
+	maxSize int
+}
+
+// New returns a transport writing to addr, a host:port. maxSize is the
+// largest datagram to send, or 0 for DefaultMaxDatagramSize.
+func New(addr string, maxSize int) (*Transport, error) {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxDatagramSize
+	}
+
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Transport{conn: conn, maxSize: maxSize}, nil
+}
+
+// With sends payloads to a statsd daemon at addr over UDP instead of
+// posting them. A host given to NewClient as udp://host:port does the same
+// with the default datagram size.
+func With(addr string, maxSize int) buckyclient.Option {
+	return func(c *buckyclient.Client) error {
+		if maxSize < 0 {
+			return &buckyclient.OptionError{Option: "udp.With", Reason: "datagram size must not be negative"}
+		}
+
+		t, err := New(addr, maxSize)
+		if err != nil {
+			return &buckyclient.OptionError{Option: "udp.With", Reason: err.Error()}
+		}
+
+		return buckyclient.WithTransport(t)(c)
+	}
+}
+
+// Send writes the payload as one or more datagrams. Every datagram is
+// tried, and the first error is returned.
+func (t *Transport) Send(ctx context.Context, payload []byte) error {
+	var first error
+
+	for len(payload) > 0 {
+		var chunk []byte
+		chunk, payload = nextDatagram(payload, t.maxSize)
+
+		if _, err := t.conn.Write(chunk); err != nil && first == nil {
+			first = err
+		}
+	}
+
+	return first
+}
+
+// Close closes the socket
+func (t *Transport) Close() error {
+	return t.conn.Close()
+}
+
+// nextDatagram splits as many whole lines off the front of payload as fit
+// in maxSize, or the first line if even that doesn't fit. The trailing
+// newline of the last line in a datagram is kept, as statsd ignores it.
+func nextDatagram(payload []byte, maxSize int) (chunk, rest []byte) {
+	if len(payload) <= maxSize {
+		return payload, nil
+	}
+
+	if i := bytes.LastIndexByte(payload[:maxSize], '\n'); i >= 0 {
+		return payload[:i+1], payload[i+1:]
+	}
+
+	if i := bytes.IndexByte(payload, '\n'); i >= 0 {
+		return payload[:i+1], payload[i+1:]
+	}
+
+	return payload, nil
+}
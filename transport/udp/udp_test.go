@@ -0,0 +1,112 @@
+package udp
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/matzhouse/go-bucky-client"
+	"github.com/stretchr/testify/assert"
+)
+
+// listenUDP returns a socket standing in for a statsd daemon
+func listenUDP(t *testing.T) net.PacketConn {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	return conn
+}
+
+// readDatagrams reads n datagrams from conn
+func readDatagrams(t *testing.T, conn net.PacketConn, n int) []string {
+	var out []string
+
+	buf := make([]byte, 65536)
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+
+	for i := 0; i < n; i++ {
+		size, _, err := conn.ReadFrom(buf)
+		if !assert.NoError(t, err) {
+			break
+		}
+
+		out = append(out, string(buf[:size]))
+	}
+
+	return out
+}
+
+func TestUDP_Transport_Send(t *testing.T) {
+	conn := listenUDP(t)
+	defer conn.Close()
+
+	tr, err := New(conn.LocalAddr().String(), 20)
+	assert.NoError(t, err)
+	defer tr.Close()
+
+	// The third line doesn't fit with either of its neighbours, and the
+	// fourth doesn't fit at all
+	long := "c.very.long.name:3|c\n"
+	payload := "a:1|c\nb:2|c\n" + long + strings.Repeat("d", 30) + ":4|c\n"
+
+	assert.NoError(t, tr.Send(context.Background(), []byte(payload)))
+	assert.Equal(t, []string{"a:1|c\nb:2|c\n", long, strings.Repeat("d", 30) + ":4|c\n"}, readDatagrams(t, conn, 3))
+}
+
+func TestUDP_nextDatagram(t *testing.T) {
+	tests := []struct {
+		payload     string
+		chunk, rest string
+	}{
+		{"a:1|c\n", "a:1|c\n", ""},
+		{"a:1|c\nb:2|c\n", "a:1|c\n", "b:2|c\n"},
+		{"a:1|c\nb:2|c", "a:1|c\n", "b:2|c"},
+		{"abcdefghij:1|c\nb:2|c\n", "abcdefghij:1|c\n", "b:2|c\n"},
+		{"abcdefghij:1|c", "abcdefghij:1|c", ""},
+	}
+
+	for _, test := range tests {
+		chunk, rest := nextDatagram([]byte(test.payload), 10)
+		assert.Equal(t, test.chunk, string(chunk), test.payload)
+		assert.Equal(t, test.rest, string(rest), test.payload)
+	}
+}
+
+func TestUDP_NewClient_Scheme(t *testing.T) {
+	conn := listenUDP(t)
+	defer conn.Close()
+
+	c, err := buckyclient.NewClient("udp://"+conn.LocalAddr().String(), 0)
+	assert.NoError(t, err)
+	c.SetLogger(log.New(ioutil.Discard, "", 0))
+
+	c.Count("hits", 2, buckyclient.Tag{Key: "env", Value: "prod"})
+
+	assert.NoError(t, c.Close())
+	assert.Equal(t, []string{"hits:2|c|#env:prod\n"}, readDatagrams(t, conn, 1))
+}
+
+func TestUDP_With(t *testing.T) {
+	conn := listenUDP(t)
+	defer conn.Close()
+
+	c, err := buckyclient.NewClient("http://localhost", 0, With(conn.LocalAddr().String(), 10))
+	assert.NoError(t, err)
+	c.SetLogger(log.New(ioutil.Discard, "", 0))
+
+	c.Count("a", 1)
+	c.Count("b", 2)
+
+	// Both lines don't fit in one datagram
+	assert.NoError(t, c.Close())
+	assert.ElementsMatch(t, []string{"a:1|c\n", "b:2|c\n"}, readDatagrams(t, conn, 2))
+
+	assert.ErrorIs(t, With(conn.LocalAddr().String(), -1)(c), buckyclient.ErrInvalidOption)
+	assert.ErrorIs(t, With("no-port", 0)(c), buckyclient.ErrInvalidOption)
+}
@@ -0,0 +1,167 @@
+package buckyclient
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransport_WithDialContext(t *testing.T) {
+	bodies := make(chan string, 1)
+	mockBucky := captureBuckyServer(bodies)
+	defer mockBucky.Close()
+
+	var dials int32
+	dial := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		atomic.AddInt32(&dials, 1)
+
+		// Ignore the address we were given and always go to the mock
+		var d net.Dialer
+		return d.DialContext(ctx, network, mockBucky.Listener.Addr().String())
+	}
+
+	cl, err := NewClient("http://bucky.invalid/send", 60, WithDialContext(dial))
+	assert.NoError(t, err)
+	cl.SetLogger(log.New(ioutil.Discard, "", 0))
+	defer cl.Stop()
+
+	cl.handleMetricWithValue(MetricWithAmount{Metric{name: "myapp.facet", unit: UnitCount}, Amount{Value: 1}, ActionSum})
+
+	assert.NoError(t, cl.flush())
+	assert.Equal(t, "myapp.facet:1|c\n", <-bodies)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&dials))
+}
+
+func TestTransport_WithDialContext_Nil(t *testing.T) {
+	assert.True(t, errors.Is(WithDialContext(nil)(&Client{}), ErrInvalidOption))
+}
+
+func TestTransport_Client_newTransport_Default(t *testing.T) {
+	cl := &Client{}
+
+	tr := cl.newTransport()
+
+	assert.NotNil(t, tr.DialContext)
+	assert.NotEqual(t, http.DefaultTransport, tr)
+}
+
+// recordingTransport keeps every payload it is given
+type recordingTransport struct {
+	payloads []string
+	infos    []FlushInfo
+	err      error
+	closed   bool
+}
+
+func (r *recordingTransport) Send(ctx context.Context, payload []byte) error {
+	info, _ := FlushInfoFromContext(ctx)
+
+	r.payloads = append(r.payloads, string(payload))
+	r.infos = append(r.infos, info)
+
+	return r.err
+}
+
+func (r *recordingTransport) Close() error {
+	r.closed = true
+	return nil
+}
+
+func TestTransport_WithTransport(t *testing.T) {
+	rt := &recordingTransport{}
+	var results []FlushResult
+
+	c := &Client{
+		logger:     log.New(ioutil.Discard, "", 0),
+		metrics:    make(map[Metric]Value),
+		bufferPool: newBufferPool(),
+	}
+
+	assert.NoError(t, WithTransport(rt)(c))
+	assert.NoError(t, WithFlushCallback(func(r FlushResult) { results = append(results, r) })(c))
+
+	c.aggregate(MetricWithAmount{Metric{name: "a", unit: UnitCount}, Amount{Value: 1}, ActionSum})
+	assert.NoError(t, c.flush())
+
+	assert.Equal(t, []string{"a:1|c\n"}, rt.payloads)
+	assert.Equal(t, uint64(1), rt.infos[0].Seq)
+	assert.Len(t, results, 1)
+	assert.Equal(t, "", results[0].Target)
+
+	// Failed sends are retried like failed posts
+	rt.err = errors.New("unreachable")
+	c.spool = newSpool(time.Minute, 1<<20)
+
+	c.aggregate(MetricWithAmount{Metric{name: "b", unit: UnitCount}, Amount{Value: 2}, ActionSum})
+	assert.ErrorIs(t, c.flush(), rt.err)
+
+	rt.err = nil
+	c.aggregate(MetricWithAmount{Metric{name: "c", unit: UnitCount}, Amount{Value: 3}, ActionSum})
+	assert.NoError(t, c.flush())
+
+	assert.Equal(t, []string{"a:1|c\n", "b:2|c\n", "b:2|c\n", "c:3|c\n"}, rt.payloads)
+}
+
+func TestTransport_WithTransport_Nil(t *testing.T) {
+	assert.True(t, errors.Is(WithTransport(nil)(&Client{}), ErrInvalidOption))
+}
+
+func TestTransport_Client_Close_ClosesTransport(t *testing.T) {
+	rt := &recordingTransport{}
+
+	c, err := NewClient("", 0, WithTransport(rt))
+	assert.NoError(t, err)
+	c.SetLogger(log.New(ioutil.Discard, "", 0))
+
+	c.Count("a", 1)
+	assert.NoError(t, c.Close())
+
+	assert.True(t, rt.closed)
+}
+
+func TestTransport_RegisterScheme(t *testing.T) {
+	rt := &recordingTransport{}
+
+	var opened *url.URL
+	RegisterScheme("recording", func(u *url.URL) (Transport, error) {
+		opened = u
+		return rt, nil
+	})
+
+	RegisterScheme("failing", func(u *url.URL) (Transport, error) {
+		return nil, errors.New("can't open")
+	})
+
+	c, err := Builder().Host("recording://collector:9000").Build()
+	assert.NoError(t, err)
+	c.SetLogger(log.New(ioutil.Discard, "", 0))
+
+	assert.Equal(t, "collector:9000", opened.Host)
+
+	c.Count("a", 1)
+	assert.NoError(t, c.Close())
+	assert.Equal(t, []string{"a:1|c\n"}, rt.payloads)
+
+	_, err = NewClient("failing://collector:9000", 0)
+	assert.EqualError(t, err, "can't open")
+
+	_, err = Builder().Host("unknown://collector:9000").Build()
+	assert.ErrorContains(t, err, "not an http, https or registered URL")
+
+	assert.Panics(t, func() { RegisterScheme("recording", func(u *url.URL) (Transport, error) { return rt, nil }) })
+	assert.Panics(t, func() { RegisterScheme("https", func(u *url.URL) (Transport, error) { return rt, nil }) })
+}
+
+func TestTransport_FlushInfoFromContext(t *testing.T) {
+	_, ok := FlushInfoFromContext(context.Background())
+	assert.False(t, ok)
+}
@@ -0,0 +1,45 @@
+package buckyclient
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnique_Client_aggregate(t *testing.T) {
+	c := &Client{metrics: make(map[Metric]Value)}
+
+	for _, member := range []string{"alice", "bob", "alice", "carol", "bob"} {
+		assert.NoError(t, c.aggregate(MetricWithAmount{Metric{name: "users", unit: UnitSet}, Amount{Member: member}, ActionUnique}))
+	}
+
+	buf := &bytes.Buffer{}
+	c.formatMetricsForFlush(buf)
+	assert.Equal(t, "users:3|s\n", buf.String())
+}
+
+func TestUnique_Client_Record(t *testing.T) {
+	// Batching records on the calling goroutine
+	c := newBatchingClient(10)
+
+	assert.NoError(t, c.Record("ids", 7, UnitSet, ActionUnique))
+	c.Unique("ids", "7")
+
+	c.drainBatches()
+
+	buf := &bytes.Buffer{}
+	c.formatMetricsForFlush(buf)
+	assert.Equal(t, "ids:1|s\n", buf.String())
+
+	assert.Equal(t, ErrInvalidAction, c.RecordFloat("ids", 1, UnitSet, ActionUnique))
+}
+
+func TestUnique_Client_WriteOpenMetrics(t *testing.T) {
+	c := &Client{metrics: make(map[Metric]Value)}
+	c.aggregate(MetricWithAmount{Metric{name: "users", unit: UnitSet}, Amount{Member: "alice"}, ActionUnique})
+
+	buf := &bytes.Buffer{}
+	assert.NoError(t, c.WriteOpenMetrics(buf))
+	assert.Equal(t, "# TYPE users gauge\nusers 1\n# EOF\n", buf.String())
+}
@@ -0,0 +1,45 @@
+package buckyclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DefaultVerifyTimeout bounds the test flush made by
+// WithStartupVerification
+const DefaultVerifyTimeout = 5 * time.Second
+
+var (
+	// ErrVerificationFailed is returned by NewClient when the server doesn't
+	// accept the test flush of WithStartupVerification
+	ErrVerificationFailed = errors.New("Startup verification failed")
+)
+
+// WithStartupVerification makes NewClient send an empty payload the way
+// every flush is sent, with the same headers, target and transport, and
+// fail with ErrVerificationFailed if it doesn't get through. A wrong URL or
+// credentials are then caught when the service starts rather than when
+// its dashboards come up empty. Unlike WithWarmup, any error status fails,
+// as does not getting an answer within DefaultVerifyTimeout.
+func WithStartupVerification() Option {
+	return func(c *Client) error {
+		c.verify = true
+		return nil
+	}
+}
+
+// verifyStartup sends the test flush
+func (c *Client) verifyStartup() error {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultVerifyTimeout)
+	defer cancel()
+
+	ctx = context.WithValue(ctx, flushContextKey{}, &flushContext{})
+
+	if err := c.flushTransport().Send(ctx, nil); err != nil {
+		return fmt.Errorf("%w: %w", ErrVerificationFailed, err)
+	}
+
+	return nil
+}
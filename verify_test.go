@@ -0,0 +1,51 @@
+package buckyclient
+
+import (
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerify_NewClient_WithStartupVerification(t *testing.T) {
+	var bodies []string
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		bodies = append(bodies, r.Method+" "+string(body))
+
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer ts.Close()
+
+	_, err := NewClient(ts.URL, 0, WithStartupVerification())
+	assert.ErrorIs(t, err, ErrVerificationFailed)
+
+	var statusErr *StatusError
+	assert.True(t, errors.As(err, &statusErr))
+	assert.Equal(t, http.StatusUnauthorized, statusErr.StatusCode)
+
+	c, err := NewClient(ts.URL, 0, WithStartupVerification(), WithHeaders(http.Header{"Authorization": {"Bearer secret"}}))
+	assert.NoError(t, err)
+	c.SetLogger(log.New(ioutil.Discard, "", 0))
+	c.Stop()
+
+	assert.Equal(t, []string{"POST ", "POST "}, bodies)
+}
+
+func TestVerify_NewClient_WithStartupVerification_Transport(t *testing.T) {
+	rt := &recordingTransport{err: errors.New("unreachable")}
+
+	_, err := NewClient("", 0, WithTransport(rt), WithStartupVerification())
+	assert.ErrorIs(t, err, ErrVerificationFailed)
+	assert.ErrorIs(t, err, rt.err)
+
+	assert.Equal(t, []string{""}, rt.payloads)
+	assert.True(t, rt.closed, "the transport is closed when NewClient fails")
+}
@@ -0,0 +1,67 @@
+package buckyclient
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// DefaultWarmupTimeout bounds the warm-up request made by WithWarmup
+const DefaultWarmupTimeout = 5 * time.Second
+
+// warmUp sends a HEAD request to the bucky server so a connection is ready
+// for the first flush. Any response short of a server error shows the
+// server is reachable, since it is only expected to accept POSTs.
+func (c *Client) warmUp() error {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultWarmupTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "HEAD", c.hostURL, nil)
+	if err != nil {
+		return fmt.Errorf("Warm up request failed: %w", err)
+	}
+
+	c.setHeaders(req.Header)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("Warm up request failed: %w", err)
+	}
+
+	// Drain the body so the connection goes back in the pool
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode > 499 {
+		return fmt.Errorf("Warm up request failed: Non-success HTTP Status Code (%d)", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// warmUpIfAsked warms up for WithWarmup, which is only for the http
+// transport
+func (c *Client) warmUpIfAsked() {
+	if !c.warmup || c.transport != nil {
+		return
+	}
+
+	if err := c.warmUp(); err != nil {
+		c.logAt(severityWarning, 1, err.Error())
+		c.handleError(err)
+	}
+}
+
+// WithWarmup makes NewClient send a HEAD request to the bucky server to
+// check it can be reached and to open a connection ahead of the first
+// flush. A failure is logged and passed to the error handler but doesn't
+// stop the client from being created.
+func WithWarmup() Option {
+	return func(c *Client) error {
+		c.warmup = true
+		return nil
+	}
+}
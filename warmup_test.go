@@ -0,0 +1,54 @@
+package buckyclient
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWarmup_NewClient_WithWarmup(t *testing.T) {
+	methods := make(chan string, 1)
+
+	mockBucky := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		methods <- r.Method
+		w.WriteHeader(http.StatusMethodNotAllowed) // still shows the server is there
+	}))
+	defer mockBucky.Close()
+
+	var got error
+	cl, err := NewClient(mockBucky.URL, 60, WithWarmup(), WithErrorHandler(func(err error) { got = err }))
+	assert.NoError(t, err)
+	cl.SetLogger(log.New(&bytes.Buffer{}, "", 0))
+	defer cl.Stop()
+
+	assert.Equal(t, "HEAD", <-methods)
+	assert.NoError(t, got)
+}
+
+func TestWarmup_NewClient_WithWarmup_Unreachable(t *testing.T) {
+	mockBucky := httptest.NewServer(http.NotFoundHandler())
+	url := mockBucky.URL
+	mockBucky.Close() // nothing is listening any more
+
+	var got error
+	cl, err := NewClient(url, 60, WithWarmup(), WithErrorHandler(func(err error) { got = err }))
+	assert.NoError(t, err)
+	cl.SetLogger(log.New(&bytes.Buffer{}, "", 0))
+	defer cl.Stop()
+
+	assert.Error(t, got)
+	assert.Contains(t, got.Error(), "Warm up request failed")
+}
+
+func TestWarmup_Client_warmUp_ServerError(t *testing.T) {
+	mockBucky := mockBuckyServer(http.StatusBadGateway)
+	defer mockBucky.Close()
+
+	cl := &Client{hostURL: mockBucky.URL, http: &http.Client{}}
+
+	assert.Error(t, cl.warmUp())
+}
@@ -0,0 +1,66 @@
+package buckyclient
+
+import (
+	"sort"
+	"time"
+)
+
+// window is a group of units that are flushed together on one interval.
+// The default window has a nil units set and takes every unit that hasn't
+// been given its own interval with WithUnitInterval.
+type window struct {
+	interval time.Duration
+	units    map[Unit]bool
+	next     time.Time
+	start    time.Time // When the current window started, protected by c.m
+	failures int       // Failed flushes in a row, for WithAdaptiveInterval
+}
+
+// owns reports whether metrics with the unit are flushed by this window
+func (w *window) owns(c *Client, unit Unit) bool {
+	if w.units != nil {
+		return w.units[unit]
+	}
+
+	_, claimed := c.unitIntervals[unit]
+	return !claimed
+}
+
+// windows returns the default window followed by one window for every
+// distinct per-unit interval, all due an interval after now
+func (c *Client) windows(now time.Time) []*window {
+	windows := []*window{{interval: c.interval, next: now.Add(c.interval)}}
+
+	byInterval := make(map[time.Duration]*window)
+	for unit, interval := range c.unitIntervals {
+		w, ok := byInterval[interval]
+		if !ok {
+			w = &window{interval: interval, units: make(map[Unit]bool), next: now.Add(interval), start: c.now()}
+			byInterval[interval] = w
+			windows = append(windows, w)
+		}
+
+		w.units[unit] = true
+	}
+
+	// Keep the order stable so flushes that are due together always
+	// happen in the same order
+	sort.SliceStable(windows[1:], func(i, j int) bool {
+		return windows[i+1].interval < windows[j+1].interval
+	})
+
+	return windows
+}
+
+// nextDue returns the time the earliest window is due
+func nextDue(windows []*window) time.Time {
+	next := windows[0].next
+
+	for _, w := range windows[1:] {
+		if w.next.Before(next) {
+			next = w.next
+		}
+	}
+
+	return next
+}
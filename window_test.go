@@ -0,0 +1,115 @@
+package buckyclient
+
+import (
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWindow_Client_windows(t *testing.T) {
+	now := time.Now()
+
+	cl := &Client{
+		interval: time.Minute,
+		unitIntervals: map[Unit]time.Duration{
+			UnitMillisecond: 10 * time.Second,
+		},
+	}
+
+	windows := cl.windows(now)
+
+	assert.Len(t, windows, 2)
+	assert.Equal(t, now.Add(time.Minute), windows[0].next)
+	assert.Equal(t, now.Add(10*time.Second), windows[1].next)
+	assert.Equal(t, now.Add(10*time.Second), nextDue(windows))
+
+	assert.True(t, windows[0].owns(cl, UnitCount))
+	assert.False(t, windows[0].owns(cl, UnitMillisecond))
+	assert.True(t, windows[1].owns(cl, UnitMillisecond))
+	assert.False(t, windows[1].owns(cl, UnitCount))
+}
+
+func TestWindow_Client_windows_Default(t *testing.T) {
+	cl := &Client{interval: time.Minute}
+
+	windows := cl.windows(time.Now())
+
+	assert.Len(t, windows, 1)
+	assert.True(t, windows[0].owns(cl, UnitCount))
+	assert.True(t, windows[0].owns(cl, UnitMillisecond))
+}
+
+func TestWindow_Client_flushWindow(t *testing.T) {
+	bodies := make(chan string, 2)
+	mockBucky := captureBuckyServer(bodies)
+	defer mockBucky.Close()
+
+	cl := &Client{
+		hostURL:    mockBucky.URL,
+		http:       &http.Client{},
+		logger:     log.New(ioutil.Discard, "", 0),
+		interval:   time.Minute,
+		metrics:    make(map[Metric]Value),
+		bufferPool: newBufferPool(),
+	}
+	assert.NoError(t, WithUnitInterval(UnitMillisecond, time.Second)(cl))
+
+	cl.handleMetricWithValue(MetricWithAmount{Metric{name: "myapp.count", unit: UnitCount}, Amount{Value: 1}, ActionSum})
+	cl.handleMetricWithValue(MetricWithAmount{Metric{name: "myapp.timer", unit: UnitMillisecond}, Amount{Value: 5}, ActionAvg})
+
+	windows := cl.windows(time.Now())
+
+	assert.NoError(t, cl.flushWindow(windows[1]))
+	assert.Equal(t, "myapp.timer:5|ms\n", <-bodies)
+
+	// The timer window is now empty, which isn't an error
+	assert.NoError(t, cl.flushWindow(windows[1]))
+
+	assert.NoError(t, cl.flushWindow(windows[0]))
+	assert.Equal(t, "myapp.count:1|c\n", <-bodies)
+}
+
+func TestWindow_Client_sender_UnitInterval(t *testing.T) {
+	bodies := make(chan string, 10)
+	mockBucky := captureBuckyServer(bodies)
+	defer mockBucky.Close()
+
+	cl := &Client{
+		hostURL:    mockBucky.URL,
+		http:       &http.Client{},
+		logger:     log.New(ioutil.Discard, "", 0),
+		interval:   time.Hour,
+		input:      make(chan MetricWithAmount, 10),
+		stop:       make(chan bool, 1),
+		stopped:    make(chan bool, 1),
+		metrics:    make(map[Metric]Value),
+		bufferPool: newBufferPool(),
+	}
+	assert.NoError(t, WithUnitInterval(UnitMillisecond, 20*time.Millisecond)(cl))
+
+	cl.handleMetricWithValue(MetricWithAmount{Metric{name: "myapp.count", unit: UnitCount}, Amount{Value: 1}, ActionSum})
+	cl.handleMetricWithValue(MetricWithAmount{Metric{name: "myapp.timer", unit: UnitMillisecond}, Amount{Value: 5}, ActionAvg})
+
+	cl.sender()
+
+	select {
+	case body := <-bodies:
+		assert.Equal(t, "myapp.timer:5|ms\n", body)
+	case <-time.After(time.Second):
+		t.Fatal("timer window was never flushed")
+	}
+
+	cl.Stop()
+
+	assert.Equal(t, "myapp.count:1|c\n", <-bodies)
+}
+
+func TestWindow_WithUnitInterval_Invalid(t *testing.T) {
+	assert.True(t, errors.Is(WithUnitInterval(Unit("h"), time.Second)(&Client{}), ErrInvalidOption))
+	assert.True(t, errors.Is(WithUnitInterval(UnitCount, 0)(&Client{}), ErrInvalidOption))
+}